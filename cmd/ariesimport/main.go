@@ -0,0 +1,85 @@
+// Command ariesimport replaces the vehicle applications of an ARIES
+// collection with a CSV of make, model, style, old part number and year
+// rows.
+//
+//	ariesimport -collection exterior -file exterior.csv -dry-run
+//
+// The rows are validated and staged before anything is replaced, and the
+// differences from the live collection are reported. Nothing is replaced
+// when a row is invalid.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/curt-labs/API/models/products"
+)
+
+var (
+	collection = flag.String("collection", "", "ARIES collection to replace")
+	file       = flag.String("file", "", "CSV of applications, stdin when empty")
+	dryRun     = flag.Bool("dry-run", false, "validate and diff without replacing the collection")
+	asJSON     = flag.Bool("json", false, "print the full report as JSON")
+)
+
+func main() {
+	flag.Parse()
+	if *collection == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	in := os.Stdin
+	if *file != "" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		in = f
+	}
+
+	report, err := products.Import(in, *collection, products.ImportOptions{DryRun: *dryRun})
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(report)
+	} else {
+		printReport(report)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printReport(r products.ImportReport) {
+	fmt.Printf("%s: %d rows, %d applications\n", r.Collection, r.Rows, r.Applications)
+	for _, e := range r.Errors {
+		fmt.Println("  " + e.Error())
+	}
+	if len(r.Errors) > 0 {
+		return
+	}
+
+	d := r.Diff
+	fmt.Printf("%d added, %d removed, %d changed, %d unchanged\n", len(d.Added), len(d.Removed), len(d.Changed), d.Unchanged)
+	for _, a := range d.Added {
+		fmt.Printf("  + %s %s %s %s %v\n", a.Year, a.Make, a.Model, a.Style, a.Parts)
+	}
+	for _, a := range d.Removed {
+		fmt.Printf("  - %s %s %s %s %v\n", a.Year, a.Make, a.Model, a.Style, a.Parts)
+	}
+	for _, c := range d.Changed {
+		fmt.Printf("  ~ %s %s %s %s +%v -%v\n", c.Year, c.Make, c.Model, c.Style, c.AddedParts, c.RemovedParts)
+	}
+
+	if r.Applied {
+		fmt.Println("replaced", r.Collection)
+	} else if *dryRun {
+		fmt.Println("dry run, nothing replaced")
+	}
+}
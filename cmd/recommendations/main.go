@@ -0,0 +1,23 @@
+// Command recommendations rebuilds the co-fitment recommendation index from
+// the products collection and overwrites the cached recommendations.
+//
+//	recommendations
+//
+// It reads every active part with its vehicles, so run it from cron after
+// fitment changes rather than from the API.
+package main
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/curt-labs/API/models/products"
+)
+
+func main() {
+	n, err := products.RefreshRecommendations()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("recommendations rebuilt for %d parts\n", n)
+}
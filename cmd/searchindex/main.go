@@ -0,0 +1,99 @@
+// Command searchindex writes the parts of the catalog to the search indexes.
+//
+//	searchindex -full -brand 1
+//	searchindex -since 2026-10-01T00:00:00Z
+//	searchindex -watch
+//
+// Without flags, the parts modified since the last sync of each brand are
+// indexed, and brands that have never been synced are reindexed. With
+// -watch, it keeps syncing every 15 minutes and rebuilds the indexes every
+// day. Run one watcher per cluster; the API doesn't index.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/curt-labs/API/models/search"
+)
+
+var (
+	full  = flag.Bool("full", false, "rebuild the indexes and swap them in")
+	brand = flag.Int("brand", 0, "brand to index, every brand with an index when zero")
+	since = flag.String("since", "", "index the parts of -brand modified after this RFC 3339 time")
+	watch = flag.Bool("watch", false, "keep syncing every 15 minutes and rebuild every day")
+)
+
+func main() {
+	flag.Parse()
+
+	if err := search.LoadBrandIndexes(); err != nil {
+		log.Fatal(err)
+	}
+
+	var idx search.Indexer
+	if *watch {
+		syncForever(&idx)
+	}
+
+	var reports []search.IndexReport
+	var err error
+
+	switch {
+	case *since != "":
+		t, perr := time.Parse(time.RFC3339, *since)
+		if perr != nil || *brand == 0 {
+			flag.Usage()
+			os.Exit(2)
+		}
+		var r search.IndexReport
+		r, err = idx.Update(*brand, t)
+		reports = append(reports, r)
+	case *full && *brand != 0:
+		reports, err = idx.Reindex(*brand)
+	case *full:
+		reports, err = idx.Reindex()
+	default:
+		reports, err = idx.Sync()
+	}
+
+	printReports(reports)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// syncForever indexes the parts modified since the last sync every 15
+// minutes and rebuilds the search indexes every day, so deleted parts drop
+// out of them.
+func syncForever(idx *search.Indexer) {
+	rebuilt := time.Now()
+	for {
+		var reports []search.IndexReport
+		var err error
+		if time.Since(rebuilt) > 24*time.Hour {
+			reports, err = idx.Reindex()
+			rebuilt = time.Now()
+		} else {
+			reports, err = idx.Sync()
+		}
+		printReports(reports)
+		if err != nil {
+			log.Printf("failed to index search: %v", err)
+		}
+		time.Sleep(15 * time.Minute)
+	}
+}
+
+func printReports(reports []search.IndexReport) {
+	for _, r := range reports {
+		kind := "updated"
+		if r.Full {
+			kind = "reindexed"
+		}
+		fmt.Printf("%s %s (brand %d): %d indexed, %d deleted\n", kind, r.Index, r.Brand, r.Indexed, r.Deleted)
+	}
+}
@@ -2,6 +2,7 @@ package acesFile
 
 import (
 	"bytes"
+	"log"
 	"time"
 
 	"github.com/curt-labs/API/helpers/apicontext"
@@ -38,3 +39,45 @@ func GetAcesFile(rw http.ResponseWriter, req *http.Request, enc encoding.Encoder
 
 	return ""
 }
+
+// GenerateAcesFile streams an ACES file built from the brand's fitment data.
+// A `since` date (2006-01-02) limits it to the parts modified since then.
+func GenerateAcesFile(rw http.ResponseWriter, req *http.Request, params martini.Params, dtx *apicontext.DataContext) string {
+	version := params["version"]
+	if !acesFile.ValidVersion(version) {
+		apierror.GenerateError("Unsupported ACES version", acesFile.ErrVersion, rw, req, http.StatusBadRequest)
+		return ""
+	}
+
+	var since time.Time
+	if s := req.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse("2006-01-02", s); err != nil {
+			apierror.GenerateError("Invalid since date", err, rw, req, http.StatusBadRequest)
+			return ""
+		}
+	}
+
+	var brandObj brand.Brand
+	brandObj.ID = dtx.BrandID
+	if err := brandObj.Get(); err != nil {
+		apierror.GenerateError("Invalid brand ID", err, rw, req, http.StatusBadRequest)
+		return ""
+	}
+
+	file := &encoding.Attachment{
+		ResponseWriter: rw,
+		ContentType:    "application/xml",
+		Filename:       brandObj.Code + "_ACES" + version + ".xml",
+	}
+	if _, err := acesFile.Generate(file, brandObj, version, since); err != nil {
+		if !file.Started() {
+			apierror.GenerateError("Trouble generating ACES file", err, rw, req)
+			return ""
+		}
+		// the file is already streaming, so an error can only end it early
+		log.Printf("[API::ACES::GENERATE]:%v", err)
+	}
+
+	return ""
+}
@@ -0,0 +1,169 @@
+package asset_ctlr
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/assets"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/category"
+	"github.com/curt-labs/API/models/products"
+	"github.com/go-martini/martini"
+)
+
+// Serve proxies the asset through the asset cache and turns the proxy
+// errors into the matching status codes.
+func Serve(w http.ResponseWriter, r *http.Request, u *url.URL, kind assets.Kind) {
+	if u == nil || u.String() == "" {
+		apierror.GenerateError("Asset not found", errors.New("no asset path"), w, r, http.StatusNotFound)
+		return
+	}
+
+	err := assets.Default.Serve(w, r, u.String(), kind)
+	switch err {
+	case nil:
+	case assets.ErrHostNotAllowed:
+		apierror.GenerateError("Asset host is not allowed", err, w, r, http.StatusForbidden)
+	case assets.ErrTooLarge, assets.ErrContentType, assets.ErrUpstream:
+		apierror.GenerateError("Trouble getting asset", err, w, r, http.StatusBadGateway)
+	default:
+		apierror.GenerateError("Trouble getting asset", err, w, r)
+	}
+}
+
+// PartImage serves one of the part's images, by image ID or by its
+// position in the part's image list.
+func PartImage(w http.ResponseWriter, r *http.Request, params martini.Params, dtx *apicontext.DataContext) {
+	path, ok := partImage(w, r, params, dtx)
+	if !ok {
+		return
+	}
+
+	Serve(w, r, path, assets.Image)
+}
+
+// PartImageDerivative serves one of the part's images resized to a preset.
+func PartImageDerivative(w http.ResponseWriter, r *http.Request, params martini.Params, dtx *apicontext.DataContext) {
+	path, ok := partImage(w, r, params, dtx)
+	if !ok {
+		return
+	}
+
+	ServeDerivative(w, r, path, params["preset"])
+}
+
+// CategoryImage serves the category's image.
+func CategoryImage(w http.ResponseWriter, r *http.Request, params martini.Params, dtx *apicontext.DataContext) {
+	c, err := getCategory(params, dtx)
+	if err != nil {
+		apierror.GenerateError("Trouble getting category", err, w, r)
+		return
+	}
+
+	Serve(w, r, c.Image, assets.Image)
+}
+
+// CategoryImageDerivative serves the category's image resized to a preset.
+func CategoryImageDerivative(w http.ResponseWriter, r *http.Request, params martini.Params, dtx *apicontext.DataContext) {
+	c, err := getCategory(params, dtx)
+	if err != nil {
+		apierror.GenerateError("Trouble getting category", err, w, r)
+		return
+	}
+
+	ServeDerivative(w, r, c.Image, params["preset"])
+}
+
+// ServeDerivative serves the preset derivative of the image, in the format
+// from the format query parameter or the Accept header.
+func ServeDerivative(w http.ResponseWriter, r *http.Request, u *url.URL, preset string) {
+	if u == nil || u.String() == "" {
+		apierror.GenerateError("Image not found", errors.New("no image path"), w, r, http.StatusNotFound)
+		return
+	}
+
+	format, err := assets.Format(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		apierror.GenerateError("Unsupported image format", err, w, r, http.StatusBadRequest)
+		return
+	}
+
+	err = assets.Default.ServeDerivative(w, r, u.String(), preset, format)
+	switch err {
+	case nil:
+	case assets.ErrPreset:
+		apierror.GenerateError("Unknown image preset", err, w, r, http.StatusNotFound)
+	case assets.ErrHostNotAllowed:
+		apierror.GenerateError("Asset host is not allowed", err, w, r, http.StatusForbidden)
+	case assets.ErrTooLarge, assets.ErrContentType, assets.ErrUpstream:
+		apierror.GenerateError("Trouble getting image", err, w, r, http.StatusBadGateway)
+	default:
+		apierror.GenerateError("Trouble getting image", err, w, r)
+	}
+}
+
+func partImage(w http.ResponseWriter, r *http.Request, params martini.Params, dtx *apicontext.DataContext) (*url.URL, bool) {
+	id, err := strconv.Atoi(params["part"])
+	if err != nil {
+		apierror.GenerateError("Trouble getting part ID", err, w, r, http.StatusBadRequest)
+		return nil, false
+	}
+	image, err := strconv.Atoi(params["image"])
+	if err != nil {
+		apierror.GenerateError("Trouble getting image ID", err, w, r, http.StatusBadRequest)
+		return nil, false
+	}
+
+	p := products.Part{
+		ID: id,
+	}
+	if err = p.Get(dtx); err != nil {
+		apierror.GenerateError("Trouble getting part", err, w, r)
+		return nil, false
+	}
+
+	for _, img := range p.Images {
+		if img.ID == image {
+			return img.Path, true
+		}
+	}
+	if image >= 0 && image < len(p.Images) {
+		return p.Images[image].Path, true
+	}
+	return nil, true
+}
+
+// CategoryPDF serves the category's PDF.
+func CategoryPDF(w http.ResponseWriter, r *http.Request, params martini.Params, dtx *apicontext.DataContext) {
+	c, err := getCategory(params, dtx)
+	if err != nil {
+		apierror.GenerateError("Trouble getting category", err, w, r)
+		return
+	}
+
+	Serve(w, r, c.PDFpath, assets.PDF)
+}
+
+// CategoryXLS serves the category's spreadsheet.
+func CategoryXLS(w http.ResponseWriter, r *http.Request, params martini.Params, dtx *apicontext.DataContext) {
+	c, err := getCategory(params, dtx)
+	if err != nil {
+		apierror.GenerateError("Trouble getting category", err, w, r)
+		return
+	}
+
+	Serve(w, r, c.XLSpath, assets.Spreadsheet)
+}
+
+func getCategory(params martini.Params, dtx *apicontext.DataContext) (category.Category, error) {
+	var c category.Category
+	var err error
+	if c.CategoryID, err = strconv.Atoi(params["id"]); err != nil {
+		return c, err
+	}
+	err = c.Get(1, 1, dtx)
+	return c, err
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/curt-labs/API/helpers/encoding"
 	"github.com/curt-labs/API/helpers/error"
 	"github.com/curt-labs/API/models/category"
+	"github.com/curt-labs/API/models/products"
 	"github.com/go-martini/martini"
 
 	"net/http"
@@ -31,7 +32,7 @@ func GetCategory(rw http.ResponseWriter, r *http.Request, params martini.Params,
 		count, _ = strconv.Atoi(ct)
 	}
 
-	err = c.Get(page, count)
+	err = c.Get(page, count, dtx)
 	if err != nil || c.CategoryID == 0 {
 		apierror.GenerateError("Trouble getting category", err, rw, r)
 		return ""
@@ -68,7 +69,13 @@ func GetCategoryParts(rw http.ResponseWriter, r *http.Request, params martini.Pa
 		count, _ = strconv.Atoi(ct)
 	}
 
-	parts, err := category.GetCategoryParts(catId, page, count)
+	rules, err := products.ParseRules(r.FormValue(products.RulesParam))
+	if err != nil {
+		apierror.GenerateError("Trouble reading filter rules", err, rw, r, http.StatusBadRequest)
+		return ""
+	}
+
+	parts, err := category.GetCategoryParts(catId, page, count, dtx, rules)
 	if err != nil {
 		apierror.GenerateError("Trouble getting parts", err, rw, r)
 		return ""
@@ -0,0 +1,36 @@
+package category_ctlr
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"testing"
+
+	"github.com/curt-labs/API/helpers/testThatHttp"
+	"github.com/curt-labs/API/helpers/visibilitymock"
+	"github.com/curt-labs/API/models/category"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCategoryPartVisibility(t *testing.T) {
+	if err := visibilitymock.Mock(); err != nil {
+		t.Skip("no database: ", err)
+	}
+	defer visibilitymock.DeMock()
+
+	id := strconv.Itoa(visibilitymock.CategoryID)
+	for _, key := range visibilitymock.Keys {
+		Convey("Testing GetCategoryParts() with a "+key.DataContext.KeyType+" key", t, func() {
+			testThatHttp.RequestWithDtx("get", "/category/", ":id/parts", id+"/parts?key="+key.DataContext.APIKey, GetCategoryParts, nil, "", key.DataContext)
+			So(testThatHttp.Response.Code, ShouldEqual, 200)
+			var res category.PartResponse
+			So(json.Unmarshal(testThatHttp.Response.Body.Bytes(), &res), ShouldBeNil)
+			var ids []int
+			for _, p := range res.Parts {
+				ids = append(ids, p.ID)
+			}
+			sort.Ints(ids)
+			So(ids, ShouldResemble, key.Visible)
+		})
+	}
+}
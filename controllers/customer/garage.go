@@ -0,0 +1,136 @@
+package customer_ctlr
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/encoding"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/customer"
+	"github.com/go-martini/martini"
+	"gopkg.in/mgo.v2/bson"
+)
+
+var errNoGarageUser = errors.New("the garage needs a customer user's API key")
+
+// GetGarage returns the vehicles the user of the API key saved.
+func GetGarage(rw http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	cu, ok := garageUser(rw, r, dtx)
+	if !ok {
+		return ""
+	}
+
+	if err := cu.GetGarage(); err != nil {
+		apierror.GenerateError("Trouble getting garage", err, rw, r)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(cu.Garage))
+}
+
+// GetGarageVehicle returns one saved vehicle.
+func GetGarageVehicle(rw http.ResponseWriter, r *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	cu, ok := garageUser(rw, r, dtx)
+	if !ok {
+		return ""
+	}
+
+	g, err := cu.GetGarageVehicle(params["id"])
+	if err != nil {
+		apierror.GenerateError("Trouble getting garage vehicle", err, rw, r, garageErrorCode(err))
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(g))
+}
+
+// AddGarageVehicle saves the JSON vehicle in the body to the garage.
+func AddGarageVehicle(rw http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	cu, ok := garageUser(rw, r, dtx)
+	if !ok {
+		return ""
+	}
+
+	var g customer.GarageVehicle
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		apierror.GenerateError("Trouble reading garage vehicle", err, rw, r, http.StatusBadRequest)
+		return ""
+	}
+	if err := g.Validate(); err != nil {
+		apierror.GenerateError("Trouble validating garage vehicle", err, rw, r, http.StatusBadRequest)
+		return ""
+	}
+
+	if err := cu.AddGarageVehicle(&g); err != nil {
+		apierror.GenerateError("Trouble saving garage vehicle", err, rw, r, garageErrorCode(err))
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(g))
+}
+
+// UpdateGarageVehicle replaces a saved vehicle with the JSON vehicle in the
+// body.
+func UpdateGarageVehicle(rw http.ResponseWriter, r *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	cu, ok := garageUser(rw, r, dtx)
+	if !ok {
+		return ""
+	}
+
+	var g customer.GarageVehicle
+	if err := json.NewDecoder(r.Body).Decode(&g); err != nil {
+		apierror.GenerateError("Trouble reading garage vehicle", err, rw, r, http.StatusBadRequest)
+		return ""
+	}
+	if err := g.Validate(); err != nil {
+		apierror.GenerateError("Trouble validating garage vehicle", err, rw, r, http.StatusBadRequest)
+		return ""
+	}
+	if !bson.IsObjectIdHex(params["id"]) {
+		apierror.GenerateError("Trouble getting garage vehicle", customer.ErrGarageVehicleNotFound, rw, r, http.StatusNotFound)
+		return ""
+	}
+	g.ID = bson.ObjectIdHex(params["id"])
+
+	if err := cu.UpdateGarageVehicle(&g); err != nil {
+		apierror.GenerateError("Trouble updating garage vehicle", err, rw, r, garageErrorCode(err))
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(g))
+}
+
+// DeleteGarageVehicle removes a saved vehicle.
+func DeleteGarageVehicle(rw http.ResponseWriter, r *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	cu, ok := garageUser(rw, r, dtx)
+	if !ok {
+		return ""
+	}
+
+	if err := cu.DeleteGarageVehicle(params["id"]); err != nil {
+		apierror.GenerateError("Trouble deleting garage vehicle", err, rw, r, garageErrorCode(err))
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(params["id"]))
+}
+
+func garageUser(rw http.ResponseWriter, r *http.Request, dtx *apicontext.DataContext) (customer.CustomerUser, bool) {
+	if dtx.UserID == "" {
+		apierror.GenerateError("Trouble getting customer user", errNoGarageUser, rw, r, http.StatusUnauthorized)
+		return customer.CustomerUser{}, false
+	}
+	return customer.CustomerUser{Id: dtx.UserID}, true
+}
+
+func garageErrorCode(err error) int {
+	switch err {
+	case customer.ErrGarageVehicleNotFound:
+		return http.StatusNotFound
+	case customer.ErrGarageFull:
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
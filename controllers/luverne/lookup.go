@@ -47,8 +47,9 @@ func QueryCategoryStyle(w http.ResponseWriter, r *http.Request, params martini.P
 	}
 
 	ctx := &products.LuverneLookupContext{
-		Statuses: statuses,
-		Session:  session,
+		Statuses:     statuses,
+		Session:      session,
+		Visibilities: products.Visibilities(dtx),
 	}
 
 	cats, err := products.LuverneQuery(
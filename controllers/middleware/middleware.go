@@ -171,6 +171,15 @@ func processDataContext(r *http.Request, c martini.Context) (*apicontext.DataCon
 	}
 	// go user.LogApiRequest(r)
 
+	//the key type decides which parts the caller can see
+	var keyType string
+	for _, k := range user.Keys {
+		if k.Key == apiKey {
+			keyType = k.Type
+			break
+		}
+	}
+
 	//handles branding
 	var brandID int
 	if brand == "" {
@@ -200,6 +209,7 @@ func processDataContext(r *http.Request, c martini.Context) (*apicontext.DataCon
 	// var dtx apicontext.DataContext
 	dtx := &apicontext.DataContext{
 		APIKey:     apiKey,
+		KeyType:    keyType,
 		BrandID:    brandID,
 		WebsiteID:  websiteID,
 		UserID:     user.Id, //current authenticated user
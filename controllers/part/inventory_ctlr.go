@@ -0,0 +1,89 @@
+package part_ctlr
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/encoding"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/products"
+	"github.com/go-martini/martini"
+)
+
+// Inventory returns the per-warehouse quantities for a part. When a ship-to
+// latitude and longitude are supplied the warehouses are ranked nearest
+// first, and the nearest warehouse able to fill ?quantity= is flagged.
+func Inventory(w http.ResponseWriter, r *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	id, err := strconv.Atoi(params["part"])
+	if err != nil {
+		apierror.GenerateError("Trouble getting part ID", err, w, r)
+		return ""
+	}
+
+	qs := r.URL.Query()
+
+	var shipTo *products.ShipTo
+	if qs.Get("latitude") != "" || qs.Get("longitude") != "" {
+		lat, latErr := strconv.ParseFloat(qs.Get("latitude"), 64)
+		lng, lngErr := strconv.ParseFloat(qs.Get("longitude"), 64)
+		if latErr != nil || lngErr != nil {
+			err = errors.New("latitude and longitude must both be valid decimal degrees")
+			apierror.GenerateError("Trouble getting ship-to location", err, w, r, http.StatusBadRequest)
+			return ""
+		}
+		shipTo = &products.ShipTo{
+			Latitude:  lat,
+			Longitude: lng,
+		}
+	}
+
+	quantity := 1
+	if qs.Get("quantity") != "" {
+		if quantity, err = strconv.Atoi(qs.Get("quantity")); err != nil {
+			apierror.GenerateError("Trouble getting quantity", err, w, r, http.StatusBadRequest)
+			return ""
+		}
+	}
+
+	p := products.Part{
+		ID: id,
+	}
+
+	if err = p.Get(dtx); err != nil {
+		apierror.GenerateError("Trouble getting part", err, w, r)
+		return ""
+	}
+
+	if err = p.GetInventory(shipTo, quantity); err != nil {
+		apierror.GenerateError("Trouble getting part inventory", err, w, r)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(p.Inventory))
+}
+
+// ImportInventory accepts a CSV inventory feed uploaded as the "file" form
+// field. See products.ParseInventoryFeed for the expected columns.
+func ImportInventory(w http.ResponseWriter, r *http.Request, enc encoding.Encoder) string {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		apierror.GenerateError("Error getting file from form", err, w, r, http.StatusBadRequest)
+		return ""
+	}
+	defer file.Close()
+
+	records, err := products.ParseInventoryFeed(file)
+	if err != nil {
+		apierror.GenerateError("Trouble reading inventory feed", err, w, r, http.StatusBadRequest)
+		return ""
+	}
+
+	if err = products.SaveInventoryFeed(records); err != nil {
+		apierror.GenerateError("Trouble saving inventory feed", err, w, r)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(map[string]int{"records": len(records)}))
+}
@@ -1,18 +1,21 @@
 package part_ctlr
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/curt-labs/API/controllers/assets"
 	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/assets"
 	"github.com/curt-labs/API/helpers/encoding"
 	"github.com/curt-labs/API/helpers/error"
-	"github.com/curt-labs/API/helpers/rest"
 	"github.com/curt-labs/API/models/customer"
 	"github.com/curt-labs/API/models/products"
 	"github.com/curt-labs/API/models/vehicle"
@@ -103,7 +106,13 @@ func All(w http.ResponseWriter, r *http.Request, params martini.Params, enc enco
 		toTime = to
 	}
 
-	parts, total, err := products.All(page, count, dtx, fromTime, toTime)
+	rules, err := products.ParseRules(r.FormValue(products.RulesParam))
+	if err != nil {
+		apierror.GenerateError("Trouble reading filter rules", err, w, r, http.StatusBadRequest)
+		return ""
+	}
+
+	parts, total, err := products.All(page, count, dtx, fromTime, toTime, rules)
 	if err != nil {
 		apierror.GenerateError("Trouble getting all parts", err, w, r)
 		return ""
@@ -297,13 +306,42 @@ func Vehicles(w http.ResponseWriter, r *http.Request, params martini.Params, enc
 		return ""
 	}
 
-	vehicles, err := vehicle.ReverseLookup(id)
+	format := r.URL.Query().Get("format")
+	if format != "summary" && format != "csv" {
+		vehicles, err := vehicle.ReverseLookup(id)
+		if err != nil {
+			apierror.GenerateError("Trouble getting part vehicles", err, w, r)
+			return ""
+		}
+
+		return encoding.Must(enc.Encode(vehicles))
+	}
+
+	var summaries []vehicle.Summary
+	if strings.EqualFold(r.URL.Query().Get("source"), "aries") {
+		summaries, err = vehicle.ReverseMongoLookupSummary(id)
+	} else {
+		summaries, err = vehicle.ReverseLookupSummary(id)
+	}
 	if err != nil {
 		apierror.GenerateError("Trouble getting part vehicles", err, w, r)
 		return ""
 	}
 
-	return encoding.Must(enc.Encode(vehicles))
+	if format == "summary" {
+		return encoding.Must(enc.Encode(summaries))
+	}
+
+	b := &bytes.Buffer{}
+	if err = vehicle.WriteSummaryCSV(b, summaries); err != nil {
+		apierror.GenerateError("Trouble writing part vehicles", err, w, r)
+		return ""
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment;filename=%d-vehicles.csv", id))
+	w.Write(b.Bytes())
+	return ""
 }
 
 //Redundant
@@ -447,22 +485,19 @@ func InstallSheet(w http.ResponseWriter, r *http.Request, params martini.Params,
 		}
 	}
 	if text == "" {
-		apierror.GenerateError("No Installation Sheet", err, w, r)
+		apierror.GenerateError("No Installation Sheet", err, w, r, http.StatusNotFound)
 		return
 	}
 
-	data, err := rest.GetPDF(text, r)
+	sheet, err := url.Parse(text)
 	if err != nil {
-		apierror.GenerateError("Error getting PDF", err, w, r)
+		apierror.GenerateError("Trouble getting installation sheet", err, w, r)
 		return
 	}
 
-	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
-	w.Header().Set("Content-Type", "application/pdf")
 	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 	w.Header().Set("Access-Control-Allow-Headers", "Origin")
-	w.Write(data)
+	asset_ctlr.Serve(w, r, sheet, assets.PDF)
 }
 
 //Redundant
@@ -542,3 +577,58 @@ func PartNumber(rw http.ResponseWriter, r *http.Request, params martini.Params,
 
 	return encoding.Must(enc.Encode(p))
 }
+
+// Recommendations returns parts from other categories that are commonly
+// fit to the same vehicles as this part. Takes an optional count.
+func Recommendations(w http.ResponseWriter, r *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	id, err := strconv.Atoi(params["part"])
+	if err != nil {
+		apierror.GenerateError("Trouble getting part ID", err, w, r)
+		return ""
+	}
+
+	count := 10
+	if qs := r.URL.Query().Get("count"); qs != "" {
+		if count, err = strconv.Atoi(qs); err != nil {
+			apierror.GenerateError("Trouble getting count", err, w, r, http.StatusBadRequest)
+			return ""
+		}
+	}
+
+	p := products.Part{
+		ID: id,
+	}
+
+	recs, err := p.GetRecommendations(dtx, count)
+	if err != nil {
+		apierror.GenerateError("Trouble getting part recommendations", err, w, r)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(recs))
+}
+
+// Compare lines up the attributes, pricing, class and fitment counts of
+// the comma separated part ids.
+func Compare(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	var ids []int
+	for _, s := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			apierror.GenerateError("Trouble getting part ID", err, w, r, http.StatusBadRequest)
+			return ""
+		}
+		ids = append(ids, id)
+	}
+
+	cmp, err := products.Compare(ids, dtx)
+	if err != nil {
+		apierror.GenerateError("Trouble comparing parts", err, w, r, http.StatusBadRequest)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(cmp))
+}
@@ -173,7 +173,7 @@ func TestParts(t *testing.T) {
 		err = json.Unmarshal(testThatHttp.Response.Body.Bytes(), &reviews)
 		So(err, ShouldBeNil)
 		So(len(reviews), ShouldBeGreaterThan, 0)
-		review.Delete(dtx) //teardown - part has FK constraint on review.partID
+		review.Delete() //teardown - part has FK constraint on review.partID
 
 		//get packaging - no package created in test
 		thyme = time.Now()
@@ -1,118 +1,152 @@
 package part_ctlr
 
-// import (
-// 	"encoding/json"
-// 	"errors"
-// 	"io/ioutil"
-// 	"net/http"
-// 	"strconv"
-
-// 	"github.com/curt-labs/API/helpers/apicontext"
-// 	"github.com/curt-labs/API/helpers/encoding"
-// 	"github.com/curt-labs/API/helpers/error"
-// 	"github.com/curt-labs/API/models/products"
-// 	"github.com/go-martini/martini"
-// )
-
-// func GetAllReviews(rw http.ResponseWriter, req *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
-// 	revs, err := products.GetAllReviews(dtx)
-// 	if err != nil {
-// 		apierror.GenerateError("Trouble getting all part reviews", err, rw, req)
-// 		return ""
-// 	}
-
-// 	return encoding.Must(enc.Encode(revs))
-// }
-// func GetReview(rw http.ResponseWriter, req *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
-// 	var rev products.Review
-// 	var err error
-
-// 	if params["id"] == "" {
-// 		err = errors.New("Missing review ID parameter in query string")
-// 		apierror.GenerateError("Trouble getting review ID", err, rw, req)
-// 		return ""
-// 	}
-
-// 	if rev.Id, err = strconv.Atoi(params["id"]); err != nil {
-// 		apierror.GenerateError("Trouble getting review ID", err, rw, req)
-// 		return ""
-// 	}
-
-// 	if err = rev.Get(dtx); err != nil {
-// 		apierror.GenerateError("Trouble getting review", err, rw, req)
-// 		return ""
-// 	}
-
-// 	return encoding.Must(enc.Encode(rev))
-// }
-
-// func SaveReview(rw http.ResponseWriter, req *http.Request, enc encoding.Encoder, params martini.Params, dtx *apicontext.DataContext) string {
-// 	var rev products.Review
-// 	var err error
-
-// 	idStr := params["id"]
-// 	if idStr != "" {
-// 		rev.Id, err = strconv.Atoi(idStr)
-// 		if err != nil {
-// 			apierror.GenerateError("Trouble getting review ID", err, rw, req)
-// 			return ""
-// 		}
-// 		err = rev.Get(dtx)
-// 		if err != nil {
-// 			apierror.GenerateError("Trouble getting review", err, rw, req)
-// 			return ""
-// 		}
-// 	}
-
-// 	//json
-// 	requestBody, err := ioutil.ReadAll(req.Body)
-// 	if err != nil {
-// 		apierror.GenerateError("Trouble reading request body while saving review", err, rw, req)
-// 		return ""
-// 	}
-// 	err = json.Unmarshal(requestBody, &rev)
-// 	if err != nil {
-// 		apierror.GenerateError("Trouble unmarshalling json request body while saving review", err, rw, req)
-// 		return ""
-// 	}
-
-// 	//create or update
-// 	if rev.Id > 0 {
-// 		err = rev.Update(dtx)
-// 	} else {
-// 		err = rev.Create(dtx)
-// 	}
-
-// 	if err != nil {
-// 		msg := "Trouble creating review"
-// 		if rev.Id > 0 {
-// 			msg = "Trouble updating review"
-// 		}
-// 		apierror.GenerateError(msg, err, rw, req)
-// 		return ""
-// 	}
-// 	return encoding.Must(enc.Encode(rev))
-// }
-
-// func DeleteReview(rw http.ResponseWriter, r *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
-// 	var rev products.Review
-// 	var err error
-
-// 	if params["id"] == "" {
-// 		err = errors.New("Missing review ID parameter in query string")
-// 		apierror.GenerateError("Trouble getting review ID", err, rw, r)
-// 		return ""
-// 	}
-
-// 	if rev.Id, err = strconv.Atoi(params["id"]); err != nil {
-// 		apierror.GenerateError("Trouble getting review ID", err, rw, r)
-// 		return ""
-// 	}
-
-// 	if err = rev.Delete(dtx); err != nil {
-// 		apierror.GenerateError("Trouble deleting review", err, rw, r)
-// 		return ""
-// 	}
-
-// 	return encoding.Must(enc.Encode(rev))
-// }
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/encoding"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/products"
+	"github.com/go-martini/martini"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// SubmitReview queues a review of the part for moderation. It won't show up
+// in /part/:part/reviews until an internal key approves it.
+func SubmitReview(rw http.ResponseWriter, req *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	var rev products.Review
+	var err error
+
+	if err = json.NewDecoder(req.Body).Decode(&rev); err != nil {
+		apierror.GenerateError("Trouble reading review", err, rw, req, http.StatusBadRequest)
+		return ""
+	}
+
+	if rev.PartID, err = strconv.Atoi(params["part"]); err != nil {
+		apierror.GenerateError("Trouble getting part ID", err, rw, req, http.StatusBadRequest)
+		return ""
+	}
+
+	if err = rev.Create(dtx); err != nil {
+		code := http.StatusBadRequest
+		if err == products.ErrDuplicateReview {
+			code = http.StatusConflict
+		}
+		apierror.GenerateError("Trouble submitting review", err, rw, req, code)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(rev))
+}
+
+// ReviewQueue lists reviews waiting on moderation. Takes optional status,
+// page and count parameters.
+func ReviewQueue(rw http.ResponseWriter, req *http.Request, enc encoding.Encoder) string {
+	page := 0
+	count := 50
+
+	qs := req.URL.Query()
+	if pg, err := strconv.Atoi(qs.Get("page")); err == nil && pg > 0 {
+		page = pg - 1
+	}
+	if ct, err := strconv.Atoi(qs.Get("count")); err == nil && ct > 0 && ct <= 500 {
+		count = ct
+	}
+
+	revs, err := products.GetReviewQueue(qs.Get("status"), page, count)
+	if err != nil {
+		apierror.GenerateError("Trouble getting review queue", err, rw, req)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(revs))
+}
+
+// ApproveReview publishes a review on its part.
+func ApproveReview(rw http.ResponseWriter, req *http.Request, params martini.Params, enc encoding.Encoder) string {
+	rev, err := reviewFromParams(params)
+	if err != nil {
+		apierror.GenerateError("Trouble getting review ID", err, rw, req, http.StatusBadRequest)
+		return ""
+	}
+
+	if err = rev.Approve(); err != nil {
+		apierror.GenerateError("Trouble approving review", err, rw, req, reviewErrorCode(err))
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(rev))
+}
+
+// RejectReview rejects a review, with an optional {"reason": ""} body.
+func RejectReview(rw http.ResponseWriter, req *http.Request, params martini.Params, enc encoding.Encoder) string {
+	rev, err := reviewFromParams(params)
+	if err != nil {
+		apierror.GenerateError("Trouble getting review ID", err, rw, req, http.StatusBadRequest)
+		return ""
+	}
+
+	reason, err := rejectionReason(req)
+	if err != nil {
+		apierror.GenerateError("Trouble reading rejection reason", err, rw, req, http.StatusBadRequest)
+		return ""
+	}
+
+	if err = rev.Reject(reason); err != nil {
+		apierror.GenerateError("Trouble rejecting review", err, rw, req, reviewErrorCode(err))
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(rev))
+}
+
+func DeleteReview(rw http.ResponseWriter, req *http.Request, params martini.Params, enc encoding.Encoder) string {
+	rev, err := reviewFromParams(params)
+	if err != nil {
+		apierror.GenerateError("Trouble getting review ID", err, rw, req, http.StatusBadRequest)
+		return ""
+	}
+
+	if err = rev.Delete(); err != nil {
+		apierror.GenerateError("Trouble deleting review", err, rw, req, reviewErrorCode(err))
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(rev))
+}
+
+// rejectionReason reads the optional {"reason": ...} body of a rejection.
+// Chunked requests don't have a content length, so an empty body is only
+// known once it's read.
+func rejectionReason(req *http.Request) (string, error) {
+	var body struct {
+		Reason string `json:"reason"`
+	}
+	if req.Body == nil || req.ContentLength == 0 {
+		return "", nil
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil && err != io.EOF {
+		return "", err
+	}
+	return body.Reason, nil
+}
+
+func reviewFromParams(params martini.Params) (products.Review, error) {
+	var rev products.Review
+	if !bson.IsObjectIdHex(params["id"]) {
+		return rev, errors.New("invalid review ID")
+	}
+	rev.ID = bson.ObjectIdHex(params["id"])
+	return rev, nil
+}
+
+func reviewErrorCode(err error) int {
+	if err == products.ErrReviewNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,36 @@
+package part_ctlr
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRejectionReason(t *testing.T) {
+	Convey("Testing rejectionReason()", t, func() {
+		req := httptest.NewRequest("PUT", "/part/reviews/1/reject", strings.NewReader(`{"reason": "spam"}`))
+		reason, err := rejectionReason(req)
+		So(err, ShouldBeNil)
+		So(reason, ShouldEqual, "spam")
+
+		req = httptest.NewRequest("PUT", "/part/reviews/1/reject", strings.NewReader(`{"reason": "chunked"}`))
+		req.ContentLength = -1
+		req.Body = ioutil.NopCloser(strings.NewReader(`{"reason": "chunked"}`))
+		reason, err = rejectionReason(req)
+		So(err, ShouldBeNil)
+		So(reason, ShouldEqual, "chunked")
+
+		req = httptest.NewRequest("PUT", "/part/reviews/1/reject", strings.NewReader(""))
+		req.ContentLength = -1
+		reason, err = rejectionReason(req)
+		So(err, ShouldBeNil)
+		So(reason, ShouldBeEmpty)
+
+		req = httptest.NewRequest("PUT", "/part/reviews/1/reject", strings.NewReader(`{"reason":`))
+		_, err = rejectionReason(req)
+		So(err, ShouldNotBeNil)
+	})
+}
@@ -0,0 +1,35 @@
+package part_ctlr
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/curt-labs/API/helpers/testThatHttp"
+	"github.com/curt-labs/API/helpers/visibilitymock"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPartVisibility(t *testing.T) {
+	if err := visibilitymock.Mock(); err != nil {
+		t.Skip("no database: ", err)
+	}
+	defer visibilitymock.DeMock()
+
+	for _, key := range visibilitymock.Keys {
+		Convey("Testing Get() with a "+key.DataContext.KeyType+" key", t, func() {
+			for id := range visibilitymock.Parts {
+				testThatHttp.RequestWithDtx("get", "/part/", ":part", strconv.Itoa(id)+"?key="+key.DataContext.APIKey, Get, nil, "", key.DataContext)
+				if key.Sees(id) {
+					So(testThatHttp.Response.Code, ShouldEqual, 200)
+					var p products.Part
+					So(json.Unmarshal(testThatHttp.Response.Body.Bytes(), &p), ShouldBeNil)
+					So(p.ID, ShouldEqual, id)
+				} else {
+					So(testThatHttp.Response.Code, ShouldNotEqual, 200)
+				}
+			}
+		})
+	}
+}
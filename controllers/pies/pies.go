@@ -0,0 +1,49 @@
+package pies_ctlr
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/encoding"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/pies"
+)
+
+// GetPiesFile streams the PIES file of the brand. A `since` date
+// (2006-01-02) limits it to the parts modified since then.
+func GetPiesFile(rw http.ResponseWriter, req *http.Request, dtx *apicontext.DataContext) string {
+	var since time.Time
+	if s := req.URL.Query().Get("since"); s != "" {
+		var err error
+		if since, err = time.Parse("2006-01-02", s); err != nil {
+			apierror.GenerateError("Invalid since date", err, rw, req, http.StatusBadRequest)
+			return ""
+		}
+	}
+
+	var brandObj brand.Brand
+	brandObj.ID = dtx.BrandID
+	if err := brandObj.Get(); err != nil {
+		apierror.GenerateError("Invalid brand ID", err, rw, req, http.StatusBadRequest)
+		return ""
+	}
+
+	file := &encoding.Attachment{
+		ResponseWriter: rw,
+		ContentType:    "application/xml",
+		Filename:       brandObj.Code + "_PIES" + pies.Version + ".xml",
+	}
+	if _, err := pies.Generate(file, brandObj, since); err != nil {
+		if !file.Started() {
+			apierror.GenerateError("Trouble generating PIES file", err, rw, req)
+			return ""
+		}
+		// the file is already streaming, so an error can only end it early
+		log.Printf("[API::PIES::GENERATE]:%v", err)
+	}
+
+	return ""
+}
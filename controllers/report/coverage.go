@@ -0,0 +1,93 @@
+package report_ctlr
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/encoding"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/coverage"
+)
+
+// defaultGapLimit is how many gaps of each category the JSON report
+// returns when there's no limit parameter.
+const defaultGapLimit = 100
+
+// FitmentCoverage returns how much of the vehicle universe each category
+// covers, with the most popular uncovered vehicles.
+func FitmentCoverage(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	rep, ok := fitmentCoverage(w, r, dtx)
+	if !ok {
+		return ""
+	}
+
+	limit := defaultGapLimit
+	if l := r.URL.Query().Get("limit"); l != "" {
+		var err error
+		if limit, err = strconv.Atoi(l); err != nil {
+			apierror.GenerateError("Trouble getting limit", err, w, r, http.StatusBadRequest)
+			return ""
+		}
+	}
+
+	return encoding.Must(enc.Encode(rep.Trim(limit)))
+}
+
+// FitmentCoverageCSV returns every uncovered vehicle of each category as
+// CSV.
+func FitmentCoverageCSV(w http.ResponseWriter, r *http.Request, dtx *apicontext.DataContext) string {
+	rep, ok := fitmentCoverage(w, r, dtx)
+	if !ok {
+		return ""
+	}
+
+	b := &bytes.Buffer{}
+	if err := rep.WriteCSV(b); err != nil {
+		apierror.GenerateError("Trouble writing fitment coverage report", err, w, r)
+		return ""
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment;filename=fitment-coverage.csv")
+	w.Write(b.Bytes())
+	return ""
+}
+
+func fitmentCoverage(w http.ResponseWriter, r *http.Request, dtx *apicontext.DataContext) (coverage.Report, bool) {
+	qs := r.URL.Query()
+	opts := coverage.Options{Brands: dtx.BrandArray}
+	if dtx.BrandID != 0 {
+		opts.Brands = []int{dtx.BrandID}
+	}
+	if cats := qs.Get("categories"); cats != "" {
+		for _, c := range strings.Split(cats, ",") {
+			if c = strings.TrimSpace(c); c != "" {
+				opts.Categories = append(opts.Categories, c)
+			}
+		}
+	}
+
+	var err error
+	if from := qs.Get("from"); from != "" {
+		if opts.FromYear, err = strconv.Atoi(from); err != nil {
+			apierror.GenerateError("Trouble getting from year", err, w, r, http.StatusBadRequest)
+			return coverage.Report{}, false
+		}
+	}
+	if to := qs.Get("to"); to != "" {
+		if opts.ToYear, err = strconv.Atoi(to); err != nil {
+			apierror.GenerateError("Trouble getting to year", err, w, r, http.StatusBadRequest)
+			return coverage.Report{}, false
+		}
+	}
+
+	rep, err := coverage.Get(opts, qs.Get("refresh") == "true")
+	if err != nil {
+		apierror.GenerateError("Trouble generating fitment coverage report", err, w, r)
+		return rep, false
+	}
+	return rep, true
+}
@@ -0,0 +1,61 @@
+package report_ctlr
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/encoding"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/quality"
+)
+
+// DataQuality scores the catalog against the completeness rules and
+// returns the overall, per-brand and per-category summaries.
+func DataQuality(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	rep, ok := dataQuality(w, r, dtx)
+	if !ok {
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(rep))
+}
+
+// DataQualityCSV returns the parts that failed at least one rule as CSV.
+func DataQualityCSV(w http.ResponseWriter, r *http.Request, dtx *apicontext.DataContext) string {
+	rep, ok := dataQuality(w, r, dtx)
+	if !ok {
+		return ""
+	}
+
+	b := &bytes.Buffer{}
+	if err := rep.WriteCSV(b); err != nil {
+		apierror.GenerateError("Trouble writing data quality report", err, w, r)
+		return ""
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment;filename=data-quality.csv")
+	w.Write(b.Bytes())
+	return ""
+}
+
+func dataQuality(w http.ResponseWriter, r *http.Request, dtx *apicontext.DataContext) (quality.Report, bool) {
+	var names []string
+	if qs := r.URL.Query().Get("rules"); qs != "" {
+		names = strings.Split(qs, ",")
+	}
+	rules, err := quality.Select(names)
+	if err != nil {
+		apierror.GenerateError("Trouble getting data quality rules", err, w, r, http.StatusBadRequest)
+		return quality.Report{}, false
+	}
+
+	rep, err := quality.Generate(rules, dtx)
+	if err != nil {
+		apierror.GenerateError("Trouble generating data quality report", err, w, r)
+		return rep, false
+	}
+	return rep, true
+}
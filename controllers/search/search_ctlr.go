@@ -18,8 +18,14 @@ func Search(rw http.ResponseWriter, r *http.Request, params martini.Params, enc
 	count, _ := strconv.Atoi(qs.Get("count"))
 	brand, _ := strconv.Atoi(qs.Get("brand"))
 	rawPartNumber := qs.Get("raw")
+	detectVehicle := qs.Get("vehicle") == "true"
+	filters, err := search.ParseFilters(qs["facet"])
+	if err != nil {
+		apierror.GenerateError("Invalid facet filter", err, rw, r, http.StatusBadRequest)
+		return ""
+	}
 
-	res, err := search.Dsl(terms, page, count, brand, dtx, rawPartNumber)
+	res, err := search.Dsl(terms, page, count, brand, dtx, rawPartNumber, filters, detectVehicle)
 	if err != nil {
 		apierror.GenerateError("Trouble searching", err, rw, r)
 		return ""
@@ -34,8 +40,14 @@ func SearchExactAndClose(rw http.ResponseWriter, r *http.Request, params martini
 	page, _ := strconv.Atoi(qs.Get("page"))
 	count, _ := strconv.Atoi(qs.Get("count"))
 	brand, _ := strconv.Atoi(qs.Get("brand"))
+	detectVehicle := qs.Get("vehicle") == "true"
+	filters, err := search.ParseFilters(qs["facet"])
+	if err != nil {
+		apierror.GenerateError("Invalid facet filter", err, rw, r, http.StatusBadRequest)
+		return ""
+	}
 
-	res, err := search.ExactAndCloseDsl(terms, page, count, brand, dtx)
+	res, err := search.ExactAndCloseDsl(terms, page, count, brand, dtx, filters, detectVehicle)
 	if err != nil {
 		apierror.GenerateError("Trouble searching", err, rw, r)
 		return ""
@@ -43,3 +55,32 @@ func SearchExactAndClose(rw http.ResponseWriter, r *http.Request, params martini
 
 	return encoding.Must(enc.Encode(res))
 }
+
+// Suggest completes the q parameter with part numbers, categories and
+// vehicles of the key's brands, or just the brand parameter, for a search
+// box.
+func Suggest(rw http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	qs := r.URL.Query()
+	count, _ := strconv.Atoi(qs.Get("count"))
+	if count > search.MaxCompletions {
+		count = search.MaxCompletions
+	}
+
+	brands := dtx.BrandArray
+	if brand, _ := strconv.Atoi(qs.Get("brand")); brand != 0 {
+		brands = nil
+		for _, b := range dtx.BrandArray {
+			if b == brand {
+				brands = []int{brand}
+			}
+		}
+	}
+
+	completions, err := search.Complete(qs.Get("q"), brands, dtx, count)
+	if err != nil {
+		apierror.GenerateError("Trouble getting search suggestions", err, rw, r)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(completions))
+}
@@ -0,0 +1,57 @@
+package search_ctlr
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/testThatHttp"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+	"github.com/curt-labs/API/models/search"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSearchVisibility(t *testing.T) {
+	m := search.NewMemoryBackend()
+	for id, visibility := range map[int]string{
+		1: products.PUBLIC,
+		2: products.LOGGEDIN,
+		3: products.DISABLED,
+	} {
+		p := products.Part{ID: id, PartNumber: strconv.Itoa(id), Status: 800, ShortDesc: "Trailer Hitch", Brand: brand.Brand{ID: 1}, WebVisibility: visibility}
+		m.Index("curt", search.Document{ID: p.PartNumber, Source: search.NewPartDocument(p)})
+	}
+
+	old := search.Backend
+	search.Backend = m
+	defer func() { search.Backend = old }()
+
+	keys := []struct {
+		dtx  *apicontext.DataContext
+		hits []string
+	}{
+		{&apicontext.DataContext{APIKey: "public", KeyType: "Public", BrandArray: []int{1}}, []string{"1"}},
+		{&apicontext.DataContext{APIKey: "private", KeyType: "Private", BrandArray: []int{1}}, []string{"1"}},
+		{&apicontext.DataContext{APIKey: "auth", KeyType: "Authentication", UserID: "user", BrandArray: []int{1}}, []string{"1", "2"}},
+	}
+
+	for _, key := range keys {
+		Convey("Testing Search() and SearchExactAndClose() with a "+key.dtx.KeyType+" key", t, func() {
+			for _, handler := range []interface{}{Search, SearchExactAndClose} {
+				testThatHttp.RequestWithDtx("get", "/search/", ":term", "hitch?key="+key.dtx.APIKey, handler, nil, "", key.dtx)
+				So(testThatHttp.Response.Code, ShouldEqual, 200)
+
+				var res search.SearchResult
+				So(json.Unmarshal(testThatHttp.Response.Body.Bytes(), &res), ShouldBeNil)
+				So(res.Hits.TotalHits, ShouldEqual, len(key.hits))
+				var ids []string
+				for _, hit := range res.Hits.Hits {
+					ids = append(ids, hit.Id)
+				}
+				So(ids, ShouldResemble, key.hits)
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package shipping_ctlr
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/encoding"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/shipping"
+)
+
+// Estimate prices a cart of parts to a destination postal code, picking
+// parcel or LTL freight from the package data.
+func Estimate(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	var req shipping.EstimateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apierror.GenerateError("Trouble reading shipping estimate request", err, w, r, http.StatusBadRequest)
+		return ""
+	}
+
+	est, err := shipping.GetEstimate(req, dtx)
+	if err != nil {
+		apierror.GenerateError("Trouble getting shipping estimate", err, w, r)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(est))
+}
@@ -60,9 +60,10 @@ func QueryCategoryStyle(w http.ResponseWriter, r *http.Request, params martini.P
 	}
 
 	ctx := &products.LookupContext{
-		Brands:   dtx.BrandArray,
-		Statuses: statuses,
-		Session:  session,
+		Brands:       dtx.BrandArray,
+		Statuses:     statuses,
+		Session:      session,
+		Visibilities: products.Visibilities(dtx),
 	}
 
 	cats, err := products.Query(
@@ -33,6 +33,13 @@ func CurtLookup(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dt
 	v.Style = r.FormValue("style")
 	delete(r.Form, "style")
 
+	g, ok := garageVehicle(w, r, dtx)
+	if !ok {
+		return ""
+	} else if g != nil {
+		v = garageCurtVehicle(g)
+	}
+
 	cl := products.CurtLookup{
 		CurtVehicle: v,
 	}
@@ -81,6 +88,13 @@ func CurtLookupGet(w http.ResponseWriter, r *http.Request, enc encoding.Encoder,
 		return err.Error()
 	}
 
+	g, ok := garageVehicle(w, r, dtx)
+	if !ok {
+		return ""
+	} else if g != nil {
+		v = garageCurtVehicle(g)
+	}
+
 	cl := products.CurtLookup{
 		CurtVehicle: v,
 	}
@@ -112,7 +126,10 @@ func CurtLookupGet(w http.ResponseWriter, r *http.Request, enc encoding.Encoder,
 //CurtLookupWorker is a function that is used by both the POST and GET versions
 //of CurtLookup. Just here so that there is as little code duplication as possible
 func CurtLookupWorker(cl products.CurtLookup, heavyduty bool, dtx *apicontext.DataContext, getCustomerPricing bool) (products.CurtLookup, error) {
-	var err error
+	err := cl.Resolve(heavyduty)
+	if err != nil {
+		return cl, err
+	}
 	if cl.CurtVehicle.Year == "" {
 		err = cl.GetYears(heavyduty)
 	} else if cl.CurtVehicle.Make == "" {
@@ -129,6 +146,9 @@ func CurtLookupWorker(cl products.CurtLookup, heavyduty bool, dtx *apicontext.Da
 			cl.Parts, err = products.BindCustomerToSeveralParts(cl.Parts, dtx)
 		}
 	}
+	if err == nil {
+		err = cl.Suggest(heavyduty)
+	}
 
 	return cl, err
 }
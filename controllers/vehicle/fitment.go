@@ -0,0 +1,117 @@
+package vehicle
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/helpers/encoding"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/fitment"
+	"github.com/curt-labs/API/models/products"
+	"github.com/go-martini/martini"
+)
+
+var (
+	// fitmentParams are the query string parameters of /v4/vehicle that
+	// aren't vehicle configurations.
+	fitmentParams = []string{"key", "engine", "category", "heavyduty", "page", "count", strings.ToLower(garageParam)}
+)
+
+// Fitment looks the vehicle up in any of the fitment engines and returns
+// the next step of the lookup in the same shape for every engine. The engine
+// is picked with `engine`, or by the brand of the API key.
+func Fitment(w http.ResponseWriter, r *http.Request, params martini.Params, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	if err := database.Init(); err != nil {
+		apierror.GenerateError("Trouble generating database connection", err, w, r)
+		return ""
+	}
+
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	qs := r.URL.Query()
+	engine := qs.Get("engine")
+	if engine == "" {
+		engine = fitment.EngineFor(dtx)
+	}
+
+	ctx := &fitment.Context{
+		DataContext: dtx,
+		Session:     session,
+		Statuses:    DefaultStatuses,
+		Category:    qs.Get("category"),
+		HeavyDuty:   strings.ToLower(qs.Get("heavyduty")) == "true",
+	}
+	ctx.Page, _ = strconv.Atoi(qs.Get("page"))
+	ctx.Count, _ = strconv.Atoi(qs.Get("count"))
+
+	p, err := fitment.New(engine, ctx)
+	if err != nil {
+		apierror.GenerateError("Trouble finding fitment engine "+engine, err, w, r, http.StatusBadRequest)
+		return ""
+	}
+
+	v := fitmentVehicle(r, params)
+	g, ok := garageVehicle(w, r, dtx)
+	if !ok {
+		return ""
+	} else if g != nil {
+		v = garageFitmentVehicle(g)
+	}
+
+	l, err := fitment.Resolve(engine, p, v)
+	if err != nil {
+		apierror.GenerateError("Trouble finding vehicles.", err, w, r)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(l))
+}
+
+// FitmentEngines lists the engines /v4/vehicle can look vehicles up in.
+func FitmentEngines(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *apicontext.DataContext) string {
+	return encoding.Must(enc.Encode(fitment.Engines()))
+}
+
+// fitmentVehicle reads the vehicle from the path, and its configurations
+// from the rest of the query string.
+func fitmentVehicle(r *http.Request, params martini.Params) fitment.Vehicle {
+	v := fitment.Vehicle{
+		Year:  params["year"],
+		Make:  params["make"],
+		Model: params["model"],
+		Style: params["style"],
+	}
+	if v.Style == "" {
+		return v
+	}
+
+	qs := r.URL.Query()
+	var keys []string
+	for key := range qs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		ignore := false
+		for _, param := range fitmentParams {
+			if param == strings.ToLower(key) {
+				ignore = true
+				break
+			}
+		}
+		if !ignore && qs.Get(key) != "" {
+			v.Configurations = append(v.Configurations, products.Configuration{
+				Key:   key,
+				Value: qs.Get(key),
+			})
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,78 @@
+package vehicle
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/error"
+	"github.com/curt-labs/API/models/customer"
+	"github.com/curt-labs/API/models/fitment"
+	"github.com/curt-labs/API/models/products"
+)
+
+const garageParam = "garageVehicle"
+
+// garageVehicle loads the saved vehicle of the garageVehicle parameter,
+// which takes the place of the year, make and model. It returns nil when
+// there's no parameter, and false once it has written an error.
+func garageVehicle(w http.ResponseWriter, r *http.Request, dtx *apicontext.DataContext) (*customer.GarageVehicle, bool) {
+	id := r.URL.Query().Get(garageParam)
+	if id == "" {
+		return nil, true
+	}
+	if dtx == nil || dtx.UserID == "" {
+		apierror.GenerateError("Trouble getting garage vehicle", errors.New("the garage needs a customer user's API key"), w, r, http.StatusUnauthorized)
+		return nil, false
+	}
+
+	cu := customer.CustomerUser{Id: dtx.UserID}
+	g, err := cu.GetGarageVehicle(id)
+	if err == customer.ErrGarageVehicleNotFound {
+		apierror.GenerateError("Trouble getting garage vehicle", err, w, r, http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		apierror.GenerateError("Trouble getting garage vehicle", err, w, r)
+		return nil, false
+	}
+	return &g, true
+}
+
+func garageAcesVehicle(g *customer.GarageVehicle) products.Vehicle {
+	v := products.Vehicle{
+		Base: products.BaseVehicle{
+			Year:  g.Year,
+			Make:  g.Make,
+			Model: g.Model,
+		},
+		Submodel: g.Submodel,
+	}
+	for _, c := range g.Configurations {
+		v.Configurations = append(v.Configurations, products.Configuration{Key: c.Key, Value: c.Value})
+	}
+	return v
+}
+
+func garageCurtVehicle(g *customer.GarageVehicle) products.CurtVehicle {
+	return products.CurtVehicle{
+		Year:  strconv.Itoa(g.Year),
+		Make:  g.Make,
+		Model: g.Model,
+		Style: g.Style,
+	}
+}
+
+func garageFitmentVehicle(g *customer.GarageVehicle) fitment.Vehicle {
+	v := fitment.Vehicle{
+		Year:  strconv.Itoa(g.Year),
+		Make:  g.Make,
+		Model: g.Model,
+		Style: g.Style,
+	}
+	if g.Type == customer.AcesGarageVehicle {
+		v.Style = g.Submodel
+		v.Configurations = garageAcesVehicle(g).Configurations
+	}
+	return v
+}
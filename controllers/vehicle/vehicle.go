@@ -18,7 +18,7 @@ import (
 )
 
 var (
-	ignoredFormParams = []string{"key"}
+	ignoredFormParams = []string{"key", products.RulesParam, strings.ToLower(garageParam)}
 )
 
 // Finds further configuration options and parts that match
@@ -39,8 +39,23 @@ func Query(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *ap
 	qs.Del("count")
 
 	l.Vehicle = LoadVehicle(r)
-
+	g, ok := garageVehicle(w, r, dtx)
+	if !ok {
+		return ""
+	} else if g != nil {
+		l.Vehicle = garageAcesVehicle(g)
+	}
 	l.Brands = dtx.BrandArray
+	if err := l.Resolve(dtx); err != nil {
+		apierror.GenerateError("Trouble resolving vehicle for vehicle lookup", err, w, r)
+		return ""
+	}
+
+	rules, err := products.ParseRules(r.FormValue(products.RulesParam))
+	if err != nil {
+		apierror.GenerateError("Trouble reading filter rules", err, w, r, http.StatusBadRequest)
+		return ""
+	}
 
 	if qs.Get("key") != "" {
 		l.CustomerKey = qs.Get("key")
@@ -69,8 +84,8 @@ func Query(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *ap
 	} else {
 
 		// Kick off part getter
-		partChan := make(chan []products.Part)
-		go l.LoadParts(partChan, page, count, dtx)
+		partErr := make(chan error, 1)
+		go l.LoadParts(partErr, page, count, rules, dtx)
 
 		if l.Vehicle.Submodel == "" { // Get Submodels
 			if err := l.GetSubmodels(); err != nil {
@@ -85,9 +100,12 @@ func Query(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *ap
 		}
 
 		select {
-		case parts := <-partChan:
-			if len(parts) > 0 {
-				l.Parts = parts
+		case err := <-partErr:
+			if err != nil {
+				apierror.GenerateError("Trouble getting parts for vehicle lookup", err, w, r)
+				return ""
+			}
+			if len(l.Parts) > 0 {
 				l.Filter, _ = apifilter.PartFilter(l.Parts, nil)
 			}
 		case <-time.After(5 * time.Second):
@@ -95,6 +113,11 @@ func Query(w http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx *ap
 		}
 	}
 
+	if err := l.Suggest(dtx); err != nil {
+		apierror.GenerateError("Trouble finding suggestions for vehicle lookup", err, w, r)
+		return ""
+	}
+
 	return encoding.Must(enc.Encode(l))
 }
 
@@ -218,3 +241,32 @@ func Inquire(rw http.ResponseWriter, r *http.Request, enc encoding.Encoder, dtx
 	return ""
 
 }
+
+// InvalidateCache removes the cached ACES lookups of the brand (every brand
+// when missing) for the year (every year when missing), for when fitment
+// changes.
+func InvalidateCache(w http.ResponseWriter, r *http.Request, enc encoding.Encoder) string {
+	qs := r.URL.Query()
+	var brand, year int
+	var err error
+	if b := qs.Get("brand"); b != "" {
+		if brand, err = strconv.Atoi(b); err != nil {
+			apierror.GenerateError("Trouble getting brand ID", err, w, r, http.StatusBadRequest)
+			return ""
+		}
+	}
+	if y := qs.Get("year"); y != "" {
+		if year, err = strconv.Atoi(y); err != nil {
+			apierror.GenerateError("Trouble getting year", err, w, r, http.StatusBadRequest)
+			return ""
+		}
+	}
+
+	removed, err := products.InvalidateLookupCache(brand, year)
+	if err != nil {
+		apierror.GenerateError("Trouble invalidating vehicle lookup cache", err, w, r)
+		return ""
+	}
+
+	return encoding.Must(enc.Encode(map[string]int{"removed": removed}))
+}
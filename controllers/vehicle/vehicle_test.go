@@ -93,3 +93,86 @@ func TestVehicles(t *testing.T) {
 	})
 	_ = apicontextmock.DeMock(dtx)
 }
+
+func TestQueryRules(t *testing.T) {
+	dtx, err := apicontextmock.Mock()
+	if err != nil {
+		t.Skip("no database: ", err)
+	}
+	defer apicontextmock.DeMock(dtx)
+
+	qs, all := vehicleWithParts(dtx.APIKey)
+	if qs == nil {
+		t.Skip("no vehicle with parts")
+	}
+
+	Convey("Testing Query() with a rule", t, func() {
+		// leave out every part of the first part's class
+		class := all.Parts[0].Class.Name
+		if class == "" {
+			class = "Other"
+		}
+		var want []int
+		for _, p := range all.Parts {
+			if p.Class.Name != class && !(class == "Other" && p.Class.Name == "") {
+				want = append(want, p.ID)
+			}
+		}
+
+		rules, _ := json.Marshal([]products.FilterRule{{Kind: "class", Values: []string{class}, Not: true}})
+		qs.Set(products.RulesParam, string(rules))
+		response := httprunner.ParameterizedJsonRequest("POST", "/vehicle", "/vehicle", &qs, &qs, Query)
+		So(response.Code, ShouldEqual, 200)
+		var l products.Lookup
+		So(json.Unmarshal(response.Body.Bytes(), &l), ShouldBeNil)
+
+		var ids []int
+		for _, p := range l.Parts {
+			ids = append(ids, p.ID)
+		}
+		So(ids, ShouldResemble, want)
+		So(l.Pagination.TotalItems, ShouldEqual, len(want))
+		So(l.Pagination.TotalItems, ShouldBeLessThan, all.Pagination.TotalItems)
+	})
+}
+
+// vehicleWithParts walks the lookup down to the first model with parts and
+// returns its query and lookup, with every part on one page.
+func vehicleWithParts(key string) (url.Values, products.Lookup) {
+	var l products.Lookup
+	lookup := func(qs url.Values) bool {
+		response := httprunner.ParameterizedJsonRequest("POST", "/vehicle", "/vehicle", &qs, &qs, Query)
+		l = products.Lookup{}
+		return response.Code == 200 && json.Unmarshal(response.Body.Bytes(), &l) == nil
+	}
+
+	qs := url.Values{"key": {key}, "count": {"1000"}}
+	if !lookup(qs) {
+		return nil, l
+	}
+	tries := 0
+	for _, year := range l.Years {
+		qs.Set("year", strconv.Itoa(year))
+		if !lookup(qs) {
+			continue
+		}
+		for _, mk := range l.Makes {
+			qs.Set("make", mk)
+			if !lookup(qs) {
+				continue
+			}
+			for _, model := range l.Models {
+				if tries++; tries > 20 {
+					return nil, l
+				}
+				qs.Set("model", model)
+				if lookup(qs) && len(l.Parts) > 0 {
+					return qs, l
+				}
+			}
+			qs.Del("model")
+		}
+		qs.Del("make")
+	}
+	return nil, l
+}
@@ -0,0 +1,40 @@
+package vehicle
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"testing"
+
+	"github.com/curt-labs/API/helpers/testThatHttp"
+	"github.com/curt-labs/API/helpers/visibilitymock"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCurtLookupVisibility(t *testing.T) {
+	if err := visibilitymock.Mock(); err != nil {
+		t.Skip("no database: ", err)
+	}
+	defer visibilitymock.DeMock()
+
+	vehicle := url.Values{
+		"year":  {visibilitymock.Year},
+		"make":  {visibilitymock.Make},
+		"model": {visibilitymock.Model},
+	}
+	for _, key := range visibilitymock.Keys {
+		Convey("Testing CurtLookupGet() with a "+key.DataContext.KeyType+" key", t, func() {
+			testThatHttp.RequestWithDtx("get", "/vehicle/curt", "", "?"+vehicle.Encode()+"&key="+key.DataContext.APIKey, CurtLookupGet, nil, "", key.DataContext)
+			So(testThatHttp.Response.Code, ShouldEqual, 200)
+			var l products.CurtLookup
+			So(json.Unmarshal(testThatHttp.Response.Body.Bytes(), &l), ShouldBeNil)
+			var ids []int
+			for _, p := range l.Parts {
+				ids = append(ids, p.ID)
+			}
+			sort.Ints(ids)
+			So(ids, ShouldResemble, key.Visible)
+		})
+	}
+}
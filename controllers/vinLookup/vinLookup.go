@@ -17,6 +17,7 @@ func GetParts(rw http.ResponseWriter, req *http.Request, enc encoding.Encoder, p
 	parts, err := vinLookup.VinPartLookup(vin, dtx)
 	if err != nil {
 		apierror.GenerateError("Trouble getting parts", err, rw, req)
+		return ""
 	}
 	//return array of vehicles containing array of parts
 	return encoding.Must(enc.Encode(parts))
@@ -28,6 +29,7 @@ func GetConfigs(rw http.ResponseWriter, req *http.Request, enc encoding.Encoder,
 	configs, err := vinLookup.GetVehicleConfigs(vin)
 	if err != nil {
 		apierror.GenerateError("Trouble getting vehicle configurations", err, rw, req)
+		return ""
 	}
 
 	return encoding.Must(enc.Encode(configs))
@@ -45,7 +47,7 @@ func GetPartsFromVehicleID(rw http.ResponseWriter, req *http.Request, enc encodi
 	parts, err := v.GetPartsFromVehicleConfig(dtx)
 	if err != nil {
 		apierror.GenerateError("Trouble getting parts from vehicle configuration", err, rw, req)
-
+		return ""
 	}
 	return encoding.Must(enc.Encode(parts))
 }
@@ -12,6 +12,7 @@ type DataContext struct {
 	BrandID     int
 	WebsiteID   int
 	APIKey      string
+	KeyType     string
 	CustomerID  int
 	UserID      string
 	Globals     map[string]interface{}
@@ -94,6 +94,7 @@ func Mock() (*apicontext.DataContext, error) {
 	for t, k := range keys {
 		if t == "Public" {
 			dtx.APIKey = k
+			dtx.KeyType = t
 		}
 	}
 	// Website
@@ -11,6 +11,7 @@ type Option struct {
 	Value    string `json:"value" xml:"value,attr"`
 	Selected bool   `json:"selected" xml:"selected,attr"`
 	Products []int  `json:"products" xml:"products"`
+	Count    int    `json:"count,omitempty" xml:"count,attr,omitempty"`
 }
 
 type Decision struct {
@@ -0,0 +1,442 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrHostNotAllowed = errors.New("asset host is not allowed")
+	ErrTooLarge       = errors.New("asset is too large")
+	ErrContentType    = errors.New("asset has an unexpected content type")
+	ErrUpstream       = errors.New("asset could not be retrieved")
+
+	DefaultHosts = []string{
+		"curtmfg.com",
+		"ariesautomotive.com",
+		"luvernetruck.com",
+		"curtgroup.com",
+		"storage.googleapis.com",
+	}
+
+	// Default is the proxy used by the asset endpoints, configured from
+	// the ASSET_* environment variables.
+	Default = NewFromEnv()
+)
+
+// Kind is a class of asset and the content types we'll serve for it.
+type Kind struct {
+	Name  string
+	Types []string
+}
+
+var (
+	PDF = Kind{
+		Name:  "pdf",
+		Types: []string{"application/pdf"},
+	}
+	Image = Kind{
+		Name:  "image",
+		Types: []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+	}
+	// Spreadsheet covers xls (which sniffs as octet-stream) and xlsx
+	// (which sniffs as zip).
+	Spreadsheet = Kind{
+		Name: "spreadsheet",
+		Types: []string{
+			"application/vnd.ms-excel",
+			"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+			"application/octet-stream",
+			"application/zip",
+		},
+	}
+)
+
+// Proxy fetches assets from an allowed set of upstream hosts and keeps them
+// in a size bounded cache on disk.
+type Proxy struct {
+	Dir        string
+	MaxBytes   int64
+	MaxObject  int64
+	TTL        time.Duration
+	Hosts      []string
+	Client     *http.Client
+	MaxResizes int
+	inflight   map[string]*call
+	inflightMu sync.Mutex
+	evictMu    sync.Mutex
+	resizes    chan struct{}
+	resizeOnce sync.Once
+}
+
+type call struct {
+	done  chan struct{}
+	entry *Entry
+	err   error
+}
+
+// Entry is a cached asset.
+type Entry struct {
+	URL         string    `json:"url"`
+	ContentType string    `json:"content_type"`
+	ETag        string    `json:"etag"`
+	Size        int64     `json:"size"`
+	Fetched     time.Time `json:"fetched"`
+	path        string
+}
+
+// NewFromEnv builds a proxy from ASSET_CACHE_DIR, ASSET_CACHE_MAX_BYTES,
+// ASSET_MAX_BYTES, ASSET_CACHE_TTL, ASSET_ALLOWED_HOSTS and
+// ASSET_MAX_RESIZES, falling back to reasonable defaults.
+func NewFromEnv() *Proxy {
+	p := &Proxy{
+		Dir:        filepath.Join(os.TempDir(), "api-assets"),
+		MaxBytes:   1 << 30,
+		MaxObject:  50 << 20,
+		TTL:        24 * time.Hour,
+		Hosts:      DefaultHosts,
+		MaxResizes: runtime.NumCPU(),
+	}
+
+	if dir := os.Getenv("ASSET_CACHE_DIR"); dir != "" {
+		p.Dir = dir
+	}
+	if n, err := strconv.ParseInt(os.Getenv("ASSET_CACHE_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		p.MaxBytes = n
+	}
+	if n, err := strconv.ParseInt(os.Getenv("ASSET_MAX_BYTES"), 10, 64); err == nil && n > 0 {
+		p.MaxObject = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("ASSET_CACHE_TTL")); err == nil && d > 0 {
+		p.TTL = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("ASSET_MAX_RESIZES")); err == nil && n > 0 {
+		p.MaxResizes = n
+	}
+	if hosts := os.Getenv("ASSET_ALLOWED_HOSTS"); hosts != "" {
+		p.Hosts = nil
+		for _, h := range strings.Split(hosts, ",") {
+			if h = strings.TrimSpace(h); h != "" {
+				p.Hosts = append(p.Hosts, h)
+			}
+		}
+	}
+
+	return p
+}
+
+// Allowed reports whether the URL points at one of the allowed hosts or
+// their subdomains.
+func (p *Proxy) Allowed(u *url.URL) bool {
+	if u == nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, h := range p.Hosts {
+		h = strings.ToLower(h)
+		if host == h || strings.HasSuffix(host, "."+h) {
+			return true
+		}
+	}
+	return false
+}
+
+// Serve writes the asset at rawURL to w, fetching it into the cache if
+// needed. ETag, If-None-Match and Range requests are handled by
+// http.ServeContent.
+func (p *Proxy) Serve(w http.ResponseWriter, r *http.Request, rawURL string, kind Kind) error {
+	return p.serve(w, r, func() (*Entry, error) {
+		return p.Get(rawURL, kind)
+	})
+}
+
+// serve writes the entry returned by get to w.
+func (p *Proxy) serve(w http.ResponseWriter, r *http.Request, get func() (*Entry, error)) error {
+	e, f, err := openEntry(get)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	now := time.Now()
+	os.Chtimes(e.path, now, now)
+
+	w.Header().Set("Content-Type", e.ContentType)
+	w.Header().Set("ETag", e.ETag)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(p.TTL.Seconds())))
+	http.ServeContent(w, r, "", e.Fetched, f)
+	return nil
+}
+
+// openEntry opens the file of the entry returned by get. Another request
+// can evict the entry between get and the open, so when the file is gone
+// the entry is looked up again, which fetches or builds it anew. Once open,
+// the file stays readable even if it's evicted.
+func openEntry(get func() (*Entry, error)) (*Entry, *os.File, error) {
+	for attempt := 0; ; attempt++ {
+		e, err := get()
+		if err != nil {
+			return nil, nil, err
+		}
+		f, err := os.Open(e.path)
+		if os.IsNotExist(err) && attempt == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		return e, f, nil
+	}
+}
+
+// Get returns the cached asset, fetching it when it's missing or stale.
+// A stale copy is returned if the upstream can't be reached.
+func (p *Proxy) Get(rawURL string, kind Kind) (*Entry, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if !p.Allowed(u) {
+		return nil, ErrHostNotAllowed
+	}
+
+	key := cacheKey(u.String())
+	cached, _ := p.load(key)
+	if cached != nil && time.Since(cached.Fetched) < p.TTL && allowedType(kind, cached.ContentType) {
+		return cached, nil
+	}
+
+	e, err := p.once(key, func() (*Entry, error) {
+		return p.fetch(key, u.String(), kind)
+	})
+	if err != nil {
+		if cached != nil && err != ErrTooLarge && err != ErrContentType {
+			return cached, nil
+		}
+		return nil, err
+	}
+	return e, nil
+}
+
+// once makes sure concurrent requests for the same cache key only fetch or
+// build the asset once.
+func (p *Proxy) once(key string, fn func() (*Entry, error)) (*Entry, error) {
+	p.inflightMu.Lock()
+	if p.inflight == nil {
+		p.inflight = make(map[string]*call)
+	}
+	if c, ok := p.inflight[key]; ok {
+		p.inflightMu.Unlock()
+		<-c.done
+		return c.entry, c.err
+	}
+	c := &call{done: make(chan struct{})}
+	p.inflight[key] = c
+	p.inflightMu.Unlock()
+
+	c.entry, c.err = fn()
+	close(c.done)
+
+	p.inflightMu.Lock()
+	delete(p.inflight, key)
+	p.inflightMu.Unlock()
+
+	return c.entry, c.err
+}
+
+func (p *Proxy) fetch(key, rawURL string, kind Kind) (*Entry, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	if p.Client != nil {
+		*client = *p.Client
+	}
+	// don't let an allowed host redirect us somewhere that isn't
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 5 || !p.Allowed(req.URL) {
+			return ErrHostNotAllowed
+		}
+		return nil
+	}
+
+	res, err := client.Get(rawURL)
+	if err != nil {
+		return nil, ErrUpstream
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, ErrUpstream
+	}
+	if res.ContentLength > p.MaxObject {
+		return nil, ErrTooLarge
+	}
+
+	return p.store(key, rawURL, func(tmp *os.File) (string, error) {
+		n, err := io.Copy(tmp, io.LimitReader(res.Body, p.MaxObject+1))
+		if err != nil {
+			return "", ErrUpstream
+		}
+		if n > p.MaxObject {
+			return "", ErrTooLarge
+		}
+
+		sniff := make([]byte, 512)
+		read, _ := tmp.ReadAt(sniff, 0)
+		contentType := detectType(res.Header.Get("Content-Type"), sniff[:read])
+		if !allowedType(kind, contentType) {
+			return "", ErrContentType
+		}
+		return contentType, nil
+	})
+}
+
+// store writes a new cache entry under key. The write func fills in the
+// temp file and returns the content type, the file is only moved into
+// place once it's complete.
+func (p *Proxy) store(key, rawURL string, write func(*os.File) (string, error)) (*Entry, error) {
+	if err := os.MkdirAll(p.Dir, 0755); err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempFile(p.Dir, key+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	contentType, err := write(tmp)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := sha256.New()
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	n, err := io.Copy(hash, tmp)
+	if err != nil {
+		return nil, err
+	}
+	if err = tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	e := &Entry{
+		URL:         rawURL,
+		ContentType: contentType,
+		ETag:        `"` + hex.EncodeToString(hash.Sum(nil))[:32] + `"`,
+		Size:        n,
+		Fetched:     time.Now(),
+		path:        filepath.Join(p.Dir, key),
+	}
+
+	if err = os.Rename(tmp.Name(), e.path); err != nil {
+		return nil, err
+	}
+	meta, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	if err = ioutil.WriteFile(e.path+".json", meta, 0644); err != nil {
+		return nil, err
+	}
+
+	p.evict(key)
+	return e, nil
+}
+
+func (p *Proxy) load(key string) (*Entry, error) {
+	path := filepath.Join(p.Dir, key)
+	data, err := ioutil.ReadFile(path + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err = json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	if _, err = os.Stat(path); err != nil {
+		return nil, err
+	}
+	e.path = path
+	return &e, nil
+}
+
+// evict removes the least recently used assets until the cache fits in
+// MaxBytes. The asset that was just fetched is never evicted.
+func (p *Proxy) evict(keep string) {
+	p.evictMu.Lock()
+	defer p.evictMu.Unlock()
+
+	files, err := ioutil.ReadDir(p.Dir)
+	if err != nil {
+		return
+	}
+
+	var total int64
+	var cached []os.FileInfo
+	for _, f := range files {
+		if f.IsDir() || strings.Contains(f.Name(), ".") {
+			continue
+		}
+		total += f.Size()
+		cached = append(cached, f)
+	}
+	if total <= p.MaxBytes {
+		return
+	}
+
+	sort.Slice(cached, func(i, j int) bool {
+		return cached[i].ModTime().Before(cached[j].ModTime())
+	})
+	for _, f := range cached {
+		if total <= p.MaxBytes {
+			break
+		}
+		if f.Name() == keep {
+			continue
+		}
+		path := filepath.Join(p.Dir, f.Name())
+		if os.Remove(path) == nil {
+			os.Remove(path + ".json")
+			total -= f.Size()
+		}
+	}
+}
+
+func detectType(header string, data []byte) string {
+	if mt, _, err := mime.ParseMediaType(header); err == nil && mt != "" && mt != "application/octet-stream" && mt != "binary/octet-stream" {
+		return mt
+	}
+	mt, _, _ := mime.ParseMediaType(http.DetectContentType(data))
+	return mt
+}
+
+func allowedType(kind Kind, contentType string) bool {
+	for _, t := range kind.Types {
+		if strings.EqualFold(t, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheKey(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,138 @@
+package assets
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var pdf = []byte("%PDF-1.4\n" + strings.Repeat("install sheet ", 100))
+
+func testProxy(t *testing.T, upstream *httptest.Server) *Proxy {
+	dir, err := ioutil.TempDir("", "assets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(upstream.URL)
+	return &Proxy{
+		Dir:       dir,
+		MaxBytes:  4096,
+		MaxObject: 2048,
+		TTL:       time.Hour,
+		Hosts:     []string{u.Hostname()},
+	}
+}
+
+func TestProxy(t *testing.T) {
+	var hits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		switch r.URL.Path {
+		case "/sheet.pdf":
+			w.Write(pdf)
+		case "/page.html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html></html>"))
+		case "/big.pdf":
+			w.Write(append(pdf, make([]byte, 4096)...))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer upstream.Close()
+
+	p := testProxy(t, upstream)
+	defer os.RemoveAll(p.Dir)
+
+	Convey("Testing Allowed()", t, func() {
+		a := &Proxy{Hosts: []string{"curtmfg.com"}}
+		u, _ := url.Parse("https://www.curtmfg.com/masterlibrary/13000.pdf")
+		So(a.Allowed(u), ShouldBeTrue)
+		u, _ = url.Parse("https://evilcurtmfg.com/13000.pdf")
+		So(a.Allowed(u), ShouldBeFalse)
+		u, _ = url.Parse("ftp://curtmfg.com/13000.pdf")
+		So(a.Allowed(u), ShouldBeFalse)
+	})
+
+	Convey("Testing Serve() caches and supports ranges", t, func() {
+		atomic.StoreInt32(&hits, 0)
+
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/part/13000.pdf", nil)
+		So(p.Serve(rec, req, upstream.URL+"/sheet.pdf", PDF), ShouldBeNil)
+		So(rec.Code, ShouldEqual, http.StatusOK)
+		So(rec.Header().Get("Content-Type"), ShouldEqual, "application/pdf")
+		So(rec.Body.Len(), ShouldEqual, len(pdf))
+		etag := rec.Header().Get("ETag")
+		So(etag, ShouldNotBeEmpty)
+
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/part/13000.pdf", nil)
+		req.Header.Set("Range", "bytes=0-3")
+		So(p.Serve(rec, req, upstream.URL+"/sheet.pdf", PDF), ShouldBeNil)
+		So(rec.Code, ShouldEqual, http.StatusPartialContent)
+		So(rec.Body.String(), ShouldEqual, "%PDF")
+
+		rec = httptest.NewRecorder()
+		req = httptest.NewRequest("GET", "/part/13000.pdf", nil)
+		req.Header.Set("If-None-Match", etag)
+		So(p.Serve(rec, req, upstream.URL+"/sheet.pdf", PDF), ShouldBeNil)
+		So(rec.Code, ShouldEqual, http.StatusNotModified)
+
+		So(atomic.LoadInt32(&hits), ShouldEqual, 1)
+	})
+
+	Convey("Testing openEntry() refetches an entry evicted before it's opened", t, func() {
+		evicted, err := p.Get(upstream.URL+"/sheet.pdf?v=evicted", PDF)
+		So(err, ShouldBeNil)
+
+		calls := 0
+		e, f, err := openEntry(func() (*Entry, error) {
+			calls++
+			if calls == 1 {
+				os.Remove(evicted.path)
+				return evicted, nil
+			}
+			return p.Get(upstream.URL+"/sheet.pdf?v=evicted", PDF)
+		})
+		So(err, ShouldBeNil)
+		defer f.Close()
+		So(calls, ShouldEqual, 2)
+		So(e.Size, ShouldEqual, len(pdf))
+		data, err := ioutil.ReadAll(f)
+		So(err, ShouldBeNil)
+		So(len(data), ShouldEqual, len(pdf))
+	})
+
+	Convey("Testing Serve() rejects bad assets", t, func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		So(p.Serve(rec, req, upstream.URL+"/page.html", PDF), ShouldEqual, ErrContentType)
+		So(p.Serve(rec, req, upstream.URL+"/big.pdf", PDF), ShouldEqual, ErrTooLarge)
+		So(p.Serve(rec, req, upstream.URL+"/missing.pdf", PDF), ShouldEqual, ErrUpstream)
+		So(p.Serve(rec, req, "https://example.com/sheet.pdf", PDF), ShouldEqual, ErrHostNotAllowed)
+	})
+
+	Convey("Testing the cache stays under MaxBytes", t, func() {
+		for i := 0; i < 6; i++ {
+			_, err := p.Get(upstream.URL+"/sheet.pdf?v="+string(rune('a'+i)), PDF)
+			So(err, ShouldBeNil)
+		}
+
+		files, _ := ioutil.ReadDir(p.Dir)
+		var total int64
+		for _, f := range files {
+			if !strings.Contains(f.Name(), ".") {
+				total += f.Size()
+			}
+		}
+		So(total, ShouldBeLessThanOrEqualTo, p.MaxBytes)
+	})
+}
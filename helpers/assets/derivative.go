@@ -0,0 +1,340 @@
+package assets
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+var (
+	ErrPreset = errors.New("unknown image preset")
+	ErrFormat = errors.New("unsupported image format")
+
+	// MaxPixels guards against decoding images that would use an
+	// unreasonable amount of memory, checked before the image is decoded.
+	MaxPixels = 50 * 1000 * 1000
+
+	// Presets are the named sizes derivatives can be requested in.
+	Presets = map[string]Preset{
+		"thumb": {Name: "thumb", Width: 100, Height: 100, Crop: true, Quality: 80},
+		"card":  {Name: "card", Width: 300, Height: 300, Quality: 85},
+		"zoom":  {Name: "zoom", Width: 1200, Height: 1200, Quality: 90},
+	}
+)
+
+// Preset is a named derivative size. Crop presets fill the box and trim the
+// overflow from the center, the rest fit inside the box. Images are never
+// enlarged.
+type Preset struct {
+	Name    string `json:"name"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Crop    bool   `json:"crop"`
+	Quality int    `json:"quality"`
+}
+
+// Encoder writes an image in one output format.
+type Encoder struct {
+	ContentType string
+	Encode      func(w io.Writer, img image.Image, quality int) error
+}
+
+var (
+	encoders = map[string]Encoder{
+		"jpeg": {
+			ContentType: "image/jpeg",
+			Encode: func(w io.Writer, img image.Image, quality int) error {
+				return jpeg.Encode(w, flatten(img), &jpeg.Options{Quality: quality})
+			},
+		},
+		"png": {
+			ContentType: "image/png",
+			Encode: func(w io.Writer, img image.Image, quality int) error {
+				return png.Encode(w, img)
+			},
+		},
+	}
+	encodersMu sync.RWMutex
+)
+
+// RegisterEncoder adds an output format. The standard library can't write
+// WebP, so it's only offered once an encoder has been registered for it.
+func RegisterEncoder(format string, e Encoder) {
+	encodersMu.Lock()
+	encoders[strings.ToLower(format)] = e
+	encodersMu.Unlock()
+}
+
+// Format picks the output format for a request. An empty or "auto" format
+// serves WebP to clients that accept it, when an encoder is registered, and
+// JPEG to everyone else.
+func Format(format, accept string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format == "jpg" {
+		format = "jpeg"
+	}
+
+	encodersMu.RLock()
+	defer encodersMu.RUnlock()
+
+	if format == "" || format == "auto" {
+		if _, ok := encoders["webp"]; ok && strings.Contains(accept, "image/webp") {
+			return "webp", nil
+		}
+		return "jpeg", nil
+	}
+	if _, ok := encoders[format]; !ok {
+		return "", ErrFormat
+	}
+	return format, nil
+}
+
+// ServeDerivative writes the preset derivative of the image at rawURL in
+// the given format, building it into the cache if needed.
+func (p *Proxy) ServeDerivative(w http.ResponseWriter, r *http.Request, rawURL, preset, format string) error {
+	w.Header().Add("Vary", "Accept")
+	return p.serve(w, r, func() (*Entry, error) {
+		return p.Derivative(rawURL, preset, format)
+	})
+}
+
+// Derivative returns the cached derivative of the image at rawURL. The
+// cache key includes the source's ETag, so a changed source image gets new
+// derivatives.
+func (p *Proxy) Derivative(rawURL, preset, format string) (*Entry, error) {
+	pr, ok := Presets[strings.ToLower(preset)]
+	if !ok {
+		return nil, ErrPreset
+	}
+	encodersMu.RLock()
+	enc, ok := encoders[format]
+	encodersMu.RUnlock()
+	if !ok {
+		return nil, ErrFormat
+	}
+
+	src, err := p.Get(rawURL, Image)
+	if err != nil {
+		return nil, err
+	}
+
+	key := cacheKey(src.URL + "|" + src.ETag + "|" + pr.Name + "|" + format)
+	if cached, _ := p.load(key); cached != nil {
+		return cached, nil
+	}
+
+	return p.once(key, func() (*Entry, error) {
+		return p.store(key, src.URL, func(tmp *os.File) (string, error) {
+			_, f, err := openEntry(func() (*Entry, error) {
+				return p.Get(rawURL, Image)
+			})
+			if err != nil {
+				return "", err
+			}
+			defer f.Close()
+
+			cfg, _, err := image.DecodeConfig(f)
+			if err != nil {
+				return "", ErrContentType
+			}
+			if cfg.Width*cfg.Height > MaxPixels {
+				return "", ErrTooLarge
+			}
+			if _, err = f.Seek(0, io.SeekStart); err != nil {
+				return "", err
+			}
+
+			release := p.acquireResize()
+			defer release()
+			img, _, err := image.Decode(f)
+			if err != nil {
+				return "", ErrContentType
+			}
+
+			if err = enc.Encode(tmp, pr.Apply(img), pr.Quality); err != nil {
+				return "", err
+			}
+			return enc.ContentType, nil
+		})
+	})
+}
+
+// acquireResize waits for one of the MaxResizes slots for building a
+// derivative and returns the func that frees it. A derivative holds the
+// decoded source and float buffers several times its size, so building too
+// many at once runs the API out of memory.
+func (p *Proxy) acquireResize() func() {
+	p.resizeOnce.Do(func() {
+		n := p.MaxResizes
+		if n <= 0 {
+			n = runtime.NumCPU()
+		}
+		p.resizes = make(chan struct{}, n)
+	})
+	p.resizes <- struct{}{}
+	return func() { <-p.resizes }
+}
+
+// Apply crops and scales the image to the preset.
+func (pr Preset) Apply(img image.Image) image.Image {
+	crop, w, h := pr.geometry(img.Bounds())
+	return Resize(img, crop, w, h)
+}
+
+// geometry works out the part of the source to use and the size to scale
+// it to.
+func (pr Preset) geometry(b image.Rectangle) (image.Rectangle, int, int) {
+	sw, sh := b.Dx(), b.Dy()
+	if sw == 0 || sh == 0 || pr.Width <= 0 || pr.Height <= 0 {
+		return b, sw, sh
+	}
+
+	if pr.Crop {
+		crop := b
+		// trim whichever side overflows the preset's aspect ratio
+		if sw*pr.Height > sh*pr.Width {
+			cw := sh * pr.Width / pr.Height
+			crop.Min.X += (sw - cw) / 2
+			crop.Max.X = crop.Min.X + cw
+		} else {
+			ch := sw * pr.Height / pr.Width
+			crop.Min.Y += (sh - ch) / 2
+			crop.Max.Y = crop.Min.Y + ch
+		}
+		if crop.Dx() <= pr.Width {
+			return crop, crop.Dx(), crop.Dy()
+		}
+		return crop, pr.Width, pr.Height
+	}
+
+	scale := math.Min(float64(pr.Width)/float64(sw), float64(pr.Height)/float64(sh))
+	if scale >= 1 {
+		return b, sw, sh
+	}
+	w := int(math.Max(1, math.Floor(float64(sw)*scale+0.5)))
+	h := int(math.Max(1, math.Floor(float64(sh)*scale+0.5)))
+	return b, w, h
+}
+
+// Resize scales the src rectangle of img to w by h. Each output pixel is the
+// area weighted average of the source pixels it covers, which holds up well
+// for the large reductions thumbnails need.
+func Resize(img image.Image, src image.Rectangle, w, h int) *image.RGBA {
+	in := image.NewRGBA(image.Rect(0, 0, src.Dx(), src.Dy()))
+	draw.Draw(in, in.Bounds(), img, src.Min, draw.Src)
+
+	out := image.NewRGBA(image.Rect(0, 0, w, h))
+	if w == 0 || h == 0 || in.Bounds().Empty() {
+		return out
+	}
+
+	xs := weights(w, src.Dx())
+	ys := weights(h, src.Dy())
+
+	// horizontal pass into a float buffer, then vertical into the output
+	rows := make([]float64, w*src.Dy()*4)
+	for y := 0; y < src.Dy(); y++ {
+		line := in.Pix[y*in.Stride:]
+		for x, c := range xs {
+			var px [4]float64
+			for i, wt := range c.weights {
+				off := (c.start + i) * 4
+				for k := 0; k < 4; k++ {
+					px[k] += float64(line[off+k]) * wt
+				}
+			}
+			copy(rows[(y*w+x)*4:], px[:])
+		}
+	}
+
+	for y, c := range ys {
+		for x := 0; x < w; x++ {
+			var px [4]float64
+			for i, wt := range c.weights {
+				off := ((c.start+i)*w + x) * 4
+				for k := 0; k < 4; k++ {
+					px[k] += rows[off+k] * wt
+				}
+			}
+			off := y*out.Stride + x*4
+			for k := 0; k < 4; k++ {
+				out.Pix[off+k] = clamp(px[k])
+			}
+		}
+	}
+
+	return out
+}
+
+type contribution struct {
+	start   int
+	weights []float64
+}
+
+// weights maps each of the dst pixels onto the span of src pixels it covers,
+// weighting the pixels on either end by how much of them falls in the span.
+func weights(dst, src int) []contribution {
+	scale := float64(src) / float64(dst)
+	cs := make([]contribution, dst)
+	for i := range cs {
+		lo := float64(i) * scale
+		hi := lo + scale
+		start := int(lo)
+		end := int(math.Ceil(hi))
+		if end > src {
+			end = src
+		}
+		if end <= start {
+			end = start + 1
+		}
+
+		c := contribution{
+			start:   start,
+			weights: make([]float64, end-start),
+		}
+		var total float64
+		for j := start; j < end; j++ {
+			wt := math.Min(hi, float64(j+1)) - math.Max(lo, float64(j))
+			c.weights[j-start] = wt
+			total += wt
+		}
+		for j := range c.weights {
+			c.weights[j] /= total
+		}
+		cs[i] = c
+	}
+	return cs
+}
+
+func clamp(f float64) uint8 {
+	switch {
+	case f <= 0:
+		return 0
+	case f >= 255:
+		return 255
+	}
+	return uint8(f + 0.5)
+}
+
+// flatten puts transparent images on a white background, JPEG would
+// otherwise turn the transparent parts black.
+func flatten(img image.Image) image.Image {
+	if o, ok := img.(interface{ Opaque() bool }); ok && o.Opaque() {
+		return img
+	}
+	out := image.NewRGBA(img.Bounds())
+	draw.Draw(out, out.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(out, out.Bounds(), img, img.Bounds().Min, draw.Over)
+	return out
+}
@@ -0,0 +1,162 @@
+package assets
+
+import (
+	"bytes"
+	. "github.com/smartystreets/goconvey/convey"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			// left half red, right half blue
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestResize(t *testing.T) {
+	Convey("Testing Resize()", t, func() {
+		src := testImage(400, 200)
+		out := Resize(src, src.Bounds(), 40, 20)
+		So(out.Bounds().Dx(), ShouldEqual, 40)
+		So(out.Bounds().Dy(), ShouldEqual, 20)
+		So(out.RGBAAt(5, 10), ShouldResemble, color.RGBA{R: 255, A: 255})
+		So(out.RGBAAt(35, 10), ShouldResemble, color.RGBA{B: 255, A: 255})
+
+		// the middle column straddles both halves
+		out = Resize(src, src.Bounds(), 3, 1)
+		mid := out.RGBAAt(1, 0)
+		So(mid.R, ShouldBeBetween, 100, 155)
+		So(mid.B, ShouldBeBetween, 100, 155)
+	})
+
+	Convey("Testing Preset.Apply()", t, func() {
+		src := testImage(400, 200)
+
+		thumb := Presets["thumb"].Apply(src)
+		So(thumb.Bounds().Dx(), ShouldEqual, 100)
+		So(thumb.Bounds().Dy(), ShouldEqual, 100)
+
+		card := Presets["card"].Apply(src)
+		So(card.Bounds().Dx(), ShouldEqual, 300)
+		So(card.Bounds().Dy(), ShouldEqual, 150)
+
+		zoom := Presets["zoom"].Apply(src)
+		So(zoom.Bounds().Dx(), ShouldEqual, 400)
+		So(zoom.Bounds().Dy(), ShouldEqual, 200)
+
+		small := Presets["thumb"].Apply(testImage(60, 30))
+		So(small.Bounds().Dx(), ShouldEqual, 30)
+		So(small.Bounds().Dy(), ShouldEqual, 30)
+	})
+
+	Convey("Testing Format()", t, func() {
+		f, err := Format("", "image/webp,image/*")
+		So(err, ShouldBeNil)
+		So(f, ShouldEqual, "jpeg")
+		f, err = Format("PNG", "")
+		So(err, ShouldBeNil)
+		So(f, ShouldEqual, "png")
+		f, err = Format("jpg", "")
+		So(err, ShouldBeNil)
+		So(f, ShouldEqual, "jpeg")
+		_, err = Format("bmp", "")
+		So(err, ShouldEqual, ErrFormat)
+	})
+}
+
+func TestDerivative(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(800, 400)); err != nil {
+		t.Fatal(err)
+	}
+
+	var hits int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		w.Write(buf.Bytes())
+	}))
+	defer upstream.Close()
+
+	p := testProxy(t, upstream)
+	p.MaxBytes = 1 << 20
+	p.MaxObject = 1 << 20
+	defer os.RemoveAll(p.Dir)
+
+	Convey("Testing ServeDerivative()", t, func() {
+		rec := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/", nil)
+		So(p.ServeDerivative(rec, req, upstream.URL+"/13000.png", "thumb", "jpeg"), ShouldBeNil)
+		So(rec.Code, ShouldEqual, http.StatusOK)
+		So(rec.Header().Get("Content-Type"), ShouldEqual, "image/jpeg")
+		img, err := jpeg.Decode(rec.Body)
+		So(err, ShouldBeNil)
+		So(img.Bounds().Dx(), ShouldEqual, 100)
+		So(img.Bounds().Dy(), ShouldEqual, 100)
+
+		first, err := p.Derivative(upstream.URL+"/13000.png", "thumb", "jpeg")
+		So(err, ShouldBeNil)
+		second, err := p.Derivative(upstream.URL+"/13000.png", "thumb", "jpeg")
+		So(err, ShouldBeNil)
+		So(second.ETag, ShouldEqual, first.ETag)
+		So(second.Fetched, ShouldEqual, first.Fetched)
+
+		card, err := p.Derivative(upstream.URL+"/13000.png", "card", "png")
+		So(err, ShouldBeNil)
+		So(card.ContentType, ShouldEqual, "image/png")
+		So(card.ETag, ShouldNotEqual, first.ETag)
+
+		So(atomic.LoadInt32(&hits), ShouldEqual, 1)
+	})
+
+	Convey("Testing Derivative() waits for a resize slot", t, func() {
+		limited := testProxy(t, upstream)
+		limited.MaxBytes = 1 << 20
+		limited.MaxObject = 1 << 20
+		limited.MaxResizes = 1
+		defer os.RemoveAll(limited.Dir)
+
+		release := limited.acquireResize()
+		done := make(chan error)
+		go func() {
+			_, err := limited.Derivative(upstream.URL+"/13000.png", "card", "jpeg")
+			done <- err
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("derivative built without a resize slot")
+		case <-time.After(50 * time.Millisecond):
+		}
+		release()
+		So(<-done, ShouldBeNil)
+	})
+
+	Convey("Testing Derivative() errors", t, func() {
+		_, err := p.Derivative(upstream.URL+"/13000.png", "huge", "jpeg")
+		So(err, ShouldEqual, ErrPreset)
+		_, err = p.Derivative(upstream.URL+"/13000.png", "thumb", "bmp")
+		So(err, ShouldEqual, ErrFormat)
+
+		MaxPixels = 1000
+		defer func() { MaxPixels = 50 * 1000 * 1000 }()
+		_, err = p.Derivative(upstream.URL+"/13000.png", "zoom", "jpeg")
+		So(err, ShouldEqual, ErrTooLarge)
+	})
+}
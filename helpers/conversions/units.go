@@ -0,0 +1,97 @@
+package conversions
+
+import (
+	"strings"
+)
+
+var (
+	// lengthUnits maps the unit labels we see in package and attribute
+	// data to their length in inches.
+	lengthUnits = map[string]float64{
+		"in":          1,
+		"in.":         1,
+		"inch":        1,
+		"inches":      1,
+		"\"":          1,
+		"ft":          12,
+		"ft.":         12,
+		"foot":        12,
+		"feet":        12,
+		"'":           12,
+		"cm":          1 / 2.54,
+		"centimeter":  1 / 2.54,
+		"centimeters": 1 / 2.54,
+		"mm":          1 / 25.4,
+		"millimeter":  1 / 25.4,
+		"millimeters": 1 / 25.4,
+		"m":           100 / 2.54,
+		"meter":       100 / 2.54,
+		"meters":      100 / 2.54,
+	}
+
+	// weightUnits maps weight unit labels to their weight in pounds.
+	weightUnits = map[string]float64{
+		"lb":        1,
+		"lbs":       1,
+		"lbs.":      1,
+		"pound":     1,
+		"pounds":    1,
+		"#":         1,
+		"oz":        1.0 / 16,
+		"ounce":     1.0 / 16,
+		"ounces":    1.0 / 16,
+		"kg":        2.20462,
+		"kgs":       2.20462,
+		"kilogram":  2.20462,
+		"kilograms": 2.20462,
+		"g":         0.00220462,
+		"gram":      0.00220462,
+		"grams":     0.00220462,
+	}
+)
+
+// ToInches converts a length in the given unit to inches. The second
+// return value is false when the unit isn't recognized. An empty unit is
+// assumed to already be in inches.
+func ToInches(value float64, unit string) (float64, bool) {
+	unit = normalizeUnit(unit)
+	if unit == "" {
+		return value, true
+	}
+	factor, ok := lengthUnits[unit]
+	if !ok {
+		return value, false
+	}
+	return value * factor, true
+}
+
+// ToPounds converts a weight in the given unit to pounds. The second
+// return value is false when the unit isn't recognized. An empty unit is
+// assumed to already be in pounds.
+func ToPounds(value float64, unit string) (float64, bool) {
+	unit = normalizeUnit(unit)
+	if unit == "" {
+		return value, true
+	}
+	factor, ok := weightUnits[unit]
+	if !ok {
+		return value, false
+	}
+	return value * factor, true
+}
+
+// IsLengthUnit reports whether unit is a known length unit.
+func IsLengthUnit(unit string) bool {
+	_, ok := lengthUnits[normalizeUnit(unit)]
+	return ok
+}
+
+// IsWeightUnit reports whether unit is a known weight unit.
+func IsWeightUnit(unit string) bool {
+	_, ok := weightUnits[normalizeUnit(unit)]
+	return ok
+}
+
+func normalizeUnit(unit string) string {
+	return strings.ToLower(strings.TrimSpace(unit))
+}
@@ -28,12 +28,16 @@ type Scanner interface {
 var (
 	EmptyDb = flag.String("clean", "", "bind empty database with structure defined")
 
-	ProductCollectionName  = "products"
-	CategoryCollectionName = "categories"
-	CustomerCollectionName = "customer"
-	ProductDatabase        = "product_data"
-	CategoryDatabase       = "category_data"
-	AriesDatabase          = "aries"
+	ProductCollectionName        = "products"
+	CategoryCollectionName       = "categories"
+	CustomerCollectionName       = "customer"
+	InventoryCollectionName      = "inventory"
+	ReviewCollectionName         = "reviews"
+	RecommendationCollectionName = "recommendations"
+	GarageCollectionName         = "garage"
+	ProductDatabase              = "product_data"
+	CategoryDatabase             = "category_data"
+	AriesDatabase                = "aries"
 
 	MongoDatabase        string
 	ProductMongoDatabase string
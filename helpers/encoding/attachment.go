@@ -0,0 +1,30 @@
+package encoding
+
+import (
+	"net/http"
+)
+
+// Attachment is a ResponseWriter for a file that's streamed as a download.
+// Its headers are only set by the first write, so an error before anything
+// is written can still be sent as an error response.
+type Attachment struct {
+	http.ResponseWriter
+	ContentType string
+	Filename    string
+
+	started bool
+}
+
+func (a *Attachment) Write(p []byte) (int, error) {
+	if !a.started {
+		a.started = true
+		a.Header().Set("Content-Type", a.ContentType)
+		a.Header().Set("Content-Disposition", "attachment;filename="+a.Filename)
+	}
+	return a.ResponseWriter.Write(p)
+}
+
+// Started reports whether any of the file has been written.
+func (a *Attachment) Started() bool {
+	return a.started
+}
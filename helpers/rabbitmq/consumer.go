@@ -2,10 +2,27 @@ package rabbitmq
 
 import (
 	"errors"
+	"log"
+	"time"
 
 	"github.com/streadway/amqp"
 )
 
+// DeadLetterSuffix is added to the name of a queue to get the queue the
+// messages that can never be handled are moved to.
+const DeadLetterSuffix = ".dead"
+
+var (
+	// MinBackoff and MaxBackoff bound the wait of Consume between
+	// reconnects. The wait doubles with each failed connection.
+	MinBackoff = time.Second
+	MaxBackoff = time.Minute
+
+	// RetryDelay is how long a consumer waits before putting a message that
+	// failed back on the queue, so an outage isn't retried in a tight loop.
+	RetryDelay = 5 * time.Second
+)
+
 type Consumer struct {
 	conn     *amqp.Connection
 	channel  *amqp.Channel
@@ -14,6 +31,7 @@ type Consumer struct {
 	Exchange Exchange
 
 	incomingMessages <-chan amqp.Delivery
+	deadLetter       func(msg *amqp.Delivery) error
 	DoneChan         chan error
 }
 
@@ -27,32 +45,76 @@ func (h HandlerFunc) HandleMessage(m *amqp.Delivery) error {
 	return h(m)
 }
 
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+// Permanent marks the error of a message that would fail the same way every
+// time it's handled, like a bad payload. The message is moved to the dead
+// letter queue instead of being retried.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err}
+}
+
+// IsPermanent reports whether the error was marked with Permanent.
+func IsPermanent(err error) bool {
+	_, ok := err.(permanentError)
+	return ok
+}
+
 func (c *Consumer) AddHandler(handler Handler) {
 	go c.handlerLoop(handler)
 }
 
+// handlerLoop acks the messages the handler handles. Messages that fail
+// with a Permanent error are moved to the dead letter queue, the rest are
+// put back on the queue after RetryDelay.
 func (c *Consumer) handlerLoop(handler Handler) {
 	for msg := range c.incomingMessages {
-		handler.HandleMessage(&msg)
-		msg.Ack(false)
+		err := handler.HandleMessage(&msg)
+		switch {
+		case err == nil:
+			msg.Ack(false)
+		case IsPermanent(err):
+			log.Printf("rabbitmq consumer %s can't handle message %s, moving it to the dead letter queue: %v", c.Name, msg.MessageId, err)
+			if c.deadLetter == nil {
+				msg.Nack(false, false)
+			} else if derr := c.deadLetter(&msg); derr != nil {
+				log.Printf("rabbitmq consumer %s failed to dead letter message %s: %v", c.Name, msg.MessageId, derr)
+				msg.Nack(false, true)
+			} else {
+				msg.Ack(false)
+			}
+		default:
+			log.Printf("rabbitmq consumer %s failed to handle message %s, retrying in %s: %v", c.Name, msg.MessageId, RetryDelay, err)
+			time.Sleep(RetryDelay)
+			msg.Nack(false, true)
+		}
 	}
 	c.DoneChan <- nil
 }
 
+// Close cancels the consumer and closes its connection, even when the
+// channel is already gone.
 func (c *Consumer) Close() error {
 	var err error
 	if c.channel != nil {
-		if err = c.channel.Cancel(c.Name, true); err != nil {
-			return err
-		}
+		err = c.channel.Cancel(c.Name, true)
 	}
 	if c.conn != nil {
-		if err = c.conn.Close(); err != nil {
-			return err
+		if cerr := c.conn.Close(); err == nil {
+			err = cerr
 		}
 	}
 
-	return nil
+	return err
 }
 
 func NewConsumer(consumerName string, queueName string, exchange Exchange, config *Config) (consumer *Consumer, err error) {
@@ -81,6 +143,11 @@ func NewConsumer(consumerName string, queueName string, exchange Exchange, confi
 	if err != nil {
 		return
 	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
 
 	//setup the channel
 	var channel *amqp.Channel
@@ -113,6 +180,17 @@ func NewConsumer(consumerName string, queueName string, exchange Exchange, confi
 	); err != nil {
 		return
 	}
+	var dead amqp.Queue
+	if dead, err = channel.QueueDeclare(
+		queueName+DeadLetterSuffix, // name of the queue
+		true,                       // durable
+		false,                      // delete when usused
+		false,                      // exclusive
+		false,                      // noWait
+		nil,                        // arguments
+	); err != nil {
+		return
+	}
 	if err = channel.QueueBind(
 		queue.Name,          //queue name
 		exchange.RoutingKey, //routing key ("binding key")
@@ -145,6 +223,61 @@ func NewConsumer(consumerName string, queueName string, exchange Exchange, confi
 	consumer.channel = channel
 	consumer.Exchange = exchange
 	consumer.incomingMessages = messages
+	consumer.deadLetter = func(msg *amqp.Delivery) error {
+		return channel.Publish(
+			"",        //default exchange
+			dead.Name, //routing key
+			false,     //mandatory?
+			false,     //immediate?
+			amqp.Publishing{
+				Headers:         msg.Headers,
+				ContentType:     msg.ContentType,
+				ContentEncoding: msg.ContentEncoding,
+				MessageId:       msg.MessageId,
+				Timestamp:       msg.Timestamp,
+				Body:            msg.Body,
+				DeliveryMode:    amqp.Persistent,
+			},
+		)
+	}
 
 	return
 }
+
+// Consume handles the messages of the queue for as long as the process
+// runs. When the connection fails or drops, it connects again after a
+// backoff.
+func Consume(consumerName string, queueName string, exchange Exchange, config *Config, handler Handler) {
+	consume(func() (*Consumer, error) {
+		return NewConsumer(consumerName, queueName, exchange, config)
+	}, handler, nil)
+}
+
+// consume connects and handles messages until stop is closed, waiting
+// between connections from MinBackoff up to MaxBackoff. The backoff starts
+// over once a connection is made.
+func consume(connect func() (*Consumer, error), handler Handler, stop <-chan struct{}) {
+	backoff := MinBackoff
+	for {
+		consumer, err := connect()
+		if err != nil {
+			log.Printf("rabbitmq consumer failed to connect, retrying in %s: %v", backoff, err)
+		} else {
+			backoff = MinBackoff
+			consumer.DoneChan = make(chan error, 1)
+			consumer.AddHandler(handler)
+			<-consumer.DoneChan
+			consumer.Close()
+			log.Printf("rabbitmq consumer %s lost its connection, reconnecting in %s", consumer.Name, backoff)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > MaxBackoff {
+			backoff = MaxBackoff
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package rabbitmq
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	"github.com/streadway/amqp"
+)
+
+func TestConsume(t *testing.T) {
+	oldMin, oldMax := MinBackoff, MaxBackoff
+	MinBackoff, MaxBackoff = time.Millisecond, 4*time.Millisecond
+	defer func() { MinBackoff, MaxBackoff = oldMin, oldMax }()
+
+	Convey("Testing consume()", t, func() {
+		stop := make(chan struct{})
+		handled := make(chan string, 2)
+		var attempts []time.Time
+
+		connect := func() (*Consumer, error) {
+			attempts = append(attempts, time.Now())
+			switch len(attempts) {
+			case 1, 2, 3:
+				return nil, errors.New("connection refused")
+			case 4, 5:
+				msgs := make(chan amqp.Delivery, 1)
+				msgs <- amqp.Delivery{Body: []byte("inventory")}
+				close(msgs)
+				return &Consumer{Name: "test", incomingMessages: msgs}, nil
+			}
+			close(stop)
+			return nil, errors.New("connection refused")
+		}
+		handler := HandlerFunc(func(msg *amqp.Delivery) error {
+			handled <- string(msg.Body)
+			return nil
+		})
+
+		done := make(chan struct{})
+		go func() {
+			consume(connect, handler, stop)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("consume didn't stop")
+		}
+
+		So(len(attempts), ShouldEqual, 6)
+		So(len(handled), ShouldEqual, 2)
+		So(attempts[3].Sub(attempts[2]), ShouldBeGreaterThanOrEqualTo, 4*time.Millisecond)
+	})
+}
+
+// acks records what was done with each delivery, by delivery tag.
+type acks map[uint64]string
+
+func (a acks) Ack(tag uint64, multiple bool) error {
+	a[tag] = "ack"
+	return nil
+}
+
+func (a acks) Nack(tag uint64, multiple bool, requeue bool) error {
+	if requeue {
+		a[tag] = "requeue"
+	} else {
+		a[tag] = "reject"
+	}
+	return nil
+}
+
+func (a acks) Reject(tag uint64, requeue bool) error {
+	return a.Nack(tag, false, requeue)
+}
+
+func TestHandlerLoop(t *testing.T) {
+	oldDelay := RetryDelay
+	RetryDelay = time.Millisecond
+	defer func() { RetryDelay = oldDelay }()
+
+	Convey("Testing handlerLoop()", t, func() {
+		a := acks{}
+		msgs := make(chan amqp.Delivery, 3)
+		msgs <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1, Body: []byte("ok")}
+		msgs <- amqp.Delivery{Acknowledger: a, DeliveryTag: 2, Body: []byte("down")}
+		msgs <- amqp.Delivery{Acknowledger: a, DeliveryTag: 3, Body: []byte("bad")}
+		close(msgs)
+
+		var dead []string
+		c := &Consumer{
+			Name:             "test",
+			incomingMessages: msgs,
+			deadLetter: func(msg *amqp.Delivery) error {
+				dead = append(dead, string(msg.Body))
+				return nil
+			},
+			DoneChan: make(chan error, 1),
+		}
+		c.handlerLoop(HandlerFunc(func(msg *amqp.Delivery) error {
+			switch string(msg.Body) {
+			case "down":
+				return errors.New("no reachable servers")
+			case "bad":
+				return Permanent(errors.New("invalid character"))
+			}
+			return nil
+		}))
+
+		So(a, ShouldResemble, acks{1: "ack", 2: "requeue", 3: "ack"})
+		So(dead, ShouldResemble, []string{"bad"})
+
+		Convey("without a dead letter queue", func() {
+			a := acks{}
+			msgs := make(chan amqp.Delivery, 1)
+			msgs <- amqp.Delivery{Acknowledger: a, DeliveryTag: 1}
+			close(msgs)
+			c := &Consumer{Name: "test", incomingMessages: msgs, DoneChan: make(chan error, 1)}
+			c.handlerLoop(HandlerFunc(func(msg *amqp.Delivery) error {
+				return Permanent(errors.New("invalid character"))
+			}))
+			So(a, ShouldResemble, acks{1: "reject"})
+		})
+	})
+}
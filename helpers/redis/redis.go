@@ -228,3 +228,35 @@ func GetFullPath(key string) ([]string, error) {
 	}
 	return data, err
 }
+
+// Scan returns the full keys matching the pattern. Unlike KEYS it walks the
+// keyspace in batches, so it doesn't block redis while it runs.
+func Scan(match string) ([]string, error) {
+	data := make([]string, 0)
+	pool := RedisPool(false)
+	if pool == nil {
+		return data, errors.New(PoolAllocationErr)
+	}
+
+	conn, err := pool.Dial()
+	if err != nil {
+		return data, err
+	}
+	defer conn.Close()
+
+	cursor := 0
+	for {
+		values, err := redix.Values(conn.Do("SCAN", cursor, "MATCH", match, "COUNT", 1000))
+		if err != nil {
+			return data, err
+		}
+		var keys []string
+		if _, err = redix.Scan(values, &cursor, &keys); err != nil {
+			return data, err
+		}
+		data = append(data, keys...)
+		if cursor == 0 {
+			return data, nil
+		}
+	}
+}
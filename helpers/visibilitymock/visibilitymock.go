@@ -0,0 +1,121 @@
+// Package visibilitymock stores a part of each web visibility, in one
+// category and fitting one vehicle, for testing that every lookup only
+// returns the parts a key can see.
+package visibilitymock
+
+import (
+	"strconv"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/models/products"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// CategoryID is the category the parts are in.
+	CategoryID = 99900
+
+	// Year, Make and Model are the vehicle the parts fit.
+	Year  = "1901"
+	Make  = "Visibility"
+	Model = "Test"
+)
+
+var (
+	// Parts are a part of each web visibility, by ID.
+	Parts = map[int]string{
+		99901: products.PUBLIC,
+		99902: products.LOGGEDIN,
+		99903: products.DISABLED,
+	}
+
+	// Keys are the keys the parts are requested with, and the parts each
+	// of them sees.
+	Keys = []Key{
+		{&apicontext.DataContext{APIKey: "public", KeyType: "Public", BrandID: 1, BrandArray: []int{1}}, []int{99901}},
+		{&apicontext.DataContext{APIKey: "private", KeyType: "Private", BrandID: 1, BrandArray: []int{1}}, []int{99901}},
+		{&apicontext.DataContext{APIKey: "auth", KeyType: "Authentication", UserID: "user", BrandID: 1, BrandArray: []int{1}}, []int{99901, 99902}},
+	}
+)
+
+// Key is a key the parts are requested with and the IDs of the parts it
+// sees, in order.
+type Key struct {
+	DataContext *apicontext.DataContext
+	Visible     []int
+}
+
+// Sees reports whether the key sees the part.
+func (k Key) Sees(id int) bool {
+	for _, v := range k.Visible {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Mock stores the category and the parts, replacing any a previous run
+// left behind.
+func Mock() error {
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+	db := session.DB(database.ProductDatabase)
+
+	_, err := db.C(database.CategoryCollectionName).Upsert(bson.M{"id": CategoryID}, bson.M{
+		"id":        CategoryID,
+		"parent_id": 0,
+		"title":     "Visibility Test",
+		"brand":     bson.M{"id": 1},
+		"children":  []bson.M{},
+		"isdeleted": false,
+	})
+	if err != nil {
+		return err
+	}
+
+	col := db.C(database.ProductCollectionName)
+	for id, visibility := range Parts {
+		_, err = col.Upsert(bson.M{"id": id}, bson.M{
+			"id":                id,
+			"part_number":       strconv.Itoa(id),
+			"status":            800,
+			"short_description": "visibility test part",
+			"brand":             bson.M{"id": 1},
+			"web_visibility":    visibility,
+			"categories": []bson.M{
+				{"id": CategoryID, "parent_id": 0, "title": "Visibility Test"},
+			},
+			"vehicle_applications": []bson.M{
+				{"year": Year, "make": Make, "model": Model, "style": ""},
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeMock removes the category and the parts.
+func DeMock() error {
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+	db := session.DB(database.ProductDatabase)
+
+	ids := make([]int, 0, len(Parts))
+	for id := range Parts {
+		ids = append(ids, id)
+	}
+	if _, err := db.C(database.ProductCollectionName).RemoveAll(bson.M{"id": bson.M{"$in": ids}}); err != nil {
+		return err
+	}
+	return db.C(database.CategoryCollectionName).Remove(bson.M{"id": CategoryID})
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/curt-labs/API/controllers/acesFile"
 	"github.com/curt-labs/API/controllers/apiKeyType"
 	"github.com/curt-labs/API/controllers/applicationGuide"
+	"github.com/curt-labs/API/controllers/assets"
 	"github.com/curt-labs/API/controllers/brand"
 	"github.com/curt-labs/API/controllers/cache"
 	"github.com/curt-labs/API/controllers/cartIntegration"
@@ -19,17 +20,30 @@ import (
 	"github.com/curt-labs/API/controllers/middleware"
 	"github.com/curt-labs/API/controllers/news"
 	"github.com/curt-labs/API/controllers/part"
+	"github.com/curt-labs/API/controllers/pies"
+	"github.com/curt-labs/API/controllers/report"
 	"github.com/curt-labs/API/controllers/search"
+	"github.com/curt-labs/API/controllers/shipping"
 	"github.com/curt-labs/API/controllers/site"
 	"github.com/curt-labs/API/controllers/testimonials"
 	"github.com/curt-labs/API/controllers/vehicle"
 	"github.com/curt-labs/API/controllers/videos"
+	"github.com/curt-labs/API/controllers/vinLookup"
 	"github.com/curt-labs/API/helpers/encoding"
+	"github.com/curt-labs/API/models/coverage"
+	"github.com/curt-labs/API/models/products"
+	"github.com/curt-labs/API/models/quality"
+	"github.com/curt-labs/API/models/search"
+	"github.com/curt-labs/API/models/shipping"
+	vinDecoder "github.com/curt-labs/API/models/vinLookup"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/cors"
 	// "github.com/martini-contrib/gzip"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/martini-contrib/sessions"
@@ -47,6 +61,63 @@ var (
 func main() {
 	flag.Parse()
 
+	if queue := os.Getenv("INVENTORY_FEED_QUEUE"); queue != "" {
+		go products.ConsumeInventoryFeed(queue, os.Getenv("INVENTORY_FEED_EXCHANGE"))
+	}
+
+	if table := os.Getenv("SHIPPING_RATE_TABLE"); table != "" {
+		if err := shipping.LoadRateTableFile(table); err != nil {
+			log.Printf("failed to load shipping rate table %s: %v", table, err)
+		}
+	}
+
+	if rules := os.Getenv("DATA_QUALITY_RULES"); rules != "" {
+		if err := quality.LoadConfigFile(rules); err != nil {
+			log.Printf("failed to load data quality rules %s: %v", rules, err)
+		}
+	}
+
+	if os.Getenv("VIN_DECODER") == "polk" {
+		vinDecoder.Provider = vinDecoder.PolkDecoder{}
+	} else if patterns := os.Getenv("VIN_PATTERNS"); patterns != "" {
+		if err := vinDecoder.LoadPatternFile(patterns); err != nil {
+			log.Printf("failed to load VIN patterns %s: %v", patterns, err)
+		}
+	}
+
+	if aliases := os.Getenv("VEHICLE_ALIASES"); aliases != "" {
+		if err := products.LoadAliasFile(aliases); err != nil {
+			log.Printf("failed to load vehicle aliases %s: %v", aliases, err)
+		}
+	}
+
+	if os.Getenv("SEARCH_BACKEND") == "memory" {
+		search.Backend = search.NewMemoryBackend()
+	}
+	if err := search.LoadBrandIndexes(); err != nil {
+		log.Printf("failed to load search indexes of the brands, searching every brand: %v", err)
+	}
+
+	go func() {
+		if err := search.RefreshCompletions(); err != nil {
+			log.Printf("failed to load search completions: %v", err)
+		}
+	}()
+
+	if weights := os.Getenv("COVERAGE_WEIGHTS"); weights != "" {
+		if err := coverage.LoadWeightsFile(weights); err != nil {
+			log.Printf("failed to load coverage weights %s: %v", weights, err)
+		}
+	}
+
+	if brands := os.Getenv("COVERAGE_BRANDS"); brands != "" {
+		go refreshCoverageReport(brands)
+	}
+
+	if brands := os.Getenv("LOOKUP_WARM_BRANDS"); brands != "" {
+		go warmLookupCache(brands)
+	}
+
 	m := martini.Classic()
 	// gorelic.InitNewrelicAgent("5fbc49f51bd658d47b4d5517f7a9cb407099c08c", "API", false)
 	// m.Use(gorelic.Handler)
@@ -66,6 +137,11 @@ func main() {
 
 	m.Group("/aces", func(r martini.Router) {
 		r.Get("/:version", acesFile.GetAcesFile)
+		r.Get("/:version/generate", acesFile.GenerateAcesFile)
+	})
+
+	m.Group("/pies", func(r martini.Router) {
+		r.Get("", pies_ctlr.GetPiesFile)
 	})
 
 	m.Group("/apiKeyTypes", func(r martini.Router) {
@@ -80,6 +156,15 @@ func main() {
 		r.Post("", Deprecated)
 	})
 
+	m.Group("/assets", func(r martini.Router) {
+		r.Get("/part/:part/image/:image", asset_ctlr.PartImage)
+		r.Get("/part/:part/image/:image/:preset", asset_ctlr.PartImageDerivative)
+		r.Get("/category/:id/image", asset_ctlr.CategoryImage)
+		r.Get("/category/:id/image/:preset", asset_ctlr.CategoryImageDerivative)
+		r.Get("/category/:id/pdf", asset_ctlr.CategoryPDF)
+		r.Get("/category/:id/xls", asset_ctlr.CategoryXLS)
+	})
+
 	//Creating, updating, and deleting all Blog related objects are handled in GoAdmin directly
 	m.Group("/blogs", func(r martini.Router) {
 		r.Get("", Deprecated)            //sort on any field e.g. ?sort=Name&direction=descending
@@ -224,6 +309,14 @@ func main() {
 		r.Delete("/deleteKey", customer_ctlr.DeleteUserApiKey)
 		r.Post("/generateKey/user/:id/key/:type", customer_ctlr.GenerateApiKey)
 		r.Get("/user/:id", customer_ctlr.GetUserById)
+
+		// Saved vehicles of the user of the key, these can't live under
+		// /customer/user since that path skips the data context
+		r.Get("/garage", customer_ctlr.GetGarage)
+		r.Post("/garage", customer_ctlr.AddGarageVehicle)
+		r.Get("/garage/:id", customer_ctlr.GetGarageVehicle)
+		r.Put("/garage/:id", customer_ctlr.UpdateGarageVehicle)
+		r.Delete("/garage/:id", customer_ctlr.DeleteGarageVehicle)
 		//r.Post("/user/:id", customer_ctlr.UpdateCustomerUser)
 		//r.Delete("/user/:id", customer_ctlr.DeleteCustomerUser)
 		// August 16th, 2017
@@ -357,17 +450,26 @@ func main() {
 	m.Group("/part", func(r martini.Router) {
 		r.Get("/featured", part_ctlr.Featured)
 		r.Get("/latest", part_ctlr.Latest)
+		r.Get("/compare", part_ctlr.Compare)
 		r.Post("/multi", part_ctlr.GetMulti) //Actually a GET request, because of some "max length" myth
+		r.Post("/inventory", middleware.InternalKeyAuthentication, part_ctlr.ImportInventory)
+		r.Get("/reviews/queue", middleware.InternalKeyAuthentication, part_ctlr.ReviewQueue)
+		r.Put("/reviews/:id/approve", middleware.InternalKeyAuthentication, part_ctlr.ApproveReview)
+		r.Put("/reviews/:id/reject", middleware.InternalKeyAuthentication, part_ctlr.RejectReview)
+		r.Delete("/reviews/:id", middleware.InternalKeyAuthentication, part_ctlr.DeleteReview)
 		r.Get("/:part/vehicles", part_ctlr.Vehicles)
 		r.Get("/:part/attributes", part_ctlr.Attributes)
 		r.Get("/:part/reviews", part_ctlr.ActiveApprovedReviews)
+		r.Post("/:part/reviews", part_ctlr.SubmitReview)
 		r.Get("/:part/categories", part_ctlr.Categories)
 		r.Get("/:part/content", part_ctlr.GetContent)
 		r.Get("/:part/images", part_ctlr.Images)
+		r.Get("/:part/inventory", part_ctlr.Inventory)
 		r.Get("/:part((.*?)\\.(PDF|pdf)$)", part_ctlr.InstallSheet)
 		r.Get("/:part/packages", part_ctlr.Packaging)
 		r.Get("/:part/pricing", part_ctlr.Prices)
 		r.Get("/:part/related", part_ctlr.GetRelated)
+		r.Get("/:part/recommendations", part_ctlr.Recommendations)
 		r.Get("/:part/videos", part_ctlr.Videos)
 		r.Get("/:part/:year/:make/:model", Deprecated)
 		r.Get("/:part/:year/:make/:model/:submodel", Deprecated)
@@ -387,6 +489,7 @@ func main() {
 		r.Delete("/:id", Deprecated)
 	})
 
+	m.Get("/search/suggest", search_ctlr.Suggest)
 	m.Get("/search/:term", search_ctlr.Search)
 	m.Get("/searchExactAndClose/:term", search_ctlr.SearchExactAndClose)
 
@@ -420,6 +523,17 @@ func main() {
 	})
 
 	//Creating of showcases is handled by GoAdmin directly
+	m.Group("/shipping", func(r martini.Router) {
+		r.Post("/estimate", shipping_ctlr.Estimate)
+	})
+
+	m.Group("/reports", func(r martini.Router) {
+		r.Get("/data-quality", middleware.InternalKeyAuthentication, report_ctlr.DataQuality)
+		r.Get("/data-quality.csv", middleware.InternalKeyAuthentication, report_ctlr.DataQualityCSV)
+		r.Get("/fitment-coverage", middleware.InternalKeyAuthentication, report_ctlr.FitmentCoverage)
+		r.Get("/fitment-coverage.csv", middleware.InternalKeyAuthentication, report_ctlr.FitmentCoverageCSV)
+	})
+
 	m.Group("/showcase", func(r martini.Router) {
 		r.Get("", Deprecated)
 		r.Get("/:id", Deprecated)
@@ -489,6 +603,7 @@ func main() {
 
 	// ARIES Year/Make/Model/Style
 	m.Post("/vehicle", vehicle.Query)
+	m.Delete("/vehicle/cache", middleware.InternalKeyAuthentication, vehicle.InvalidateCache)
 	m.Post("/findVehicle", Deprecated)
 	m.Post("/vehicle/inquire", Deprecated)
 
@@ -517,6 +632,14 @@ func main() {
 	m.Post("/vehicle/curt", vehicle.CurtLookup)
 	m.Get("/vehicle/curt", vehicle.CurtLookupGet)
 
+	// Year/Make/Model/Style for every brand, in one shape
+	m.Get("/v4/vehicle/engines", vehicle.FitmentEngines)
+	m.Get("/v4/vehicle", vehicle.Fitment)
+	m.Get("/v4/vehicle/:year", vehicle.Fitment)
+	m.Get("/v4/vehicle/:year/:make", vehicle.Fitment)
+	m.Get("/v4/vehicle/:year/:make/:model", vehicle.Fitment)
+	m.Get("/v4/vehicle/:year/:make/:model/:style", vehicle.Fitment)
+
 	//videos are handled in GoAdmin
 	m.Group("/videos", func(r martini.Router) {
 		r.Get("/distinct", videos_ctlr.DistinctVideos) //old "videos" table - curtmfg?
@@ -538,11 +661,11 @@ func main() {
 
 	m.Group("/vin", func(r martini.Router) {
 		//option 1 - two calls - ultimately returns parts
-		r.Get("/configs/:vin", Deprecated)         //returns vehicles - user must call vin/vehicle with vehicleID to get parts
-		r.Get("/vehicleID/:vehicleID", Deprecated) //returns an array of parts
+		r.Get("/configs/:vin", vinLookup.GetConfigs)                    //returns vehicles - user must call vin/vehicle with vehicleID to get parts
+		r.Get("/vehicleID/:vehicleID", vinLookup.GetPartsFromVehicleID) //returns an array of parts
 
 		//option 2 - one call - returns vehicles with parts
-		r.Get("/:vin", Deprecated) //returns vehicles + configs with associates parts -or- an array of parts if only one vehicle config matches
+		r.Get("/:vin", vinLookup.GetParts) //returns vehicles + configs with associates parts -or- an array of parts if only one vehicle config matches
 	})
 
 	m.Get("/status", func(w http.ResponseWriter, r *http.Request) {
@@ -565,6 +688,64 @@ func main() {
 	log.Fatal(srv.ListenAndServe())
 }
 
+// warmLookupCache reloads the popular vehicle lookups into redis twice a day
+// for each brand set, like "1 3 1,3".
+func warmLookupCache(brandSets string) {
+	sets := parseBrandSets(brandSets)
+
+	years, err := strconv.Atoi(os.Getenv("LOOKUP_WARM_YEARS"))
+	if err != nil {
+		years = 10
+	}
+	var makes []string
+	if mk := os.Getenv("LOOKUP_WARM_MAKES"); mk != "" {
+		makes = strings.Split(mk, ",")
+	}
+
+	for {
+		for _, brands := range sets {
+			w := products.WarmLookup{Brands: brands, Years: years, Makes: makes}
+			if err := products.WarmLookupCache(w); err != nil {
+				log.Printf("failed to warm vehicle lookup cache for brands %v: %v", brands, err)
+			}
+		}
+		time.Sleep(12 * time.Hour)
+	}
+}
+
+// refreshCoverageReport regenerates the fitment coverage report of each
+// brand set, like "1 3 1,3", every day so the report endpoints are served
+// from the cache.
+func refreshCoverageReport(brandSets string) {
+	sets := parseBrandSets(brandSets)
+
+	for {
+		for _, brands := range sets {
+			if _, err := coverage.Get(coverage.Options{Brands: brands}, true); err != nil {
+				log.Printf("failed to generate fitment coverage report for brands %v: %v", brands, err)
+			}
+		}
+		time.Sleep(24 * time.Hour)
+	}
+}
+
+// parseBrandSets splits brand sets like "1 3 1,3" into their brand IDs.
+func parseBrandSets(brandSets string) [][]int {
+	var sets [][]int
+	for _, set := range strings.Fields(brandSets) {
+		var brands []int
+		for _, b := range strings.Split(set, ",") {
+			if id, err := strconv.Atoi(b); err == nil {
+				brands = append(brands, id)
+			}
+		}
+		if len(brands) > 0 {
+			sets = append(sets, brands)
+		}
+	}
+	return sets
+}
+
 func Deprecated(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusGone)
 	w.Header().Set("Content-Type", "text/plain")
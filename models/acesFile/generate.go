@@ -0,0 +1,484 @@
+package acesFile
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+var (
+	// ErrVersion is returned for ACES versions the generator can't write.
+	ErrVersion = errors.New("unsupported ACES version")
+
+	// Versions are the ACES versions the generator writes.
+	Versions = []string{"3.0", "3.1", "3.2", "4.0", "4.1", "4.2"}
+
+	vcdbVersionDate = os.Getenv("ACES_VCDB_VERSION_DATE")
+	qdbVersionDate  = os.Getenv("ACES_QDB_VERSION_DATE")
+	pcdbVersionDate = os.Getenv("ACES_PCDB_VERSION_DATE")
+	senderName      = os.Getenv("ACES_SENDER_NAME")
+	senderPhone     = os.Getenv("ACES_SENDER_PHONE")
+
+	baseVehiclesStmt = `select bv.YearID, ma.MakeName, mo.ModelName, bv.AAIABaseVehicleID
+		from BaseVehicle as bv
+		join vcdb_Make as ma on bv.MakeID = ma.ID
+		join vcdb_Model as mo on bv.ModelID = mo.ID
+		where bv.AAIABaseVehicleID > 0`
+	submodelsStmt  = `select SubmodelName, AAIASubmodelID from Submodel where AAIASubmodelID > 0`
+	qualifiersStmt = `select cat.name, ca.value, ca.vcdbID
+		from ConfigAttributeType as cat
+		join ConfigAttribute as ca on ca.ConfigAttributeTypeID = cat.ID
+		where ca.vcdbID > 0`
+
+	// qualifierElements are the ACES elements of our configuration types,
+	// in the order the schema wants them in an App.
+	qualifierElements = []struct {
+		Type    string
+		Element string
+	}{
+		{"mfrbodycode", "MfrBodyCode"},
+		{"numberofdoors", "BodyNumDoors"},
+		{"bodytype", "BodyType"},
+		{"drivetype", "DriveType"},
+		{"enginedesignation", "EngineDesignation"},
+		{"enginevin", "EngineVIN"},
+		{"engineversion", "EngineVersion"},
+		{"fueldeliverytype", "FuelDeliveryType"},
+		{"fueldeliverysubtype", "FuelDeliverySubType"},
+		{"fuelsystemcontroltype", "FuelSystemControlType"},
+		{"fuelsystemdesign", "FuelSystemDesign"},
+		{"aspiration", "Aspiration"},
+		{"cylinderheadtype", "CylinderHeadType"},
+		{"fueltype", "FuelType"},
+		{"ignitionsystem", "IgnitionSystemType"},
+		{"transmissionmanufacturercode", "TransmissionMfrCode"},
+		{"transmissiontype", "TransmissionType"},
+		{"transmissioncontroltype", "TransmissionControlType"},
+		{"transmissionnumspeeds", "TransmissionNumSpeeds"},
+		{"transmissionelectroniccontrolled", "TransElecControlled"},
+		{"valves", "ValvesPerEngine"},
+		{"bedlength", "BedLength"},
+		{"bedtype", "BedType"},
+		{"wheelbase", "WheelBase"},
+		{"brakesystem", "BrakeSystem"},
+		{"frontbraketype", "FrontBrakeType"},
+		{"rearbraketype", "RearBrakeType"},
+		{"brakeabs", "BrakeABS"},
+		{"frontspringtype", "FrontSpringType"},
+		{"rearspringtype", "RearSpringType"},
+		{"steeringsystem", "SteeringSystem"},
+		{"steeringtype", "SteeringType"},
+	}
+)
+
+// Header is the ACES file header.
+type Header struct {
+	Company         string `xml:"Company"`
+	SenderName      string `xml:"SenderName"`
+	SenderPhone     string `xml:"SenderPhone"`
+	TransferDate    string `xml:"TransferDate"`
+	BrandAAIAID     string `xml:"BrandAAIAID,omitempty"`
+	DocumentTitle   string `xml:"DocumentTitle"`
+	EffectiveDate   string `xml:"EffectiveDate"`
+	SubmissionType  string `xml:"SubmissionType"`
+	VcdbVersionDate string `xml:"VcdbVersionDate"`
+	QdbVersionDate  string `xml:"QdbVersionDate"`
+	PcdbVersionDate string `xml:"PcdbVersionDate"`
+}
+
+// App is a single ACES application of a part to a vehicle.
+type App struct {
+	XMLName     xml.Name    `xml:"App"`
+	Action      string      `xml:"action,attr"`
+	ID          int         `xml:"id,attr"`
+	BaseVehicle IDElement   `xml:"BaseVehicle"`
+	SubModel    *IDElement  `xml:"SubModel,omitempty"`
+	Qualifiers  []Qualifier `xml:",any"`
+	Notes       []string    `xml:"Note,omitempty"`
+	Qty         int         `xml:"Qty"`
+	PartType    IDElement   `xml:"PartType"`
+	Part        PartElement `xml:"Part"`
+}
+
+// PartElement is the part number of an App. ACES 4.0 added the brand of the
+// part as an attribute.
+type PartElement struct {
+	BrandAAIAID string `xml:"BrandAAIAID,attr,omitempty"`
+	Number      string `xml:",chardata"`
+}
+
+// IDElement is an element that refers to a VCdb or PCdb record.
+type IDElement struct {
+	ID int `xml:"id,attr"`
+}
+
+// Qualifier is a vehicle attribute of an App, such as <BodyType id="5"/>.
+type Qualifier struct {
+	XMLName xml.Name
+	ID      int `xml:"id,attr"`
+}
+
+// VCdb maps our vehicles and configurations to their VCdb IDs.
+type VCdb struct {
+	BaseVehicles map[string]int
+	Submodels    map[string]int
+	Qualifiers   map[string]int
+}
+
+// Stats counts what a Writer wrote.
+type Stats struct {
+	Parts   int `json:"parts" xml:"parts"`
+	Apps    int `json:"apps" xml:"apps"`
+	Skipped int `json:"skipped" xml:"skipped"`
+}
+
+// Writer streams an ACES file one part at a time.
+type Writer struct {
+	enc   *xml.Encoder
+	vcdb  *VCdb
+	brand string
+	Stats Stats
+}
+
+// ValidVersion reports whether the generator writes the ACES version.
+func ValidVersion(version string) bool {
+	for _, v := range Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// atLeast reports whether the ACES version is min or later. Versions are
+// all a single digit major and minor, so they compare as strings.
+func atLeast(version, min string) bool {
+	return version >= min
+}
+
+// LoadVCdb reads the VCdb IDs of our base vehicles, submodels and
+// configuration values.
+func LoadVCdb() (*VCdb, error) {
+	if err := database.Init(); err != nil {
+		return nil, err
+	}
+
+	v := &VCdb{
+		BaseVehicles: make(map[string]int),
+		Submodels:    make(map[string]int),
+		Qualifiers:   make(map[string]int),
+	}
+
+	rows, err := database.DB.Query(baseVehiclesStmt)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var bv products.BaseVehicle
+		var id int
+		if err := rows.Scan(&bv.Year, &bv.Make, &bv.Model, &id); err == nil {
+			v.BaseVehicles[baseVehicleKey(bv)] = id
+		}
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(submodelsStmt)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name string
+		var id int
+		if err := rows.Scan(&name, &id); err == nil {
+			v.Submodels[strings.ToLower(strings.TrimSpace(name))] = id
+		}
+	}
+	rows.Close()
+
+	rows, err = database.DB.Query(qualifiersStmt)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var name, value string
+		var id int
+		if err := rows.Scan(&name, &value, &id); err == nil {
+			v.Qualifiers[qualifierKey(name, value)] = id
+		}
+	}
+	rows.Close()
+
+	return v, nil
+}
+
+// NewWriter writes the start of the ACES file and its header. The brand's
+// Auto Care ID is written in the header from ACES 3.2, which added it, and
+// on every Part from ACES 4.0.
+func NewWriter(w io.Writer, version string, h Header, vcdb *VCdb) (*Writer, error) {
+	if !ValidVersion(version) {
+		return nil, ErrVersion
+	}
+
+	brand := ""
+	if atLeast(version, "4.0") {
+		brand = h.BrandAAIAID
+	}
+	if !atLeast(version, "3.2") {
+		h.BrandAAIAID = ""
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+
+	aw := &Writer{
+		enc:   xml.NewEncoder(w),
+		vcdb:  vcdb,
+		brand: brand,
+	}
+	aw.enc.Indent("", "\t")
+
+	start := xml.StartElement{
+		Name: xml.Name{Local: "ACES"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "version"}, Value: version}},
+	}
+	if err := aw.enc.EncodeToken(start); err != nil {
+		return nil, err
+	}
+	if err := aw.enc.EncodeElement(h, xml.StartElement{Name: xml.Name{Local: "Header"}}); err != nil {
+		return nil, err
+	}
+
+	return aw, nil
+}
+
+// WritePart writes an App for every ACES vehicle configuration of the part.
+// Parts without a part type, and vehicles without a VCdb base vehicle, are
+// skipped. A delete writes the Apps with the delete action.
+func (w *Writer) WritePart(p products.Part, remove bool) error {
+	if p.AcesPartTypeID == 0 || len(p.AcesVehicles) == 0 {
+		w.Stats.Skipped++
+		return nil
+	}
+
+	action := "A"
+	if remove {
+		action = "D"
+	}
+
+	written := false
+	for _, av := range p.AcesVehicles {
+		for _, app := range w.apps(p, av) {
+			w.Stats.Apps++
+			app.ID = w.Stats.Apps
+			app.Action = action
+			if err := w.enc.Encode(app); err != nil {
+				return err
+			}
+			written = true
+		}
+	}
+
+	if written {
+		w.Stats.Parts++
+	} else {
+		w.Stats.Skipped++
+	}
+	return nil
+}
+
+// Close writes the footer and the end of the ACES file.
+func (w *Writer) Close() error {
+	footer := struct {
+		XMLName     xml.Name `xml:"Footer"`
+		RecordCount int      `xml:"RecordCount"`
+	}{RecordCount: w.Stats.Apps}
+	if err := w.enc.Encode(footer); err != nil {
+		return err
+	}
+	if err := w.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "ACES"}}); err != nil {
+		return err
+	}
+	return w.enc.Flush()
+}
+
+// apps returns the Apps of one of the part's vehicles, one for each of its
+// configurations.
+func (w *Writer) apps(p products.Part, av products.AcesVehicle) []App {
+	id, ok := w.vcdb.BaseVehicles[baseVehicleKey(av.Base)]
+	if !ok {
+		return nil
+	}
+
+	app := App{
+		BaseVehicle: IDElement{ID: id},
+		Qty:         1,
+		PartType:    IDElement{ID: p.AcesPartTypeID},
+		Part:        PartElement{BrandAAIAID: w.brand, Number: p.PartNumber},
+	}
+	if av.Submodel != "" {
+		if sub, ok := w.vcdb.Submodels[strings.ToLower(strings.TrimSpace(av.Submodel))]; ok {
+			app.SubModel = &IDElement{ID: sub}
+		} else {
+			app.Notes = append(app.Notes, av.Submodel)
+		}
+	}
+
+	if len(av.Attributes) == 0 {
+		return []App{app}
+	}
+
+	apps := make([]App, 0, len(av.Attributes))
+	for _, conf := range av.Attributes {
+		a := app
+		a.Notes = append([]string(nil), app.Notes...)
+		a.Qualifiers, a.Notes = w.qualifiers(conf.Options, a.Notes)
+		apps = append(apps, a)
+	}
+	return apps
+}
+
+// qualifiers maps the options to ACES elements in schema order. Options
+// without a VCdb ID become notes.
+func (w *Writer) qualifiers(opts []products.ConfigOption, notes []string) ([]Qualifier, []string) {
+	var quals []Qualifier
+	used := make([]bool, len(opts))
+	for _, qe := range qualifierElements {
+		for i, opt := range opts {
+			if used[i] || normalizeType(opt.Key) != qe.Type {
+				continue
+			}
+			if id, ok := w.vcdb.Qualifiers[qualifierKey(opt.Key, opt.Value)]; ok {
+				quals = append(quals, Qualifier{XMLName: xml.Name{Local: qe.Element}, ID: id})
+				used[i] = true
+			}
+		}
+	}
+	for i, opt := range opts {
+		if !used[i] {
+			notes = append(notes, fmt.Sprintf("%s: %s", opt.Key, opt.Value))
+		}
+	}
+	return quals, notes
+}
+
+// Generate streams the ACES file of the brand to w. A non zero since
+// writes a delta file of the parts modified since then, deleting the Apps
+// of the parts that are no longer active.
+func Generate(w io.Writer, b brand.Brand, version string, since time.Time) (Stats, error) {
+	if !ValidVersion(version) {
+		return Stats{}, ErrVersion
+	}
+
+	vcdb, err := LoadVCdb()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		"brand.id": b.ID,
+		"status": bson.M{
+			"$in": products.ActiveStatuses(),
+		},
+	}
+	submission := "FULL"
+	if !since.IsZero() {
+		delete(query, "status")
+		query["date_modified"] = bson.M{"$gte": since}
+		submission = "UPDATE"
+	}
+
+	fields := bson.M{
+		"id": 1, "part_number": 1, "status": 1, "acesPartTypeId": 1, "aces_vehicles": 1,
+	}
+	var p products.Part
+	iter := session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Select(fields).Sort("part_number").Iter()
+	// read the first part before writing anything, so a failed query is
+	// still an error rather than an empty file
+	more := iter.Next(&p)
+	if !more {
+		if err := iter.Close(); err != nil {
+			return Stats{}, err
+		}
+	}
+
+	aw, err := NewWriter(w, version, NewHeader(b, submission), vcdb)
+	if err != nil {
+		iter.Close()
+		return Stats{}, err
+	}
+
+	for ; more; more = iter.Next(&p) {
+		if err := aw.WritePart(p, !active(p.Status)); err != nil {
+			iter.Close()
+			return aw.Stats, err
+		}
+		p = products.Part{}
+	}
+	if err := iter.Close(); err != nil {
+		return aw.Stats, err
+	}
+
+	return aw.Stats, aw.Close()
+}
+
+// NewHeader returns the header of the brand's ACES file.
+func NewHeader(b brand.Brand, submission string) Header {
+	today := time.Now().Format("2006-01-02")
+	h := Header{
+		Company:         b.FormalName,
+		SenderName:      senderName,
+		SenderPhone:     senderPhone,
+		TransferDate:    today,
+		BrandAAIAID:     b.AutocareID,
+		DocumentTitle:   b.Name + " ACES",
+		EffectiveDate:   today,
+		SubmissionType:  submission,
+		VcdbVersionDate: vcdbVersionDate,
+		QdbVersionDate:  qdbVersionDate,
+		PcdbVersionDate: pcdbVersionDate,
+	}
+	if h.Company == "" {
+		h.Company = b.Name
+	}
+	for _, date := range []*string{&h.VcdbVersionDate, &h.QdbVersionDate, &h.PcdbVersionDate} {
+		if *date == "" {
+			*date = today
+		}
+	}
+	return h
+}
+
+func active(status int) bool {
+	for _, s := range products.ActiveStatuses() {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func baseVehicleKey(bv products.BaseVehicle) string {
+	return fmt.Sprintf("%d|%s|%s", bv.Year, strings.ToLower(strings.TrimSpace(bv.Make)), strings.ToLower(strings.TrimSpace(bv.Model)))
+}
+
+func qualifierKey(name, value string) string {
+	return normalizeType(name) + "|" + strings.ToLower(strings.TrimSpace(value))
+}
+
+func normalizeType(name string) string {
+	return strings.ToLower(strings.Replace(name, " ", "", -1))
+}
@@ -0,0 +1,319 @@
+package acesFile
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+var (
+	headerSequence = []string{
+		"Company", "SenderName", "SenderPhone", "TransferDate", "BrandAAIAID", "DocumentTitle",
+		"EffectiveDate", "SubmissionType", "VcdbVersionDate", "QdbVersionDate", "PcdbVersionDate",
+	}
+	headerRequired = []string{"Company", "TransferDate", "SubmissionType", "VcdbVersionDate", "QdbVersionDate", "PcdbVersionDate"}
+)
+
+// appSequence is the order of the elements of an App in the ACES schema.
+func appSequence() []string {
+	seq := []string{"BaseVehicle", "SubModel"}
+	for _, qe := range qualifierElements {
+		seq = append(seq, qe.Element)
+	}
+	return append(seq, "Note", "Qty", "PartType", "Part")
+}
+
+type element struct {
+	name     string
+	attrs    map[string]string
+	text     string
+	children []*element
+}
+
+func parse(r io.Reader) (*element, error) {
+	dec := xml.NewDecoder(r)
+	var stack []*element
+	var root *element
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return root, nil
+		} else if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			e := &element{name: t.Name.Local, attrs: make(map[string]string)}
+			for _, a := range t.Attr {
+				e.attrs[a.Name.Local] = a.Value
+			}
+			if len(stack) == 0 {
+				root = e
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, e)
+			}
+			stack = append(stack, e)
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(bytes.TrimSpace(t))
+			}
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		}
+	}
+}
+
+// inSequence checks the children of e appear in the order of seq, each at
+// most once unless repeatable.
+func inSequence(e *element, seq []string, repeatable ...string) error {
+	pos := 0
+	last := ""
+	for _, c := range e.children {
+		if c.name == last {
+			ok := false
+			for _, r := range repeatable {
+				ok = ok || r == c.name
+			}
+			if !ok {
+				return fmt.Errorf("%s repeats in %s", c.name, e.name)
+			}
+			continue
+		}
+		found := false
+		for pos < len(seq) {
+			if seq[pos] == c.name {
+				found = true
+				break
+			}
+			pos++
+		}
+		if !found {
+			return fmt.Errorf("%s is out of order or not allowed in %s", c.name, e.name)
+		}
+		last = c.name
+	}
+	return nil
+}
+
+func child(e *element, name string) *element {
+	for _, c := range e.children {
+		if c.name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// validate checks the document against the structure of the ACES 3.x and
+// 4.x schemas, and the official schema of its version when there is one.
+func validate(doc []byte) error {
+	root, err := parse(bytes.NewReader(doc))
+	if err != nil {
+		return err
+	}
+	if root == nil || root.name != "ACES" {
+		return fmt.Errorf("root element is not ACES")
+	}
+	version := root.attrs["version"]
+	if !ValidVersion(version) {
+		return fmt.Errorf("bad version %q", version)
+	}
+	if len(root.children) < 2 || root.children[0].name != "Header" || root.children[len(root.children)-1].name != "Footer" {
+		return fmt.Errorf("ACES needs a Header first and a Footer last")
+	}
+
+	header := root.children[0]
+	if err := inSequence(header, headerSequence); err != nil {
+		return err
+	}
+	for _, name := range headerRequired {
+		if c := child(header, name); c == nil || c.text == "" {
+			return fmt.Errorf("Header is missing %s", name)
+		}
+	}
+	if child(header, "BrandAAIAID") != nil && version < "3.2" {
+		return fmt.Errorf("BrandAAIAID isn't in the ACES %s Header", version)
+	}
+
+	apps := root.children[1 : len(root.children)-1]
+	seq := appSequence()
+	for _, app := range apps {
+		if app.name != "App" {
+			return fmt.Errorf("%s is not allowed in ACES", app.name)
+		}
+		if a := app.attrs["action"]; a != "A" && a != "D" {
+			return fmt.Errorf("bad App action %q", a)
+		}
+		if _, err := strconv.Atoi(app.attrs["id"]); err != nil {
+			return fmt.Errorf("bad App id %q", app.attrs["id"])
+		}
+		if err := inSequence(app, seq, "Note"); err != nil {
+			return err
+		}
+		for _, name := range []string{"BaseVehicle", "Qty", "PartType", "Part"} {
+			if child(app, name) == nil {
+				return fmt.Errorf("App is missing %s", name)
+			}
+		}
+		if _, ok := child(app, "Part").attrs["BrandAAIAID"]; ok && version < "4.0" {
+			return fmt.Errorf("Part has no BrandAAIAID in ACES %s", version)
+		}
+	}
+
+	count := child(root.children[len(root.children)-1], "RecordCount")
+	if count == nil || count.text != strconv.Itoa(len(apps)) {
+		return fmt.Errorf("RecordCount doesn't match the %d Apps", len(apps))
+	}
+	return validateSchema(version, doc)
+}
+
+// validateSchema validates the document with xmllint against the Auto Care
+// schema of its version, like ACES_4_2_XSDSchema_Rev2.xsd, from the
+// ACES_XSD_DIR directory. The schemas are licensed to members, so they're
+// not in the repo; without them or xmllint only the structure is checked.
+func validateSchema(version string, doc []byte) error {
+	dir := os.Getenv("ACES_XSD_DIR")
+	xmllint, err := exec.LookPath("xmllint")
+	if dir == "" || err != nil {
+		return nil
+	}
+	schemas, _ := filepath.Glob(filepath.Join(dir, "ACES_"+strings.Replace(version, ".", "_", 1)+"*.xsd"))
+	if len(schemas) == 0 {
+		return nil
+	}
+
+	cmd := exec.Command(xmllint, "--noout", "--schema", schemas[len(schemas)-1], "-")
+	cmd.Stdin = bytes.NewReader(doc)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ACES %s schema: %s", version, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func testVCdb() *VCdb {
+	return &VCdb{
+		BaseVehicles: map[string]int{"2016|chevrolet|silverado 1500": 5911},
+		Submodels:    map[string]int{"lt": 20},
+		Qualifiers: map[string]int{
+			qualifierKey("Bed Length", "69.3"):    12,
+			qualifierKey("Body Type", "Crew Cab"): 5,
+		},
+	}
+}
+
+func testPart() products.Part {
+	return products.Part{
+		PartNumber:     "13000",
+		Status:         800,
+		AcesPartTypeID: 4452,
+		AcesVehicles: []products.AcesVehicle{
+			{
+				Base:     products.BaseVehicle{Year: 2016, Make: "Chevrolet", Model: "Silverado 1500"},
+				Submodel: "LT",
+				Attributes: []products.AcesConfiguration{
+					{Options: []products.ConfigOption{
+						{Key: "Bed Length", Value: "69.3"},
+						{Key: "Body Type", Value: "Crew Cab"},
+						{Key: "Hitch Color", Value: "Black"},
+					}},
+					{Options: []products.ConfigOption{{Key: "Body Type", Value: "Crew Cab"}}},
+				},
+			},
+			{Base: products.BaseVehicle{Year: 1950, Make: "Willys", Model: "Jeepster"}},
+		},
+	}
+}
+
+func TestWriter(t *testing.T) {
+	b := brand.Brand{ID: 1, Name: "CURT", FormalName: "CURT Manufacturing", AutocareID: "BKDK"}
+
+	Convey("Testing Writer", t, func() {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, "3.2", NewHeader(b, "FULL"), testVCdb())
+		So(err, ShouldBeNil)
+
+		So(w.WritePart(testPart(), false), ShouldBeNil)
+		So(w.WritePart(products.Part{PartNumber: "40000"}, false), ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+
+		So(w.Stats.Parts, ShouldEqual, 1)
+		So(w.Stats.Apps, ShouldEqual, 2)
+		So(w.Stats.Skipped, ShouldEqual, 1)
+
+		So(validate(buf.Bytes()), ShouldBeNil)
+
+		doc := buf.String()
+		So(doc, ShouldContainSubstring, `<ACES version="3.2">`)
+		So(doc, ShouldContainSubstring, `<Part>13000</Part>`)
+		So(doc, ShouldContainSubstring, `<BaseVehicle id="5911"></BaseVehicle>`)
+		So(doc, ShouldContainSubstring, `<SubModel id="20"></SubModel>`)
+		So(doc, ShouldContainSubstring, `<BodyType id="5"></BodyType>`)
+		So(doc, ShouldContainSubstring, `<BedLength id="12"></BedLength>`)
+		So(doc, ShouldContainSubstring, `<Note>Hitch Color: Black</Note>`)
+		So(doc, ShouldContainSubstring, `<PartType id="4452"></PartType>`)
+		So(doc, ShouldContainSubstring, `<BrandAAIAID>BKDK</BrandAAIAID>`)
+	})
+
+	Convey("Testing a delta file", t, func() {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, "4.2", NewHeader(b, "UPDATE"), testVCdb())
+		So(err, ShouldBeNil)
+		So(w.WritePart(testPart(), true), ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+
+		So(validate(buf.Bytes()), ShouldBeNil)
+		So(buf.String(), ShouldContainSubstring, `<App action="D" id="1">`)
+		So(buf.String(), ShouldContainSubstring, `<SubmissionType>UPDATE</SubmissionType>`)
+	})
+
+	Convey("Testing every version", t, func() {
+		write := func(version string) string {
+			var buf bytes.Buffer
+			w, err := NewWriter(&buf, version, NewHeader(b, "FULL"), testVCdb())
+			So(err, ShouldBeNil)
+			So(w.WritePart(testPart(), false), ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+			So(validate(buf.Bytes()), ShouldBeNil)
+			return buf.String()
+		}
+
+		for _, version := range Versions {
+			doc := write(version)
+			So(strings.Contains(doc, `<BrandAAIAID>BKDK</BrandAAIAID>`), ShouldEqual, version >= "3.2")
+			So(strings.Contains(doc, `<Part BrandAAIAID="BKDK">13000</Part>`), ShouldEqual, version >= "4.0")
+		}
+		So(write("3.0"), ShouldNotEqual, write("3.2"))
+		So(write("3.2"), ShouldNotEqual, write("4.2"))
+	})
+
+	Convey("Testing validate()", t, func() {
+		So(validate([]byte(`<ACES version="3.1"><Header><Company>CURT</Company><TransferDate>2016-01-01</TransferDate><BrandAAIAID>BKDK</BrandAAIAID>`+
+			`<SubmissionType>FULL</SubmissionType><VcdbVersionDate>2016-01-01</VcdbVersionDate><QdbVersionDate>2016-01-01</QdbVersionDate>`+
+			`<PcdbVersionDate>2016-01-01</PcdbVersionDate></Header><Footer><RecordCount>0</RecordCount></Footer></ACES>`)), ShouldNotBeNil)
+		So(validate([]byte(`<ACES version="3.2"><Header><Company>CURT</Company><TransferDate>2016-01-01</TransferDate>`+
+			`<SubmissionType>FULL</SubmissionType><VcdbVersionDate>2016-01-01</VcdbVersionDate><QdbVersionDate>2016-01-01</QdbVersionDate>`+
+			`<PcdbVersionDate>2016-01-01</PcdbVersionDate></Header><App action="A" id="1"><BaseVehicle id="1"/>`+
+			`<Qty>1</Qty><PartType id="1"/><Part BrandAAIAID="BKDK">13000</Part></App><Footer><RecordCount>1</RecordCount></Footer></ACES>`)), ShouldNotBeNil)
+		So(validate([]byte(`<ACES version="3.2"><Header></Header><Footer></Footer></ACES>`)), ShouldNotBeNil)
+		So(validate([]byte(`<ACES version="3.2"><Header><Company>CURT</Company><TransferDate>2016-01-01</TransferDate>`+
+			`<SubmissionType>FULL</SubmissionType><VcdbVersionDate>2016-01-01</VcdbVersionDate><QdbVersionDate>2016-01-01</QdbVersionDate>`+
+			`<PcdbVersionDate>2016-01-01</PcdbVersionDate></Header><App action="A" id="1"><Part>13000</Part><BaseVehicle id="1"/>`+
+			`<Qty>1</Qty><PartType id="1"/></App><Footer><RecordCount>1</RecordCount></Footer></ACES>`)), ShouldNotBeNil)
+	})
+
+	Convey("Testing NewWriter() with an unknown version", t, func() {
+		_, err := NewWriter(&bytes.Buffer{}, "2.0", Header{}, testVCdb())
+		So(err, ShouldEqual, ErrVersion)
+	})
+}
@@ -72,7 +72,7 @@ func GetCategoryTree(dtx *apicontext.DataContext) ([]Category, error) {
 	return cats, err
 }
 
-func (c *Category) Get(page, count int) error {
+func (c *Category) Get(page, count int, dtx *apicontext.DataContext) error {
 
 	session, err := mgo.DialWithInfo(database.MongoPartConnectionString())
 	if err != nil {
@@ -92,15 +92,17 @@ func (c *Category) Get(page, count int) error {
 		Parts:   []products.Part{},
 	}
 
-	c.ProductListing.TotalItems, err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(bson.M{"id": bson.M{"$in": c.ProductIdentifiers}}).Count()
+	query := bson.M{"id": bson.M{"$in": c.ProductIdentifiers}, "web_visibility": products.VisibilityQuery(products.Visibilities(dtx))}
+	c.ProductListing.TotalItems, err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Count()
 	if err != nil {
 		c.ProductListing.TotalItems = 1
 	}
 
-	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(bson.M{"id": bson.M{"$in": c.ProductIdentifiers}}).Sort("id").Skip((page - 1) * count).Limit(count).All(&c.ProductListing.Parts)
+	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Sort("id").Skip((page - 1) * count).Limit(count).All(&c.ProductListing.Parts)
 	if err != nil {
 		return err
 	}
+	c.ProductListing.Parts = products.FilterVisible(c.ProductListing.Parts, dtx)
 
 	c.ProductListing.ReturnedCount = len(c.ProductListing.Parts)
 	c.ProductListing.TotalPages = c.ProductListing.TotalItems / c.ProductListing.PerPage
@@ -108,7 +110,10 @@ func (c *Category) Get(page, count int) error {
 	return nil
 }
 
-func GetCategoryParts(catId, page, count int) (PartResponse, error) {
+// GetCategoryParts returns a page of the parts of the category and its
+// children. With rules, only the parts that match them are paged and
+// counted.
+func GetCategoryParts(catId, page, count int, dtx *apicontext.DataContext, rules []products.FilterRule) (PartResponse, error) {
 	var parts PartResponse
 
 	session, err := mgo.DialWithInfo(database.MongoPartConnectionString())
@@ -142,12 +147,22 @@ func GetCategoryParts(catId, page, count int) (PartResponse, error) {
 		"status": bson.M{
 			"$in": statuses,
 		},
+		"web_visibility": products.VisibilityQuery(products.Visibilities(dtx)),
+	}
+
+	if len(rules) > 0 {
+		var total int
+		iter := session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Sort("id").Iter()
+		parts.Parts, total, err = products.FilterPage(iter, rules, dtx, (page-1)*count, count)
+		parts.TotalPages = int(math.Ceil(float64(total) / float64(count)))
+		return parts, err
 	}
 
 	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Limit(count).Skip((page - 1) * count).All(&parts.Parts)
 	if err != nil {
 		return parts, err
 	}
+	parts.Parts = products.FilterVisible(parts.Parts, dtx)
 
 	//get total parts count
 	total_items, err := session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Count()
@@ -0,0 +1,440 @@
+package coverage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/helpers/redis"
+	"github.com/curt-labs/API/models/products"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// CacheKey prefixes the cached coverage reports.
+	CacheKey = "reports:coverage"
+
+	// CacheTimeout is how long a coverage report is cached, in seconds.
+	CacheTimeout = 86400
+
+	// Years is how many model years back a report covers by default.
+	Years = 15
+)
+
+var (
+	// DefaultCategories are the product lines product management tracks
+	// coverage of. A part covers a vehicle for a category when any of its
+	// category titles contains the category.
+	DefaultCategories = []string{"Hitch", "Wiring", "Running Board"}
+
+	// VehicleTypes limits the vehicle universe to the types we make parts
+	// for.
+	VehicleTypes = []string{"Car", "Truck", "Van"}
+
+	// Popularity ranks the gaps. Replace it with LoadWeightsFile.
+	Popularity = Weights{}
+
+	universeStmt = `select distinct bv.YearID, ma.MakeName, mo.ModelName from BaseVehicle as bv
+		join Make as ma on bv.MakeID = ma.MakeID
+		join Model as mo on bv.ModelID = mo.ModelID
+		join VehicleType as vt on mo.VehicleTypeID = vt.VehicleTypeID
+		where bv.YearID between ? and ? && vt.VehicleTypeName in (%s)
+		order by bv.YearID desc, ma.MakeName, mo.ModelName`
+)
+
+// Vehicle is a base vehicle of the universe.
+type Vehicle struct {
+	Year  int    `json:"year" xml:"year,attr"`
+	Make  string `json:"make" xml:"make,attr"`
+	Model string `json:"model" xml:"model,attr"`
+}
+
+// Gap is a vehicle no part of a category fits.
+type Gap struct {
+	Vehicle
+	Weight float64 `json:"weight" xml:"weight,attr"`
+}
+
+// Category is the coverage of one category. Gaps are ranked most popular
+// first.
+type Category struct {
+	Name     string  `json:"name" xml:"name,attr"`
+	Parts    int     `json:"parts" xml:"parts,attr"`
+	Vehicles int     `json:"vehicles" xml:"vehicles,attr"`
+	Covered  int     `json:"covered" xml:"covered,attr"`
+	Percent  float64 `json:"percent" xml:"percent,attr"`
+	Gaps     []Gap   `json:"gaps" xml:"gaps"`
+}
+
+// Report is the fitment coverage of the categories over the vehicle
+// universe.
+type Report struct {
+	Generated  time.Time  `json:"generated" xml:"generated,attr"`
+	FromYear   int        `json:"from_year" xml:"from_year,attr"`
+	ToYear     int        `json:"to_year" xml:"to_year,attr"`
+	Brands     []int      `json:"brands" xml:"brands"`
+	Categories []Category `json:"categories" xml:"categories"`
+}
+
+// Options select what a report covers. Zero values fall back to the
+// defaults.
+type Options struct {
+	Categories []string
+	FromYear   int
+	ToYear     int
+	Brands     []int
+}
+
+// Weights are the popularity of vehicles, keyed by year, make and model.
+// A year of 0 weights every year of the model and an empty model every
+// model of the make.
+type Weights map[string]float64
+
+// LoadWeights reads a popularity CSV of year, make, model and weight rows.
+// The year and model can be left blank, and a header row is skipped.
+func LoadWeights(r io.Reader) (Weights, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 4
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	w := make(Weights, len(lines))
+	for i, line := range lines {
+		weight, err := strconv.ParseFloat(strings.TrimSpace(line[3]), 64)
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: invalid weight %q", i+1, line[3])
+		}
+		var year int
+		if y := strings.TrimSpace(line[0]); y != "" {
+			if year, err = strconv.Atoi(y); err != nil {
+				return nil, fmt.Errorf("line %d: invalid year %q", i+1, line[0])
+			}
+		}
+		if strings.TrimSpace(line[1]) == "" {
+			return nil, fmt.Errorf("line %d: make is required", i+1)
+		}
+		w[key(year, line[1], line[2])] = weight
+	}
+	return w, nil
+}
+
+// LoadWeightsFile replaces Popularity with the weights stored at path.
+func LoadWeightsFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w, err := LoadWeights(f)
+	if err != nil {
+		return err
+	}
+	Popularity = w
+	return nil
+}
+
+// Weight returns the popularity of the vehicle, from its most specific
+// weight.
+func (w Weights) Weight(v Vehicle) float64 {
+	for _, k := range []string{key(v.Year, v.Make, v.Model), key(0, v.Make, v.Model), key(0, v.Make, "")} {
+		if weight, ok := w[k]; ok {
+			return weight
+		}
+	}
+	return 0
+}
+
+func (o Options) withDefaults() Options {
+	if len(o.Categories) == 0 {
+		o.Categories = DefaultCategories
+	}
+	if o.ToYear == 0 {
+		o.ToYear = time.Now().Year() + 1
+	}
+	if o.FromYear == 0 {
+		o.FromYear = o.ToYear - Years
+	}
+	brands := append([]int(nil), o.Brands...)
+	sort.Ints(brands)
+	o.Brands = brands
+	return o
+}
+
+func (o Options) cacheKey() string {
+	var brands []string
+	for _, b := range o.Brands {
+		brands = append(brands, strconv.Itoa(b))
+	}
+	var cats []string
+	for _, c := range o.Categories {
+		cats = append(cats, strings.ToLower(c))
+	}
+	sort.Strings(cats)
+	return fmt.Sprintf("%s:%s:%d-%d:%s", CacheKey, strings.Join(brands, ","), o.FromYear, o.ToYear, strings.Join(cats, ","))
+}
+
+// Get returns the cached report for the options, generating and caching
+// it when there isn't one or refresh is set.
+func Get(opts Options, refresh bool) (Report, error) {
+	opts = opts.withDefaults()
+
+	var rep Report
+	if !refresh {
+		if data, err := redis.Get(opts.cacheKey()); err == nil && len(data) > 0 {
+			if err = json.Unmarshal(data, &rep); err == nil {
+				return rep, nil
+			}
+		}
+	}
+
+	rep, err := Generate(opts)
+	if err != nil {
+		return rep, err
+	}
+	go redis.Setex(opts.cacheKey(), rep, CacheTimeout)
+	return rep, nil
+}
+
+// Generate cross references the VCDB base vehicles of the years with the
+// vehicles the active parts of each category fit.
+func Generate(opts Options) (Report, error) {
+	opts = opts.withDefaults()
+	rep := Report{
+		Generated: time.Now(),
+		FromYear:  opts.FromYear,
+		ToYear:    opts.ToYear,
+		Brands:    opts.Brands,
+	}
+
+	universe, err := Universe(opts.FromYear, opts.ToYear)
+	if err != nil {
+		return rep, err
+	}
+
+	for _, cat := range opts.Categories {
+		fits, parts, err := categoryFitment(cat, opts.Brands)
+		if err != nil {
+			return rep, err
+		}
+		c := Analyze(universe, fits, Popularity)
+		c.Name = cat
+		c.Parts = parts
+		rep.Categories = append(rep.Categories, c)
+	}
+
+	return rep, nil
+}
+
+// Universe returns the VCDB base vehicles of the years.
+func Universe(from, to int) ([]Vehicle, error) {
+	if err := database.Init(); err != nil {
+		return nil, err
+	}
+
+	args := []interface{}{from, to}
+	marks := make([]string, 0, len(VehicleTypes))
+	for _, t := range VehicleTypes {
+		args = append(args, t)
+		marks = append(marks, "?")
+	}
+
+	stmt, err := database.VcdbDB.Prepare(fmt.Sprintf(universeStmt, strings.Join(marks, ",")))
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.Query(args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vs []Vehicle
+	for rows.Next() {
+		var v Vehicle
+		if err = rows.Scan(&v.Year, &v.Make, &v.Model); err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+	return vs, rows.Err()
+}
+
+// categoryFitment returns the vehicles fit by the active parts of the
+// brands in the category, from both the CURT vehicle applications and the
+// ACES vehicles, along with how many parts there are.
+func categoryFitment(category string, brands []int) ([]Vehicle, int, error) {
+	if err := database.Init(); err != nil {
+		return nil, 0, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		"status": bson.M{
+			"$in": products.ActiveStatuses(),
+		},
+		"categories.title": bson.RegEx{
+			Pattern: regexp.QuoteMeta(category),
+			Options: "i",
+		},
+	}
+	if len(brands) > 0 {
+		query["brand.id"] = bson.M{"$in": brands}
+	}
+	fields := bson.M{
+		"vehicle_applications.year": 1, "vehicle_applications.make": 1, "vehicle_applications.model": 1,
+		"aces_vehicles.base": 1,
+	}
+
+	var fits []Vehicle
+	var parts int
+	var p products.Part
+	iter := session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Select(fields).Iter()
+	for iter.Next(&p) {
+		parts++
+		fits = append(fits, PartVehicles(p)...)
+		p = products.Part{}
+	}
+	return fits, parts, iter.Close()
+}
+
+// PartVehicles returns the base vehicles of the part's CURT vehicle
+// applications and ACES vehicles.
+func PartVehicles(p products.Part) []Vehicle {
+	var vs []Vehicle
+	for _, app := range p.Vehicles {
+		year, err := strconv.Atoi(strings.TrimSpace(app.Year))
+		if err != nil {
+			continue
+		}
+		vs = append(vs, Vehicle{Year: year, Make: app.Make, Model: app.Model})
+	}
+	for _, av := range p.AcesVehicles {
+		vs = append(vs, Vehicle{Year: av.Base.Year, Make: av.Base.Make, Model: av.Base.Model})
+	}
+	return vs
+}
+
+// Analyze returns which vehicles of the universe none of the fits cover,
+// most popular first. Makes and models are compared through the vehicle
+// alias table, without regard to case or punctuation.
+func Analyze(universe, fits []Vehicle, w Weights) Category {
+	covered := make(map[string]bool, len(fits))
+	for _, f := range fits {
+		covered[key(f.Year, f.Make, f.Model)] = true
+	}
+
+	c := Category{Gaps: make([]Gap, 0)}
+	seen := make(map[string]bool, len(universe))
+	for _, v := range universe {
+		k := key(v.Year, v.Make, v.Model)
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		c.Vehicles++
+
+		if covered[k] {
+			c.Covered++
+			continue
+		}
+		c.Gaps = append(c.Gaps, Gap{Vehicle: v, Weight: w.Weight(v)})
+	}
+	if c.Vehicles > 0 {
+		c.Percent = float64(int(float64(c.Covered)/float64(c.Vehicles)*10000+0.5)) / 100
+	}
+
+	sort.SliceStable(c.Gaps, func(i, j int) bool {
+		a, b := c.Gaps[i], c.Gaps[j]
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		if a.Year != b.Year {
+			return a.Year > b.Year
+		}
+		if !strings.EqualFold(a.Make, b.Make) {
+			return strings.ToLower(a.Make) < strings.ToLower(b.Make)
+		}
+		return strings.ToLower(a.Model) < strings.ToLower(b.Model)
+	})
+
+	return c
+}
+
+// Trim limits the gaps of each category of the report to the most popular
+// limit.
+func (rep Report) Trim(limit int) Report {
+	if limit <= 0 {
+		return rep
+	}
+	cats := make([]Category, len(rep.Categories))
+	for i, c := range rep.Categories {
+		if len(c.Gaps) > limit {
+			c.Gaps = c.Gaps[:limit]
+		}
+		cats[i] = c
+	}
+	rep.Categories = cats
+	return rep
+}
+
+// WriteCSV writes the gaps of every category as CSV.
+func (rep Report) WriteCSV(w io.Writer) error {
+	wr := csv.NewWriter(w)
+	wr.Write([]string{
+		"Category",
+		"Year",
+		"Make",
+		"Model",
+		"Weight",
+	})
+	for _, c := range rep.Categories {
+		for _, g := range c.Gaps {
+			wr.Write([]string{
+				c.Name,
+				strconv.Itoa(g.Year),
+				g.Make,
+				g.Model,
+				strconv.FormatFloat(g.Weight, 'f', -1, 64),
+			})
+		}
+	}
+	wr.Flush()
+	return wr.Error()
+}
+
+// key is the year with the make and model resolved through the vehicle
+// alias table, folded to lowercase letters and digits.
+func key(year int, vehicleMake, model string) string {
+	vehicleMake, model = strings.TrimSpace(vehicleMake), strings.TrimSpace(model)
+	if model != "" {
+		model = products.NormalizeModel(vehicleMake, model)
+	}
+	return fmt.Sprintf("%d|%s|%s", year, fold(products.NormalizeMake(vehicleMake)), fold(model))
+}
+
+func fold(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, s)
+}
@@ -0,0 +1,96 @@
+package coverage
+
+import (
+	"bytes"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestCoverage(t *testing.T) {
+	universe := []Vehicle{
+		{Year: 2016, Make: "Chevrolet", Model: "Silverado 1500"},
+		{Year: 2016, Make: "Ford", Model: "F-150"},
+		{Year: 2015, Make: "Ford", Model: "F-150"},
+		{Year: 2016, Make: "Toyota", Model: "Tacoma"},
+		{Year: 2016, Make: "Honda", Model: "Civic"},
+		{Year: 2016, Make: "Honda", Model: "Civic"},
+	}
+
+	Convey("Testing LoadWeights()", t, func() {
+		w, err := LoadWeights(strings.NewReader("year,make,model,weight\n,Ford,F150,100\n2016,Toyota,Tacoma,90\n,Honda,,10\n"))
+		So(err, ShouldBeNil)
+		So(w.Weight(Vehicle{Year: 2015, Make: "ford", Model: "F-150"}), ShouldEqual, 100)
+		So(w.Weight(Vehicle{Year: 2016, Make: "Toyota", Model: "Tacoma"}), ShouldEqual, 90)
+		So(w.Weight(Vehicle{Year: 2015, Make: "Toyota", Model: "Tacoma"}), ShouldEqual, 0)
+		So(w.Weight(Vehicle{Year: 2016, Make: "Honda", Model: "Accord"}), ShouldEqual, 10)
+
+		_, err = LoadWeights(strings.NewReader(",Ford,F-150,100\n,Ford,Escape,lots\n"))
+		So(err, ShouldNotBeNil)
+		_, err = LoadWeights(strings.NewReader("2016,,F-150,100\n"))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Testing PartVehicles()", t, func() {
+		p := products.Part{
+			Vehicles: []products.VehicleApplication{
+				{Year: "2016", Make: "Chevy", Model: "Silverado"},
+				{Year: "", Make: "Ford", Model: "F-150"},
+			},
+			AcesVehicles: []products.AcesVehicle{
+				{Base: products.BaseVehicle{Year: 2015, Make: "Ford", Model: "F-150"}},
+			},
+		}
+		So(PartVehicles(p), ShouldResemble, []Vehicle{
+			{Year: 2016, Make: "Chevy", Model: "Silverado"},
+			{Year: 2015, Make: "Ford", Model: "F-150"},
+		})
+	})
+
+	Convey("Testing Analyze()", t, func() {
+		fits := []Vehicle{
+			{Year: 2016, Make: "chevy", Model: "silverado-1500"},
+			{Year: 2016, Make: "Ford", Model: "F150"},
+		}
+		w := Weights{key(2016, "Toyota", "Tacoma"): 90}
+
+		c := Analyze(universe, fits, w)
+		So(c.Vehicles, ShouldEqual, 5)
+		So(c.Covered, ShouldEqual, 2)
+		So(c.Percent, ShouldEqual, 40)
+		So(c.Gaps, ShouldResemble, []Gap{
+			{Vehicle: Vehicle{Year: 2016, Make: "Toyota", Model: "Tacoma"}, Weight: 90},
+			{Vehicle: Vehicle{Year: 2016, Make: "Honda", Model: "Civic"}},
+			{Vehicle: Vehicle{Year: 2015, Make: "Ford", Model: "F-150"}},
+		})
+
+		c = Analyze(nil, fits, w)
+		So(c.Percent, ShouldEqual, 0)
+		So(c.Gaps, ShouldBeEmpty)
+	})
+
+	Convey("Testing Trim() and WriteCSV()", t, func() {
+		c := Analyze(universe, nil, Weights{})
+		c.Name = "Hitch"
+		rep := Report{Categories: []Category{c}}
+
+		So(len(rep.Trim(2).Categories[0].Gaps), ShouldEqual, 2)
+		So(len(rep.Categories[0].Gaps), ShouldEqual, 5)
+		So(len(rep.Trim(0).Categories[0].Gaps), ShouldEqual, 5)
+
+		b := &bytes.Buffer{}
+		So(rep.Trim(1).WriteCSV(b), ShouldBeNil)
+		So(b.String(), ShouldEqual, "Category,Year,Make,Model,Weight\nHitch,2016,Chevrolet,Silverado 1500,0\n")
+	})
+
+	Convey("Testing Options", t, func() {
+		o := Options{Brands: []int{3, 1}}.withDefaults()
+		So(o.Categories, ShouldResemble, DefaultCategories)
+		So(o.FromYear, ShouldEqual, o.ToYear-Years)
+		So(o.Brands, ShouldResemble, []int{1, 3})
+
+		o = Options{Categories: []string{"Wiring", "hitch"}, FromYear: 2010, ToYear: 2016, Brands: []int{1}}.withDefaults()
+		So(o.cacheKey(), ShouldEqual, "reports:coverage:1:2010-2016:hitch,wiring")
+	})
+}
@@ -0,0 +1,215 @@
+package customer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-labs/API/helpers/database"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// AcesGarageVehicle is an ACES base vehicle with an optional submodel
+	// and configurations.
+	AcesGarageVehicle = "aces"
+	// CurtGarageVehicle is a CURT year, make, model and style.
+	CurtGarageVehicle = "curt"
+
+	// MaxGarageVehicles is the most vehicles a user can save.
+	MaxGarageVehicles = 100
+)
+
+var (
+	ErrGarageVehicleNotFound = errors.New("garage vehicle not found")
+	ErrGarageFull            = fmt.Errorf("a garage can hold at most %d vehicles", MaxGarageVehicles)
+)
+
+// GarageVehicle is a vehicle a customer user saved to look up again.
+type GarageVehicle struct {
+	ID             bson.ObjectId         `bson:"_id,omitempty" json:"id,omitempty" xml:"id,attr,omitempty"`
+	UserID         string                `bson:"user_id" json:"-" xml:"-"`
+	Nickname       string                `bson:"nickname" json:"nickname" xml:"nickname"`
+	Type           string                `bson:"type" json:"type" xml:"type,attr"`
+	Year           int                   `bson:"year" json:"year" xml:"year"`
+	Make           string                `bson:"make" json:"make" xml:"make"`
+	Model          string                `bson:"model" json:"model" xml:"model"`
+	Submodel       string                `bson:"submodel,omitempty" json:"submodel,omitempty" xml:"submodel,omitempty"`
+	Configurations []GarageConfiguration `bson:"configurations,omitempty" json:"configurations,omitempty" xml:"configurations,omitempty"`
+	Style          string                `bson:"style,omitempty" json:"style,omitempty" xml:"style,omitempty"`
+	DateAdded      time.Time             `bson:"date_added" json:"date_added" xml:"date_added,attr"`
+	DateModified   time.Time             `bson:"date_modified" json:"date_modified" xml:"date_modified,attr"`
+}
+
+// GarageConfiguration is a selected ACES configuration option, like a
+// "Bed Length" of "78.0".
+type GarageConfiguration struct {
+	Key   string `bson:"key" json:"key" xml:"key"`
+	Value string `bson:"value" json:"value" xml:"value"`
+}
+
+// Validate checks the vehicle has what its type needs and fills in a
+// nickname when there isn't one.
+func (g *GarageVehicle) Validate() error {
+	g.Type = strings.ToLower(strings.TrimSpace(g.Type))
+	g.Nickname = strings.TrimSpace(g.Nickname)
+	g.Make = strings.TrimSpace(g.Make)
+	g.Model = strings.TrimSpace(g.Model)
+	g.Submodel = strings.TrimSpace(g.Submodel)
+	g.Style = strings.TrimSpace(g.Style)
+
+	if g.Type == "" {
+		g.Type = AcesGarageVehicle
+	}
+	if g.Year == 0 || g.Make == "" || g.Model == "" {
+		return errors.New("year, make and model are required")
+	}
+
+	switch g.Type {
+	case AcesGarageVehicle:
+		if g.Style != "" {
+			return errors.New("ACES vehicles have a submodel, not a style")
+		}
+		if len(g.Configurations) > 0 && g.Submodel == "" {
+			return errors.New("configurations need a submodel")
+		}
+		for _, c := range g.Configurations {
+			if strings.TrimSpace(c.Key) == "" || strings.TrimSpace(c.Value) == "" {
+				return errors.New("configurations need a key and value")
+			}
+		}
+	case CurtGarageVehicle:
+		if g.Submodel != "" || len(g.Configurations) > 0 {
+			return errors.New("CURT vehicles have a style, not a submodel or configurations")
+		}
+	default:
+		return fmt.Errorf("vehicle type must be %s or %s", AcesGarageVehicle, CurtGarageVehicle)
+	}
+
+	if g.Nickname == "" {
+		g.Nickname = strings.Join([]string{strconv.Itoa(g.Year), g.Make, g.Model}, " ")
+	}
+	if len(g.Nickname) > 100 {
+		return errors.New("nickname must be 100 characters or less")
+	}
+	return nil
+}
+
+// GetGarage loads the vehicles the user saved, by nickname.
+func (cu *CustomerUser) GetGarage() error {
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	cu.Garage = make([]GarageVehicle, 0)
+	return session.DB(database.ProductDatabase).C(database.GarageCollectionName).
+		Find(bson.M{"user_id": cu.Id}).
+		Sort("nickname").
+		All(&cu.Garage)
+}
+
+// GetGarageVehicle returns one of the user's saved vehicles.
+func (cu *CustomerUser) GetGarageVehicle(id string) (GarageVehicle, error) {
+	var g GarageVehicle
+	if !bson.IsObjectIdHex(id) {
+		return g, ErrGarageVehicleNotFound
+	}
+
+	if err := database.Init(); err != nil {
+		return g, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	err := session.DB(database.ProductDatabase).C(database.GarageCollectionName).
+		Find(bson.M{"_id": bson.ObjectIdHex(id), "user_id": cu.Id}).
+		One(&g)
+	if err == mgo.ErrNotFound {
+		return g, ErrGarageVehicleNotFound
+	}
+	return g, err
+}
+
+// AddGarageVehicle saves the vehicle to the user's garage.
+func (cu *CustomerUser) AddGarageVehicle(g *GarageVehicle) error {
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	c := session.DB(database.ProductDatabase).C(database.GarageCollectionName)
+	count, err := c.Find(bson.M{"user_id": cu.Id}).Count()
+	if err != nil {
+		return err
+	}
+	if count >= MaxGarageVehicles {
+		return ErrGarageFull
+	}
+
+	g.ID = bson.NewObjectId()
+	g.UserID = cu.Id
+	g.DateAdded = time.Now()
+	g.DateModified = g.DateAdded
+	return c.Insert(g)
+}
+
+// UpdateGarageVehicle replaces one of the user's saved vehicles.
+func (cu *CustomerUser) UpdateGarageVehicle(g *GarageVehicle) error {
+	existing, err := cu.GetGarageVehicle(g.ID.Hex())
+	if err != nil {
+		return err
+	}
+	if err = g.Validate(); err != nil {
+		return err
+	}
+
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	g.UserID = cu.Id
+	g.DateAdded = existing.DateAdded
+	g.DateModified = time.Now()
+	return session.DB(database.ProductDatabase).C(database.GarageCollectionName).UpdateId(g.ID, g)
+}
+
+// DeleteGarageVehicle removes one of the user's saved vehicles.
+func (cu *CustomerUser) DeleteGarageVehicle(id string) error {
+	if !bson.IsObjectIdHex(id) {
+		return ErrGarageVehicleNotFound
+	}
+
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	err := session.DB(database.ProductDatabase).C(database.GarageCollectionName).
+		Remove(bson.M{"_id": bson.ObjectIdHex(id), "user_id": cu.Id})
+	if err == mgo.ErrNotFound {
+		return ErrGarageVehicleNotFound
+	}
+	return err
+}
+
+// deleteGarage removes every vehicle the user saved.
+func (cu *CustomerUser) deleteGarage() error {
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	_, err := session.DB(database.ProductDatabase).C(database.GarageCollectionName).RemoveAll(bson.M{"user_id": cu.Id})
+	return err
+}
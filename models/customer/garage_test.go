@@ -0,0 +1,51 @@
+package customer
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestGarageVehicle(t *testing.T) {
+	Convey("Testing Validate() with an ACES vehicle", t, func() {
+		g := GarageVehicle{
+			Year:           2016,
+			Make:           " Chevrolet ",
+			Model:          "Silverado 1500",
+			Submodel:       "LT",
+			Configurations: []GarageConfiguration{{Key: "Bed Length", Value: "78.0"}},
+		}
+		So(g.Validate(), ShouldBeNil)
+		So(g.Type, ShouldEqual, AcesGarageVehicle)
+		So(g.Make, ShouldEqual, "Chevrolet")
+		So(g.Nickname, ShouldEqual, "2016 Chevrolet Silverado 1500")
+
+		g.Submodel = ""
+		So(g.Validate(), ShouldNotBeNil)
+
+		g.Submodel = "LT"
+		g.Style = "Crew Cab"
+		So(g.Validate(), ShouldNotBeNil)
+	})
+
+	Convey("Testing Validate() with a CURT vehicle", t, func() {
+		g := GarageVehicle{
+			Nickname: "Joe's truck",
+			Type:     "CURT",
+			Year:     2016,
+			Make:     "Ford",
+			Model:    "F-150",
+			Style:    "All",
+		}
+		So(g.Validate(), ShouldBeNil)
+		So(g.Type, ShouldEqual, CurtGarageVehicle)
+		So(g.Nickname, ShouldEqual, "Joe's truck")
+
+		g.Submodel = "XL"
+		So(g.Validate(), ShouldNotBeNil)
+	})
+
+	Convey("Testing Validate() with missing fields", t, func() {
+		So((&GarageVehicle{Make: "Ford", Model: "F-150"}).Validate(), ShouldNotBeNil)
+		So((&GarageVehicle{Year: 2016, Make: "Ford", Model: "F-150", Type: "aries"}).Validate(), ShouldNotBeNil)
+	})
+}
@@ -42,6 +42,7 @@ type CustomerUser struct {
 	Keys               []ApiCredentials `json:"keys" xml:"keys"`
 	Brands             brand.Brands     `json:"brands,omitempty" xml:"brands,omitempty"`
 	ComnetAccounts     []ComnetAccount  `json:"accounts" xml:"accounts"`
+	Garage             []GarageVehicle  `json:"garage,omitempty" xml:"garage,omitempty"`
 }
 
 type ComnetAccountType struct {
@@ -1302,7 +1303,7 @@ func (cu *CustomerUser) Delete() error {
 		return err
 	}
 	tx.Commit()
-	return nil
+	return cu.deleteGarage()
 }
 
 func (cu *CustomerUser) SendRegistrationEmail() error {
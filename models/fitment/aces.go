@@ -0,0 +1,93 @@
+package fitment
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/curt-labs/API/models/products"
+)
+
+// partsTimeout is how long the ACES engine waits for its parts, the same as
+// the /vehicle lookup.
+var partsTimeout = 5 * time.Second
+
+// ErrPartsTimeout is returned when the ACES engine gives up on its parts.
+var ErrPartsTimeout = errors.New("timed out loading the parts of the vehicle")
+
+func init() {
+	Register("aces", func(ctx *Context) FitmentProvider {
+		return &acesProvider{ctx: ctx}
+	})
+}
+
+// acesProvider looks vehicles up in the ACES VCdb tables, as /vehicle does.
+type acesProvider struct {
+	ctx *Context
+}
+
+func (a *acesProvider) lookup(v Vehicle) products.Lookup {
+	year, _ := strconv.Atoi(v.Year)
+	return products.Lookup{
+		Brands: a.ctx.DataContext.BrandArray,
+		Vehicle: products.Vehicle{
+			Base: products.BaseVehicle{
+				Year:  year,
+				Make:  v.Make,
+				Model: v.Model,
+			},
+			Submodel:       v.Style,
+			Configurations: v.Configurations,
+		},
+	}
+}
+
+func (a *acesProvider) Years() ([]string, error) {
+	l := a.lookup(Vehicle{})
+	if err := l.GetYears(a.ctx.DataContext); err != nil {
+		return nil, err
+	}
+	return years(l.Years), nil
+}
+
+func (a *acesProvider) Makes(v Vehicle) ([]string, error) {
+	l := a.lookup(v)
+	err := l.GetMakes(a.ctx.DataContext)
+	return l.Makes, err
+}
+
+func (a *acesProvider) Models(v Vehicle) ([]string, error) {
+	l := a.lookup(v)
+	err := l.GetModels()
+	return l.Models, err
+}
+
+func (a *acesProvider) Styles(v Vehicle) ([]string, error) {
+	l := a.lookup(v)
+	err := l.GetSubmodels()
+	return l.Submodels, err
+}
+
+func (a *acesProvider) Configurations(v Vehicle) ([]products.ConfigurationOption, error) {
+	l := a.lookup(v)
+	err := l.GetConfigurations()
+	return l.Configurations, err
+}
+
+func (a *acesProvider) Parts(v Vehicle) ([]products.Part, error) {
+	l := a.lookup(v)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- l.GetParts(a.ctx.Page, a.ctx.Count, nil, a.ctx.DataContext)
+	}()
+
+	select {
+	case err := <-errs:
+		if err != nil {
+			return nil, err
+		}
+		return l.Parts, nil
+	case <-time.After(partsTimeout):
+		return nil, ErrPartsTimeout
+	}
+}
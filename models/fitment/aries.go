@@ -0,0 +1,117 @@
+package fitment
+
+import (
+	"sort"
+
+	"github.com/curt-labs/API/models/products"
+)
+
+func init() {
+	Register("aries", func(ctx *Context) FitmentProvider {
+		return &ariesProvider{ctx: ctx}
+	})
+}
+
+// ariesProvider looks vehicles up in the ARIES vehicle collections, as the
+// /vehicle/mongo routes do. The context's category picks the collection;
+// without one every collection is searched. It has no configurations.
+type ariesProvider struct {
+	ctx *Context
+}
+
+func (a *ariesProvider) collections() ([]string, error) {
+	if a.ctx.Category != "" {
+		return []string{a.ctx.Category}, nil
+	}
+	return products.GetAriesVehicleCollections(a.ctx.Session)
+}
+
+// apps returns the values of the next step for the vehicle across the
+// collections.
+func (a *ariesProvider) apps(v Vehicle) ([]string, error) {
+	cols, err := a.collections()
+	if err != nil {
+		return nil, err
+	}
+
+	nv := products.NoSqlVehicle{
+		Year:  v.Year,
+		Make:  v.Make,
+		Model: v.Model,
+	}
+	var vals []string
+	for _, col := range cols {
+		_, colVals, err := products.GetApps(nv, col)
+		if err != nil {
+			return nil, err
+		}
+		vals = merge(vals, colVals...)
+	}
+	return vals, nil
+}
+
+func (a *ariesProvider) Years() ([]string, error) {
+	vals, err := a.apps(Vehicle{})
+	sort.Sort(sort.Reverse(sort.StringSlice(vals)))
+	return vals, err
+}
+
+func (a *ariesProvider) Makes(v Vehicle) ([]string, error) {
+	vals, err := a.apps(Vehicle{Year: v.Year})
+	sort.Strings(vals)
+	return vals, err
+}
+
+func (a *ariesProvider) Models(v Vehicle) ([]string, error) {
+	vals, err := a.apps(Vehicle{Year: v.Year, Make: v.Make})
+	sort.Strings(vals)
+	return vals, err
+}
+
+func (a *ariesProvider) Styles(v Vehicle) ([]string, error) {
+	vals, err := a.apps(Vehicle{Year: v.Year, Make: v.Make, Model: v.Model})
+	sort.Strings(vals)
+	return vals, err
+}
+
+func (a *ariesProvider) Configurations(v Vehicle) ([]products.ConfigurationOption, error) {
+	return nil, nil
+}
+
+func (a *ariesProvider) Parts(v Vehicle) ([]products.Part, error) {
+	nv := products.NoSqlVehicle{
+		Year:  v.Year,
+		Make:  v.Make,
+		Model: v.Model,
+		Style: v.Style,
+	}
+
+	if a.ctx.Category != "" {
+		l, err := products.FindVehiclesWithParts(nv, a.ctx.Category, a.ctx.DataContext, a.ctx.Session)
+		return l.Parts, err
+	}
+
+	lookups, err := products.FindVehiclesFromAllCategories(nv, a.ctx.DataContext, a.ctx.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	var cols []string
+	for col := range lookups {
+		cols = append(cols, col)
+	}
+	sort.Strings(cols)
+
+	var parts []products.Part
+	seen := make(map[int]bool)
+	for _, col := range cols {
+		for _, p := range lookups[col].Parts {
+			if seen[p.ID] {
+				continue
+			}
+			seen[p.ID] = true
+			parts = append(parts, p)
+		}
+	}
+	return parts, nil
+}
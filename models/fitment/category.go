@@ -0,0 +1,121 @@
+package fitment
+
+import (
+	"strings"
+
+	"github.com/curt-labs/API/models/products"
+)
+
+func init() {
+	Register("category", func(ctx *Context) FitmentProvider {
+		return &categoryProvider{ctx: ctx}
+	})
+}
+
+// categoryProvider looks vehicles up by category style, as /vehicle/category
+// does. The styles come from every category the vehicle's parts are in.
+type categoryProvider struct {
+	ctx *Context
+}
+
+func (c *categoryProvider) query(v Vehicle) (*products.CategoryVehicle, error) {
+	ctx := &products.LookupContext{
+		Session:      c.ctx.Session,
+		Statuses:     c.ctx.Statuses,
+		Brands:       c.ctx.DataContext.BrandArray,
+		Visibilities: products.Visibilities(c.ctx.DataContext),
+	}
+
+	args := []string{v.Year, v.Make, v.Model, c.ctx.Category}
+	for len(args) > 0 && args[len(args)-1] == "" {
+		args = args[:len(args)-1]
+	}
+	return products.Query(ctx, args...)
+}
+
+func (c *categoryProvider) Years() ([]string, error) {
+	cv, err := c.query(Vehicle{})
+	if err != nil {
+		return nil, err
+	}
+	return cv.Years, nil
+}
+
+func (c *categoryProvider) Makes(v Vehicle) ([]string, error) {
+	cv, err := c.query(v)
+	if err != nil {
+		return nil, err
+	}
+	return cv.Makes, nil
+}
+
+func (c *categoryProvider) Models(v Vehicle) ([]string, error) {
+	cv, err := c.query(v)
+	if err != nil {
+		return nil, err
+	}
+	return cv.Models, nil
+}
+
+func (c *categoryProvider) Styles(v Vehicle) ([]string, error) {
+	cv, err := c.query(v)
+	if err != nil {
+		return nil, err
+	}
+	return categoryStyles(cv.Categories), nil
+}
+
+func (c *categoryProvider) Configurations(v Vehicle) ([]products.ConfigurationOption, error) {
+	return nil, nil
+}
+
+func (c *categoryProvider) Parts(v Vehicle) ([]products.Part, error) {
+	cv, err := c.query(v)
+	if err != nil {
+		return nil, err
+	}
+	return styleParts(cv.Categories, cv.Products, v.Style), nil
+}
+
+// categoryStyles returns the styles of the categories, leaving out the
+// placeholder of the parts that fit every style.
+func categoryStyles(cats []products.LookupCategory) []string {
+	var styles []string
+	for _, cat := range cats {
+		for _, opt := range cat.StyleOptions {
+			if opt.Style == products.AllPlaceholder {
+				continue
+			}
+			styles = merge(styles, opt.Style)
+		}
+	}
+	return styles
+}
+
+// styleParts returns the parts that fit the style, including the parts that
+// fit every style. All of the parts fit when there is no style.
+func styleParts(cats []products.LookupCategory, parts []products.Part, style string) []products.Part {
+	if style == "" {
+		return parts
+	}
+
+	numbers := make(map[string]bool)
+	for _, cat := range cats {
+		for _, opt := range cat.StyleOptions {
+			if opt.Style != products.AllPlaceholder && !strings.EqualFold(opt.Style, style) {
+				continue
+			}
+			for _, fit := range opt.FitmentNumbers {
+				numbers[fit.Number] = true
+			}
+		}
+	}
+
+	var fits []products.Part
+	for _, p := range parts {
+		if numbers[p.PartNumber] {
+			fits = append(fits, p)
+		}
+	}
+	return fits
+}
@@ -0,0 +1,62 @@
+package fitment
+
+import (
+	"github.com/curt-labs/API/models/products"
+)
+
+func init() {
+	Register("curt", func(ctx *Context) FitmentProvider {
+		return &curtProvider{ctx: ctx}
+	})
+}
+
+// curtProvider looks vehicles up in the CURT vehicle applications of the
+// product catalog, as /vehicle/curt does. It has no configurations.
+type curtProvider struct {
+	ctx *Context
+}
+
+func (c *curtProvider) lookup(v Vehicle) products.CurtLookup {
+	return products.CurtLookup{
+		CurtVehicle: products.CurtVehicle{
+			Year:  v.Year,
+			Make:  v.Make,
+			Model: v.Model,
+			Style: v.Style,
+		},
+	}
+}
+
+func (c *curtProvider) Years() ([]string, error) {
+	l := c.lookup(Vehicle{})
+	err := l.GetYears(c.ctx.HeavyDuty)
+	return l.Years, err
+}
+
+func (c *curtProvider) Makes(v Vehicle) ([]string, error) {
+	l := c.lookup(v)
+	err := l.GetMakes(c.ctx.HeavyDuty)
+	return l.Makes, err
+}
+
+func (c *curtProvider) Models(v Vehicle) ([]string, error) {
+	l := c.lookup(v)
+	err := l.GetModels(c.ctx.HeavyDuty)
+	return l.Models, err
+}
+
+func (c *curtProvider) Styles(v Vehicle) ([]string, error) {
+	l := c.lookup(v)
+	err := l.GetStyles(c.ctx.HeavyDuty)
+	return l.Styles, err
+}
+
+func (c *curtProvider) Configurations(v Vehicle) ([]products.ConfigurationOption, error) {
+	return nil, nil
+}
+
+func (c *curtProvider) Parts(v Vehicle) ([]products.Part, error) {
+	l := c.lookup(v)
+	err := l.GetParts(c.ctx.DataContext, c.ctx.HeavyDuty)
+	return l.Parts, err
+}
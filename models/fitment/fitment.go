@@ -0,0 +1,236 @@
+package fitment
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/models/products"
+
+	"gopkg.in/mgo.v2"
+)
+
+var (
+	// ErrEngine is returned when no provider is registered under an engine
+	// name.
+	ErrEngine = errors.New("unknown fitment engine")
+
+	// DefaultEngine serves lookups for callers that don't name an engine
+	// and aren't limited to a single brand.
+	DefaultEngine = "aces"
+
+	providers = map[string]Factory{}
+
+	// brandEngines is the engine that serves the vehicle lookups of each
+	// brand's website.
+	brandEngines = map[int]string{
+		1: "aces",
+		3: "aries",
+		4: "luverne",
+	}
+)
+
+// FitmentProvider is a Year/Make/Model lookup engine. Each step narrows the
+// vehicle by one more attribute; the engines that don't know a step return
+// nothing for it.
+type FitmentProvider interface {
+	Years() ([]string, error)
+	Makes(v Vehicle) ([]string, error)
+	Models(v Vehicle) ([]string, error)
+	Styles(v Vehicle) ([]string, error)
+	Configurations(v Vehicle) ([]products.ConfigurationOption, error)
+	Parts(v Vehicle) ([]products.Part, error)
+}
+
+// Factory creates a provider for a single lookup.
+type Factory func(ctx *Context) FitmentProvider
+
+// Context holds the caller and resources a provider runs a lookup with.
+type Context struct {
+	DataContext *apicontext.DataContext
+	Session     *mgo.Session
+	Statuses    []int
+
+	// Category limits the category style engines to a category title
+	// and the ARIES engine to a collection.
+	Category  string
+	HeavyDuty bool
+	Page      int
+	Count     int
+}
+
+// Vehicle is the vehicle being looked up, as far as it has been narrowed.
+// The submodel of ACES lookups is its Style.
+type Vehicle struct {
+	Year           string                   `json:"year,omitempty" xml:"year,omitempty"`
+	Make           string                   `json:"make,omitempty" xml:"make,omitempty"`
+	Model          string                   `json:"model,omitempty" xml:"model,omitempty"`
+	Style          string                   `json:"style,omitempty" xml:"style,omitempty"`
+	Configurations []products.Configuration `json:"configurations,omitempty" xml:"configurations,omitempty"`
+}
+
+// Lookup is the response of every engine.
+type Lookup struct {
+	Engine         string                         `json:"engine" xml:"engine,attr"`
+	Vehicle        Vehicle                        `json:"vehicle" xml:"vehicle"`
+	Years          []string                       `json:"available_years,omitempty" xml:"available_years,omitempty"`
+	Makes          []string                       `json:"available_makes,omitempty" xml:"available_makes,omitempty"`
+	Models         []string                       `json:"available_models,omitempty" xml:"available_models,omitempty"`
+	Styles         []string                       `json:"available_styles,omitempty" xml:"available_styles,omitempty"`
+	Configurations []products.ConfigurationOption `json:"available_configurations,omitempty" xml:"available_configurations,omitempty"`
+	Parts          []products.Part                `json:"parts,omitempty" xml:"parts,omitempty"`
+	Suggestions    []string                       `json:"suggestions,omitempty" xml:"suggestions,omitempty"`
+}
+
+// Register makes a provider available under the engine name, replacing
+// any provider registered under it before.
+func Register(engine string, f Factory) {
+	providers[strings.ToLower(engine)] = f
+}
+
+// Engines returns the names of the registered engines.
+func Engines() []string {
+	var names []string
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates the provider registered under the engine name.
+func New(engine string, ctx *Context) (FitmentProvider, error) {
+	f, ok := providers[strings.ToLower(engine)]
+	if !ok {
+		return nil, ErrEngine
+	}
+	return f(ctx), nil
+}
+
+// EngineFor returns the engine that serves the brands of the data context,
+// falling back to DefaultEngine when the caller has more than one brand.
+func EngineFor(dtx *apicontext.DataContext) string {
+	if dtx != nil && len(dtx.BrandArray) == 1 {
+		if engine, ok := brandEngines[dtx.BrandArray[0]]; ok {
+			return engine
+		}
+	}
+	return DefaultEngine
+}
+
+// Resolve returns the next step of the lookup for the vehicle. Once the
+// model is known the matching parts are returned along with the styles, or
+// the configurations when the style is known as well. A make or model that
+// isn't one of the engine's is resolved through the alias table, and one
+// that still matches nothing gets suggestions instead.
+func Resolve(engine string, p FitmentProvider, v Vehicle) (Lookup, error) {
+	l := Lookup{
+		Engine: strings.ToLower(engine),
+	}
+
+	err := resolveVehicle(p, &v)
+	l.Vehicle = v
+	if err != nil {
+		return l, err
+	}
+
+	switch {
+	case v.Year == "":
+		l.Years, err = p.Years()
+	case v.Make == "":
+		l.Makes, err = p.Makes(v)
+	case v.Model == "":
+		l.Models, err = p.Models(v)
+		if err == nil && len(l.Models) == 0 {
+			l.Suggestions = suggestMake(p, v)
+		}
+	default:
+		if v.Style == "" {
+			l.Styles, err = p.Styles(v)
+		} else {
+			l.Configurations, err = p.Configurations(v)
+		}
+		if err != nil {
+			return l, err
+		}
+		l.Parts, err = p.Parts(v)
+		if err == nil && len(l.Parts) == 0 && len(l.Styles) == 0 && len(l.Configurations) == 0 {
+			l.Suggestions = suggestModel(p, v)
+		}
+	}
+
+	return l, err
+}
+
+// resolveVehicle resolves the make and model of the vehicle against the
+// makes and models of the engine.
+func resolveVehicle(p FitmentProvider, v *Vehicle) error {
+	if v.Year == "" {
+		return nil
+	}
+
+	var err error
+	v.Make, err = products.ResolveMake(v.Make, func() ([]string, error) {
+		return p.Makes(Vehicle{Year: v.Year})
+	})
+	if err != nil || v.Make == "" {
+		return err
+	}
+	v.Model, err = products.ResolveModel(v.Make, v.Model, func() ([]string, error) {
+		return p.Models(Vehicle{Year: v.Year, Make: v.Make})
+	})
+	return err
+}
+
+// suggestMake returns the makes of the year closest to the make of the
+// vehicle. Suggestions are a courtesy, so errors just mean there are none.
+func suggestMake(p FitmentProvider, v Vehicle) []string {
+	makes, err := p.Makes(Vehicle{Year: v.Year})
+	if err != nil {
+		return nil
+	}
+	return products.Suggest(v.Make, makes)
+}
+
+// suggestModel returns the models of the make closest to the model of the
+// vehicle, or the closest makes when the make matched nothing either.
+func suggestModel(p FitmentProvider, v Vehicle) []string {
+	models, err := p.Models(Vehicle{Year: v.Year, Make: v.Make})
+	if err != nil {
+		return nil
+	}
+	if len(models) == 0 {
+		return suggestMake(p, v)
+	}
+	return products.Suggest(v.Model, models)
+}
+
+func years(ints []int) []string {
+	strs := make([]string, 0, len(ints))
+	for _, i := range ints {
+		strs = append(strs, strconv.Itoa(i))
+	}
+	return strs
+}
+
+// merge adds the values missing from dst without regard to case.
+func merge(dst []string, vals ...string) []string {
+	for _, val := range vals {
+		if val == "" {
+			continue
+		}
+		found := false
+		for _, d := range dst {
+			if strings.EqualFold(d, val) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst = append(dst, val)
+		}
+	}
+	return dst
+}
@@ -0,0 +1,206 @@
+package fitment
+
+import (
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+type fakeProvider struct {
+	calls []string
+}
+
+func (f *fakeProvider) Years() ([]string, error) {
+	f.calls = append(f.calls, "years")
+	return []string{"2016", "2015"}, nil
+}
+
+func (f *fakeProvider) Makes(v Vehicle) ([]string, error) {
+	f.calls = append(f.calls, "makes")
+	return []string{"Chevrolet"}, nil
+}
+
+func (f *fakeProvider) Models(v Vehicle) ([]string, error) {
+	f.calls = append(f.calls, "models")
+	return []string{"Silverado 1500"}, nil
+}
+
+func (f *fakeProvider) Styles(v Vehicle) ([]string, error) {
+	f.calls = append(f.calls, "styles")
+	return []string{"LT"}, nil
+}
+
+func (f *fakeProvider) Configurations(v Vehicle) ([]products.ConfigurationOption, error) {
+	f.calls = append(f.calls, "configurations")
+	return []products.ConfigurationOption{{Type: "Bed Length", Options: []string{"Short"}}}, nil
+}
+
+func (f *fakeProvider) Parts(v Vehicle) ([]products.Part, error) {
+	f.calls = append(f.calls, "parts")
+	return []products.Part{{ID: 13000}}, nil
+}
+
+// unknownProvider only knows the Chevrolet Silverado 1500, and has no parts
+// for it.
+type unknownProvider struct {
+	fakeProvider
+}
+
+func (u *unknownProvider) Models(v Vehicle) ([]string, error) {
+	if v.Make != "Chevrolet" {
+		return nil, nil
+	}
+	return []string{"Silverado 1500", "Silverado 2500 HD"}, nil
+}
+
+func (u *unknownProvider) Styles(v Vehicle) ([]string, error) {
+	return nil, nil
+}
+
+func (u *unknownProvider) Parts(v Vehicle) ([]products.Part, error) {
+	return nil, nil
+}
+
+func TestResolve(t *testing.T) {
+	Convey("Testing Resolve()", t, func() {
+		f := &fakeProvider{}
+		l, err := Resolve("Fake", f, Vehicle{})
+		So(err, ShouldBeNil)
+		So(l.Engine, ShouldEqual, "fake")
+		So(l.Years, ShouldResemble, []string{"2016", "2015"})
+		So(f.calls, ShouldResemble, []string{"years"})
+
+		f = &fakeProvider{}
+		l, _ = Resolve("fake", f, Vehicle{Year: "2016"})
+		So(l.Makes, ShouldResemble, []string{"Chevrolet"})
+		So(f.calls, ShouldResemble, []string{"makes"})
+
+		f = &fakeProvider{}
+		l, _ = Resolve("fake", f, Vehicle{Year: "2016", Make: "Chevrolet"})
+		So(f.calls, ShouldResemble, []string{"models"})
+
+		f = &fakeProvider{}
+		l, _ = Resolve("fake", f, Vehicle{Year: "2016", Make: "Chevrolet", Model: "Silverado 1500"})
+		So(f.calls, ShouldResemble, []string{"styles", "parts"})
+		So(l.Styles, ShouldResemble, []string{"LT"})
+		So(len(l.Parts), ShouldEqual, 1)
+
+		f = &fakeProvider{}
+		l, _ = Resolve("fake", f, Vehicle{Year: "2016", Make: "Chevrolet", Model: "Silverado 1500", Style: "LT"})
+		So(f.calls, ShouldResemble, []string{"configurations", "parts"})
+		So(l.Styles, ShouldBeEmpty)
+		So(l.Vehicle.Style, ShouldEqual, "LT")
+	})
+
+	Convey("Testing Resolve() with aliases and suggestions", t, func() {
+		l, err := Resolve("fake", &unknownProvider{}, Vehicle{Year: "2016", Make: "chevy"})
+		So(err, ShouldBeNil)
+		So(l.Vehicle.Make, ShouldEqual, "Chevrolet")
+		So(l.Models, ShouldResemble, []string{"Silverado 1500", "Silverado 2500 HD"})
+		So(l.Suggestions, ShouldBeEmpty)
+
+		l, _ = Resolve("fake", &unknownProvider{}, Vehicle{Year: "2016", Make: "Chevorlet"})
+		So(l.Models, ShouldBeEmpty)
+		So(l.Suggestions, ShouldResemble, []string{"Chevrolet"})
+
+		l, _ = Resolve("fake", &unknownProvider{}, Vehicle{Year: "2016", Make: "Chevy", Model: "Silverado 15"})
+		So(l.Suggestions, ShouldResemble, []string{"Silverado 1500"})
+
+		l, _ = Resolve("fake", &unknownProvider{}, Vehicle{Year: "2016", Make: "Chevy", Model: "Silverado"})
+		So(l.Vehicle.Model, ShouldEqual, "Silverado")
+		So(l.Suggestions, ShouldResemble, []string{"Silverado 1500", "Silverado 2500 HD"})
+	})
+}
+
+func TestRegistry(t *testing.T) {
+	Convey("Testing New()", t, func() {
+		So(Engines(), ShouldResemble, []string{"aces", "aries", "category", "curt", "luverne"})
+
+		p, err := New("ACES", &Context{})
+		So(err, ShouldBeNil)
+		So(p, ShouldNotBeNil)
+
+		_, err = New("vcdb", &Context{})
+		So(err, ShouldEqual, ErrEngine)
+
+		Register("fake", func(ctx *Context) FitmentProvider { return &fakeProvider{} })
+		defer delete(providers, "fake")
+		p, err = New("fake", &Context{})
+		So(err, ShouldBeNil)
+		So(p, ShouldHaveSameTypeAs, &fakeProvider{})
+	})
+
+	Convey("Testing EngineFor()", t, func() {
+		So(EngineFor(nil), ShouldEqual, DefaultEngine)
+		So(EngineFor(&apicontext.DataContext{BrandArray: []int{3}}), ShouldEqual, "aries")
+		So(EngineFor(&apicontext.DataContext{BrandArray: []int{4}}), ShouldEqual, "luverne")
+		So(EngineFor(&apicontext.DataContext{BrandArray: []int{1, 3}}), ShouldEqual, DefaultEngine)
+	})
+}
+
+func TestCategoryStyles(t *testing.T) {
+	cats := []products.LookupCategory{
+		{StyleOptions: []products.StyleOption{
+			{Style: "LT", FitmentNumbers: []products.FitmentMapping{{Number: "13000"}}},
+			{Style: products.AllPlaceholder, FitmentNumbers: []products.FitmentMapping{{Number: "11000"}}},
+		}},
+		{StyleOptions: []products.StyleOption{
+			{Style: "lt", FitmentNumbers: []products.FitmentMapping{{Number: "56000"}}},
+			{Style: "WT", FitmentNumbers: []products.FitmentMapping{{Number: "45000"}}},
+		}},
+	}
+	parts := []products.Part{{PartNumber: "13000"}, {PartNumber: "11000"}, {PartNumber: "56000"}, {PartNumber: "45000"}}
+
+	Convey("Testing categoryStyles()", t, func() {
+		So(categoryStyles(cats), ShouldResemble, []string{"LT", "WT"})
+	})
+
+	Convey("Testing styleParts()", t, func() {
+		So(len(styleParts(cats, parts, "")), ShouldEqual, 4)
+
+		var numbers []string
+		for _, p := range styleParts(cats, parts, "LT") {
+			numbers = append(numbers, p.PartNumber)
+		}
+		So(numbers, ShouldResemble, []string{"13000", "11000", "56000"})
+	})
+}
+
+func TestLuverne(t *testing.T) {
+	cats := []products.LuverneLookupCategory{
+		{
+			Fitments: []*products.LuverneFitment{{Title: "Cab", Options: []string{"Crew"}}},
+			Products: []products.LuverneFitmentMapping{
+				{Number: "575001", Attributes: []products.LuverneFitmentAttribute{{Key: "Cab", Value: "Crew"}}},
+				{Number: "575002", Attributes: []products.LuverneFitmentAttribute{{Key: "Cab", Value: "Regular"}}},
+			},
+		},
+		{
+			Fitments: []*products.LuverneFitment{
+				{Title: "Cab", Options: []string{"Regular", "Crew"}},
+				{Title: "Box", Options: []string{"6.5'"}},
+			},
+			Products: []products.LuverneFitmentMapping{
+				{Number: "580001", Attributes: []products.LuverneFitmentAttribute{{Key: "Box", Value: "6.5'"}}},
+			},
+		},
+	}
+	parts := []products.Part{{PartNumber: "575001"}, {PartNumber: "575002"}, {PartNumber: "580001"}}
+
+	Convey("Testing luverneConfigurations()", t, func() {
+		So(luverneConfigurations(cats), ShouldResemble, []products.ConfigurationOption{
+			{Type: "Cab", Options: []string{"Crew", "Regular"}},
+			{Type: "Box", Options: []string{"6.5'"}},
+		})
+	})
+
+	Convey("Testing luverneParts()", t, func() {
+		So(len(luverneParts(cats, parts, nil)), ShouldEqual, 3)
+
+		fits := luverneParts(cats, parts, []products.Configuration{{Key: "cab", Value: "crew"}})
+		So(len(fits), ShouldEqual, 2)
+		So(fits[0].PartNumber, ShouldEqual, "575001")
+		So(fits[1].PartNumber, ShouldEqual, "580001")
+	})
+}
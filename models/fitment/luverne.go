@@ -0,0 +1,139 @@
+package fitment
+
+import (
+	"strings"
+
+	"github.com/curt-labs/API/models/products"
+)
+
+func init() {
+	Register("luverne", func(ctx *Context) FitmentProvider {
+		return &luverneProvider{ctx: ctx}
+	})
+}
+
+// luverneProvider looks vehicles up in the Luverne applications, as
+// /luverne/vehicle does. Luverne has no styles; the body, box, cab, fuel and
+// wheel fitments are its configurations.
+type luverneProvider struct {
+	ctx *Context
+}
+
+func (l *luverneProvider) query(v Vehicle) (*products.LuverneCategoryVehicle, error) {
+	ctx := &products.LuverneLookupContext{
+		Session:      l.ctx.Session,
+		Statuses:     l.ctx.Statuses,
+		Visibilities: products.Visibilities(l.ctx.DataContext),
+	}
+
+	args := []string{v.Year, v.Make, v.Model, l.ctx.Category}
+	for len(args) > 0 && args[len(args)-1] == "" {
+		args = args[:len(args)-1]
+	}
+	return products.LuverneQuery(ctx, args...)
+}
+
+func (l *luverneProvider) Years() ([]string, error) {
+	lv, err := l.query(Vehicle{})
+	if err != nil {
+		return nil, err
+	}
+	return lv.Years, nil
+}
+
+func (l *luverneProvider) Makes(v Vehicle) ([]string, error) {
+	lv, err := l.query(v)
+	if err != nil {
+		return nil, err
+	}
+	return lv.Makes, nil
+}
+
+func (l *luverneProvider) Models(v Vehicle) ([]string, error) {
+	lv, err := l.query(v)
+	if err != nil {
+		return nil, err
+	}
+	return lv.Models, nil
+}
+
+func (l *luverneProvider) Styles(v Vehicle) ([]string, error) {
+	return nil, nil
+}
+
+func (l *luverneProvider) Configurations(v Vehicle) ([]products.ConfigurationOption, error) {
+	lv, err := l.query(v)
+	if err != nil {
+		return nil, err
+	}
+	return luverneConfigurations(lv.Categories), nil
+}
+
+func (l *luverneProvider) Parts(v Vehicle) ([]products.Part, error) {
+	lv, err := l.query(v)
+	if err != nil {
+		return nil, err
+	}
+	return luverneParts(lv.Categories, lv.Products, v.Configurations), nil
+}
+
+// luverneConfigurations combines the fitments of every category.
+func luverneConfigurations(cats []products.LuverneLookupCategory) []products.ConfigurationOption {
+	var opts []products.ConfigurationOption
+	for _, cat := range cats {
+		for _, fit := range cat.Fitments {
+			found := false
+			for i := range opts {
+				if opts[i].Type == fit.Title {
+					opts[i].Options = merge(opts[i].Options, fit.Options...)
+					found = true
+					break
+				}
+			}
+			if !found {
+				opts = append(opts, products.ConfigurationOption{
+					Type:    fit.Title,
+					Options: merge(nil, fit.Options...),
+				})
+			}
+		}
+	}
+	return opts
+}
+
+// luverneParts returns the parts with an application that matches each of
+// the configurations. An application without a value for a configuration
+// matches it.
+func luverneParts(cats []products.LuverneLookupCategory, parts []products.Part, confs []products.Configuration) []products.Part {
+	if len(confs) == 0 {
+		return parts
+	}
+
+	numbers := make(map[string]bool)
+	for _, cat := range cats {
+		for _, mapping := range cat.Products {
+			if luverneMatches(mapping, confs) {
+				numbers[mapping.Number] = true
+			}
+		}
+	}
+
+	var fits []products.Part
+	for _, p := range parts {
+		if numbers[p.PartNumber] {
+			fits = append(fits, p)
+		}
+	}
+	return fits
+}
+
+func luverneMatches(mapping products.LuverneFitmentMapping, confs []products.Configuration) bool {
+	for _, conf := range confs {
+		for _, attr := range mapping.Attributes {
+			if strings.EqualFold(attr.Key, conf.Key) && !strings.EqualFold(attr.Value, conf.Value) {
+				return false
+			}
+		}
+	}
+	return true
+}
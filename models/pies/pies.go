@@ -0,0 +1,532 @@
+package pies
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// Version is the PIES version of the export.
+	Version = "7.2"
+
+	namespace = "http://www.autocare.org"
+	currency  = "USD"
+	language  = "EN"
+)
+
+var (
+	technicalContact = os.Getenv("PIES_TECHNICAL_CONTACT")
+	contactEmail     = os.Getenv("PIES_CONTACT_EMAIL")
+
+	// priceSheets are the PIES price types of our price types, each
+	// published on its own price sheet.
+	priceSheets = []struct {
+		Type      string
+		PriceType string
+		Sheet     string
+	}{
+		{"List", "LST", "LIST"},
+		{"Jobber", "JBR", "JOBBER"},
+		{"MAP", "MAP", "MAP"},
+		{"Retail", "RET", "RETAIL"},
+	}
+
+	// contentDescriptions are the PIES description codes of our content
+	// types, matched on a part of the type name.
+	contentDescriptions = []struct {
+		Match string
+		Code  string
+	}{
+		{"bullet", "FAB"},
+		{"feature", "FAB"},
+		{"marketing", "MKT"},
+		{"long", "EXT"},
+		{"description", "EXT"},
+	}
+
+	weightUnits = map[string]string{
+		"lb": "PG", "lbs": "PG", "pound": "PG", "pounds": "PG",
+		"oz": "ON", "ounce": "ON", "ounces": "ON",
+		"kg": "KG", "g": "GR",
+	}
+	dimensionUnits = map[string]string{
+		"in": "IN", "inch": "IN", "inches": "IN",
+		"ft": "FT", "cm": "CM", "mm": "MM", "m": "MR",
+	}
+)
+
+// Header is the PIES file header.
+type Header struct {
+	XMLName              xml.Name `xml:"Header"`
+	PIESVersion          string   `xml:"PIESVersion"`
+	SubmissionType       string   `xml:"SubmissionType"`
+	BlanketEffectiveDate string   `xml:"BlanketEffectiveDate"`
+	BrandOwnerAAIAID     string   `xml:"BrandOwnerAAIAID,omitempty"`
+	CurrencyCode         string   `xml:"CurrencyCode"`
+	LanguageCode         string   `xml:"LanguageCode"`
+	TechnicalContact     string   `xml:"TechnicalContact,omitempty"`
+	ContactEmail         string   `xml:"ContactEmail,omitempty"`
+}
+
+// PriceSheet describes the prices published under a price sheet number.
+type PriceSheet struct {
+	MaintenanceType  string `xml:"MaintenanceType,attr"`
+	PriceSheetNumber string `xml:"PriceSheetNumber"`
+	CurrencyCode     string `xml:"CurrencyCode"`
+	EffectiveDate    string `xml:"EffectiveDate"`
+}
+
+// Item is a part in PIES.
+type Item struct {
+	XMLName           xml.Name           `xml:"Item"`
+	MaintenanceType   string             `xml:"MaintenanceType,attr"`
+	HazardousMaterial string             `xml:"HazardousMaterialCode"`
+	GTIN              *GTIN              `xml:"ItemLevelGTIN,omitempty"`
+	PartNumber        string             `xml:"PartNumber"`
+	BrandAAIAID       string             `xml:"BrandAAIAID,omitempty"`
+	BrandLabel        string             `xml:"BrandLabel"`
+	PartTerminologyID int                `xml:"PartTerminologyID,omitempty"`
+	Descriptions      []Description      `xml:"Descriptions>Description,omitempty"`
+	Prices            []Pricing          `xml:"Prices>Pricing,omitempty"`
+	Attributes        []ProductAttribute `xml:"ProductAttributes>ProductAttribute,omitempty"`
+	Packages          []Package          `xml:"Packages>Package,omitempty"`
+	DigitalAssets     []DigitalAsset     `xml:"DigitalAssets>DigitalFileInformation,omitempty"`
+}
+
+// GTIN is the UPC of an item.
+type GTIN struct {
+	Qualifier string `xml:"GTINQualifier,attr"`
+	Value     string `xml:",chardata"`
+}
+
+// Description is a description segment.
+type Description struct {
+	MaintenanceType string `xml:"MaintenanceType,attr"`
+	Code            string `xml:"DescriptionCode,attr"`
+	LanguageCode    string `xml:"LanguageCode,attr"`
+	Sequence        int    `xml:"Sequence,attr,omitempty"`
+	Text            string `xml:",chardata"`
+}
+
+// Pricing is a price segment.
+type Pricing struct {
+	MaintenanceType  string `xml:"MaintenanceType,attr"`
+	PriceType        string `xml:"PriceType,attr"`
+	PriceSheetNumber string `xml:"PriceSheetNumber"`
+	CurrencyCode     string `xml:"CurrencyCode"`
+	Price            Price  `xml:"Price"`
+}
+
+// Price is an amount per unit of measure.
+type Price struct {
+	UOM   string `xml:"UOM,attr"`
+	Value string `xml:",chardata"`
+}
+
+// ProductAttribute is a product attribute segment.
+type ProductAttribute struct {
+	MaintenanceType string `xml:"MaintenanceType,attr"`
+	AttributeID     string `xml:"AttributeID,attr"`
+	PADBAttribute   string `xml:"PADBAttribute,attr"`
+	RecordNumber    int    `xml:"RecordNumber,attr"`
+	Value           string `xml:",chardata"`
+}
+
+// Package is a package segment.
+type Package struct {
+	MaintenanceType  string      `xml:"MaintenanceType,attr"`
+	PackageUOM       string      `xml:"PackageUOM"`
+	QuantityofEaches int         `xml:"QuantityofEaches"`
+	Dimensions       *Dimensions `xml:"Dimensions,omitempty"`
+	Weights          *Weights    `xml:"Weights,omitempty"`
+}
+
+// Dimensions are the shipping dimensions of a package.
+type Dimensions struct {
+	UOM            string `xml:"UOM,attr"`
+	ShippingHeight string `xml:"ShippingHeight"`
+	ShippingWidth  string `xml:"ShippingWidth"`
+	ShippingLength string `xml:"ShippingLength"`
+}
+
+// Weights is the weight of a package.
+type Weights struct {
+	UOM    string `xml:"UOM,attr"`
+	Weight string `xml:"Weight"`
+}
+
+// DigitalAsset is a digital file information segment.
+type DigitalAsset struct {
+	MaintenanceType string `xml:"MaintenanceType,attr"`
+	LanguageCode    string `xml:"LanguageCode,attr"`
+	FileName        string `xml:"FileName"`
+	AssetType       string `xml:"AssetType"`
+	FileType        string `xml:"FileType,omitempty"`
+	URI             string `xml:"URI"`
+}
+
+// Stats counts what a Writer wrote.
+type Stats struct {
+	Items   int `json:"items" xml:"items"`
+	Deleted int `json:"deleted" xml:"deleted"`
+}
+
+// Writer streams a PIES file one part at a time.
+type Writer struct {
+	enc   *xml.Encoder
+	brand brand.Brand
+	Stats Stats
+}
+
+// NewHeader returns the header of the brand's PIES file.
+func NewHeader(b brand.Brand, submission string) Header {
+	return Header{
+		PIESVersion:          Version,
+		SubmissionType:       submission,
+		BlanketEffectiveDate: time.Now().Format("2006-01-02"),
+		BrandOwnerAAIAID:     b.AutocareID,
+		CurrencyCode:         currency,
+		LanguageCode:         language,
+		TechnicalContact:     technicalContact,
+		ContactEmail:         contactEmail,
+	}
+}
+
+// NewWriter writes the start of the PIES file, its header and the price
+// sheets, and opens the items.
+func NewWriter(w io.Writer, b brand.Brand, h Header) (*Writer, error) {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return nil, err
+	}
+
+	pw := &Writer{
+		enc:   xml.NewEncoder(w),
+		brand: b,
+	}
+	pw.enc.Indent("", "\t")
+
+	start := xml.StartElement{
+		Name: xml.Name{Local: "PIES"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: namespace}},
+	}
+	if err := pw.enc.EncodeToken(start); err != nil {
+		return nil, err
+	}
+	if err := pw.enc.Encode(h); err != nil {
+		return nil, err
+	}
+
+	var sheets []PriceSheet
+	for _, ps := range priceSheets {
+		sheets = append(sheets, PriceSheet{
+			MaintenanceType:  "A",
+			PriceSheetNumber: ps.Sheet,
+			CurrencyCode:     currency,
+			EffectiveDate:    h.BlanketEffectiveDate,
+		})
+	}
+	wrapper := struct {
+		XMLName xml.Name     `xml:"PriceSheets"`
+		Sheets  []PriceSheet `xml:"PriceSheet"`
+	}{Sheets: sheets}
+	if err := pw.enc.Encode(wrapper); err != nil {
+		return nil, err
+	}
+
+	if err := pw.enc.EncodeToken(xml.StartElement{Name: xml.Name{Local: "Items"}}); err != nil {
+		return nil, err
+	}
+	return pw, nil
+}
+
+// WritePart writes the part as an item. A delete writes only the item's
+// identity with the delete maintenance type.
+func (w *Writer) WritePart(p products.Part, remove bool) error {
+	item := Item{
+		MaintenanceType:   "A",
+		HazardousMaterial: "N",
+		PartNumber:        p.PartNumber,
+		BrandAAIAID:       w.brand.AutocareID,
+		BrandLabel:        w.brand.Name,
+		PartTerminologyID: p.AcesPartTypeID,
+	}
+	if p.UPC != "" {
+		item.GTIN = &GTIN{Qualifier: "UP", Value: p.UPC}
+	}
+
+	if remove {
+		item.MaintenanceType = "D"
+		w.Stats.Deleted++
+		return w.enc.Encode(item)
+	}
+
+	item.Descriptions = descriptions(p)
+	item.Prices = prices(p.Pricing)
+	item.Attributes = attributes(p.Attributes)
+	item.Packages = packages(p.Packages)
+	item.DigitalAssets = digitalAssets(p)
+
+	w.Stats.Items++
+	return w.enc.Encode(item)
+}
+
+// Close writes the trailer and the end of the PIES file.
+func (w *Writer) Close() error {
+	if err := w.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "Items"}}); err != nil {
+		return err
+	}
+	trailer := struct {
+		XMLName         xml.Name `xml:"Trailer"`
+		ItemCount       int      `xml:"ItemCount"`
+		TransactionDate string   `xml:"TransactionDate"`
+	}{
+		ItemCount:       w.Stats.Items + w.Stats.Deleted,
+		TransactionDate: time.Now().Format("2006-01-02"),
+	}
+	if err := w.enc.Encode(trailer); err != nil {
+		return err
+	}
+	if err := w.enc.EncodeToken(xml.EndElement{Name: xml.Name{Local: "PIES"}}); err != nil {
+		return err
+	}
+	return w.enc.Flush()
+}
+
+// Generate streams the PIES file of the brand to w. A non zero since
+// exports only the parts modified since then, deleting the parts that are no
+// longer active.
+func Generate(w io.Writer, b brand.Brand, since time.Time) (Stats, error) {
+	if err := database.Init(); err != nil {
+		return Stats{}, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		"brand.id": b.ID,
+		"status": bson.M{
+			"$in": products.ActiveStatuses(),
+		},
+	}
+	submission := "FULL"
+	if !since.IsZero() {
+		delete(query, "status")
+		query["date_modified"] = bson.M{"$gte": since}
+		submission = "UPDATE"
+	}
+
+	fields := bson.M{
+		"id": 1, "part_number": 1, "status": 1, "short_description": 1, "upc": 1, "acesPartTypeId": 1,
+		"attributes": 1, "packages": 1, "pricing": 1, "images": 1, "content": 1, "install_sheet": 1,
+	}
+	var p products.Part
+	iter := session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Select(fields).Sort("part_number").Iter()
+	// read the first part before writing anything, so a failed query is
+	// still an error rather than an empty file
+	more := iter.Next(&p)
+	if !more {
+		if err := iter.Close(); err != nil {
+			return Stats{}, err
+		}
+	}
+
+	pw, err := NewWriter(w, b, NewHeader(b, submission))
+	if err != nil {
+		iter.Close()
+		return Stats{}, err
+	}
+
+	for ; more; more = iter.Next(&p) {
+		if err := pw.WritePart(p, !active(p.Status)); err != nil {
+			iter.Close()
+			return pw.Stats, err
+		}
+		p = products.Part{}
+	}
+	if err := iter.Close(); err != nil {
+		return pw.Stats, err
+	}
+
+	return pw.Stats, pw.Close()
+}
+
+func descriptions(p products.Part) []Description {
+	var descs []Description
+	if p.ShortDesc != "" {
+		descs = append(descs, Description{MaintenanceType: "A", Code: "DES", LanguageCode: language, Text: p.ShortDesc})
+	}
+
+	content := append([]products.Content(nil), p.Content...)
+	sort.SliceStable(content, func(i, j int) bool { return content[i].Sort < content[j].Sort })
+
+	sequences := make(map[string]int)
+	for _, c := range content {
+		code := descriptionCode(c.ContentType.Type)
+		if code == "" || strings.TrimSpace(c.Text) == "" {
+			continue
+		}
+		sequences[code]++
+		descs = append(descs, Description{
+			MaintenanceType: "A",
+			Code:            code,
+			LanguageCode:    language,
+			Sequence:        sequences[code],
+			Text:            strings.TrimSpace(c.Text),
+		})
+	}
+	return descs
+}
+
+func descriptionCode(contentType string) string {
+	t := strings.ToLower(contentType)
+	for _, cd := range contentDescriptions {
+		if strings.Contains(t, cd.Match) {
+			return cd.Code
+		}
+	}
+	return ""
+}
+
+func prices(pricing []products.Price) []Pricing {
+	var ps []Pricing
+	for _, pr := range pricing {
+		if pr.Price <= 0 {
+			continue
+		}
+		for _, sheet := range priceSheets {
+			if !strings.EqualFold(pr.Type, sheet.Type) {
+				continue
+			}
+			ps = append(ps, Pricing{
+				MaintenanceType:  "A",
+				PriceType:        sheet.PriceType,
+				PriceSheetNumber: sheet.Sheet,
+				CurrencyCode:     currency,
+				Price:            Price{UOM: "PE", Value: fmt.Sprintf("%.2f", pr.Price)},
+			})
+		}
+	}
+	return ps
+}
+
+func attributes(attrs []products.Attribute) []ProductAttribute {
+	var pas []ProductAttribute
+	for _, a := range attrs {
+		if strings.TrimSpace(a.Key) == "" || strings.TrimSpace(a.Value) == "" {
+			continue
+		}
+		pas = append(pas, ProductAttribute{
+			MaintenanceType: "A",
+			AttributeID:     a.Key,
+			PADBAttribute:   "N",
+			RecordNumber:    len(pas) + 1,
+			Value:           a.Value,
+		})
+	}
+	return pas
+}
+
+func packages(pkgs []products.Package) []Package {
+	var ps []Package
+	for _, pkg := range pkgs {
+		p := Package{
+			MaintenanceType:  "A",
+			PackageUOM:       strings.ToUpper(pkg.PackageUnit),
+			QuantityofEaches: pkg.Quantity,
+		}
+		if p.PackageUOM == "" {
+			p.PackageUOM = "EA"
+		}
+		if p.QuantityofEaches == 0 {
+			p.QuantityofEaches = 1
+		}
+		if pkg.Height > 0 || pkg.Width > 0 || pkg.Length > 0 {
+			p.Dimensions = &Dimensions{
+				UOM:            unit(dimensionUnits, pkg.DimensionUnit, "IN"),
+				ShippingHeight: number(pkg.Height),
+				ShippingWidth:  number(pkg.Width),
+				ShippingLength: number(pkg.Length),
+			}
+		}
+		if pkg.Weight > 0 {
+			p.Weights = &Weights{
+				UOM:    unit(weightUnits, pkg.WeightUnit, "PG"),
+				Weight: number(pkg.Weight),
+			}
+		}
+		ps = append(ps, p)
+	}
+	return ps
+}
+
+// digitalAssets returns the images, the first as the primary photo, and
+// the install sheet.
+func digitalAssets(p products.Part) []DigitalAsset {
+	var assets []DigitalAsset
+	for _, img := range p.Images {
+		if img.Path == nil {
+			continue
+		}
+		assetType := "ZZ1"
+		if len(assets) == 0 {
+			assetType = "P01"
+		}
+		assets = append(assets, asset(img.Path.String(), assetType))
+	}
+	if p.InstallSheet != nil && p.InstallSheet.String() != "" {
+		assets = append(assets, asset(p.InstallSheet.String(), "INS"))
+	}
+	return assets
+}
+
+func asset(uri, assetType string) DigitalAsset {
+	name := path.Base(uri)
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	return DigitalAsset{
+		MaintenanceType: "A",
+		LanguageCode:    language,
+		FileName:        name,
+		AssetType:       assetType,
+		FileType:        strings.ToUpper(strings.TrimPrefix(path.Ext(name), ".")),
+		URI:             uri,
+	}
+}
+
+func unit(units map[string]string, u, def string) string {
+	u = strings.ToLower(strings.TrimSpace(u))
+	if u == "" {
+		return def
+	}
+	if code, ok := units[u]; ok {
+		return code
+	}
+	return strings.ToUpper(u)
+}
+
+func number(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func active(status int) bool {
+	for _, s := range products.ActiveStatuses() {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
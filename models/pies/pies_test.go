@@ -0,0 +1,120 @@
+package pies
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/url"
+	"testing"
+)
+
+func testPart() products.Part {
+	sheet, _ := url.Parse("https://www.curtmfg.com/masterlibrary/13000/installsheet/13000.pdf")
+	img, _ := url.Parse("https://www.curtmfg.com/masterlibrary/13000/images/13000.jpg")
+	img2, _ := url.Parse("https://www.curtmfg.com/masterlibrary/13000/images/13000_2.png?v=1")
+	return products.Part{
+		PartNumber:     "13000",
+		Status:         800,
+		ShortDesc:      "Class 3 Trailer Hitch",
+		UPC:            "036293130001",
+		AcesPartTypeID: 4452,
+		InstallSheet:   sheet,
+		Images:         []products.Image{{Path: img}, {Path: img2}},
+		Attributes: []products.Attribute{
+			{Key: "Finish", Value: "Carbide Black Powder Coat"},
+			{Key: "Color", Value: ""},
+		},
+		Content: []products.Content{
+			{Text: "Rust resistant", ContentType: products.ContentType{Type: "Bullet"}, Sort: 2},
+			{Text: "Custom fit", ContentType: products.ContentType{Type: "Bullet"}, Sort: 1},
+			{Text: "https://www.curtmfg.com/13000.pdf", ContentType: products.ContentType{Type: "installationSheet"}},
+			{Text: "The best hitch", ContentType: products.ContentType{Type: "Marketing Description"}, Sort: 3},
+		},
+		Pricing: []products.Price{
+			{Type: "List", Price: 250},
+			{Type: "Jobber", Price: 175.5},
+			{Type: "Cost", Price: 100},
+		},
+		Packages: []products.Package{
+			{Height: 10, Width: 20, Length: 30.5, Weight: 50, DimensionUnit: "in", WeightUnit: "lb", PackageUnit: "ea", Quantity: 1},
+		},
+	}
+}
+
+func TestWriter(t *testing.T) {
+	b := brand.Brand{ID: 1, Name: "CURT", AutocareID: "BKDK"}
+
+	Convey("Testing Writer", t, func() {
+		var buf bytes.Buffer
+		w, err := NewWriter(&buf, b, NewHeader(b, "FULL"))
+		So(err, ShouldBeNil)
+		So(w.WritePart(testPart(), false), ShouldBeNil)
+		So(w.WritePart(products.Part{PartNumber: "11000"}, true), ShouldBeNil)
+		So(w.Close(), ShouldBeNil)
+
+		So(w.Stats.Items, ShouldEqual, 1)
+		So(w.Stats.Deleted, ShouldEqual, 1)
+
+		var doc struct {
+			XMLName xml.Name `xml:"PIES"`
+			Header  Header
+			Sheets  []PriceSheet `xml:"PriceSheets>PriceSheet"`
+			Items   []Item       `xml:"Items>Item"`
+			Count   int          `xml:"Trailer>ItemCount"`
+		}
+		So(xml.Unmarshal(buf.Bytes(), &doc), ShouldBeNil)
+		So(doc.Header.PIESVersion, ShouldEqual, Version)
+		So(doc.Header.BrandOwnerAAIAID, ShouldEqual, "BKDK")
+		So(len(doc.Sheets), ShouldEqual, len(priceSheets))
+		So(doc.Count, ShouldEqual, 2)
+		So(len(doc.Items), ShouldEqual, 2)
+
+		item := doc.Items[0]
+		So(item.MaintenanceType, ShouldEqual, "A")
+		So(item.GTIN.Value, ShouldEqual, "036293130001")
+		So(item.PartTerminologyID, ShouldEqual, 4452)
+		So(item.BrandLabel, ShouldEqual, "CURT")
+
+		So(len(item.Descriptions), ShouldEqual, 4)
+		So(item.Descriptions[0].Code, ShouldEqual, "DES")
+		So(item.Descriptions[1].Text, ShouldEqual, "Custom fit")
+		So(item.Descriptions[1].Sequence, ShouldEqual, 1)
+		So(item.Descriptions[2].Code, ShouldEqual, "FAB")
+		So(item.Descriptions[2].Sequence, ShouldEqual, 2)
+		So(item.Descriptions[3].Code, ShouldEqual, "MKT")
+
+		So(len(item.Prices), ShouldEqual, 2)
+		So(item.Prices[0].PriceType, ShouldEqual, "LST")
+		So(item.Prices[0].Price.Value, ShouldEqual, "250.00")
+		So(item.Prices[1].PriceType, ShouldEqual, "JBR")
+
+		So(len(item.Attributes), ShouldEqual, 1)
+		So(item.Attributes[0].AttributeID, ShouldEqual, "Finish")
+
+		So(len(item.Packages), ShouldEqual, 1)
+		So(item.Packages[0].PackageUOM, ShouldEqual, "EA")
+		So(item.Packages[0].Dimensions.UOM, ShouldEqual, "IN")
+		So(item.Packages[0].Dimensions.ShippingLength, ShouldEqual, "30.5")
+		So(item.Packages[0].Weights.UOM, ShouldEqual, "PG")
+
+		So(len(item.DigitalAssets), ShouldEqual, 3)
+		So(item.DigitalAssets[0].AssetType, ShouldEqual, "P01")
+		So(item.DigitalAssets[0].FileType, ShouldEqual, "JPG")
+		So(item.DigitalAssets[1].FileName, ShouldEqual, "13000_2.png")
+		So(item.DigitalAssets[2].AssetType, ShouldEqual, "INS")
+
+		deleted := doc.Items[1]
+		So(deleted.MaintenanceType, ShouldEqual, "D")
+		So(deleted.PartNumber, ShouldEqual, "11000")
+		So(deleted.Descriptions, ShouldBeEmpty)
+	})
+
+	Convey("Testing unit()", t, func() {
+		So(unit(weightUnits, "LBS", "PG"), ShouldEqual, "PG")
+		So(unit(weightUnits, "", "PG"), ShouldEqual, "PG")
+		So(unit(dimensionUnits, "cm", "IN"), ShouldEqual, "CM")
+		So(unit(dimensionUnits, "yd", "IN"), ShouldEqual, "YD")
+	})
+}
@@ -19,9 +19,10 @@ const AllPlaceholder = "All"
 
 // LookupContext Holds required configuration settings and resources.
 type LookupContext struct {
-	Session  *mgo.Session
-	Statuses []int
-	Brands   []int
+	Session      *mgo.Session
+	Statuses     []int
+	Brands       []int
+	Visibilities []string
 }
 
 // CategoryVehicleBase The lowest level vehicle properties used to query
@@ -73,6 +74,14 @@ type FitmentAttribute struct {
 // Query Returns a `CategoryVehicle` that holds matching information for the
 // queried `CategoryVehicleBase` attributes.
 func Query(ctx *LookupContext, args ...string) (*CategoryVehicle, error) {
+	args, err := resolveArgs(args, func(year string) ([]string, error) {
+		return getMakes(ctx, year)
+	}, func(year, vehicleMake string) ([]string, error) {
+		return getModels(ctx, year, vehicleMake)
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	var redisKey string
 	var category string
@@ -228,10 +237,7 @@ func getModels(ctx *LookupContext, year, vehicleMake string) ([]string, error) {
 		"vehicle_applications": bson.M{
 			"$elemMatch": bson.M{
 				"year": year,
-				"make": bson.RegEx{
-					Pattern: "^" + vehicleMake + "$",
-					Options: "i",
-				},
+				"make": exactly(vehicleMake),
 			},
 		},
 		"status": bson.M{
@@ -283,14 +289,8 @@ func getStyles(ctx *LookupContext, year, vehicleMake, model, category string) ([
 		"vehicle_applications": bson.M{
 			"$elemMatch": bson.M{
 				"year": year,
-				"make": bson.RegEx{
-					Pattern: "^" + vehicleMake + "$",
-					Options: "i",
-				},
-				"model": bson.RegEx{
-					Pattern: "^" + model + "$",
-					Options: "i",
-				},
+				"make": exactly(vehicleMake),
+				"model": exactly(model),
 			},
 		},
 		"status": bson.M{
@@ -299,6 +299,7 @@ func getStyles(ctx *LookupContext, year, vehicleMake, model, category string) ([
 		"brand.id": bson.M{
 			"$in": ctx.Brands,
 		},
+		"web_visibility": VisibilityQuery(ctx.Visibilities),
 	}
 
 	if category != "" {
@@ -0,0 +1,275 @@
+package products
+
+import (
+	"errors"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/conversions"
+	"github.com/curt-labs/API/helpers/database"
+)
+
+const (
+	// MaxComparedParts is the most parts that can be compared at once.
+	MaxComparedParts = 10
+
+	UnitPounds = "lb"
+	UnitInches = "in"
+)
+
+var measurementPattern = regexp.MustCompile(`^\s*(-?[\d,]*\.?\d+)\s*(.*?)\s*$`)
+
+// Comparison lines up the specs of several parts.
+type Comparison struct {
+	Parts      []ComparedPart  `json:"parts" xml:"parts"`
+	Attributes []ComparisonRow `json:"attributes" xml:"attributes"`
+}
+
+// ComparedPart is the summary of a part in a comparison.
+type ComparedPart struct {
+	ID            int     `json:"id" xml:"id,attr"`
+	PartNumber    string  `json:"part_number" xml:"part_number,attr"`
+	ShortDesc     string  `json:"short_description" xml:"short_description,attr"`
+	Brand         string  `json:"brand" xml:"brand,attr"`
+	Class         string  `json:"class" xml:"class,attr"`
+	ListPrice     float64 `json:"list_price" xml:"list_price,attr"`
+	CustomerPrice float64 `json:"customer_price,omitempty" xml:"customer_price,attr,omitempty"`
+	Fitments      int     `json:"fitments" xml:"fitments,attr"`
+	AverageReview float64 `json:"average_review" xml:"average_review,attr"`
+}
+
+// ComparisonRow is one attribute across every compared part. Values are in
+// the same order as Comparison.Parts.
+type ComparisonRow struct {
+	Field   string            `json:"field" xml:"field,attr"`
+	Unit    string            `json:"unit,omitempty" xml:"unit,attr,omitempty"`
+	Differs bool              `json:"differs" xml:"differs,attr"`
+	Values  []ComparisonValue `json:"values" xml:"values"`
+}
+
+// ComparisonValue is a single part's value for an attribute. Measurements
+// in a known unit are converted to the row's unit.
+type ComparisonValue struct {
+	PartID     int      `json:"part" xml:"part,attr"`
+	Raw        string   `json:"raw,omitempty" xml:"raw,attr,omitempty"`
+	Normalized *float64 `json:"normalized,omitempty" xml:"normalized,attr,omitempty"`
+	Missing    bool     `json:"missing,omitempty" xml:"missing,attr,omitempty"`
+}
+
+type measurement struct {
+	value float64
+	unit  string
+}
+
+// Compare loads the parts and builds their comparison, keeping the parts in
+// the order they were requested.
+func Compare(ids []int, dtx *apicontext.DataContext) (Comparison, error) {
+	var cmp Comparison
+	if len(ids) < 2 {
+		return cmp, errors.New("at least two parts are required for a comparison")
+	}
+	if len(ids) > MaxComparedParts {
+		return cmp, errors.New("too many parts to compare, the maximum is " + strconv.Itoa(MaxComparedParts))
+	}
+
+	if err := database.Init(); err != nil {
+		return cmp, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	parts, err := GetVisible(ids, dtx, session)
+	if err != nil {
+		return cmp, err
+	}
+	if parts, err = BindCustomerToSeveralParts(parts, dtx); err != nil {
+		return cmp, err
+	}
+
+	byID := make(map[int]Part, len(parts))
+	for _, p := range parts {
+		byID[p.ID] = p
+	}
+
+	var ordered []Part
+	for _, id := range ids {
+		p, ok := byID[id]
+		if !ok {
+			return cmp, errors.New("part " + strconv.Itoa(id) + " not found")
+		}
+		ordered = append(ordered, p)
+	}
+
+	return BuildComparison(ordered), nil
+}
+
+// BuildComparison lines up the union of the parts' attributes.
+func BuildComparison(parts []Part) Comparison {
+	cmp := Comparison{
+		Parts:      make([]ComparedPart, 0, len(parts)),
+		Attributes: make([]ComparisonRow, 0),
+	}
+
+	var fields []string
+	seen := make(map[string]string)
+	values := make([]map[string]string, len(parts))
+
+	for i, p := range parts {
+		cmp.Parts = append(cmp.Parts, comparedPart(p))
+
+		values[i] = make(map[string]string)
+		for _, attr := range p.Attributes {
+			key := strings.ToLower(strings.TrimSpace(attr.Key))
+			if key == "" {
+				continue
+			}
+			if _, ok := seen[key]; !ok {
+				seen[key] = strings.TrimSpace(attr.Key)
+				fields = append(fields, key)
+			}
+			if _, ok := values[i][key]; !ok {
+				values[i][key] = strings.TrimSpace(attr.Value)
+			}
+		}
+	}
+
+	for _, key := range fields {
+		row := ComparisonRow{
+			Field: seen[key],
+		}
+
+		measurements := make([]*measurement, len(parts))
+		units := make(map[string]bool)
+		comparable := true
+		for i := range parts {
+			raw, ok := values[i][key]
+			if !ok || raw == "" {
+				continue
+			}
+			m, ok := parseMeasurement(raw)
+			if !ok {
+				comparable = false
+				continue
+			}
+			measurements[i] = m
+			units[m.unit] = true
+		}
+		numeric := comparable && len(units) == 1
+		if numeric {
+			for u := range units {
+				row.Unit = u
+			}
+		}
+
+		for i, p := range parts {
+			v := ComparisonValue{
+				PartID: p.ID,
+			}
+			raw, ok := values[i][key]
+			if !ok || raw == "" {
+				v.Missing = true
+			} else {
+				v.Raw = raw
+				if numeric && measurements[i] != nil {
+					n := measurements[i].value
+					v.Normalized = &n
+				}
+			}
+			row.Values = append(row.Values, v)
+		}
+
+		row.Differs = differs(row.Values)
+		cmp.Attributes = append(cmp.Attributes, row)
+	}
+
+	return cmp
+}
+
+func comparedPart(p Part) ComparedPart {
+	cp := ComparedPart{
+		ID:            p.ID,
+		PartNumber:    p.PartNumber,
+		ShortDesc:     p.ShortDesc,
+		Brand:         p.Brand.Name,
+		Class:         p.Class.Name,
+		CustomerPrice: p.Customer.Price,
+		AverageReview: p.AverageReview,
+		Fitments:      len(p.Vehicles),
+	}
+	if cp.Fitments == 0 {
+		cp.Fitments = len(p.AcesVehicles)
+	}
+	for _, pr := range p.Pricing {
+		if pr.Type == "List" {
+			cp.ListPrice = pr.Price
+			break
+		}
+	}
+	return cp
+}
+
+// parseMeasurement reads values like "8,000 lbs." or "2 in" and converts
+// them to pounds or inches. Unitless numbers are left as they are.
+func parseMeasurement(raw string) (*measurement, bool) {
+	match := measurementPattern.FindStringSubmatch(raw)
+	if match == nil {
+		return nil, false
+	}
+	n, err := strconv.ParseFloat(strings.Replace(match[1], ",", "", -1), 64)
+	if err != nil {
+		return nil, false
+	}
+
+	unit := match[2]
+	switch {
+	case unit == "":
+		return &measurement{value: n}, true
+	case conversions.IsWeightUnit(unit):
+		lbs, _ := conversions.ToPounds(n, unit)
+		return &measurement{value: round2(lbs), unit: UnitPounds}, true
+	case conversions.IsLengthUnit(unit):
+		in, _ := conversions.ToInches(n, unit)
+		return &measurement{value: round2(in), unit: UnitInches}, true
+	}
+	return nil, false
+}
+
+func differs(values []ComparisonValue) bool {
+	var first *ComparisonValue
+	for i := range values {
+		v := &values[i]
+		if first == nil {
+			first = v
+			continue
+		}
+		if v.Missing != first.Missing {
+			return true
+		}
+		if v.Normalized != nil && first.Normalized != nil {
+			if !nearlyEqual(*v.Normalized, *first.Normalized) {
+				return true
+			}
+			continue
+		}
+		if !strings.EqualFold(v.Raw, first.Raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// nearlyEqual allows for the rounding that creeps in when a spec was
+// published in metric and converted, 3628.74 kg is still an 8,000 lb GTW.
+func nearlyEqual(a, b float64) bool {
+	return math.Abs(a-b) <= 0.001*math.Max(math.Abs(a), math.Abs(b))
+}
+
+func round2(f float64) float64 {
+	if f < 0 {
+		return -round2(-f)
+	}
+	return float64(int64(f*100+0.5)) / 100
+}
@@ -0,0 +1,55 @@
+package products
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestBuildComparison(t *testing.T) {
+	parts := []Part{
+		{
+			ID:         13000,
+			Class:      Class{Name: "Class 3"},
+			Pricing:    []Price{{Type: "List", Price: 250}},
+			Vehicles:   []VehicleApplication{{Year: "2016", Make: "Chevrolet", Model: "Silverado 1500"}},
+			Attributes: []Attribute{{Key: "GTW", Value: "8,000 lbs."}, {Key: "Finish", Value: "Black"}, {Key: "Receiver Size", Value: "2 in"}},
+		},
+		{
+			ID:         13001,
+			Class:      Class{Name: "Class 3"},
+			Pricing:    []Price{{Type: "List", Price: 275}},
+			Attributes: []Attribute{{Key: "gtw", Value: "3628.74 kg"}, {Key: "Finish", Value: "black"}, {Key: "Receiver Size", Value: "2.5\""}, {Key: "Drilling", Value: "No"}},
+		},
+	}
+
+	Convey("Testing BuildComparison()", t, func() {
+		cmp := BuildComparison(parts)
+		So(len(cmp.Parts), ShouldEqual, 2)
+		So(cmp.Parts[0].ListPrice, ShouldEqual, 250)
+		So(cmp.Parts[0].Fitments, ShouldEqual, 1)
+		So(cmp.Parts[1].Class, ShouldEqual, "Class 3")
+
+		So(len(cmp.Attributes), ShouldEqual, 4)
+
+		gtw := cmp.Attributes[0]
+		So(gtw.Field, ShouldEqual, "GTW")
+		So(gtw.Unit, ShouldEqual, UnitPounds)
+		So(*gtw.Values[0].Normalized, ShouldEqual, 8000)
+		So(*gtw.Values[1].Normalized, ShouldAlmostEqual, 8000, 0.05)
+		So(gtw.Differs, ShouldBeFalse)
+
+		finish := cmp.Attributes[1]
+		So(finish.Unit, ShouldEqual, "")
+		So(finish.Values[0].Normalized, ShouldBeNil)
+		So(finish.Differs, ShouldBeFalse)
+
+		receiver := cmp.Attributes[2]
+		So(receiver.Unit, ShouldEqual, UnitInches)
+		So(receiver.Differs, ShouldBeTrue)
+
+		drilling := cmp.Attributes[3]
+		So(drilling.Values[0].Missing, ShouldBeTrue)
+		So(drilling.Values[1].Raw, ShouldEqual, "No")
+		So(drilling.Differs, ShouldBeTrue)
+	})
+}
@@ -298,6 +298,11 @@ type DefinedConfiguration struct {
 }
 
 func (l *Lookup) GetConfigurations() error {
+	if l.cached("configurations", &l.Configurations) && len(l.Configurations) > 0 {
+		l.Pagination = singlePage(len(l.Configurations))
+		return nil
+	}
+
 	stmtBeginning := `select distinct cat.name, cat.AcesTypeID from vcdb_Vehicle as v
 		join VehicleConfigAttribute as vca on v.ConfigID = vca.VehicleConfigID
 		join ConfigAttribute as ca on vca.AttributeID = ca.ID
@@ -350,8 +355,11 @@ func (l *Lookup) GetConfigurations() error {
 	}
 	defer res.Close()
 
+	failed := false
 	for i := 0; i < count; i++ {
-		<-ch
+		if <-ch != nil {
+			failed = true
+		}
 	}
 
 	l.Pagination = Pagination{
@@ -361,6 +369,9 @@ func (l *Lookup) GetConfigurations() error {
 		PerPage:       len(l.Submodels),
 		TotalPages:    1,
 	}
+	if !failed {
+		l.cache("configurations", l.Configurations, len(l.Configurations))
+	}
 
 	return nil
 }
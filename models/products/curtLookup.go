@@ -18,6 +18,11 @@ var (
 	statuses = []int{700, 800, 810, 815, 850, 870, 888, 900, 910, 950}
 )
 
+// ActiveStatuses returns the part statuses the catalog lookups serve.
+func ActiveStatuses() []int {
+	return append([]int(nil), statuses...)
+}
+
 const (
 	CURT_LOOKUP_KEY     = "curtlookup:"
 	CL_YEARS_KEY        = CURT_LOOKUP_KEY + "years"
@@ -43,6 +48,10 @@ type CurtLookup struct {
 	Models []string `json:"available_models,omitempty" xml:"available_models, omitempty"`
 	Styles []string `json:"available_styles,omitempty" xml:"available_styles, omitempty"`
 	Parts  []Part   `json:"parts,omitempty" xml:"parts, omitempty"`
+
+	// Suggestions are the closest makes, models or styles to the one that
+	// matched nothing.
+	Suggestions []string `json:"suggestions,omitempty" xml:"suggestions, omitempty"`
 	CurtVehicle
 }
 
@@ -261,10 +270,7 @@ func (c *CurtLookup) GetModels(heavyduty bool) error {
 		"vehicle_applications": bson.M{
 			"$elemMatch": bson.M{
 				"year": c.Year,
-				"make": bson.RegEx{
-					Pattern: "^" + c.Make + "$",
-					Options: "i",
-				},
+				"make": exactly(c.Make),
 			},
 		},
 		"vehicle_applications.0": bson.M{
@@ -335,14 +341,8 @@ func (c *CurtLookup) GetStyles(heavyduty bool) error {
 		"vehicle_applications": bson.M{
 			"$elemMatch": bson.M{
 				"year": c.Year,
-				"make": bson.RegEx{
-					Pattern: "^" + c.Make + "$",
-					Options: "i",
-				},
-				"model": bson.RegEx{
-					Pattern: "^" + c.Model + "$",
-					Options: "i",
-				},
+				"make": exactly(c.Make),
+				"model": exactly(c.Model),
 			},
 		},
 		"vehicle_applications.0": bson.M{
@@ -406,14 +406,8 @@ func (c *CurtLookup) GetParts(dtx *apicontext.DataContext, heavyduty bool) error
 	appQuery := bson.M{
 		"$elemMatch": bson.M{
 			"year": c.Year,
-			"make": bson.RegEx{
-				Pattern: "^" + c.Make + "$",
-				Options: "i",
-			},
-			"model": bson.RegEx{
-				Pattern: "^" + c.Model + "$",
-				Options: "i",
-			},
+			"make": exactly(c.Make),
+			"model": exactly(c.Model),
 		},
 	}
 
@@ -421,18 +415,9 @@ func (c *CurtLookup) GetParts(dtx *apicontext.DataContext, heavyduty bool) error
 		appQuery = bson.M{
 			"$elemMatch": bson.M{
 				"year": c.Year,
-				"make": bson.RegEx{
-					Pattern: "^" + c.Make + "$",
-					Options: "i",
-				},
-				"model": bson.RegEx{
-					Pattern: "^" + c.Model + "$",
-					Options: "i",
-				},
-				"style": bson.RegEx{
-					Pattern: "^" + c.Style + "$",
-					Options: "i",
-				},
+				"make": exactly(c.Make),
+				"model": exactly(c.Model),
+				"style": exactly(c.Style),
 			},
 		}
 	}
@@ -445,10 +430,53 @@ func (c *CurtLookup) GetParts(dtx *apicontext.DataContext, heavyduty bool) error
 		"vehicle_applications.0": bson.M{
 			"$exists": true,
 		},
-		"brand.id": 1,
+		"brand.id":       1,
+		"web_visibility": VisibilityQuery(Visibilities(dtx)),
 	}
 
 	err = col.Find(qry).All(&c.Parts)
+	c.Parts = FilterVisible(c.Parts, dtx)
 
 	return err
 }
+
+// Suggest fills Suggestions when the lookup of the make, model or style
+// matched nothing. The step before it is looked up again to find the
+// closest values.
+func (c *CurtLookup) Suggest(heavyduty bool) error {
+	switch {
+	case c.Year == "" || c.Make == "":
+		return nil
+	case c.Model == "":
+		if len(c.Models) > 0 {
+			return nil
+		}
+		return c.suggestMake(heavyduty)
+	case len(c.Parts) > 0:
+		return nil
+	case len(c.Styles) > 0:
+		if c.Style != "" {
+			c.Suggestions = Suggest(c.Style, c.Styles)
+		}
+		return nil
+	}
+
+	prev := CurtLookup{CurtVehicle: CurtVehicle{Year: c.Year, Make: c.Make}}
+	if err := prev.GetModels(heavyduty); err != nil {
+		return err
+	}
+	if len(prev.Models) == 0 {
+		return c.suggestMake(heavyduty)
+	}
+	c.Suggestions = Suggest(c.Model, prev.Models)
+	return nil
+}
+
+func (c *CurtLookup) suggestMake(heavyduty bool) error {
+	prev := CurtLookup{CurtVehicle: CurtVehicle{Year: c.Year}}
+	if err := prev.GetMakes(heavyduty); err != nil {
+		return err
+	}
+	c.Suggestions = Suggest(c.Make, prev.Makes)
+	return nil
+}
@@ -0,0 +1,370 @@
+package products
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/database"
+	"gopkg.in/mgo.v2"
+)
+
+const (
+	OpEquals = "eq"
+	OpIn     = "in"
+	OpRange  = "range"
+
+	// RulesParam is the query/form parameter that carries a JSON array of
+	// rules into /part, /category/:id/parts and the vehicle lookup.
+	RulesParam = "rules"
+)
+
+// FilterRule is a single filtration rule. Which fields are used depends on
+// the Kind, see the built in rule kinds registered in init.
+type FilterRule struct {
+	Kind    string         `json:"kind" xml:"kind,attr"`
+	Field   string         `json:"field,omitempty" xml:"field,attr,omitempty"`
+	Op      string         `json:"op,omitempty" xml:"op,attr,omitempty"`
+	Values  []string       `json:"values,omitempty" xml:"values,omitempty"`
+	Min     *float64       `json:"min,omitempty" xml:"min,attr,omitempty"`
+	Max     *float64       `json:"max,omitempty" xml:"max,attr,omitempty"`
+	Vehicle *VehicleFilter `json:"vehicle,omitempty" xml:"vehicle,omitempty"`
+	Not     bool           `json:"not,omitempty" xml:"not,attr,omitempty"`
+}
+
+// VehicleFilter matches parts that fit the vehicle. Empty fields match
+// anything.
+type VehicleFilter struct {
+	Year  string `json:"year" xml:"year,attr"`
+	Make  string `json:"make" xml:"make,attr"`
+	Model string `json:"model" xml:"model,attr"`
+	Style string `json:"style,omitempty" xml:"style,attr,omitempty"`
+}
+
+// RuleMatcher decides whether a part passes a compiled rule.
+type RuleMatcher interface {
+	Match(p Part) bool
+}
+
+// RuleMatcherFunc adapts a function to a RuleMatcher.
+type RuleMatcherFunc func(p Part) bool
+
+// Match implements RuleMatcher.
+func (f RuleMatcherFunc) Match(p Part) bool {
+	return f(p)
+}
+
+// RuleBuilder compiles a FilterRule into a RuleMatcher, returning an error when the
+// rule is missing what it needs.
+type RuleBuilder func(r FilterRule) (RuleMatcher, error)
+
+var (
+	ruleRegistry   = make(map[string]RuleBuilder)
+	ruleRegistryMu sync.RWMutex
+
+	ruleNumberPattern = regexp.MustCompile(`-?\d+(\.\d+)?`)
+)
+
+func init() {
+	RegisterFilterRule("attribute", attributeRule)
+	RegisterFilterRule("price", priceRule)
+	RegisterFilterRule("category", categoryRule)
+	RegisterFilterRule("class", classRule)
+	RegisterFilterRule("brand", brandRule)
+	RegisterFilterRule("vehicle", vehicleRule)
+}
+
+// RegisterFilterRule makes a rule kind available to CompileRules. Registering a kind
+// that already exists replaces it.
+func RegisterFilterRule(kind string, b RuleBuilder) {
+	ruleRegistryMu.Lock()
+	defer ruleRegistryMu.Unlock()
+	ruleRegistry[strings.ToLower(kind)] = b
+}
+
+// CompileRules builds a single RuleMatcher that requires every rule to match.
+func CompileRules(rules []FilterRule) (RuleMatcher, error) {
+	ruleRegistryMu.RLock()
+	defer ruleRegistryMu.RUnlock()
+
+	matchers := make([]RuleMatcher, 0, len(rules))
+	for _, r := range rules {
+		b, ok := ruleRegistry[strings.ToLower(r.Kind)]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter rule kind: %s", r.Kind)
+		}
+		m, err := b(r)
+		if err != nil {
+			return nil, err
+		}
+		if r.Not {
+			inner := m
+			m = RuleMatcherFunc(func(p Part) bool {
+				return !inner.Match(p)
+			})
+		}
+		matchers = append(matchers, m)
+	}
+
+	return RuleMatcherFunc(func(p Part) bool {
+		for _, m := range matchers {
+			if !m.Match(p) {
+				return false
+			}
+		}
+		return true
+	}), nil
+}
+
+// FilterParts returns the parts that match every rule, in their original
+// order.
+func FilterParts(parts []Part, rules []FilterRule) ([]Part, error) {
+	if len(rules) == 0 {
+		return parts, nil
+	}
+
+	m, err := CompileRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := make([]Part, 0)
+	for _, p := range parts {
+		if m.Match(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
+}
+
+// FilterPartIDs is the Part Filter Concept from DESIGN.md: load the parts
+// and return the ones that meet the rules.
+func FilterPartIDs(ids []int, rules []FilterRule, dtx *apicontext.DataContext) ([]Part, error) {
+	if err := database.Init(); err != nil {
+		return nil, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	parts, err := GetVisible(ids, dtx, session)
+	if err != nil {
+		return nil, err
+	}
+	if parts, err = BindCustomerToSeveralParts(parts, dtx); err != nil {
+		return nil, err
+	}
+
+	return FilterParts(parts, rules)
+}
+
+// FilterPage reads the parts of a query that the caller can see and that
+// match every rule, and returns the page of count of them after skipping
+// skip, with the number that matched. Rules are applied before paging so
+// pages are full and the total is what the rules let through.
+func FilterPage(iter *mgo.Iter, rules []FilterRule, dtx *apicontext.DataContext, skip, count int) ([]Part, int, error) {
+	m, err := CompileRules(rules)
+	if err != nil {
+		iter.Close()
+		return nil, 0, err
+	}
+
+	visibilities := Visibilities(dtx)
+	parts := make([]Part, 0)
+	total := 0
+	var p Part
+	for iter.Next(&p) {
+		if visible(p.WebVisibility, visibilities) {
+			p.SetWebFlags()
+			if m.Match(p) {
+				if total >= skip && (count <= 0 || total < skip+count) {
+					parts = append(parts, p)
+				}
+				total++
+			}
+		}
+		p = Part{}
+	}
+	if err = iter.Close(); err != nil {
+		return nil, 0, err
+	}
+	return parts, total, nil
+}
+
+// ParseRules reads a JSON array of rules, like the RulesParam of a
+// request. No rules returns an empty slice.
+func ParseRules(raw string) ([]FilterRule, error) {
+	var rules []FilterRule
+	if raw == "" {
+		return rules, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &rules); err != nil {
+		return rules, fmt.Errorf("invalid filter rules: %v", err)
+	}
+	if _, err := CompileRules(rules); err != nil {
+		return rules, err
+	}
+	return rules, nil
+}
+
+func attributeRule(r FilterRule) (RuleMatcher, error) {
+	if r.Field == "" {
+		return nil, fmt.Errorf("attribute rule requires a field")
+	}
+	values, err := valueMatcher(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return RuleMatcherFunc(func(p Part) bool {
+		for _, attr := range p.Attributes {
+			if strings.EqualFold(attr.Key, r.Field) && values(attr.Value) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func priceRule(r FilterRule) (RuleMatcher, error) {
+	if r.Min == nil && r.Max == nil {
+		return nil, fmt.Errorf("price rule requires a min or max")
+	}
+	priceType := r.Field
+	if priceType == "" {
+		priceType = "List"
+	}
+
+	return RuleMatcherFunc(func(p Part) bool {
+		if strings.EqualFold(priceType, "Customer") {
+			return inRange(p.Customer.Price, r.Min, r.Max)
+		}
+		for _, pr := range p.Pricing {
+			if strings.EqualFold(pr.Type, priceType) {
+				return inRange(pr.Price, r.Min, r.Max)
+			}
+		}
+		return false
+	}), nil
+}
+
+func categoryRule(r FilterRule) (RuleMatcher, error) {
+	if len(r.Values) == 0 {
+		return nil, fmt.Errorf("category rule requires values")
+	}
+
+	return RuleMatcherFunc(func(p Part) bool {
+		for _, cat := range p.Categories {
+			if containsFold(r.Values, strconv.Itoa(cat.CategoryID)) ||
+				containsFold(r.Values, strconv.Itoa(cat.ParentID)) ||
+				containsFold(r.Values, cat.Title) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+func classRule(r FilterRule) (RuleMatcher, error) {
+	if len(r.Values) == 0 {
+		return nil, fmt.Errorf("class rule requires values")
+	}
+
+	return RuleMatcherFunc(func(p Part) bool {
+		name := p.Class.Name
+		if name == "" {
+			name = "Other"
+		}
+		return containsFold(r.Values, name) || (p.Class.ID > 0 && containsFold(r.Values, strconv.Itoa(p.Class.ID)))
+	}), nil
+}
+
+func brandRule(r FilterRule) (RuleMatcher, error) {
+	if len(r.Values) == 0 {
+		return nil, fmt.Errorf("brand rule requires values")
+	}
+
+	return RuleMatcherFunc(func(p Part) bool {
+		return containsFold(r.Values, strconv.Itoa(p.Brand.ID)) ||
+			containsFold(r.Values, p.Brand.Code) ||
+			containsFold(r.Values, p.Brand.Name)
+	}), nil
+}
+
+func vehicleRule(r FilterRule) (RuleMatcher, error) {
+	v := r.Vehicle
+	if v == nil || (v.Year == "" && v.Make == "" && v.Model == "") {
+		return nil, fmt.Errorf("vehicle rule requires a vehicle")
+	}
+
+	match := func(field, want string) bool {
+		return want == "" || strings.EqualFold(strings.TrimSpace(field), strings.TrimSpace(want))
+	}
+
+	return RuleMatcherFunc(func(p Part) bool {
+		for _, app := range p.Vehicles {
+			if match(app.Year, v.Year) && match(app.Make, v.Make) && match(app.Model, v.Model) && match(app.Style, v.Style) {
+				return true
+			}
+		}
+		for _, av := range p.AcesVehicles {
+			if match(strconv.Itoa(av.Base.Year), v.Year) && match(av.Base.Make, v.Make) && match(av.Base.Model, v.Model) && match(av.Submodel, v.Style) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// valueMatcher compiles the equals/in/range operators shared by rules
+// that compare against a string value.
+func valueMatcher(r FilterRule) (func(string) bool, error) {
+	switch strings.ToLower(r.Op) {
+	case "", OpEquals:
+		if len(r.Values) != 1 {
+			return nil, fmt.Errorf("%s rule with op %s requires a single value", r.Kind, OpEquals)
+		}
+		return func(v string) bool {
+			return strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(r.Values[0]))
+		}, nil
+	case OpIn:
+		if len(r.Values) == 0 {
+			return nil, fmt.Errorf("%s rule with op %s requires values", r.Kind, OpIn)
+		}
+		return func(v string) bool {
+			return containsFold(r.Values, v)
+		}, nil
+	case OpRange:
+		if r.Min == nil && r.Max == nil {
+			return nil, fmt.Errorf("%s rule with op %s requires a min or max", r.Kind, OpRange)
+		}
+		return func(v string) bool {
+			n, ok := leadingNumber(v)
+			return ok && inRange(n, r.Min, r.Max)
+		}, nil
+	}
+	return nil, fmt.Errorf("unknown op for %s rule: %s", r.Kind, r.Op)
+}
+
+// leadingNumber pulls the first number out of values like "3,500 lbs".
+func leadingNumber(s string) (float64, bool) {
+	found := ruleNumberPattern.FindString(strings.Replace(s, ",", "", -1))
+	if found == "" {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(found, 64)
+	return n, err == nil
+}
+
+func inRange(n float64, min, max *float64) bool {
+	if min != nil && n < *min {
+		return false
+	}
+	if max != nil && n > *max {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+package products
+
+import (
+	"github.com/curt-labs/API/models/brand"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func ruleTestParts() []Part {
+	return []Part{
+		{
+			ID:         13000,
+			Brand:      brand.Brand{ID: 1, Code: "CURT"},
+			Class:      Class{ID: 3, Name: "Class 3"},
+			Categories: []Category{{CategoryID: 11, Title: "Trailer Hitches"}},
+			Attributes: []Attribute{{Key: "GTW", Value: "8,000 lbs."}, {Key: "Finish", Value: "Black"}},
+			Pricing:    []Price{{Type: "List", Price: 250}},
+			Vehicles:   []VehicleApplication{{Year: "2016", Make: "Chevrolet", Model: "Silverado 1500"}},
+		},
+		{
+			ID:         11000,
+			Brand:      brand.Brand{ID: 1, Code: "CURT"},
+			Class:      Class{ID: 1, Name: "Class 1"},
+			Categories: []Category{{CategoryID: 11, Title: "Trailer Hitches"}},
+			Attributes: []Attribute{{Key: "GTW", Value: "2,000 lbs."}, {Key: "Finish", Value: "Chrome"}},
+			Pricing:    []Price{{Type: "List", Price: 150}},
+			Vehicles:   []VehicleApplication{{Year: "2012", Make: "Honda", Model: "Civic"}},
+		},
+		{
+			ID:         56000,
+			Brand:      brand.Brand{ID: 3, Code: "ARIES"},
+			Categories: []Category{{CategoryID: 20, Title: "Wiring"}},
+			Pricing:    []Price{{Type: "List", Price: 40}},
+		},
+	}
+}
+
+func partIDs(parts []Part) []int {
+	var found []int
+	for _, p := range parts {
+		found = append(found, p.ID)
+	}
+	return found
+}
+
+func TestFilterParts(t *testing.T) {
+	min := 5000.0
+	max := 200.0
+
+	Convey("Testing FilterParts()", t, func() {
+		parts, err := FilterParts(ruleTestParts(), nil)
+		So(err, ShouldBeNil)
+		So(len(parts), ShouldEqual, 3)
+
+		parts, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "attribute", Field: "finish", Values: []string{"black"}}})
+		So(err, ShouldBeNil)
+		So(partIDs(parts), ShouldResemble, []int{13000})
+
+		parts, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "attribute", Field: "Finish", Op: OpIn, Values: []string{"Black", "Chrome"}}})
+		So(err, ShouldBeNil)
+		So(partIDs(parts), ShouldResemble, []int{13000, 11000})
+
+		parts, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "attribute", Field: "GTW", Op: OpRange, Min: &min}})
+		So(err, ShouldBeNil)
+		So(partIDs(parts), ShouldResemble, []int{13000})
+
+		parts, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "price", Max: &max}})
+		So(err, ShouldBeNil)
+		So(partIDs(parts), ShouldResemble, []int{11000, 56000})
+
+		parts, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "category", Values: []string{"wiring"}}})
+		So(err, ShouldBeNil)
+		So(partIDs(parts), ShouldResemble, []int{56000})
+
+		parts, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "class", Values: []string{"3"}}, {Kind: "brand", Values: []string{"curt"}}})
+		So(err, ShouldBeNil)
+		So(partIDs(parts), ShouldResemble, []int{13000})
+
+		parts, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "vehicle", Vehicle: &VehicleFilter{Make: "honda", Model: "civic"}}})
+		So(err, ShouldBeNil)
+		So(partIDs(parts), ShouldResemble, []int{11000})
+
+		parts, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "brand", Values: []string{"1"}, Not: true}})
+		So(err, ShouldBeNil)
+		So(partIDs(parts), ShouldResemble, []int{56000})
+	})
+
+	Convey("Testing FilterParts() with bad rules", t, func() {
+		_, err := FilterParts(ruleTestParts(), []FilterRule{{Kind: "color"}})
+		So(err, ShouldNotBeNil)
+		_, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "attribute", Field: "GTW", Op: OpRange}})
+		So(err, ShouldNotBeNil)
+		_, err = FilterParts(ruleTestParts(), []FilterRule{{Kind: "vehicle"}})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Testing RegisterFilterRule()", t, func() {
+		RegisterFilterRule("featured", func(r FilterRule) (RuleMatcher, error) {
+			return RuleMatcherFunc(func(p Part) bool {
+				return p.ID == 56000
+			}), nil
+		})
+		parts, err := FilterParts(ruleTestParts(), []FilterRule{{Kind: "Featured"}})
+		So(err, ShouldBeNil)
+		So(partIDs(parts), ShouldResemble, []int{56000})
+	})
+}
+
+func TestParseRules(t *testing.T) {
+	Convey("Testing ParseRules()", t, func() {
+		rules, err := ParseRules(`[{"kind":"brand","values":["1"]}]`)
+		So(err, ShouldBeNil)
+		So(len(rules), ShouldEqual, 1)
+
+		rules, err = ParseRules("")
+		So(err, ShouldBeNil)
+		So(len(rules), ShouldEqual, 0)
+
+		_, err = ParseRules("nope")
+		So(err, ShouldNotBeNil)
+	})
+}
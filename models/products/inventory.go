@@ -1,7 +1,36 @@
 package products
 
 import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/helpers/rabbitmq"
+	"github.com/streadway/amqp"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// earthRadiusMiles is used for the great-circle distance between a
+	// ship-to location and a warehouse.
+	earthRadiusMiles = 3959.0
+)
+
+var (
+	getAllWarehouses = `select w.id, w.name, w.code, w.address, w.city, w.postalCode, w.tollFreePhone, w.fax, w.localPhone, w.manager, w.latitude, w.longitude,
+						s.stateID, s.state, s.abbr, c.countryID, c.name, c.abbr
+						from Warehouses as w
+						left join State as s on w.stateID = s.stateID
+						left join Country as c on s.countryID = c.countryID
+						order by w.code`
 )
 
 type Warehouse struct {
@@ -23,6 +52,7 @@ type Warehouse struct {
 type PartInventory struct {
 	TotalAvailability int         `json:"total_availability" xml:"total_availability,attr"`
 	Warehouses        []Inventory `json:"inventory,omitempty" xml:"inventory,omitempty"`
+	Nearest           *Inventory  `json:"nearest,omitempty" xml:"nearest,omitempty"`
 }
 
 type Inventory struct {
@@ -30,6 +60,7 @@ type Inventory struct {
 	Warehouse   Warehouse `json:"warehouse" xml:"warehouse"`
 	Quantity    int       `json:"quantity" xml:"quantity,attr"`
 	DateUpdated time.Time `json:"date_updated" xml:"date_update,attr"`
+	Distance    float64   `json:"distance,omitempty" xml:"distance,attr,omitempty"`
 }
 
 type State struct {
@@ -49,5 +80,353 @@ type FeedRecord struct {
 	Warehouse  Warehouse `json:"warehouse" xml:"warehouse"`
 	Part       int       `json:"part" xml:"part,attr"`
 	Quantity   int       `json:"quantity" xml:"quantity,attr"`
-	DateUpdate time.Time `json:"date_updated" xml:"date_updated`
+	DateUpdate time.Time `json:"date_updated" xml:"date_updated"`
+}
+
+// ShipTo is the location a dealer wants product delivered to. Warehouses
+// are ranked by their distance from it.
+type ShipTo struct {
+	Latitude  float64 `json:"latitude" xml:"latitude,attr"`
+	Longitude float64 `json:"longitude" xml:"longitude,attr"`
+}
+
+// inventoryRecord is the stored shape of a single part/warehouse quantity.
+// Warehouse details are kept in MySQL and joined on read.
+type inventoryRecord struct {
+	Part          int       `bson:"part"`
+	WarehouseCode string    `bson:"warehouse"`
+	Quantity      int       `bson:"quantity"`
+	DateUpdated   time.Time `bson:"date_updated"`
+}
+
+// ParseInventoryFeed reads a CSV inventory feed. Each row is
+// warehouse code, part ID, quantity and an optional update date
+// (RFC3339 or YYYY-MM-DD). A leading header row is skipped.
+func ParseInventoryFeed(r io.Reader) ([]FeedRecord, error) {
+	var records []FeedRecord
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	lines, err := reader.ReadAll()
+	if err != nil {
+		return records, err
+	}
+
+	now := time.Now()
+	for i, line := range lines {
+		if len(line) < 3 {
+			continue
+		}
+		part, err := strconv.Atoi(strings.TrimSpace(line[1]))
+		if err != nil {
+			if i == 0 {
+				continue // header
+			}
+			return records, fmt.Errorf("line %d: invalid part: %s", i+1, line[1])
+		}
+		qty, err := strconv.Atoi(strings.TrimSpace(line[2]))
+		if err != nil {
+			return records, fmt.Errorf("line %d: invalid quantity: %s", i+1, line[2])
+		}
+
+		rec := FeedRecord{
+			Warehouse:  Warehouse{Code: strings.ToUpper(strings.TrimSpace(line[0]))},
+			Part:       part,
+			Quantity:   qty,
+			DateUpdate: now,
+		}
+		if rec.Warehouse.Code == "" {
+			return records, fmt.Errorf("line %d: missing warehouse", i+1)
+		}
+
+		if len(line) > 3 && strings.TrimSpace(line[3]) != "" {
+			rec.DateUpdate, err = parseFeedDate(strings.TrimSpace(line[3]))
+			if err != nil {
+				return records, fmt.Errorf("line %d: invalid date: %s", i+1, line[3])
+			}
+		}
+
+		records = append(records, rec)
+	}
+
+	return records, nil
+}
+
+func parseFeedDate(str string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, str); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", str)
+}
+
+// SaveInventoryFeed upserts the quantity of each part/warehouse pair in the
+// feed. Records older than what we already have are ignored by the query so
+// out of order deliveries from the queue can't roll quantities back.
+func SaveInventoryFeed(records []FeedRecord) error {
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	col := session.DB(database.ProductDatabase).C(database.InventoryCollectionName)
+	err := col.EnsureIndex(mgo.Index{
+		Key:        []string{"part", "warehouse"},
+		Unique:     true,
+		Background: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	bulk := col.Bulk()
+	bulk.Unordered()
+	for _, rec := range records {
+		selector := bson.M{
+			"part":      rec.Part,
+			"warehouse": rec.Warehouse.Code,
+			"date_updated": bson.M{
+				"$not": bson.M{"$gt": rec.DateUpdate},
+			},
+		}
+		bulk.Upsert(selector, inventoryRecord{
+			Part:          rec.Part,
+			WarehouseCode: rec.Warehouse.Code,
+			Quantity:      rec.Quantity,
+			DateUpdated:   rec.DateUpdate,
+		})
+	}
+
+	_, err = bulk.Run()
+	if mgoErr, ok := err.(*mgo.BulkError); ok {
+		// a newer record already exists and the upsert collided with
+		// the unique index, which is exactly what we want to happen.
+		for _, c := range mgoErr.Cases() {
+			if !mgo.IsDup(c.Err) {
+				return err
+			}
+		}
+		return nil
+	}
+	return err
+}
+
+// ConsumeInventoryFeed keeps part inventory up to date from the feed records
+// published to the queue, bound to the exchange ("inventory" when empty).
+// It reconnects whenever the connection to RabbitMQ fails, so it never
+// returns.
+func ConsumeInventoryFeed(queue, exchange string) {
+	ex := rabbitmq.Exchange{
+		Name:       "inventory",
+		Type:       "direct",
+		RoutingKey: "feed",
+	}
+	if exchange != "" {
+		ex.Name = exchange
+	}
+	rabbitmq.Consume("api-inventory", queue, ex, nil, InventoryFeedHandler)
+}
+
+// InventoryFeedHandler handles queued inventory feed messages, which carry a
+// JSON encoded array of FeedRecords. Messages that aren't one are dead
+// lettered, the rest are retried until they're saved.
+var InventoryFeedHandler = rabbitmq.HandlerFunc(func(msg *amqp.Delivery) error {
+	var records []FeedRecord
+	if err := json.Unmarshal(msg.Body, &records); err != nil {
+		return rabbitmq.Permanent(err)
+	}
+	return SaveInventoryFeed(records)
+})
+
+// GetWarehouses returns all warehouses indexed by warehouse code.
+func GetWarehouses() (map[string]Warehouse, error) {
+	warehouses := make(map[string]Warehouse, 0)
+
+	err := database.Init()
+	if err != nil {
+		return warehouses, err
+	}
+
+	rows, err := database.DB.Query(getAllWarehouses)
+	if err != nil {
+		return warehouses, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var w Warehouse
+		var name, address, city, postal, toll, fax, local, manager *string
+		var lat, lng *float64
+		var stateID, countryID *int
+		var state, abbr, country, countryAbbr *string
+		err = rows.Scan(
+			&w.ID,
+			&name,
+			&w.Code,
+			&address,
+			&city,
+			&postal,
+			&toll,
+			&fax,
+			&local,
+			&manager,
+			&lat,
+			&lng,
+			&stateID,
+			&state,
+			&abbr,
+			&countryID,
+			&country,
+			&countryAbbr,
+		)
+		if err != nil {
+			return warehouses, err
+		}
+		if name != nil {
+			w.Name = *name
+		}
+		if address != nil {
+			w.Address = *address
+		}
+		if city != nil {
+			w.City = *city
+		}
+		if postal != nil {
+			w.PostalCode = *postal
+		}
+		if toll != nil {
+			w.TollFreePhone = *toll
+		}
+		if fax != nil {
+			w.Fax = *fax
+		}
+		if local != nil {
+			w.LocalPhone = *local
+		}
+		if manager != nil {
+			w.Manager = *manager
+		}
+		if lat != nil {
+			w.Latitude = *lat
+		}
+		if lng != nil {
+			w.Longitude = *lng
+		}
+		if stateID != nil {
+			w.State.ID = *stateID
+		}
+		if state != nil {
+			w.State.State = *state
+		}
+		if abbr != nil {
+			w.State.Abbreviation = *abbr
+		}
+		if countryID != nil {
+			w.State.Country.ID = *countryID
+		}
+		if country != nil {
+			w.State.Country.Name = *country
+		}
+		if countryAbbr != nil {
+			w.State.Country.Abbreviation = *countryAbbr
+		}
+
+		warehouses[strings.ToUpper(w.Code)] = w
+	}
+
+	return warehouses, rows.Err()
+}
+
+// GetInventory populates p.Inventory with the quantity on hand at each
+// warehouse. When a ship-to location is given, warehouses are ordered
+// nearest first and Nearest is set to the closest warehouse that can
+// fill the requested quantity.
+func (p *Part) GetInventory(shipTo *ShipTo, quantity int) error {
+	err := database.Init()
+	if err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	var records []inventoryRecord
+	err = session.DB(database.ProductDatabase).C(database.InventoryCollectionName).Find(bson.M{"part": p.ID}).All(&records)
+	if err != nil {
+		return err
+	}
+
+	warehouses, err := GetWarehouses()
+	if err != nil {
+		return err
+	}
+
+	p.Inventory = PartInventory{}
+	for _, rec := range records {
+		w, ok := warehouses[strings.ToUpper(rec.WarehouseCode)]
+		if !ok {
+			w = Warehouse{Code: rec.WarehouseCode}
+		}
+		p.Inventory.Warehouses = append(p.Inventory.Warehouses, Inventory{
+			Part:        rec.Part,
+			Warehouse:   w,
+			Quantity:    rec.Quantity,
+			DateUpdated: rec.DateUpdated,
+		})
+	}
+
+	p.Inventory.Rank(shipTo, quantity)
+
+	return nil
+}
+
+// Rank totals the available quantity and, given a ship-to location, sorts
+// the warehouses by distance and picks the nearest one holding at least
+// quantity units.
+func (pi *PartInventory) Rank(shipTo *ShipTo, quantity int) {
+	pi.TotalAvailability = 0
+	pi.Nearest = nil
+	for _, inv := range pi.Warehouses {
+		if inv.Quantity > 0 {
+			pi.TotalAvailability += inv.Quantity
+		}
+	}
+
+	if shipTo == nil {
+		sort.Slice(pi.Warehouses, func(i, j int) bool {
+			return pi.Warehouses[i].Warehouse.Code < pi.Warehouses[j].Warehouse.Code
+		})
+		return
+	}
+
+	for i, inv := range pi.Warehouses {
+		pi.Warehouses[i].Distance = Distance(shipTo.Latitude, shipTo.Longitude, inv.Warehouse.Latitude, inv.Warehouse.Longitude)
+	}
+	sort.SliceStable(pi.Warehouses, func(i, j int) bool {
+		return pi.Warehouses[i].Distance < pi.Warehouses[j].Distance
+	})
+
+	if quantity < 1 {
+		quantity = 1
+	}
+	for i, inv := range pi.Warehouses {
+		if inv.Quantity >= quantity {
+			pi.Nearest = &pi.Warehouses[i]
+			break
+		}
+	}
+}
+
+// Distance returns the great-circle distance in miles between two
+// latitude/longitude pairs.
+func Distance(lat1, lng1, lat2, lng2 float64) float64 {
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLng := (lng2 - lng1) * rad
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return earthRadiusMiles * 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
 }
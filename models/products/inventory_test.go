@@ -0,0 +1,57 @@
+package products
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestParseInventoryFeed(t *testing.T) {
+	Convey("Testing ParseInventoryFeed()", t, func() {
+		feed := "warehouse,part,quantity,date\nec,11000,12,2018-10-01\nWC,11000,0,\n"
+		records, err := ParseInventoryFeed(strings.NewReader(feed))
+		So(err, ShouldBeNil)
+		So(len(records), ShouldEqual, 2)
+		So(records[0].Warehouse.Code, ShouldEqual, "EC")
+		So(records[0].Part, ShouldEqual, 11000)
+		So(records[0].Quantity, ShouldEqual, 12)
+		So(records[0].DateUpdate.Year(), ShouldEqual, 2018)
+		So(records[1].DateUpdate.IsZero(), ShouldBeFalse)
+	})
+
+	Convey("Testing ParseInventoryFeed() with a bad quantity", t, func() {
+		_, err := ParseInventoryFeed(strings.NewReader("EC,11000,lots\n"))
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestRankInventory(t *testing.T) {
+	// Eau Claire, WI and Dallas, TX
+	ec := Warehouse{Code: "EC", Latitude: 44.8113, Longitude: -91.4985}
+	tx := Warehouse{Code: "TX", Latitude: 32.7767, Longitude: -96.7970}
+
+	Convey("Testing Rank() without a ship-to location", t, func() {
+		pi := PartInventory{Warehouses: []Inventory{
+			{Warehouse: tx, Quantity: 3},
+			{Warehouse: ec, Quantity: 5},
+		}}
+		pi.Rank(nil, 1)
+		So(pi.TotalAvailability, ShouldEqual, 8)
+		So(pi.Warehouses[0].Warehouse.Code, ShouldEqual, "EC")
+		So(pi.Nearest, ShouldBeNil)
+	})
+
+	Convey("Testing Rank() from Austin, TX", t, func() {
+		pi := PartInventory{Warehouses: []Inventory{
+			{Warehouse: ec, Quantity: 5},
+			{Warehouse: tx, Quantity: 1},
+		}}
+		pi.Rank(&ShipTo{Latitude: 30.2672, Longitude: -97.7431}, 1)
+		So(pi.Warehouses[0].Warehouse.Code, ShouldEqual, "TX")
+		So(pi.Warehouses[0].Distance, ShouldBeBetween, 150, 200)
+		So(pi.Nearest.Warehouse.Code, ShouldEqual, "TX")
+
+		pi.Rank(&ShipTo{Latitude: 30.2672, Longitude: -97.7431}, 2)
+		So(pi.Nearest.Warehouse.Code, ShouldEqual, "EC")
+	})
+}
@@ -0,0 +1,186 @@
+package products
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/curt-labs/API/helpers/redis"
+)
+
+const (
+	LOOKUP_KEY = "lookup"
+
+	// LookupCacheTimeout is how long, in seconds, the lookup results stay
+	// in redis.
+	LookupCacheTimeout = 86400
+)
+
+// WarmLookup describes the lookups to load into redis ahead of time: the
+// makes of the most recent Years years and the models of Makes.
+type WarmLookup struct {
+	Brands []int
+	Years  int
+	Makes  []string
+}
+
+// LookupCacheKey is the redis key of the kind (years, makes, models,
+// submodels or configurations) of lookup results for the brands and the
+// vehicle selected so far, like
+// lookup:1,3:2016:chevrolet:silverado 1500:lt:configurations.
+func LookupCacheKey(kind string, brands []int, v Vehicle) string {
+	ids := make([]int, len(brands))
+	copy(ids, brands)
+	sort.Ints(ids)
+	bs := make([]string, 0, len(ids))
+	for _, b := range ids {
+		bs = append(bs, strconv.Itoa(b))
+	}
+
+	key := []string{LOOKUP_KEY, strings.Join(bs, ",")}
+	if v.Base.Year > 0 {
+		key = append(key, strconv.Itoa(v.Base.Year))
+	}
+	for _, s := range []string{v.Base.Make, v.Base.Model, v.Submodel} {
+		if s == "" {
+			break
+		}
+		key = append(key, strings.ToLower(strings.TrimSpace(s)))
+	}
+	if len(v.Configurations) > 0 {
+		configs := make([]string, 0, len(v.Configurations))
+		for _, c := range v.Configurations {
+			configs = append(configs, strings.ToLower(c.Key+"="+c.Value))
+		}
+		sort.Strings(configs)
+		key = append(key, strings.Join(configs, ","))
+	}
+	return strings.Join(append(key, kind), ":")
+}
+
+// cached reads the lookup results of kind into v. Lookups marked for
+// refreshing always miss.
+func (l *Lookup) cached(kind string, v interface{}) bool {
+	if l.refresh || len(l.Brands) == 0 {
+		return false
+	}
+	data, err := redis.Get(LookupCacheKey(kind, l.Brands, l.Vehicle))
+	if err != nil || len(data) == 0 {
+		return false
+	}
+	return json.Unmarshal(data, v) == nil
+}
+
+// cache stores the lookup results of kind. Empty results aren't stored so a
+// lookup made before the fitment is loaded isn't stuck empty.
+func (l *Lookup) cache(kind string, v interface{}, n int) {
+	if len(l.Brands) == 0 || n == 0 {
+		return
+	}
+	go redis.Setex(LookupCacheKey(kind, l.Brands, l.Vehicle), v, LookupCacheTimeout)
+}
+
+func singlePage(n int) Pagination {
+	return Pagination{
+		TotalItems:    n,
+		ReturnedCount: n,
+		Page:          1,
+		PerPage:       n,
+		TotalPages:    1,
+	}
+}
+
+// InvalidateLookupCache removes the cached lookups of every brand set
+// including the brand. When year is set only the lookups of that year and
+// the list of years are removed. A brand of 0 matches every brand set.
+func InvalidateLookupCache(brand, year int) (int, error) {
+	prefix := fmt.Sprintf("%s:%s:", redis.Prefix, LOOKUP_KEY)
+	keys, err := redis.Scan(prefix + "*")
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, key := range keys {
+		if !strings.HasPrefix(key, prefix) || !lookupKeyMatches(strings.TrimPrefix(key, prefix), brand, year) {
+			continue
+		}
+		if err = redis.DeleteFullPath(key); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// lookupKeyMatches reports whether the key, without its prefix, belongs to
+// the brand and year.
+func lookupKeyMatches(key string, brand, year int) bool {
+	segments := strings.Split(key, ":")
+	if len(segments) < 2 {
+		return false
+	}
+
+	if brand > 0 {
+		found := false
+		for _, b := range strings.Split(segments[0], ",") {
+			found = found || b == strconv.Itoa(brand)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if year == 0 || segments[1] == "years" {
+		return true
+	}
+	return segments[1] == strconv.Itoa(year)
+}
+
+// WarmLookupCache reloads the years of the brands, the makes of the most
+// recent years and the models of the popular makes into redis.
+func WarmLookupCache(w WarmLookup) error {
+	l := Lookup{Brands: w.Brands, refresh: true}
+	if err := l.GetYears(nil); err != nil {
+		return err
+	}
+
+	years := l.Years
+	if w.Years > 0 && len(years) > w.Years {
+		years = years[:w.Years]
+	}
+	for _, year := range years {
+		ml := Lookup{Brands: w.Brands, refresh: true}
+		ml.Vehicle.Base.Year = year
+		if err := ml.GetMakes(nil); err != nil {
+			return err
+		}
+
+		for _, mk := range ml.Makes {
+			if !containsFold(w.Makes, mk) {
+				continue
+			}
+			mo := Lookup{Brands: w.Brands, refresh: true}
+			mo.Vehicle.Base.Year = year
+			mo.Vehicle.Base.Make = mk
+			if err := mo.GetModels(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// containsFold reports whether s is one of vals, without regard to case or
+// surrounding whitespace.
+func containsFold(vals []string, s string) bool {
+	s = strings.TrimSpace(s)
+	for _, v := range vals {
+		if strings.EqualFold(strings.TrimSpace(v), s) {
+			return true
+		}
+	}
+	return false
+}
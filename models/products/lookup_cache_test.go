@@ -0,0 +1,40 @@
+package products
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestLookupCache(t *testing.T) {
+	Convey("Testing LookupCacheKey()", t, func() {
+		So(LookupCacheKey("years", []int{3, 1}, Vehicle{}), ShouldEqual, "lookup:1,3:years")
+
+		v := Vehicle{Base: BaseVehicle{Year: 2016, Make: "Chevrolet"}}
+		So(LookupCacheKey("makes", []int{1}, Vehicle{Base: BaseVehicle{Year: 2016}}), ShouldEqual, "lookup:1:2016:makes")
+		So(LookupCacheKey("models", []int{1}, v), ShouldEqual, "lookup:1:2016:chevrolet:models")
+
+		v.Base.Model = "Silverado 1500"
+		v.Submodel = "LT"
+		v.Configurations = []Configuration{
+			{Key: "Drive Type", Value: "4WD"},
+			{Key: "Body Type", Value: "Crew Cab"},
+		}
+		So(LookupCacheKey("configurations", []int{1, 3}, v), ShouldEqual,
+			"lookup:1,3:2016:chevrolet:silverado 1500:lt:body type=crew cab,drive type=4wd:configurations")
+	})
+
+	Convey("Testing lookupKeyMatches()", t, func() {
+		So(lookupKeyMatches("1,3:years", 3, 2016), ShouldBeTrue)
+		So(lookupKeyMatches("1,3:2016:chevrolet:models", 1, 2016), ShouldBeTrue)
+		So(lookupKeyMatches("1,3:2016:chevrolet:models", 0, 0), ShouldBeTrue)
+		So(lookupKeyMatches("1,3:2015:chevrolet:models", 1, 2016), ShouldBeFalse)
+		So(lookupKeyMatches("13:2016:makes", 1, 0), ShouldBeFalse)
+		So(lookupKeyMatches("years", 0, 0), ShouldBeFalse)
+	})
+
+	Convey("Testing cached() without brands", t, func() {
+		var years []int
+		l := Lookup{}
+		So(l.cached("years", &years), ShouldBeFalse)
+	})
+}
@@ -16,8 +16,9 @@ import (
 
 // LuverneLookupContext Holds required configuration settings and resources.
 type LuverneLookupContext struct {
-	Session  *mgo.Session
-	Statuses []int
+	Session      *mgo.Session
+	Statuses     []int
+	Visibilities []string
 }
 
 // LuverneVehicleBase The lowest level vehicle properties used to query
@@ -74,6 +75,14 @@ type LuverneFitmentAttribute struct {
 // Query Returns a `CategoryVehicle` that holds matching information for the
 // queried `CategoryVehicleBase` attributes.
 func LuverneQuery(ctx *LuverneLookupContext, args ...string) (*LuverneCategoryVehicle, error) {
+	args, err := resolveArgs(args, func(year string) ([]string, error) {
+		return getLuverneMakes(ctx, year)
+	}, func(year, vehicleMake string) ([]string, error) {
+		return getLuverneModels(ctx, year, vehicleMake)
+	})
+	if err != nil {
+		return nil, err
+	}
 
 	var redisKey string
 	var category string
@@ -225,10 +234,7 @@ func getLuverneModels(ctx *LuverneLookupContext, year, vehicleMake string) ([]st
 		"luverne_applications": bson.M{
 			"$elemMatch": bson.M{
 				"year": year,
-				"make": bson.RegEx{
-					Pattern: "^" + vehicleMake + "$",
-					Options: "i",
-				},
+				"make": exactly(vehicleMake),
 			},
 		},
 		"status": bson.M{
@@ -280,20 +286,15 @@ func getLuverneStyles(ctx *LuverneLookupContext, year, vehicleMake, model, categ
 		"luverne_applications": bson.M{
 			"$elemMatch": bson.M{
 				"year": year,
-				"make": bson.RegEx{
-					Pattern: "^" + vehicleMake + "$",
-					Options: "i",
-				},
-				"model": bson.RegEx{
-					Pattern: "^" + model + "$",
-					Options: "i",
-				},
+				"make": exactly(vehicleMake),
+				"model": exactly(model),
 			},
 		},
 		"status": bson.M{
 			"$in": []int{700, 800, 810, 815, 850, 870, 888, 900, 910, 950},
 		},
-		"brand.id": 4,
+		"brand.id":       4,
+		"web_visibility": VisibilityQuery(ctx.Visibilities),
 	}
 
 	if category != "" {
@@ -1,25 +1,18 @@
 package products
 
 import (
-	"encoding/json"
-	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/curt-labs/API/helpers/apicontext"
 	"github.com/curt-labs/API/helpers/database"
-	"github.com/curt-labs/API/helpers/redis"
 	_ "github.com/go-sql-driver/mysql"
 )
 
 func (l *Lookup) GetMakes(dtx *apicontext.DataContext) error {
-	redis_key := fmt.Sprintf("lookup:year:%d:makes:%s", l.Vehicle.Base.Year, dtx.BrandString)
-	data, err := redis.Get(redis_key)
-	if err == nil {
-		err = json.Unmarshal(data, &l.Makes)
-		if len(l.Makes) > 0 {
-			return nil
-		}
+	if l.cached("makes", &l.Makes) && len(l.Makes) > 0 {
+		l.Pagination = singlePage(len(l.Makes))
+		return nil
 	}
 
 	stmtBeginning := `
@@ -37,7 +30,7 @@ func (l *Lookup) GetMakes(dtx *apicontext.DataContext) error {
 	brandStmt = strings.TrimRight(brandStmt, ",") + ")"
 	wholeStmt := stmtBeginning + brandStmt + stmtEnd
 
-	err = database.Init()
+	err := database.Init()
 	if err != nil {
 		return err
 	}
@@ -70,8 +63,6 @@ func (l *Lookup) GetMakes(dtx *apicontext.DataContext) error {
 		PerPage:       len(l.Makes),
 		TotalPages:    1,
 	}
-	if dtx.BrandString != "" {
-		redis.Setex(redis_key, l.Makes, 86400)
-	}
+	l.cache("makes", l.Makes, len(l.Makes))
 	return nil
 }
@@ -9,6 +9,11 @@ import (
 )
 
 func (l *Lookup) GetModels() error {
+	if l.cached("models", &l.Models) && len(l.Models) > 0 {
+		l.Pagination = singlePage(len(l.Models))
+		return nil
+	}
+
 	stmtBeginning := `select distinct mo.ModelName from vcdb_Model as mo
 		join BaseVehicle as bv on mo.ID = bv.ModelID
 		join vcdb_Make as ma on bv.MakeID = ma.ID
@@ -59,6 +64,7 @@ func (l *Lookup) GetModels() error {
 		PerPage:       len(l.Models),
 		TotalPages:    1,
 	}
+	l.cache("models", l.Models, len(l.Models))
 
 	return nil
 }
@@ -1,16 +1,41 @@
 package products
 
 import (
+	"database/sql"
 	"encoding/csv"
+	"errors"
 	"fmt"
-	"mime/multipart"
+	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/curt-labs/API/helpers/database"
 	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
 )
 
+// StagingSuffix is added to the name of a collection to get the collection
+// an import is staged in.
+const StagingSuffix = "_staging"
+
+// MinApplicationYear is the oldest year an imported application can have.
+const MinApplicationYear = 1900
+
+var (
+	// ErrImportInvalid is returned when an import has rows that failed
+	// validation. The live collection is left as it was.
+	ErrImportInvalid = errors.New("import has invalid rows")
+
+	// ErrImportEmpty is returned when an import has no applications.
+	ErrImportEmpty = errors.New("import has no applications")
+)
+
+// Input is a row of an application CSV: make, model, style, old part
+// number and year.
 type Input struct {
+	Line  int
 	Year  string
 	Make  string
 	Model string
@@ -18,136 +43,365 @@ type Input struct {
 	Part  string
 }
 
+// Application is the parts that fit a vehicle in an ARIES collection.
 type Application struct {
-	Year  string `bson:"year"`
-	Make  string `bson:"make"`
-	Model string `bson:"model"`
-	Style string `bson:"style"`
-	Parts []int  `bson:"parts"`
+	Year  string `bson:"year" json:"year"`
+	Make  string `bson:"make" json:"make"`
+	Model string `bson:"model" json:"model"`
+	Style string `bson:"style" json:"style"`
+	Parts []int  `bson:"parts" json:"parts"`
 }
 
-var (
-	VehicleApplications map[string]Application
-	PartConversion      map[string]int
-	Session             *mgo.Session
-	inf                 = database.AriesMongoConnectionString()
-)
+// PartLookup returns the part ID of an old part number, or 0 when there's
+// no such part. An error means the parts couldn't be looked up at all.
+type PartLookup func(oldPartNumber string) (int, error)
+
+// ImportOptions change how an import is applied.
+type ImportOptions struct {
+	// DryRun validates and diffs the import without replacing the live
+	// collection.
+	DryRun bool
+
+	// Parts looks up the parts of the rows. Defaults to the Part table.
+	Parts PartLookup
+}
+
+// ImportError is a row that failed validation.
+type ImportError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e ImportError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// ApplicationChange is a vehicle in both collections that fits different
+// parts after the import.
+type ApplicationChange struct {
+	Application
+	AddedParts   []int `json:"added_parts,omitempty"`
+	RemovedParts []int `json:"removed_parts,omitempty"`
+}
+
+// ImportDiff compares the staged applications to the live ones.
+type ImportDiff struct {
+	Added     []Application       `json:"added"`
+	Removed   []Application       `json:"removed"`
+	Changed   []ApplicationChange `json:"changed"`
+	Unchanged int                 `json:"unchanged"`
+}
+
+// ImportReport is the outcome of an import.
+type ImportReport struct {
+	Collection   string        `json:"collection"`
+	Rows         int           `json:"rows"`
+	Applications int           `json:"applications"`
+	Errors       []ImportError `json:"errors,omitempty"`
+	Diff         ImportDiff    `json:"diff"`
+	Applied      bool          `json:"applied"`
+}
+
+// Import replaces the applications of an ARIES collection with the CSV
+// in r. The rows are validated and written to a staging collection, which
+// is diffed against the live collection and renamed over it in one step.
+// The live collection is untouched when anything fails or on a dry run.
+func Import(r io.Reader, collection string, opts ImportOptions) (ImportReport, error) {
+	report := ImportReport{Collection: collection}
+	if collection == "" || strings.HasSuffix(collection, StagingSuffix) || strings.Contains(collection, "system") {
+		return report, fmt.Errorf("invalid collection %q", collection)
+	}
+	if opts.Parts == nil {
+		opts.Parts = partByOldNumber
+	}
 
-func Import(f multipart.File, collectionName string) ([]error, []error, error) {
-	var err error
-	var conversionErrs []error
-	var insertErrs []error
-	VehicleApplications = make(map[string]Application)
-	PartConversion = make(map[string]int)
-	Session, err = mgo.DialWithInfo(inf)
-	es, err := CaptureCsv(f)
+	es, err := CaptureCsv(r)
 	if err != nil {
-		return conversionErrs, insertErrs, err
+		return report, err
 	}
+	report.Rows = len(es)
 
-	for _, e := range es {
-		if cerr := ConvertToApplication(e); cerr != nil {
-			conversionErrs = append(conversionErrs, cerr)
-			continue
-		}
+	apps, errs, err := BuildApplications(es, opts.Parts)
+	if err != nil {
+		return report, err
+	}
+	report.Applications = len(apps)
+	report.Errors = errs
+	if len(errs) > 0 {
+		return report, ErrImportInvalid
+	}
+	if len(apps) == 0 {
+		return report, ErrImportEmpty
 	}
 
-	_ = ClearCollection(collectionName)
+	info := database.AriesMongoConnectionString()
+	session, err := mgo.DialWithInfo(info)
+	if err != nil {
+		return report, err
+	}
+	defer session.Close()
 
-	for _, app := range VehicleApplications {
-		if ierr := IntoDB(app, collectionName); ierr != nil {
-			insertErrs = append(insertErrs, ierr)
-			continue
-		}
+	db := session.DB(info.Database)
+	staging := db.C(collection + StagingSuffix)
+	if err = stageApplications(staging, apps); err != nil {
+		staging.DropCollection()
+		return report, err
+	}
+
+	var live []Application
+	if err = db.C(collection).Find(nil).All(&live); err != nil {
+		staging.DropCollection()
+		return report, err
+	}
+	report.Diff = DiffApplications(live, apps)
+
+	if opts.DryRun {
+		return report, staging.DropCollection()
+	}
+
+	err = session.Run(bson.D{
+		{Name: "renameCollection", Value: info.Database + "." + staging.Name},
+		{Name: "to", Value: info.Database + "." + collection},
+		{Name: "dropTarget", Value: true},
+	}, nil)
+	if err != nil {
+		staging.DropCollection()
+		return report, err
+	}
+	report.Applied = true
+
+	return report, nil
+}
+
+// stageApplications replaces the staging collection with the applications
+// and indexes it the way the lookups query it.
+func stageApplications(c *mgo.Collection, apps []Application) error {
+	if err := c.DropCollection(); err != nil && err.Error() != "ns not found" {
+		return err
+	}
+
+	bulk := c.Bulk()
+	bulk.Unordered()
+	for _, app := range apps {
+		bulk.Insert(app)
+	}
+	if _, err := bulk.Run(); err != nil {
+		return err
 	}
 
-	return conversionErrs, insertErrs, err
+	return c.EnsureIndex(mgo.Index{
+		Key:        []string{"year", "make", "model", "style"},
+		Background: true,
+	})
 }
 
-//Csv to Struct
-func CaptureCsv(f multipart.File) ([]Input, error) {
-	var e Input
+// CaptureCsv reads the rows of an application CSV, skipping a header row
+// and rows with fewer than five columns.
+func CaptureCsv(r io.Reader) ([]Input, error) {
 	var es []Input
 
-	reader := csv.NewReader(f)
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
 
 	lines, err := reader.ReadAll()
 	if err != nil {
 		return es, err
 	}
 
-	for _, line := range lines {
+	for i, line := range lines {
 		if len(line) < 5 {
 			continue
 		}
-		e = Input{
+		if i == 0 && strings.EqualFold(strings.TrimSpace(line[4]), "year") {
+			continue
+		}
+		es = append(es, Input{
+			Line:  i + 1,
 			Make:  strings.ToLower(strings.TrimSpace(line[0])),
 			Model: strings.ToLower(strings.TrimSpace(line[1])),
 			Style: strings.ToLower(strings.TrimSpace(line[2])),
 			Part:  strings.TrimSpace(line[3]),
 			Year:  strings.ToLower(strings.TrimSpace(line[4])),
-		}
-
-		es = append(es, e)
+		})
 	}
 	return es, nil
 }
 
-//Convert Input ot Applications array
-func ConvertToApplication(e Input) error {
-	var partID int
+// BuildApplications groups the rows into an application per vehicle,
+// sorted by vehicle. Rows with unknown parts, missing vehicle names, years
+// outside of MinApplicationYear to two years from now or that repeat a
+// vehicle and part are returned as errors. It stops at the first part the
+// lookup fails on, since every row after it would fail the same way.
+func BuildApplications(es []Input, parts PartLookup) ([]Application, []ImportError, error) {
+	var errs []ImportError
+	apps := make(map[string]*Application)
+	partIDs := make(map[string]int)
+	seen := make(map[string]int)
+	maxYear := time.Now().Year() + 2
 
-	if partID = PartConversion[e.Part]; partID == 0 {
+	for _, e := range es {
+		year, err := strconv.Atoi(e.Year)
+		if err != nil || year < MinApplicationYear || year > maxYear {
+			errs = append(errs, ImportError{Line: e.Line, Message: fmt.Sprintf("invalid year %q", e.Year)})
+			continue
+		}
+		if e.Make == "" || e.Model == "" {
+			errs = append(errs, ImportError{Line: e.Line, Message: "make and model are required"})
+			continue
+		}
 
-		err := database.Init()
-		if err != nil {
-			return err
+		partID, ok := partIDs[e.Part]
+		if !ok {
+			if partID, err = parts(e.Part); err != nil {
+				return nil, errs, fmt.Errorf("looking up part %s: %v", e.Part, err)
+			}
+			partIDs[e.Part] = partID
 		}
+		if partID == 0 {
+			errs = append(errs, ImportError{Line: e.Line, Message: fmt.Sprintf("invalid part: %s", e.Part)})
+			continue
+		}
+
+		tmp := Application{
+			Year:  e.Year,
+			Make:  e.Make,
+			Model: e.Model,
+			Style: e.Style,
+		}
+		key := tmp.string()
 
-		stmt, err := database.DB.Prepare("select partID from Part where oldPartNumber = ?")
-		if err != nil {
-			return err
+		dup := fmt.Sprintf("%s|%d", key, partID)
+		if line, ok := seen[dup]; ok {
+			errs = append(errs, ImportError{Line: e.Line, Message: fmt.Sprintf("duplicate of line %d", line)})
+			continue
 		}
-		defer stmt.Close()
+		seen[dup] = e.Line
 
-		if err := stmt.QueryRow(e.Part).Scan(&partID); err != nil || partID == 0 {
-			return fmt.Errorf("invalid part: %s", e.Part)
+		if apps[key] == nil {
+			apps[key] = &tmp
 		}
+		apps[key].Parts = append(apps[key].Parts, partID)
+	}
+
+	list := make([]Application, 0, len(apps))
+	for _, app := range apps {
+		sort.Ints(app.Parts)
+		list = append(list, *app)
+	}
+	sortApplications(list)
+
+	return list, errs, nil
+}
+
+// DiffApplications compares the vehicles and parts of the staged
+// applications to the live ones.
+func DiffApplications(live, staged []Application) ImportDiff {
+	diff := ImportDiff{
+		Added:   make([]Application, 0),
+		Removed: make([]Application, 0),
+		Changed: make([]ApplicationChange, 0),
+	}
 
-		PartConversion[e.Part] = partID
+	liveApps := make(map[string]Application, len(live))
+	for _, app := range live {
+		liveApps[app.string()] = app
 	}
 
-	tmp := Application{
-		Parts: []int{partID},
-		Year:  e.Year,
-		Make:  e.Make,
-		Model: e.Model,
-		Style: e.Style,
+	for _, app := range staged {
+		key := app.string()
+		old, ok := liveApps[key]
+		if !ok {
+			diff.Added = append(diff.Added, app)
+			continue
+		}
+		delete(liveApps, key)
+
+		added, removed := diffParts(old.Parts, app.Parts)
+		if len(added) == 0 && len(removed) == 0 {
+			diff.Unchanged++
+			continue
+		}
+		diff.Changed = append(diff.Changed, ApplicationChange{
+			Application:  app,
+			AddedParts:   added,
+			RemovedParts: removed,
+		})
 	}
 
-	idx := VehicleApplications[tmp.string()]
-	if idx.Year == "" {
-		VehicleApplications[tmp.string()] = tmp
-		return nil
+	for _, app := range liveApps {
+		diff.Removed = append(diff.Removed, app)
 	}
+	sortApplications(diff.Added)
+	sortApplications(diff.Removed)
 
-	idx.Parts = append(idx.Parts, partID)
-	VehicleApplications[tmp.string()] = idx
+	return diff
+}
 
-	return nil
+// diffParts returns the parts of b that aren't in a and the parts of a
+// that aren't in b.
+func diffParts(a, b []int) (added, removed []int) {
+	inA := make(map[int]bool, len(a))
+	for _, id := range a {
+		inA[id] = true
+	}
+	inB := make(map[int]bool, len(b))
+	for _, id := range b {
+		inB[id] = true
+		if !inA[id] {
+			added = append(added, id)
+		}
+	}
+	for _, id := range a {
+		if !inB[id] {
+			removed = append(removed, id)
+		}
+	}
+	sort.Ints(added)
+	sort.Ints(removed)
+	return added, removed
 }
 
-//Dump into mongo
-func IntoDB(app Application, collectionName string) error {
-	return Session.DB(inf.Database).C(collectionName).Insert(app)
+// sortApplications sorts by year, newest first, then make, model and
+// style.
+func sortApplications(apps []Application) {
+	sort.Slice(apps, func(i, j int) bool {
+		a, b := apps[i], apps[j]
+		if a.Year != b.Year {
+			return a.Year > b.Year
+		}
+		if a.Make != b.Make {
+			return a.Make < b.Make
+		}
+		if a.Model != b.Model {
+			return a.Model < b.Model
+		}
+		return a.Style < b.Style
+	})
 }
 
-//Drop collection specified
-func ClearCollection(name string) error {
-	return Session.DB(inf.Database).C(name).DropCollection()
+// partByOldNumber looks up the part ID of an old part number in the Part
+// table.
+func partByOldNumber(oldPartNumber string) (int, error) {
+	err := database.Init()
+	if err != nil {
+		return 0, err
+	}
+
+	stmt, err := database.DB.Prepare("select partID from Part where oldPartNumber = ?")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var partID int
+	err = stmt.QueryRow(oldPartNumber).Scan(&partID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return partID, err
 }
 
-//ToString
+// string is the key of the vehicle of the application.
 func (a *Application) string() string {
-	return fmt.Sprintf("%s%s%s%s", a.Year, a.Make, a.Model, a.Style)
+	return fmt.Sprintf("%s|%s|%s|%s", a.Year, a.Make, a.Model, a.Style)
 }
@@ -0,0 +1,108 @@
+package products
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func fakeParts(oldPartNumber string) (int, error) {
+	switch oldPartNumber {
+	case "AR-1":
+		return 1, nil
+	case "AR-2":
+		return 2, nil
+	}
+	return 0, nil
+}
+
+func TestApplicationImport(t *testing.T) {
+	Convey("Testing CaptureCsv()", t, func() {
+		es, err := CaptureCsv(strings.NewReader("make,model,style,part,year\nChevrolet, Silverado 1500 ,LT,AR-1,2016\nshort,row\n"))
+		So(err, ShouldBeNil)
+		So(len(es), ShouldEqual, 1)
+		So(es[0], ShouldResemble, Input{Line: 2, Year: "2016", Make: "chevrolet", Model: "silverado 1500", Style: "lt", Part: "AR-1"})
+	})
+
+	Convey("Testing BuildApplications()", t, func() {
+		apps, errs, err := BuildApplications([]Input{
+			{Line: 1, Year: "2016", Make: "chevrolet", Model: "silverado 1500", Part: "AR-2"},
+			{Line: 2, Year: "2016", Make: "chevrolet", Model: "silverado 1500", Part: "AR-1"},
+			{Line: 3, Year: "2015", Make: "ford", Model: "f-150", Style: "xlt", Part: "AR-1"},
+		}, fakeParts)
+		So(err, ShouldBeNil)
+		So(errs, ShouldBeEmpty)
+		So(apps, ShouldResemble, []Application{
+			{Year: "2016", Make: "chevrolet", Model: "silverado 1500", Parts: []int{1, 2}},
+			{Year: "2015", Make: "ford", Model: "f-150", Style: "xlt", Parts: []int{1}},
+		})
+
+		apps, errs, err = BuildApplications([]Input{
+			{Line: 1, Year: "2016", Make: "chevrolet", Model: "silverado 1500", Part: "AR-1"},
+			{Line: 2, Year: "2016", Make: "chevrolet", Model: "silverado 1500", Part: "AR-1"},
+			{Line: 3, Year: "1850", Make: "ford", Model: "f-150", Part: "AR-1"},
+			{Line: 4, Year: "2o16", Make: "ford", Model: "f-150", Part: "AR-1"},
+			{Line: 5, Year: "2016", Make: "ford", Model: "f-150", Part: "AR-9"},
+			{Line: 6, Year: "2016", Make: "ford", Part: "AR-1"},
+		}, fakeParts)
+		So(err, ShouldBeNil)
+		So(len(apps), ShouldEqual, 1)
+		So(errs, ShouldResemble, []ImportError{
+			{Line: 2, Message: "duplicate of line 1"},
+			{Line: 3, Message: `invalid year "1850"`},
+			{Line: 4, Message: `invalid year "2o16"`},
+			{Line: 5, Message: "invalid part: AR-9"},
+			{Line: 6, Message: "make and model are required"},
+		})
+
+		lookups := 0
+		down := func(string) (int, error) {
+			lookups++
+			return 0, errors.New("connection refused")
+		}
+		apps, errs, err = BuildApplications([]Input{
+			{Line: 1, Year: "2016", Make: "chevrolet", Model: "silverado 1500", Part: "AR-1"},
+			{Line: 2, Year: "2016", Make: "ford", Model: "f-150", Part: "AR-2"},
+		}, down)
+		So(err, ShouldNotBeNil)
+		So(apps, ShouldBeEmpty)
+		So(errs, ShouldBeEmpty)
+		So(lookups, ShouldEqual, 1)
+	})
+
+	Convey("Testing DiffApplications()", t, func() {
+		live := []Application{
+			{Year: "2016", Make: "chevrolet", Model: "silverado 1500", Parts: []int{1, 2}},
+			{Year: "2015", Make: "ford", Model: "f-150", Parts: []int{1}},
+			{Year: "2014", Make: "ram", Model: "1500", Parts: []int{3}},
+		}
+		staged := []Application{
+			{Year: "2016", Make: "chevrolet", Model: "silverado 1500", Parts: []int{1, 2}},
+			{Year: "2015", Make: "ford", Model: "f-150", Parts: []int{2}},
+			{Year: "2017", Make: "gmc", Model: "sierra 1500", Parts: []int{1}},
+		}
+
+		d := DiffApplications(live, staged)
+		So(d.Unchanged, ShouldEqual, 1)
+		So(d.Added, ShouldResemble, []Application{staged[2]})
+		So(d.Removed, ShouldResemble, []Application{live[2]})
+		So(d.Changed, ShouldResemble, []ApplicationChange{
+			{Application: staged[1], AddedParts: []int{2}, RemovedParts: []int{1}},
+		})
+	})
+
+	Convey("Testing Import() validation", t, func() {
+		_, err := Import(strings.NewReader(""), "exterior"+StagingSuffix, ImportOptions{})
+		So(err, ShouldNotBeNil)
+
+		report, err := Import(strings.NewReader("chevrolet,silverado 1500,,AR-9,2016\n"), "exterior", ImportOptions{Parts: fakeParts})
+		So(err, ShouldEqual, ErrImportInvalid)
+		So(report.Rows, ShouldEqual, 1)
+		So(len(report.Errors), ShouldEqual, 1)
+		So(report.Applied, ShouldBeFalse)
+
+		_, err = Import(strings.NewReader(""), "exterior", ImportOptions{Parts: fakeParts})
+		So(err, ShouldEqual, ErrImportEmpty)
+	})
+}
@@ -118,7 +118,7 @@ func GetAriesVehicleCollections(session *mgo.Session) ([]string, error) {
 
 	validCols := make([]string, 0)
 	for _, col := range cols {
-		if !strings.Contains(col, "system") {
+		if !strings.Contains(col, "system") && !strings.HasSuffix(col, StagingSuffix) {
 			validCols = append(validCols, col)
 		}
 	}
@@ -127,6 +127,9 @@ func GetAriesVehicleCollections(session *mgo.Session) ([]string, error) {
 }
 
 func GetApps(v NoSqlVehicle, collection string) (stage string, vals []string, err error) {
+	if err = v.resolve(collection); err != nil {
+		return
+	}
 
 	if v.Year != "" && v.Make != "" && v.Model != "" && v.Style != "" {
 		return
@@ -182,6 +185,9 @@ func GetApps(v NoSqlVehicle, collection string) (stage string, vals []string, er
 }
 
 func FindVehicles(v NoSqlVehicle, collection string, dtx *apicontext.DataContext) (l NoSqlLookup, err error) {
+	if err = v.resolve(collection); err != nil {
+		return
+	}
 
 	l = NoSqlLookup{}
 
@@ -435,6 +441,9 @@ func buildPartMap() error {
 }
 
 func FindVehiclesWithParts(v NoSqlVehicle, collection string, dtx *apicontext.DataContext, sess *mgo.Session) (l NoSqlLookup, err error) {
+	if err = v.resolve(collection); err != nil {
+		return
+	}
 
 	l = NoSqlLookup{}
 
@@ -469,7 +478,7 @@ func FindVehiclesWithParts(v NoSqlVehicle, collection string, dtx *apicontext.Da
 
 	c.Find(queryMap).Distinct("parts", &ids)
 
-	l.Parts, err = GetMany(ids, getBrandsFromDTX(dtx), sess)
+	l.Parts, err = GetVisible(ids, dtx, sess)
 	if err != nil {
 		return l, err
 	}
@@ -493,7 +502,7 @@ func FindVehiclesWithParts(v NoSqlVehicle, collection string, dtx *apicontext.Da
 //query base+style
 //get parts
 
-func FindVehiclesFromAllCategories(v NoSqlVehicle, dtx *apicontext.DataContext, sess *mgo.Session) (map[string]NoSqlLookup, error) {
+func FindVehiclesFromAllCategories(vehicle NoSqlVehicle, dtx *apicontext.DataContext, sess *mgo.Session) (map[string]NoSqlLookup, error) {
 	var l NoSqlLookup
 	lookupMap := make(map[string]NoSqlLookup)
 
@@ -505,6 +514,10 @@ func FindVehiclesFromAllCategories(v NoSqlVehicle, dtx *apicontext.DataContext,
 
 	//from each category
 	for _, col := range cols {
+		v := vehicle
+		if err := v.resolve(col); err != nil {
+			continue
+		}
 
 		c := sess.DB(AriesDb).C(col)
 		queryMap := make(map[string]interface{})
@@ -527,7 +540,7 @@ func FindVehiclesFromAllCategories(v NoSqlVehicle, dtx *apicontext.DataContext,
 			continue
 		}
 		//add parts
-		l.Parts, err = GetMany(ids, getBrandsFromDTX(dtx), sess)
+		l.Parts, err = GetVisible(ids, dtx, sess)
 		if err != nil {
 			continue
 		}
@@ -551,6 +564,9 @@ func FindPartsFromOneCategory(v NoSqlVehicle, collection string, dtx *apicontext
 	if err != nil {
 		return lookupMap, err
 	}
+	if err = v.resolve(collection); err != nil {
+		return lookupMap, err
+	}
 	c := sess.DB(AriesDb).C(collection)
 	queryMap := make(map[string]interface{})
 	//query base vehicle
@@ -570,7 +586,7 @@ func FindPartsFromOneCategory(v NoSqlVehicle, collection string, dtx *apicontext
 	c.Find(queryMap).Distinct("parts", &ids)
 	//add parts
 
-	l.Parts, err = GetMany(ids, getBrandsFromDTX(dtx), sess)
+	l.Parts, err = GetVisible(ids, dtx, sess)
 	if err != nil {
 		return lookupMap, err
 	}
@@ -42,6 +42,7 @@ type Part struct {
 	Content           []Content            `json:"content" xml:"content" bson:"content"`
 	Pricing           []Price              `json:"pricing" xml:"pricing" bson:"pricing"`
 	Reviews           []Review             `json:"reviews" xml:"reviews" bson:"reviews"`
+	RatingHistogram   RatingHistogram      `json:"rating_histogram" xml:"rating_histogram" bson:"rating_histogram"`
 	Images            []Image              `json:"images" xml:"images" bson:"images"`
 	Related           []int                `json:"related" xml:"related" bson:"related" bson:"related"`
 	ReplacedBy        int                  `bson:"replaced_by" json:"replaced_by,omitempty" xml:"replaced_by,omitempty"`
@@ -147,6 +148,10 @@ func (p *Part) Get(dtx *apicontext.DataContext) error {
 	if err := p.FromDatabase(brands); err != nil {
 		return err
 	}
+	if !p.VisibleTo(dtx) {
+		return mgo.ErrNotFound
+	}
+	p.SetWebFlags()
 
 	return err
 }
@@ -164,7 +169,7 @@ func GetMulti(dtx *apicontext.DataContext, ids []string) ([]Part, error) {
 	session := database.ProductMongoSession.Copy()
 	defer session.Close()
 
-	query := bson.M{"part_number": bson.M{"$in": ids}, "brand.id": bson.M{"$in": brands}}
+	query := bson.M{"part_number": bson.M{"$in": ids}, "brand.id": bson.M{"$in": brands}, "web_visibility": VisibilityQuery(Visibilities(dtx))}
 
 	var parts []Part
 	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).All(&parts)
@@ -172,7 +177,7 @@ func GetMulti(dtx *apicontext.DataContext, ids []string) ([]Part, error) {
 		return nil, err
 	}
 
-	return BindCustomerToSeveralParts(parts, dtx)
+	return BindCustomerToSeveralParts(FilterVisible(parts, dtx), dtx)
 }
 
 func (p *Part) GetNoCust(dtx *apicontext.DataContext, sess *mgo.Session) error {
@@ -237,11 +242,13 @@ func Identifiers(brand int, dtx *apicontext.DataContext) ([]string, error) {
 	return parts, nil
 }
 
-func All(page, count int, dtx *apicontext.DataContext, from time.Time, to time.Time) ([]Part, int, error) {
+// All returns a page of the parts of the data context's brands, sorted by
+// ID, and how many there are. With rules, only the parts that match them
+// are paged and counted.
+func All(page, count int, dtx *apicontext.DataContext, from time.Time, to time.Time, rules []FilterRule) ([]Part, int, error) {
 	var total int
 	var query bson.M
-	//Currently a list of all visibilities, might add or subtract later
-	visibility := []string{PUBLIC, DISABLED, LOGGEDIN}
+	visibility := VisibilityQuery(Visibilities(dtx))
 	brands := getBrandsFromDTX(dtx)
 	parts := make([]Part, 0)
 
@@ -259,21 +266,25 @@ func All(page, count int, dtx *apicontext.DataContext, from time.Time, to time.T
 			//In the case both "to" and "from" are specified
 			if !time.Time.IsZero(to) {
 				query = bson.M{"brand.id": bson.M{"$in": brands},
-					"web_visibility": bson.M{"$in": visibility},
+					"web_visibility": visibility,
 					"date_modified":  bson.M{"$lte": to, "$gte": from}}
 			} else { //In the case only "from" is specified
 				query = bson.M{"brand.id": bson.M{"$in": brands},
-					"web_visibility": bson.M{"$in": visibility},
+					"web_visibility": visibility,
 					"date_modified":  bson.M{"$gte": from}}
 			}
 		} else if !time.Time.IsZero(to) { //In the case only "to" is specified
 			query = bson.M{"brand.id": bson.M{"$in": brands},
-				"web_visibility": bson.M{"$in": visibility},
+				"web_visibility": visibility,
 				"date_modified":  bson.M{"$lte": to}}
 		}
 
 	} else { //In the case neither "to" or "from" are specified
-		query = bson.M{"brand.id": bson.M{"$in": brands}, "web_visibility": bson.M{"$in": visibility}}
+		query = bson.M{"brand.id": bson.M{"$in": brands}, "web_visibility": visibility}
+	}
+
+	if len(rules) > 0 {
+		return FilterPage(session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Sort("id").Iter(), rules, dtx, page*count, count)
 	}
 
 	//We get the count here so that we can return it as part of the JSON response
@@ -288,26 +299,7 @@ func All(page, count int, dtx *apicontext.DataContext, from time.Time, to time.T
 
 	//Determining Web Visibility, based on flags that we are given by data team
 	for ind := range parts {
-		if parts[ind].WebVisibility == PUBLIC {
-			parts[ind].ShowForLoggedIn = false
-			if parts[ind].Status >= 700 {
-				parts[ind].ShowOnWebsite = true
-			} else {
-				parts[ind].ShowOnWebsite = false
-			}
-
-		} else if parts[ind].WebVisibility == DISABLED {
-			parts[ind].ShowOnWebsite = false
-			parts[ind].ShowForLoggedIn = false
-		} else if parts[ind].WebVisibility == LOGGEDIN {
-			parts[ind].ShowForLoggedIn = true
-			if parts[ind].Status >= 700 {
-				parts[ind].ShowOnWebsite = true
-			} else {
-				parts[ind].ShowOnWebsite = false
-			}
-
-		}
+		parts[ind].SetWebFlags()
 	}
 	return parts, total, err
 }
@@ -324,8 +316,8 @@ func Featured(count int, dtx *apicontext.DataContext, brand int) ([]Part, error)
 		return parts, err
 	}
 	defer session.Close()
-	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(bson.M{"featured": true, "brand.id": bson.M{"$in": brands}}).Sort("id:1").Limit(count).All(&parts)
-	return parts, err
+	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(bson.M{"featured": true, "brand.id": bson.M{"$in": brands}, "web_visibility": VisibilityQuery(Visibilities(dtx))}).Sort("id:1").Limit(count).All(&parts)
+	return FilterVisible(parts, dtx), err
 }
 
 func Latest(count int, dtx *apicontext.DataContext, brand int) ([]Part, error) {
@@ -340,8 +332,8 @@ func Latest(count int, dtx *apicontext.DataContext, brand int) ([]Part, error) {
 		return parts, err
 	}
 	defer session.Close()
-	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(bson.M{"brand.id": bson.M{"$in": brands}}).Sort("-date_added").Limit(count).All(&parts)
-	return parts, err
+	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(bson.M{"brand.id": bson.M{"$in": brands}, "web_visibility": VisibilityQuery(Visibilities(dtx))}).Sort("-date_added").Limit(count).All(&parts)
+	return FilterVisible(parts, dtx), err
 }
 
 func (p *Part) GetRelated(dtx *apicontext.DataContext) ([]Part, error) {
@@ -361,9 +353,10 @@ func (p *Part) GetRelated(dtx *apicontext.DataContext) ([]Part, error) {
 		"brand.id": bson.M{
 			"$in": brands,
 		},
+		"web_visibility": VisibilityQuery(Visibilities(dtx)),
 	}
 	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Sort("id:1").All(&parts)
-	return parts, err
+	return FilterVisible(parts, dtx), err
 }
 
 func (p *Part) BindCustomer(dtx *apicontext.DataContext) {
@@ -496,42 +489,22 @@ func (p *Part) GetPartByPartNumber(dtx *apicontext.DataContext) (err error) {
 		return err
 	}
 	defer session.Close()
-	pattern := bson.RegEx{
-		Pattern: "^" + p.PartNumber + "$",
-		Options: "i",
-	}
-	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(bson.M{"part_number": pattern}).One(&p)
+	err = session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(bson.M{"part_number": exactly(p.PartNumber)}).One(&p)
 	if err != nil {
 		return err
 	}
 
+	if !p.VisibleTo(dtx) {
+		return mgo.ErrNotFound
+	}
+
 	parts, err := BindCustomerToSeveralParts([]Part{*p}, dtx)
 	if len(parts) > 0 {
 		*p = parts[0]
 	}
 
 	//Determining Web Visibility, based on flags that we are given by data team
-
-	if p.WebVisibility == PUBLIC {
-		p.ShowForLoggedIn = false
-		if p.Status >= 700 {
-			p.ShowOnWebsite = true
-		} else {
-			p.ShowOnWebsite = false
-		}
-
-	} else if p.WebVisibility == DISABLED {
-		p.ShowOnWebsite = false
-		p.ShowForLoggedIn = false
-	} else if p.WebVisibility == LOGGEDIN {
-		p.ShowForLoggedIn = true
-		if p.Status >= 700 {
-			p.ShowOnWebsite = true
-		} else {
-			p.ShowOnWebsite = false
-		}
-
-	}
+	p.SetWebFlags()
 
 	return err
 }
@@ -0,0 +1,324 @@
+package products
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/helpers/redis"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// MinSharedVehicles is how many vehicles two parts have to have in
+	// common before we'll recommend one with the other.
+	MinSharedVehicles = 2
+
+	// MaxRecommendations is how many recommendations are stored per part.
+	MaxRecommendations = 25
+
+	recommendationRedisKey = "recommendations"
+)
+
+// Recommendation is a part that's frequently fit to the same vehicles as
+// another part, but from a different category.
+type Recommendation struct {
+	Part           Part    `json:"part" xml:"part"`
+	Score          float64 `json:"score" xml:"score,attr"`
+	SharedVehicles int     `json:"shared_vehicles" xml:"shared_vehicles,attr"`
+}
+
+// RecommendationEntry is a scored recommendation as it's stored in the
+// recommendation index.
+type RecommendationEntry struct {
+	PartID         int     `bson:"part_id" json:"part_id"`
+	BrandID        int     `bson:"brand_id" json:"brand_id"`
+	Score          float64 `bson:"score" json:"score"`
+	SharedVehicles int     `bson:"shared_vehicles" json:"shared_vehicles"`
+}
+
+// RecommendationIndex maps a part ID to its recommendations, best first.
+type RecommendationIndex map[int][]RecommendationEntry
+
+type recommendationDoc struct {
+	PartID          int                   `bson:"part_id"`
+	Recommendations []RecommendationEntry `bson:"recommendations"`
+	DateBuilt       time.Time             `bson:"date_built"`
+}
+
+// BuildRecommendationIndex scores every pair of parts that fit the same
+// vehicles. Parts sharing a category aren't recommended together, since a
+// hitch should bring up a wiring harness, not another hitch. The score is
+// the cosine similarity of the two parts' vehicle sets.
+func BuildRecommendationIndex(parts []Part, limit int) RecommendationIndex {
+	idx := make(RecommendationIndex)
+
+	byID := make(map[int]*Part, len(parts))
+	vehicleCounts := make(map[int]int, len(parts))
+	categories := make(map[int]map[int]bool, len(parts))
+	fitments := make(map[string][]int)
+
+	for i := range parts {
+		p := &parts[i]
+		byID[p.ID] = p
+
+		categories[p.ID] = make(map[int]bool)
+		for _, cat := range p.Categories {
+			categories[p.ID][cat.CategoryID] = true
+		}
+
+		seen := make(map[string]bool)
+		for _, v := range p.Vehicles {
+			key := vehicleKey(v)
+			if key == "" || seen[key] {
+				continue
+			}
+			seen[key] = true
+			fitments[key] = append(fitments[key], p.ID)
+		}
+		vehicleCounts[p.ID] = len(seen)
+	}
+
+	shared := make(map[int]map[int]int)
+	for _, ids := range fitments {
+		for _, a := range ids {
+			if shared[a] == nil {
+				shared[a] = make(map[int]int)
+			}
+			for _, b := range ids {
+				if a != b {
+					shared[a][b]++
+				}
+			}
+		}
+	}
+
+	for a, counts := range shared {
+		var entries []RecommendationEntry
+		for b, n := range counts {
+			if n < MinSharedVehicles || sharesCategory(categories[a], categories[b]) {
+				continue
+			}
+			score := float64(n) / math.Sqrt(float64(vehicleCounts[a]*vehicleCounts[b]))
+			entries = append(entries, RecommendationEntry{
+				PartID:         b,
+				BrandID:        byID[b].Brand.ID,
+				Score:          math.Floor(score*1000+0.5) / 1000,
+				SharedVehicles: n,
+			})
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		sort.Slice(entries, func(i, j int) bool {
+			if entries[i].Score != entries[j].Score {
+				return entries[i].Score > entries[j].Score
+			}
+			if entries[i].SharedVehicles != entries[j].SharedVehicles {
+				return entries[i].SharedVehicles > entries[j].SharedVehicles
+			}
+			return entries[i].PartID < entries[j].PartID
+		})
+		if limit > 0 && len(entries) > limit {
+			entries = entries[:limit]
+		}
+		idx[a] = entries
+	}
+
+	return idx
+}
+
+// RefreshRecommendations rebuilds the recommendation index from the
+// products collection and overwrites the cached recommendations of every
+// part it touches. It's run offline by cmd/recommendations, the request path
+// only ever reads the stored index. Returns the number of parts indexed.
+func RefreshRecommendations() (int, error) {
+	if err := database.Init(); err != nil {
+		return 0, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	var parts []Part
+	err := session.DB(database.ProductDatabase).C(database.ProductCollectionName).
+		Find(bson.M{"status": bson.M{"$in": statuses}}).
+		Select(bson.M{"id": 1, "brand": 1, "categories.id": 1, "vehicle_applications": 1}).
+		All(&parts)
+	if err != nil {
+		return 0, err
+	}
+
+	idx := BuildRecommendationIndex(parts, MaxRecommendations)
+
+	built := time.Now()
+	c := session.DB(database.ProductDatabase).C(database.RecommendationCollectionName)
+	if err = c.EnsureIndex(mgo.Index{Key: []string{"part_id"}, Unique: true, Background: true}); err != nil {
+		return 0, err
+	}
+
+	bulk := c.Bulk()
+	bulk.Unordered()
+	pending := 0
+	for id, entries := range idx {
+		bulk.Upsert(bson.M{"part_id": id}, recommendationDoc{
+			PartID:          id,
+			Recommendations: entries,
+			DateBuilt:       built,
+		})
+		pending++
+		if pending == 500 {
+			if _, err = bulk.Run(); err != nil {
+				return 0, err
+			}
+			bulk = c.Bulk()
+			bulk.Unordered()
+			pending = 0
+		}
+	}
+	if pending > 0 {
+		if _, err = bulk.Run(); err != nil {
+			return 0, err
+		}
+	}
+
+	// anything not rebuilt doesn't have recommendations anymore
+	var stale []recommendationDoc
+	err = c.Find(bson.M{"date_built": bson.M{"$lt": built}}).Select(bson.M{"part_id": 1}).All(&stale)
+	if err != nil {
+		return 0, err
+	}
+	if _, err = c.RemoveAll(bson.M{"date_built": bson.M{"$lt": built}}); err != nil {
+		return 0, err
+	}
+
+	for id, entries := range idx {
+		if err = redis.Setex(recommendationCacheKey(id), entries, redis.CacheTimeout); err != nil {
+			return len(idx), err
+		}
+	}
+	for _, doc := range stale {
+		if err = redis.Delete(recommendationCacheKey(doc.PartID)); err != nil {
+			return len(idx), err
+		}
+	}
+
+	return len(idx), nil
+}
+
+// GetRecommendations returns the parts most often fit alongside this part,
+// limited to the brands of the data context.
+func (p *Part) GetRecommendations(dtx *apicontext.DataContext, count int) ([]Recommendation, error) {
+	recs := make([]Recommendation, 0)
+
+	entries, err := recommendationEntries(p.ID)
+	if err != nil {
+		return recs, err
+	}
+
+	brands := getBrandsFromDTX(dtx)
+	allowed := make(map[int]bool, len(brands))
+	for _, b := range brands {
+		allowed[b] = true
+	}
+
+	var ids []int
+	for _, e := range entries {
+		if allowed[e.BrandID] {
+			ids = append(ids, e.PartID)
+		}
+	}
+	if len(ids) == 0 {
+		return recs, nil
+	}
+
+	if err = database.Init(); err != nil {
+		return recs, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	parts, err := GetVisible(ids, dtx, session)
+	if err != nil {
+		return recs, err
+	}
+	if parts, err = BindCustomerToSeveralParts(parts, dtx); err != nil {
+		return recs, err
+	}
+
+	byID := make(map[int]Part, len(parts))
+	for _, part := range parts {
+		byID[part.ID] = part
+	}
+
+	for _, e := range entries {
+		part, ok := byID[e.PartID]
+		if !ok {
+			continue
+		}
+		recs = append(recs, Recommendation{
+			Part:           part,
+			Score:          e.Score,
+			SharedVehicles: e.SharedVehicles,
+		})
+		if count > 0 && len(recs) == count {
+			break
+		}
+	}
+
+	return recs, nil
+}
+
+func recommendationEntries(partID int) ([]RecommendationEntry, error) {
+	var entries []RecommendationEntry
+
+	redisKey := recommendationCacheKey(partID)
+	if data, err := redis.Get(redisKey); err == nil && len(data) > 0 {
+		if err = json.Unmarshal(data, &entries); err == nil {
+			return entries, nil
+		}
+	}
+
+	if err := database.Init(); err != nil {
+		return entries, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	var doc recommendationDoc
+	err := session.DB(database.ProductDatabase).C(database.RecommendationCollectionName).Find(bson.M{"part_id": partID}).One(&doc)
+	if err != nil && err != mgo.ErrNotFound {
+		return entries, err
+	}
+	entries = doc.Recommendations
+
+	go redis.Setex(redisKey, entries, redis.CacheTimeout)
+
+	return entries, nil
+}
+
+func recommendationCacheKey(partID int) string {
+	return recommendationRedisKey + ":" + strconv.Itoa(partID)
+}
+
+func vehicleKey(v VehicleApplication) string {
+	if v.Year == "" || v.Make == "" || v.Model == "" {
+		return ""
+	}
+	return strings.ToLower(strings.Join([]string{v.Year, v.Make, v.Model, v.Style}, "|"))
+}
+
+func sharesCategory(a, b map[int]bool) bool {
+	for id := range a {
+		if b[id] {
+			return true
+		}
+	}
+	return false
+}
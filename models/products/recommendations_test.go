@@ -0,0 +1,45 @@
+package products
+
+import (
+	"github.com/curt-labs/API/models/brand"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestBuildRecommendationIndex(t *testing.T) {
+	silverado := []VehicleApplication{
+		{Year: "2015", Make: "Chevrolet", Model: "Silverado 1500", Style: ""},
+		{Year: "2016", Make: "Chevrolet", Model: "Silverado 1500", Style: ""},
+		{Year: "2017", Make: "Chevrolet", Model: "Silverado 1500", Style: ""},
+	}
+	hitchCat := []Category{{CategoryID: 1}}
+	wiringCat := []Category{{CategoryID: 2}}
+
+	parts := []Part{
+		{ID: 13000, Brand: brand.Brand{ID: 1}, Categories: hitchCat, Vehicles: silverado},
+		{ID: 13001, Brand: brand.Brand{ID: 1}, Categories: hitchCat, Vehicles: silverado},
+		{ID: 56000, Brand: brand.Brand{ID: 1}, Categories: wiringCat, Vehicles: silverado},
+		{ID: 56001, Brand: brand.Brand{ID: 3}, Categories: wiringCat, Vehicles: silverado[:2]},
+		{ID: 56002, Brand: brand.Brand{ID: 1}, Categories: wiringCat, Vehicles: silverado[:1]},
+	}
+
+	Convey("Testing BuildRecommendationIndex()", t, func() {
+		idx := BuildRecommendationIndex(parts, 0)
+
+		recs := idx[13000]
+		So(len(recs), ShouldEqual, 2)
+		So(recs[0].PartID, ShouldEqual, 56000)
+		So(recs[0].Score, ShouldEqual, 1)
+		So(recs[0].SharedVehicles, ShouldEqual, 3)
+		So(recs[1].PartID, ShouldEqual, 56001)
+		So(recs[1].BrandID, ShouldEqual, 3)
+		So(recs[1].Score, ShouldEqual, 0.816)
+
+		// only shares a single vehicle
+		_, ok := idx[56002]
+		So(ok, ShouldBeFalse)
+
+		So(len(idx[56000]), ShouldEqual, 2)
+		So(len(BuildRecommendationIndex(parts, 1)[13000]), ShouldEqual, 1)
+	})
+}
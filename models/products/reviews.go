@@ -2,18 +2,407 @@ package products
 
 import (
 	// "github.com/curt-labs/API/models/customer"
-
+	"errors"
+	"fmt"
+	"math"
+	"net/mail"
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/database"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	ReviewPending  = "pending"
+	ReviewFlagged  = "flagged"
+	ReviewApproved = "approved"
+	ReviewRejected = "rejected"
+
+	maxReviewLinks = 2
+)
+
+var (
+	ErrDuplicateReview = errors.New("a review for this part has already been submitted")
+	ErrReviewNotFound  = errors.New("review not found")
+
+	linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)`)
+
+	// spamTerms are phrases we've only ever seen in junk reviews.
+	spamTerms = []string{
+		"viagra",
+		"cialis",
+		"casino",
+		"payday loan",
+		"work from home",
+		"click here",
+		"buy now",
+		"free money",
+		"crypto",
+		"seo services",
+	}
 )
 
 // Review ...
 type Review struct {
-	Rating      int        `bson:"rating" json:"rating" xml:"rating"`
-	Subject     string     `bson:"subject" json:"subject" xml:"subject"`
-	ReviewText  string     `bson:"review_text" json:"review_text" xml:"review_text"`
-	Name        string     `bson:"name" json:"name" xml:"name"`
-	Email       string     `bson:"email" json:"email" xml:"email"`
-	CreatedDate *time.Time `bson:"created_date" json:"created_date" xml:"created_date"`
-	Active      bool       `json:"active,omitempty" xml:"active,omitempty"`
-	Approved    bool       `json:"approved,omitempty" xml:"approved,omitempty"`
+	ID            bson.ObjectId `bson:"_id,omitempty" json:"id,omitempty" xml:"id,omitempty"`
+	PartID        int           `bson:"part_id,omitempty" json:"part_id,omitempty" xml:"part_id,omitempty"`
+	Rating        int           `bson:"rating" json:"rating" xml:"rating"`
+	Subject       string        `bson:"subject" json:"subject" xml:"subject"`
+	ReviewText    string        `bson:"review_text" json:"review_text" xml:"review_text"`
+	Name          string        `bson:"name" json:"name" xml:"name"`
+	Email         string        `bson:"email" json:"email" xml:"email"`
+	CreatedDate   *time.Time    `bson:"created_date" json:"created_date" xml:"created_date"`
+	Active        bool          `json:"active,omitempty" xml:"active,omitempty"`
+	Approved      bool          `json:"approved,omitempty" xml:"approved,omitempty"`
+	Status        string        `bson:"status,omitempty" json:"status,omitempty" xml:"status,omitempty"`
+	Flags         []string      `bson:"flags,omitempty" json:"flags,omitempty" xml:"flags,omitempty"`
+	ModeratedDate *time.Time    `bson:"moderated_date,omitempty" json:"moderated_date,omitempty" xml:"moderated_date,omitempty"`
+	Reason        string        `bson:"reason,omitempty" json:"reason,omitempty" xml:"reason,omitempty"`
+}
+
+type Reviews []Review
+
+// RatingHistogram is the number of active, approved reviews at each star
+// rating.
+type RatingHistogram struct {
+	One   int `bson:"one" json:"one" xml:"one,attr"`
+	Two   int `bson:"two" json:"two" xml:"two,attr"`
+	Three int `bson:"three" json:"three" xml:"three,attr"`
+	Four  int `bson:"four" json:"four" xml:"four,attr"`
+	Five  int `bson:"five" json:"five" xml:"five,attr"`
+}
+
+// Validate checks a submitted review for the fields we require.
+func (r *Review) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	r.Email = strings.TrimSpace(r.Email)
+	r.Subject = strings.TrimSpace(r.Subject)
+	r.ReviewText = strings.TrimSpace(r.ReviewText)
+
+	if r.PartID == 0 {
+		return errors.New("review must be for a part")
+	}
+	if r.Rating < 1 || r.Rating > 5 {
+		return errors.New("rating must be between 1 and 5")
+	}
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	if _, err := mail.ParseAddress(r.Email); err != nil {
+		return fmt.Errorf("invalid email address: %s", r.Email)
+	}
+	if len(r.Subject) > 255 {
+		return errors.New("subject must be 255 characters or less")
+	}
+	if len(r.ReviewText) < 10 {
+		return errors.New("review text must be at least 10 characters")
+	}
+	if len(r.ReviewText) > 5000 {
+		return errors.New("review text must be 5000 characters or less")
+	}
+
+	return nil
+}
+
+// SpamFlags runs the review through a few cheap heuristics and returns the
+// reasons it looks like spam. Flagged reviews still go to moderation, they
+// just get looked at with a little more suspicion.
+func (r *Review) SpamFlags() []string {
+	var flags []string
+	text := strings.ToLower(r.Subject + " " + r.ReviewText)
+
+	if links := len(linkPattern.FindAllString(text, -1)); links > maxReviewLinks {
+		flags = append(flags, fmt.Sprintf("contains %d links", links))
+	}
+
+	for _, term := range spamTerms {
+		if strings.Contains(text, term) {
+			flags = append(flags, fmt.Sprintf("contains \"%s\"", term))
+		}
+	}
+
+	var letters, upper int
+	for _, c := range r.ReviewText {
+		if unicode.IsLetter(c) {
+			letters++
+			if unicode.IsUpper(c) {
+				upper++
+			}
+		}
+	}
+	if letters >= 20 && float64(upper)/float64(letters) > 0.7 {
+		flags = append(flags, "mostly upper case")
+	}
+
+	if repeatedRun(r.ReviewText) >= 8 {
+		flags = append(flags, "repeated characters")
+	}
+
+	return flags
+}
+
+// repeatedRun returns the longest run of a single repeated character.
+func repeatedRun(s string) int {
+	var longest, run int
+	var last rune
+	for i, c := range s {
+		if i > 0 && c == last {
+			run++
+		} else {
+			run = 1
+		}
+		if run > longest {
+			longest = run
+		}
+		last = c
+	}
+	return longest
+}
+
+// SummarizeReviews calculates the average rating and histogram of the
+// active, approved reviews.
+func SummarizeReviews(revs []Review) (float64, RatingHistogram) {
+	var hist RatingHistogram
+	var total, count int
+	for _, rev := range revs {
+		if !rev.Active || !rev.Approved {
+			continue
+		}
+		switch rev.Rating {
+		case 1:
+			hist.One++
+		case 2:
+			hist.Two++
+		case 3:
+			hist.Three++
+		case 4:
+			hist.Four++
+		case 5:
+			hist.Five++
+		default:
+			continue
+		}
+		total += rev.Rating
+		count++
+	}
+
+	if count == 0 {
+		return 0, hist
+	}
+
+	avg := float64(total) / float64(count)
+	return math.Floor(avg*100+0.5) / 100, hist
+}
+
+// Create submits a review for moderation. Reviews are held in the review
+// collection until they are approved, at which point they are added to the
+// part.
+func (r *Review) Create(dtx *apicontext.DataContext) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+
+	p := Part{
+		ID: r.PartID,
+	}
+	if err := p.FromDatabase(getBrandsFromDTX(dtx)); err != nil {
+		if err == mgo.ErrNotFound {
+			return fmt.Errorf("part %d not found", r.PartID)
+		}
+		return err
+	}
+
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	c := session.DB(database.ProductDatabase).C(database.ReviewCollectionName)
+
+	// the same person only gets one review per part, and nobody gets to
+	// paste the same review onto a part twice.
+	dupes, err := c.Find(bson.M{
+		"part_id": r.PartID,
+		"status":  bson.M{"$ne": ReviewRejected},
+		"$or": []bson.M{
+			bson.M{"email": bson.RegEx{Pattern: "^" + regexp.QuoteMeta(r.Email) + "$", Options: "i"}},
+			bson.M{"review_text": r.ReviewText},
+		},
+	}).Count()
+	if err != nil {
+		return err
+	}
+	if dupes > 0 {
+		return ErrDuplicateReview
+	}
+	for _, rev := range p.Reviews {
+		if strings.EqualFold(rev.Email, r.Email) || rev.ReviewText == r.ReviewText {
+			return ErrDuplicateReview
+		}
+	}
+
+	now := time.Now()
+	r.ID = bson.NewObjectId()
+	r.CreatedDate = &now
+	r.Active = true
+	r.Approved = false
+	r.ModeratedDate = nil
+	r.Reason = ""
+	r.Flags = r.SpamFlags()
+	r.Status = ReviewPending
+	if len(r.Flags) > 0 {
+		r.Status = ReviewFlagged
+	}
+
+	return c.Insert(r)
+}
+
+// Get retrieves a review from the moderation collection.
+func (r *Review) Get() error {
+	if !r.ID.Valid() {
+		return ErrReviewNotFound
+	}
+
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	err := session.DB(database.ProductDatabase).C(database.ReviewCollectionName).FindId(r.ID).One(r)
+	if err == mgo.ErrNotFound {
+		return ErrReviewNotFound
+	}
+	return err
+}
+
+// GetReviewQueue returns reviews awaiting moderation, oldest first. Pending
+// and flagged reviews are returned when status is empty.
+func GetReviewQueue(status string, page, count int) (Reviews, error) {
+	var revs Reviews
+
+	if err := database.Init(); err != nil {
+		return revs, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	query := bson.M{"status": bson.M{"$in": []string{ReviewPending, ReviewFlagged}}}
+	if status != "" {
+		query = bson.M{"status": status}
+	}
+
+	err := session.DB(database.ProductDatabase).C(database.ReviewCollectionName).
+		Find(query).
+		Sort("created_date").
+		Skip(page * count).
+		Limit(count).
+		All(&revs)
+
+	return revs, err
+}
+
+// Approve publishes the review on its part and recomputes the part's
+// average rating and histogram.
+func (r *Review) Approve() error {
+	if err := r.Get(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	r.Status = ReviewApproved
+	r.Active = true
+	r.Approved = true
+	r.ModeratedDate = &now
+	r.Reason = ""
+
+	return r.moderate(true)
+}
+
+// Reject takes the review out of the queue, and off of the part if it had
+// already been approved.
+func (r *Review) Reject(reason string) error {
+	if err := r.Get(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	r.Status = ReviewRejected
+	r.Active = false
+	r.Approved = false
+	r.ModeratedDate = &now
+	r.Reason = reason
+
+	return r.moderate(false)
+}
+
+// Delete removes the review from the moderation collection and the part.
+func (r *Review) Delete() error {
+	if err := database.Init(); err != nil {
+		return err
+	}
+	if err := r.Get(); err != nil {
+		return err
+	}
+
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	if err := session.DB(database.ProductDatabase).C(database.ReviewCollectionName).RemoveId(r.ID); err != nil {
+		return err
+	}
+
+	return updatePartReviews(session, r.PartID, bson.M{"$pull": bson.M{"reviews": bson.M{"_id": r.ID}}})
+}
+
+func (r *Review) moderate(publish bool) error {
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	if err := session.DB(database.ProductDatabase).C(database.ReviewCollectionName).UpdateId(r.ID, r); err != nil {
+		return err
+	}
+
+	// always pull first so approving twice doesn't double up the review
+	pull := bson.M{"$pull": bson.M{"reviews": bson.M{"_id": r.ID}}}
+	if err := updatePartReviews(session, r.PartID, pull); err != nil {
+		return err
+	}
+	if !publish {
+		return nil
+	}
+
+	published := *r
+	published.Email = ""
+	published.Flags = nil
+
+	return updatePartReviews(session, r.PartID, bson.M{"$push": bson.M{"reviews": published}})
+}
+
+// updatePartReviews applies change to the reviews of the part and then
+// recomputes the part's review summary.
+func updatePartReviews(session *mgo.Session, partID int, change bson.M) error {
+	c := session.DB(database.ProductDatabase).C(database.ProductCollectionName)
+
+	if _, err := c.UpdateAll(bson.M{"id": partID}, change); err != nil {
+		return err
+	}
+
+	var p Part
+	if err := c.Find(bson.M{"id": partID}).Select(bson.M{"reviews": 1}).One(&p); err != nil {
+		return err
+	}
+
+	avg, hist := SummarizeReviews(p.Reviews)
+	_, err := c.UpdateAll(bson.M{"id": partID}, bson.M{"$set": bson.M{
+		"average_review":   avg,
+		"rating_histogram": hist,
+	}})
+	return err
 }
@@ -0,0 +1,76 @@
+package products
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestReviewValidate(t *testing.T) {
+	Convey("Testing Validate()", t, func() {
+		r := Review{
+			PartID:     11000,
+			Rating:     5,
+			Name:       " Alex ",
+			Email:      "alex@example.com",
+			Subject:    "Solid hitch",
+			ReviewText: "Bolted right on, no drilling needed.",
+		}
+		So(r.Validate(), ShouldBeNil)
+		So(r.Name, ShouldEqual, "Alex")
+
+		bad := r
+		bad.Rating = 6
+		So(bad.Validate(), ShouldNotBeNil)
+
+		bad = r
+		bad.Email = "not an email"
+		So(bad.Validate(), ShouldNotBeNil)
+
+		bad = r
+		bad.ReviewText = "meh"
+		So(bad.Validate(), ShouldNotBeNil)
+
+		bad = r
+		bad.PartID = 0
+		So(bad.Validate(), ShouldNotBeNil)
+	})
+}
+
+func TestReviewSpamFlags(t *testing.T) {
+	Convey("Testing SpamFlags()", t, func() {
+		r := Review{Subject: "Great", ReviewText: "Fits my truck perfectly and the finish has held up all winter."}
+		So(r.SpamFlags(), ShouldBeEmpty)
+
+		r.ReviewText = "CLICK HERE FOR THE BEST DEALS ON HITCHES ANYWHERE"
+		flags := r.SpamFlags()
+		So(len(flags), ShouldEqual, 2)
+
+		r.ReviewText = "see http://a.com and http://b.com and www.c.com"
+		So(r.SpamFlags(), ShouldResemble, []string{"contains 3 links"})
+
+		r.ReviewText = "great" + strings.Repeat("!", 10)
+		So(r.SpamFlags(), ShouldResemble, []string{"repeated characters"})
+	})
+}
+
+func TestSummarizeReviews(t *testing.T) {
+	Convey("Testing SummarizeReviews()", t, func() {
+		avg, hist := SummarizeReviews(nil)
+		So(avg, ShouldEqual, 0)
+		So(hist, ShouldResemble, RatingHistogram{})
+
+		avg, hist = SummarizeReviews([]Review{
+			{Rating: 5, Active: true, Approved: true},
+			{Rating: 4, Active: true, Approved: true},
+			{Rating: 4, Active: true, Approved: true},
+			{Rating: 1, Active: true, Approved: false},
+			{Rating: 2, Active: false, Approved: true},
+		})
+		So(avg, ShouldEqual, 4.33)
+		So(hist.Five, ShouldEqual, 1)
+		So(hist.Four, ShouldEqual, 2)
+		So(hist.One, ShouldEqual, 0)
+		So(hist.Two, ShouldEqual, 0)
+	})
+}
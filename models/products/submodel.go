@@ -9,6 +9,11 @@ import (
 )
 
 func (l *Lookup) GetSubmodels() error {
+	if l.cached("submodels", &l.Submodels) && len(l.Submodels) > 0 {
+		l.Pagination = singlePage(len(l.Submodels))
+		return nil
+	}
+
 	stmtBeginning := `
 		select distinct s.SubmodelName from vcdb_Vehicle as v
 		join Submodel as s on v.SubModelID = s.ID
@@ -60,6 +65,7 @@ func (l *Lookup) GetSubmodels() error {
 		PerPage:       len(l.Submodels),
 		TotalPages:    1,
 	}
+	l.cache("submodels", l.Submodels, len(l.Submodels))
 
 	return nil
 }
@@ -11,9 +11,9 @@ import (
 
 	"github.com/curt-labs/API/helpers/apicontext"
 	"github.com/curt-labs/API/helpers/database"
-	"github.com/curt-labs/API/helpers/sortutil"
 	"github.com/curt-labs/API/models/contact"
 	_ "github.com/go-sql-driver/mysql"
+	"gopkg.in/mgo.v2/bson"
 )
 
 var (
@@ -66,8 +66,14 @@ type Lookup struct {
 	Parts          []Part                `json:"parts" xml:"parts"`
 	Filter         interface{}           `json:"filter" xml:"filter"`
 	Pagination     Pagination            `json:"pagination" xml:"pagination"`
-	CustomerKey    string                `json:"-" xml:"-"`
-	Brands         []int                 `json:"-" xml:"-"`
+
+	// Suggestions are the closest makes or models to the one that matched
+	// nothing.
+	Suggestions []string `json:"suggestions,omitempty" xml:"suggestions,omitempty"`
+	CustomerKey string   `json:"-" xml:"-"`
+	Brands      []int    `json:"-" xml:"-"`
+
+	refresh bool
 }
 
 type Pagination struct {
@@ -78,21 +84,110 @@ type Pagination struct {
 	TotalPages    int `json:"total_pages" xml:"total_pages"`
 }
 
-func (l *Lookup) LoadParts(ch chan []Part, page int, count int, dtx *apicontext.DataContext) {
+// Suggest fills Suggestions when the lookup of the make or model matched
+// nothing. The step before it is looked up again to find the closest
+// values.
+func (l *Lookup) Suggest(dtx *apicontext.DataContext) error {
+	v := l.Vehicle.Base
+	switch {
+	case v.Year == 0 || v.Make == "":
+		return nil
+	case v.Model == "":
+		if len(l.Models) > 0 {
+			return nil
+		}
+		return l.suggestMake(dtx)
+	case len(l.Parts) > 0 || len(l.Submodels) > 0 || len(l.Configurations) > 0:
+		return nil
+	}
+
+	prev := Lookup{Vehicle: Vehicle{Base: BaseVehicle{Year: v.Year, Make: v.Make}}, Brands: l.Brands}
+	if err := prev.GetModels(); err != nil {
+		return err
+	}
+	if len(prev.Models) == 0 {
+		return l.suggestMake(dtx)
+	}
+	l.Suggestions = Suggest(v.Model, prev.Models)
+	return nil
+}
+
+func (l *Lookup) suggestMake(dtx *apicontext.DataContext) error {
+	prev := Lookup{Vehicle: Vehicle{Base: BaseVehicle{Year: l.Vehicle.Base.Year}}, Brands: l.Brands}
+	if err := prev.GetMakes(dtx); err != nil {
+		return err
+	}
+	l.Suggestions = Suggest(l.Vehicle.Base.Make, prev.Makes)
+	return nil
+}
+
+// LoadParts loads the parts like GetParts and sends its error on ch when
+// it's done.
+func (l *Lookup) LoadParts(ch chan error, page int, count int, rules []FilterRule, dtx *apicontext.DataContext) {
+	ch <- l.GetParts(page, count, rules, dtx)
+}
+
+// GetParts loads a page of the parts that fit the vehicle and its
+// configurations and match every rule into Parts, and paginates them. Rules
+// are applied before paging, like FilterPage, so pages are full and the
+// total is what the rules let through.
+func (l *Lookup) GetParts(page int, count int, rules []FilterRule, dtx *apicontext.DataContext) error {
 	if count == 0 {
 		count = 50
 	}
+	if page == 0 {
+		page = 1
+	}
+
+	ids, err := l.fittingPartIDs(dtx)
+	if err != nil {
+		return err
+	}
 
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		"id":                bson.M{"$in": ids},
+		"brand.id":          bson.M{"$in": getBrandsFromDTX(dtx)},
+		"short_description": bson.M{"$ne": ""},
+		"web_visibility":    VisibilityQuery(Visibilities(dtx)),
+	}
+	iter := session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Sort("id").Iter()
+	parts, total, err := FilterPage(iter, rules, dtx, (page-1)*count, count)
+	if err != nil {
+		return err
+	}
+	if l.Parts, err = BindCustomerToSeveralParts(parts, dtx); err != nil {
+		return err
+	}
+
+	totalPages := total / count
+	if math.Mod(float64(total), float64(count)) > 0 {
+		totalPages++
+	}
+
+	l.Pagination = Pagination{
+		TotalItems:    total,
+		ReturnedCount: len(l.Parts),
+		Page:          page,
+		PerPage:       count,
+		TotalPages:    totalPages,
+	}
+	return nil
+}
+
+// fittingPartIDs returns the IDs of the parts that fit the vehicle and its
+// configurations, in order.
+func (l *Lookup) fittingPartIDs(dtx *apicontext.DataContext) ([]int, error) {
 	err := database.Init()
 	if err != nil {
-		ch <- nil
-		return
+		return nil, err
 	}
 
 	stmt, err := database.DB.Prepare(partMatcherStmt)
 	if err != nil {
-		ch <- nil
-		return
+		return nil, err
 	}
 	defer stmt.Close()
 
@@ -102,9 +197,8 @@ func (l *Lookup) LoadParts(ch chan []Part, page int, count int, dtx *apicontext.
 	}
 
 	rows, err := stmt.Query(l.Vehicle.Base.Year, l.Vehicle.Base.Make, l.Vehicle.Base.Model, l.Vehicle.Submodel, strings.Join(brands, ","))
-	if err != nil || rows == nil {
-		ch <- nil
-		return
+	if err != nil {
+		return nil, err
 	}
 	defer rows.Close()
 
@@ -145,6 +239,9 @@ func (l *Lookup) LoadParts(ch chan []Part, page int, count int, dtx *apicontext.
 			}
 		}
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
 
 	// index the qualified configurations
 	confIndex := make(map[string]string, 0)
@@ -174,58 +271,7 @@ func (l *Lookup) LoadParts(ch chan []Part, page int, count int, dtx *apicontext.
 	}
 
 	sort.Ints(parts)
-
-	partCount := len(parts)
-	pagedParts := parts
-	if partCount > count {
-		start := 0
-		if page > 1 {
-			start = count * (page - 1)
-		}
-		end := start + count
-		if len(parts) <= end {
-			pagedParts = parts[start:]
-		} else {
-			pagedParts = parts[start : start+count]
-		}
-	}
-
-	l.Parts = make([]Part, 0)
-	perChan := make(chan int)
-	for i, p := range pagedParts {
-		go func(j int, prt Part) {
-			if err := prt.Get(dtx); err == nil && prt.ShortDesc != "" {
-				l.Parts = append(l.Parts, prt)
-			}
-			perChan <- 1
-		}(i, Part{ID: p})
-	}
-
-	for _, _ = range pagedParts {
-		<-perChan
-	}
-
-	sortutil.AscByField(l.Parts, "ID")
-
-	mod := math.Mod(float64(partCount), float64(count))
-	totalPages := partCount / count
-	if mod > 0 {
-		totalPages++
-	}
-	if page == 0 {
-		page = 1
-	}
-
-	l.Pagination = Pagination{
-		TotalItems:    partCount,
-		ReturnedCount: len(l.Parts),
-		Page:          page,
-		PerPage:       count,
-		TotalPages:    totalPages,
-	}
-
-	ch <- nil
-	return
+	return parts, nil
 }
 
 func (v *Vehicle) GetVcdbID() (int, error) {
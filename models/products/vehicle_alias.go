@@ -0,0 +1,431 @@
+package products
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// MaxSuggestions is the most suggestions returned for an unknown make or
+// model.
+const MaxSuggestions = 5
+
+// AliasTable maps the names customers type for makes and models to the
+// names in our fitment data. Keys are compared by their letters and digits
+// only, so "F150", "f 150" and "F-150" are the same key. Models are keyed
+// by the make they belong to. An alias must name a single vehicle; "F250"
+// is a F-250 before 1999 and a F-250 Super Duty after, so it isn't one.
+type AliasTable struct {
+	Makes  map[string]string            `json:"makes"`
+	Models map[string]map[string]string `json:"models"`
+}
+
+var (
+	// DefaultAliases are the aliases we see the most in lookups.
+	DefaultAliases = AliasTable{
+		Makes: map[string]string{
+			"chevy":       "Chevrolet",
+			"chev":        "Chevrolet",
+			"vw":          "Volkswagen",
+			"volkswagon":  "Volkswagen",
+			"mercedes":    "Mercedes-Benz",
+			"benz":        "Mercedes-Benz",
+			"landrover":   "Land Rover",
+			"range rover": "Land Rover",
+			"alfa":        "Alfa Romeo",
+			"mini cooper": "Mini",
+			"caddy":       "Cadillac",
+			"olds":        "Oldsmobile",
+		},
+		Models: map[string]map[string]string{
+			"ford": {
+				"f150":              "F-150",
+				"f250sd":            "F-250 Super Duty",
+				"f350sd":            "F-350 Super Duty",
+				"e150":              "E-150",
+				"e250":              "E-250",
+				"e350":              "E-350",
+				"explorersporttrac": "Explorer Sport Trac",
+			},
+			"chevrolet": {
+				"s10":   "S10",
+				"k1500": "K1500",
+				"c1500": "C1500",
+			},
+			"gmc": {
+				"s15": "S15",
+			},
+			"ram": {
+				"ram1500": "1500",
+				"ram2500": "2500",
+				"ram3500": "3500",
+			},
+		},
+	}
+
+	// Aliases normalizes the makes and models of every lookup engine. Swap
+	// it out for a table loaded with LoadAliasFile.
+	Aliases = &DefaultAliases
+)
+
+// LoadAliasTable decodes a JSON alias table. The default aliases are kept
+// unless the table overrides them.
+func LoadAliasTable(r io.Reader) (*AliasTable, error) {
+	var t AliasTable
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, err
+	}
+
+	merged := &AliasTable{
+		Makes:  make(map[string]string),
+		Models: make(map[string]map[string]string),
+	}
+	for _, src := range []AliasTable{DefaultAliases, t} {
+		for alias, name := range src.Makes {
+			merged.Makes[AliasKey(alias)] = name
+		}
+		for mk, models := range src.Models {
+			mk = AliasKey(mk)
+			if merged.Models[mk] == nil {
+				merged.Models[mk] = make(map[string]string)
+			}
+			for alias, name := range models {
+				merged.Models[mk][AliasKey(alias)] = name
+			}
+		}
+	}
+	return merged, nil
+}
+
+// LoadAliasFile replaces Aliases with the alias table stored at path.
+func LoadAliasFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	t, err := LoadAliasTable(f)
+	if err != nil {
+		return err
+	}
+	Aliases = t
+	return nil
+}
+
+// Make returns the name in our data of the make, or the make with its
+// whitespace cleaned up when it isn't an alias.
+func (t *AliasTable) Make(vehicleMake string) string {
+	vehicleMake = cleanName(vehicleMake)
+	if name := t.lookup(t.Makes, vehicleMake); name != "" {
+		return name
+	}
+	return vehicleMake
+}
+
+// Model returns the name in our data of the model of the make, or the
+// model with its whitespace cleaned up when it isn't an alias.
+func (t *AliasTable) Model(vehicleMake, model string) string {
+	model = cleanName(model)
+	if name := t.lookup(t.Models[AliasKey(t.Make(vehicleMake))], model); name != "" {
+		return name
+	}
+	return model
+}
+
+func (t *AliasTable) lookup(aliases map[string]string, name string) string {
+	key := AliasKey(name)
+	if key == "" {
+		return ""
+	}
+	for alias, n := range aliases {
+		if AliasKey(alias) == key {
+			return n
+		}
+	}
+	return ""
+}
+
+// NormalizeMake resolves the make through Aliases.
+func NormalizeMake(vehicleMake string) string {
+	return Aliases.Make(vehicleMake)
+}
+
+// NormalizeModel resolves the model of the make through Aliases.
+func NormalizeModel(vehicleMake, model string) string {
+	return Aliases.Model(vehicleMake, model)
+}
+
+// NameLister lists the makes or models a lookup step can match.
+type NameLister func() ([]string, error)
+
+// ResolveMake returns the make as it's named among the makes, trying the
+// make itself before its alias. See resolveName.
+func (t *AliasTable) ResolveMake(vehicleMake string, makes NameLister) (string, error) {
+	vehicleMake = cleanName(vehicleMake)
+	return resolveName(vehicleMake, t.lookup(t.Makes, vehicleMake), makes)
+}
+
+// ResolveModel returns the model of the make as it's named among the
+// models, trying the model itself before its alias. See resolveName.
+func (t *AliasTable) ResolveModel(vehicleMake, model string, models NameLister) (string, error) {
+	model = cleanName(model)
+	return resolveName(model, t.lookup(t.Models[AliasKey(cleanName(vehicleMake))], model), models)
+}
+
+// resolveName returns the name that matches the cleaned up name among the
+// names of its lookup step, or else the one that matches its alias. A name
+// that matches neither is returned as it is so it gets suggestions. The
+// names are only listed when the name has an alias other than itself, so
+// most lookups cost nothing extra.
+func resolveName(name, alias string, names NameLister) (string, error) {
+	if alias == "" || strings.EqualFold(alias, name) {
+		return name, nil
+	}
+
+	list, err := names()
+	if err != nil {
+		return name, err
+	}
+	for _, key := range []string{AliasKey(name), AliasKey(alias)} {
+		for _, n := range list {
+			if AliasKey(n) == key {
+				return n, nil
+			}
+		}
+	}
+	return name, nil
+}
+
+// ResolveMake resolves the make through Aliases.
+func ResolveMake(vehicleMake string, makes NameLister) (string, error) {
+	return Aliases.ResolveMake(vehicleMake, makes)
+}
+
+// ResolveModel resolves the model of the make through Aliases.
+func ResolveModel(vehicleMake, model string, models NameLister) (string, error) {
+	return Aliases.ResolveModel(vehicleMake, model, models)
+}
+
+// Resolve resolves the make and model of the vehicle against the ACES
+// makes and models of its year.
+func (l *Lookup) Resolve(dtx *apicontext.DataContext) error {
+	v := &l.Vehicle
+	v.Submodel = cleanName(v.Submodel)
+	if v.Base.Year == 0 {
+		return nil
+	}
+
+	var err error
+	v.Base.Make, err = ResolveMake(v.Base.Make, func() ([]string, error) {
+		prev := Lookup{Vehicle: Vehicle{Base: BaseVehicle{Year: v.Base.Year}}, Brands: l.Brands}
+		err := prev.GetMakes(dtx)
+		return prev.Makes, err
+	})
+	if err != nil || v.Base.Make == "" {
+		return err
+	}
+	v.Base.Model, err = ResolveModel(v.Base.Make, v.Base.Model, func() ([]string, error) {
+		prev := Lookup{Vehicle: Vehicle{Base: BaseVehicle{Year: v.Base.Year, Make: v.Base.Make}}, Brands: l.Brands}
+		err := prev.GetModels()
+		return prev.Models, err
+	})
+	return err
+}
+
+// Resolve resolves the make and model of the vehicle against the CURT
+// makes and models of its year.
+func (c *CurtLookup) Resolve(heavyduty bool) error {
+	c.Style = cleanName(c.Style)
+	if c.Year == "" {
+		return nil
+	}
+
+	var err error
+	c.Make, err = ResolveMake(c.Make, func() ([]string, error) {
+		prev := CurtLookup{CurtVehicle: CurtVehicle{Year: c.Year}}
+		err := prev.GetMakes(heavyduty)
+		return prev.Makes, err
+	})
+	if err != nil || c.Make == "" {
+		return err
+	}
+	c.Model, err = ResolveModel(c.Make, c.Model, func() ([]string, error) {
+		prev := CurtLookup{CurtVehicle: CurtVehicle{Year: c.Year, Make: c.Make}}
+		err := prev.GetModels(heavyduty)
+		return prev.Models, err
+	})
+	return err
+}
+
+// resolve resolves the make and model against the ARIES makes and models
+// of the year in the collection and lowercases the vehicle to match it.
+func (v *NoSqlVehicle) resolve(collection string) error {
+	v.Style = strings.ToLower(cleanName(v.Style))
+	if v.Year == "" {
+		return nil
+	}
+
+	var err error
+	v.Make, err = ResolveMake(v.Make, func() ([]string, error) {
+		_, makes, err := GetApps(NoSqlVehicle{Year: v.Year}, collection)
+		return makes, err
+	})
+	v.Make = strings.ToLower(v.Make)
+	if err != nil || v.Make == "" {
+		return err
+	}
+	v.Model, err = ResolveModel(v.Make, v.Model, func() ([]string, error) {
+		_, models, err := GetApps(NoSqlVehicle{Year: v.Year, Make: v.Make}, collection)
+		return models, err
+	})
+	v.Model = strings.ToLower(v.Model)
+	return err
+}
+
+// resolveArgs resolves the make and model of year, make, model, category
+// lookup arguments against the makes and models of the year.
+func resolveArgs(args []string, makes func(year string) ([]string, error), models func(year, vehicleMake string) ([]string, error)) ([]string, error) {
+	args = append([]string(nil), args...)
+	var err error
+	if len(args) > 1 {
+		args[1], err = ResolveMake(args[1], func() ([]string, error) {
+			return makes(args[0])
+		})
+		if err != nil {
+			return args, err
+		}
+	}
+	if len(args) > 2 {
+		args[2], err = ResolveModel(args[1], args[2], func() ([]string, error) {
+			return models(args[0], args[1])
+		})
+	}
+	return args, err
+}
+
+// exactly matches the whole value without regard to case. Regular
+// expression characters in the value are matched literally.
+func exactly(val string) bson.RegEx {
+	return bson.RegEx{
+		Pattern: "^" + regexp.QuoteMeta(val) + "$",
+		Options: "i",
+	}
+}
+
+// Suggest returns the candidates closest to an input that matched nothing,
+// best first. Candidates starting with the input or within a couple of
+// typos of it are suggested.
+func Suggest(input string, candidates []string) []string {
+	key := AliasKey(input)
+	if key == "" {
+		return []string{}
+	}
+
+	type suggestion struct {
+		name  string
+		score int
+	}
+	var found []suggestion
+	seen := make(map[string]bool)
+	for _, c := range candidates {
+		ck := AliasKey(c)
+		if ck == "" || seen[ck] {
+			continue
+		}
+		seen[ck] = true
+
+		score := distance(key, ck)
+		if strings.HasPrefix(ck, key) || strings.HasPrefix(key, ck) {
+			score = 0
+		} else if score > maxTypos(key) {
+			continue
+		}
+		found = append(found, suggestion{name: c, score: score})
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].score != found[j].score {
+			return found[i].score < found[j].score
+		}
+		return strings.ToLower(found[i].name) < strings.ToLower(found[j].name)
+	})
+
+	suggestions := make([]string, 0, MaxSuggestions)
+	for _, s := range found {
+		if len(suggestions) == MaxSuggestions {
+			break
+		}
+		suggestions = append(suggestions, s.name)
+	}
+	return suggestions
+}
+
+// maxTypos is how many edits a suggestion can be from the input; a third
+// of its length, at least one.
+func maxTypos(key string) int {
+	if n := len([]rune(key)) / 3; n > 1 {
+		return n
+	}
+	return 1
+}
+
+// distance is the number of insertions, deletions, substitutions and
+// swaps of neighbouring characters between a and b.
+func distance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	d := make([][]int, len(ar)+1)
+	for i := range d {
+		d[i] = make([]int, len(br)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] && d[i-2][j-2]+1 < d[i][j] {
+				d[i][j] = d[i-2][j-2] + 1
+			}
+		}
+	}
+	return d[len(ar)][len(br)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+// AliasKey is the lowercase letters and digits of a name, which names and
+// their aliases are matched by.
+func AliasKey(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToLower(r)
+		}
+		return -1
+	}, name)
+}
+
+// cleanName trims the name and collapses its runs of whitespace.
+func cleanName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
@@ -0,0 +1,131 @@
+package products
+
+import (
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func TestVehicleAliases(t *testing.T) {
+	Convey("Testing NormalizeMake()", t, func() {
+		So(NormalizeMake("Chevy"), ShouldEqual, "Chevrolet")
+		So(NormalizeMake("  VW "), ShouldEqual, "Volkswagen")
+		So(NormalizeMake("Land  Rover"), ShouldEqual, "Land Rover")
+		So(NormalizeMake("Toyota"), ShouldEqual, "Toyota")
+		So(NormalizeMake(""), ShouldEqual, "")
+	})
+
+	Convey("Testing NormalizeModel()", t, func() {
+		So(NormalizeModel("Ford", "F150"), ShouldEqual, "F-150")
+		So(NormalizeModel("ford", "f 150"), ShouldEqual, "F-150")
+		So(NormalizeModel("Ford", "F250"), ShouldEqual, "F250")
+		So(NormalizeModel("Chevy", "Silverado"), ShouldEqual, "Silverado")
+		So(NormalizeModel("Toyota", "F150"), ShouldEqual, "F150")
+		So(NormalizeModel("Ford", "Escape"), ShouldEqual, "Escape")
+	})
+
+	Convey("Testing ResolveMake() and ResolveModel()", t, func() {
+		listed := 0
+		list := func(names ...string) NameLister {
+			return func() ([]string, error) {
+				listed++
+				return names, nil
+			}
+		}
+
+		Convey("names without an alias aren't listed", func() {
+			name, err := ResolveMake(" Toyota ", list("Toyota"))
+			So(err, ShouldBeNil)
+			So(name, ShouldEqual, "Toyota")
+			name, err = ResolveModel("Ford", "F-150", list("F-150"))
+			So(name, ShouldEqual, "F-150")
+			So(listed, ShouldEqual, 0)
+		})
+
+		Convey("names in the data win over their alias", func() {
+			name, err := ResolveModel("Ford", "f150", list("F150", "F-150 Heritage"))
+			So(err, ShouldBeNil)
+			So(name, ShouldEqual, "F150")
+			So(listed, ShouldEqual, 1)
+		})
+
+		Convey("aliases resolve names that aren't in the data", func() {
+			name, _ := ResolveMake("chevy", list("Chevrolet", "Ford"))
+			So(name, ShouldEqual, "Chevrolet")
+			name, _ = ResolveModel("Ford", "f150", list("F-150", "F-250"))
+			So(name, ShouldEqual, "F-150")
+		})
+
+		Convey("names matching nothing are kept for suggestions", func() {
+			name, _ := ResolveModel("Ford", "f150", list("Ranger"))
+			So(name, ShouldEqual, "f150")
+		})
+
+		Convey("list errors are returned", func() {
+			_, err := ResolveMake("chevy", func() ([]string, error) {
+				return nil, errors.New("down")
+			})
+			So(err, ShouldNotBeNil)
+		})
+	})
+
+	Convey("Testing resolveArgs()", t, func() {
+		makes := func(year string) ([]string, error) {
+			return []string{"Chevrolet", "Ford"}, nil
+		}
+		models := func(year, vehicleMake string) ([]string, error) {
+			if year == "1998" {
+				return []string{"F-150", "F-250"}, nil
+			}
+			return []string{"F-150", "F-250 Super Duty"}, nil
+		}
+
+		args, err := resolveArgs([]string{"2016", "Ford", "F150", "Hitches"}, makes, models)
+		So(err, ShouldBeNil)
+		So(args, ShouldResemble, []string{"2016", "Ford", "F-150", "Hitches"})
+
+		args, _ = resolveArgs([]string{"1998", "Ford", "F-250"}, makes, models)
+		So(args, ShouldResemble, []string{"1998", "Ford", "F-250"})
+
+		args, _ = resolveArgs([]string{"2016", "chevy"}, makes, models)
+		So(args, ShouldResemble, []string{"2016", "Chevrolet"})
+
+		args, _ = resolveArgs([]string{"2016"}, makes, models)
+		So(args, ShouldResemble, []string{"2016"})
+	})
+
+	Convey("Testing exactly()", t, func() {
+		So(exactly("F-150 (4WD)").Pattern, ShouldEqual, `^F-150 \(4WD\)$`)
+		So(exactly("F-150").Options, ShouldEqual, "i")
+	})
+
+	Convey("Testing LoadAliasTable()", t, func() {
+		tbl, err := LoadAliasTable(strings.NewReader(`{"makes":{"Chevy":"Chevy Trucks","Merc":"Mercury"},"models":{"Chevrolet":{"Cruze LT":"Cruze"}}}`))
+		So(err, ShouldBeNil)
+		So(tbl.Make("merc"), ShouldEqual, "Mercury")
+		So(tbl.Make("chevy"), ShouldEqual, "Chevy Trucks")
+		So(tbl.Make("VW"), ShouldEqual, "Volkswagen")
+		So(tbl.Model("Chevrolet", "cruze-lt"), ShouldEqual, "Cruze")
+		So(tbl.Model("Ford", "F150"), ShouldEqual, "F-150")
+
+		_, err = LoadAliasTable(strings.NewReader(`{"makes":[]}`))
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Testing Suggest()", t, func() {
+		makes := []string{"Chevrolet", "Chrysler", "Dodge", "Ford", "GMC", "Toyota"}
+		So(Suggest("Chevorlet", makes), ShouldResemble, []string{"Chevrolet"})
+		So(Suggest("ch", makes), ShouldResemble, []string{"Chevrolet", "Chrysler"})
+		So(Suggest("Fodr", makes), ShouldResemble, []string{"Ford"})
+		So(Suggest("Tesla", makes), ShouldBeEmpty)
+		So(Suggest("", makes), ShouldBeEmpty)
+
+		models := []string{"F-150", "F-250 Super Duty", "Escape"}
+		So(Suggest("F15", models), ShouldResemble, []string{"F-150"})
+		So(Suggest("F150 XLT", models), ShouldResemble, []string{"F-150"})
+
+		many := []string{"A1", "A2", "A3", "A4", "A5", "A6", "A7"}
+		So(len(Suggest("A", many)), ShouldEqual, MaxSuggestions)
+	})
+}
@@ -18,13 +18,13 @@ func TestLoadParts(t *testing.T) {
 	Convey("Testing LoadParts()", t, func() {
 
 		Convey("without year/make/model", func() {
-			ch := make(chan []Part)
+			ch := make(chan error)
 
-			go l.LoadParts(ch, 0, 0, MockedDTX)
+			go l.LoadParts(ch, 0, 0, nil, MockedDTX)
 
-			parts := <-ch
+			So(<-ch, ShouldBeNil)
 
-			So(len(parts), ShouldEqual, 0)
+			So(len(l.Parts), ShouldEqual, 0)
 		})
 
 		Convey("with bogus data", func() {
@@ -32,13 +32,13 @@ func TestLoadParts(t *testing.T) {
 			l.Vehicle.Base.Make = "KD"
 			l.Vehicle.Base.Model = "123"
 			l.Vehicle.Submodel = "LKJ"
-			ch := make(chan []Part)
+			ch := make(chan error)
 
-			go l.LoadParts(ch, 0, 0, MockedDTX)
+			go l.LoadParts(ch, 0, 0, nil, MockedDTX)
 
-			parts := <-ch
+			So(<-ch, ShouldBeNil)
 
-			So(len(parts), ShouldEqual, 0)
+			So(len(l.Parts), ShouldEqual, 0)
 		})
 
 		Convey("with year", func() {
@@ -49,13 +49,13 @@ func TestLoadParts(t *testing.T) {
 			}
 			l.Vehicle.Base.Year = l.Years[api_helpers.RandGenerator(len(l.Years)-1)]
 
-			ch := make(chan []Part)
+			ch := make(chan error)
 
-			go l.LoadParts(ch, 0, 0, MockedDTX)
+			go l.LoadParts(ch, 0, 0, nil, MockedDTX)
 
-			parts := <-ch
+			So(<-ch, ShouldBeNil)
 
-			So(len(parts), ShouldEqual, 0)
+			So(len(l.Parts), ShouldEqual, 0)
 		})
 
 		Convey("with year/make", func() {
@@ -72,13 +72,13 @@ func TestLoadParts(t *testing.T) {
 			}
 			l.Vehicle.Base.Make = l.Makes[api_helpers.RandGenerator(len(l.Makes)-1)]
 
-			ch := make(chan []Part)
+			ch := make(chan error)
 
-			go l.LoadParts(ch, 0, 0, MockedDTX)
+			go l.LoadParts(ch, 0, 0, nil, MockedDTX)
 
-			parts := <-ch
+			So(<-ch, ShouldBeNil)
 
-			So(len(parts), ShouldEqual, 0)
+			So(len(l.Parts), ShouldEqual, 0)
 		})
 
 		Convey("with year/make/model", func() {
@@ -104,12 +104,12 @@ func TestLoadParts(t *testing.T) {
 			}
 			l.Vehicle.Base.Model = l.Models[api_helpers.RandGenerator(len(l.Models)-1)]
 
-			ch := make(chan []Part)
+			ch := make(chan error)
 
-			go l.LoadParts(ch, 0, 0, MockedDTX)
+			go l.LoadParts(ch, 0, 0, nil, MockedDTX)
 
-			parts := <-ch
-			So(len(parts), ShouldBeGreaterThanOrEqualTo, 0)
+			So(<-ch, ShouldBeNil)
+			So(len(l.Parts), ShouldBeGreaterThanOrEqualTo, 0)
 		})
 
 		Convey("with year/make/model/submodel", func() {
@@ -143,12 +143,12 @@ func TestLoadParts(t *testing.T) {
 			}
 			l.Vehicle.Submodel = l.Submodels[api_helpers.RandGenerator(len(l.Submodels)-1)]
 
-			ch := make(chan []Part)
+			ch := make(chan error)
 
-			go l.LoadParts(ch, 0, 0, MockedDTX)
+			go l.LoadParts(ch, 0, 0, nil, MockedDTX)
 
-			parts := <-ch
-			So(len(parts), ShouldBeGreaterThanOrEqualTo, 0)
+			So(<-ch, ShouldBeNil)
+			So(len(l.Parts), ShouldBeGreaterThanOrEqualTo, 0)
 		})
 	})
 	_ = apicontextmock.DeMock(MockedDTX)
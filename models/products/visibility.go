@@ -0,0 +1,119 @@
+package products
+
+import (
+	"strings"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/database"
+	"gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Key types that see more than public parts, matched without regard to
+// case.
+const (
+	InternalKeyType       = "Internal"
+	AuthenticationKeyType = "Authentication"
+)
+
+// Visibilities returns the web visibilities of the parts the caller may
+// see. Internal keys see every part, the authentication key a customer user
+// gets when they log in also sees logged in only parts, and every other key
+// only sees public parts.
+func Visibilities(dtx *apicontext.DataContext) []string {
+	if dtx == nil {
+		return []string{PUBLIC}
+	}
+	switch {
+	case strings.EqualFold(dtx.KeyType, InternalKeyType):
+		return []string{PUBLIC, LOGGEDIN, DISABLED}
+	case strings.EqualFold(dtx.KeyType, AuthenticationKeyType) && dtx.UserID != "":
+		return []string{PUBLIC, LOGGEDIN}
+	}
+	return []string{PUBLIC}
+}
+
+// VisibleTo reports whether the caller may see the part.
+func (p *Part) VisibleTo(dtx *apicontext.DataContext) bool {
+	return visible(p.WebVisibility, Visibilities(dtx))
+}
+
+// FilterVisible drops the parts the caller may not see and sets the web
+// flags on the rest.
+func FilterVisible(parts []Part, dtx *apicontext.DataContext) []Part {
+	visibilities := Visibilities(dtx)
+	filtered := make([]Part, 0, len(parts))
+	for _, p := range parts {
+		if !visible(p.WebVisibility, visibilities) {
+			continue
+		}
+		p.SetWebFlags()
+		filtered = append(filtered, p)
+	}
+	return filtered
+}
+
+// SetWebFlags sets ShowOnWebsite and ShowForLoggedIn from the part's web
+// visibility and status, based on flags that we are given by data team.
+func (p *Part) SetWebFlags() {
+	switch p.WebVisibility {
+	case DISABLED:
+		p.ShowOnWebsite = false
+		p.ShowForLoggedIn = false
+	case LOGGEDIN:
+		p.ShowForLoggedIn = true
+		p.ShowOnWebsite = p.Status >= 700
+	default:
+		p.ShowForLoggedIn = false
+		p.ShowOnWebsite = p.Status >= 700
+	}
+}
+
+// GetVisible loads the active parts the caller may see, limited to the
+// brands of the data context.
+func GetVisible(ids []int, dtx *apicontext.DataContext, sess *mgo.Session) ([]Part, error) {
+	c := sess.DB(database.ProductMongoDatabase).C(database.ProductCollectionName)
+	qry := bson.M{
+		"id":             bson.M{"$in": ids},
+		"status":         bson.M{"$in": statuses},
+		"brand.id":       bson.M{"$in": getBrandsFromDTX(dtx)},
+		"web_visibility": VisibilityQuery(Visibilities(dtx)),
+	}
+
+	var parts []Part
+	if err := c.Find(qry).All(&parts); err != nil {
+		return parts, err
+	}
+	for i := range parts {
+		parts[i].SetWebFlags()
+	}
+	return parts, nil
+}
+
+// VisibilityQuery matches the web_visibility of parts with one of the
+// visibilities. Parts without a visibility are treated as public.
+func VisibilityQuery(visibilities []string) bson.M {
+	if len(visibilities) == 0 {
+		visibilities = []string{PUBLIC}
+	}
+	in := make([]interface{}, 0, len(visibilities)+2)
+	for _, v := range visibilities {
+		in = append(in, v)
+		if v == PUBLIC {
+			in = append(in, "", nil)
+		}
+	}
+	return bson.M{"$in": in}
+}
+
+func visible(visibility string, visibilities []string) bool {
+	if visibility == "" {
+		visibility = PUBLIC
+	}
+	for _, v := range visibilities {
+		if v == visibility {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package products
+
+import (
+	"github.com/curt-labs/API/helpers/apicontext"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func visibilityParts() []Part {
+	return []Part{
+		{ID: 1, Status: 800, WebVisibility: PUBLIC},
+		{ID: 2, Status: 800, WebVisibility: LOGGEDIN},
+		{ID: 3, Status: 800, WebVisibility: DISABLED},
+		{ID: 4, Status: 800},
+		{ID: 5, Status: 600, WebVisibility: PUBLIC},
+	}
+}
+
+func visibleIDs(parts []Part) []int {
+	var ids []int
+	for _, p := range parts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestVisibilities(t *testing.T) {
+	public := &apicontext.DataContext{KeyType: "Public", UserID: "user"}
+	private := &apicontext.DataContext{KeyType: "Private", UserID: "user"}
+	loggedIn := &apicontext.DataContext{KeyType: "AUTHENTICATION", UserID: "user"}
+	internal := &apicontext.DataContext{KeyType: "Internal"}
+
+	Convey("Testing Visibilities()", t, func() {
+		So(Visibilities(nil), ShouldResemble, []string{PUBLIC})
+		So(Visibilities(public), ShouldResemble, []string{PUBLIC})
+		So(Visibilities(private), ShouldResemble, []string{PUBLIC})
+		So(Visibilities(loggedIn), ShouldResemble, []string{PUBLIC, LOGGEDIN})
+		So(Visibilities(&apicontext.DataContext{KeyType: "Authentication"}), ShouldResemble, []string{PUBLIC})
+		So(Visibilities(internal), ShouldResemble, []string{PUBLIC, LOGGEDIN, DISABLED})
+	})
+
+	Convey("Testing FilterVisible()", t, func() {
+		So(visibleIDs(FilterVisible(visibilityParts(), public)), ShouldResemble, []int{1, 4, 5})
+		So(visibleIDs(FilterVisible(visibilityParts(), loggedIn)), ShouldResemble, []int{1, 2, 4, 5})
+		So(visibleIDs(FilterVisible(visibilityParts(), internal)), ShouldResemble, []int{1, 2, 3, 4, 5})
+
+		parts := FilterVisible(visibilityParts(), internal)
+		So(parts[0].ShowOnWebsite, ShouldBeTrue)
+		So(parts[0].ShowForLoggedIn, ShouldBeFalse)
+		So(parts[1].ShowOnWebsite, ShouldBeTrue)
+		So(parts[1].ShowForLoggedIn, ShouldBeTrue)
+		So(parts[2].ShowOnWebsite, ShouldBeFalse)
+		So(parts[2].ShowForLoggedIn, ShouldBeFalse)
+		So(parts[4].ShowOnWebsite, ShouldBeFalse)
+	})
+
+	Convey("Testing VisibleTo()", t, func() {
+		p := Part{WebVisibility: LOGGEDIN}
+		So(p.VisibleTo(public), ShouldBeFalse)
+		So(p.VisibleTo(loggedIn), ShouldBeTrue)
+		p.WebVisibility = DISABLED
+		So(p.VisibleTo(loggedIn), ShouldBeFalse)
+		So(p.VisibleTo(internal), ShouldBeTrue)
+	})
+
+	Convey("Testing VisibilityQuery()", t, func() {
+		So(VisibilityQuery(nil)["$in"], ShouldResemble, []interface{}{PUBLIC, "", nil})
+		So(VisibilityQuery(Visibilities(loggedIn))["$in"], ShouldResemble, []interface{}{PUBLIC, "", nil, LOGGEDIN})
+
+		// the vehicle lookups carry the visibilities on their context
+		ctx := &LookupContext{Visibilities: Visibilities(internal)}
+		So(VisibilityQuery(ctx.Visibilities)["$in"], ShouldContain, DISABLED)
+	})
+}
@@ -1,26 +1,19 @@
 package products
 
 import (
-	"encoding/json"
-	"fmt"
 	"strconv"
 	"strings"
 
 	"github.com/curt-labs/API/helpers/apicontext"
 	"github.com/curt-labs/API/helpers/database"
-	"github.com/curt-labs/API/helpers/redis"
 	_ "github.com/go-sql-driver/mysql"
 )
 
 func (l *Lookup) GetYears(dtx *apicontext.DataContext) error {
 	//hit redis first
-	redis_key := fmt.Sprintf("lookup:years:%s", dtx.BrandString)
-	data, err := redis.Get(redis_key)
-	if err == nil {
-		err = json.Unmarshal(data, &l.Years)
-		if len(l.Years) > 0 {
-			return nil
-		}
+	if l.cached("years", &l.Years) && len(l.Years) > 0 {
+		l.Pagination = singlePage(len(l.Years))
+		return nil
 	}
 
 	stmtBeginning := `
@@ -39,7 +32,7 @@ func (l *Lookup) GetYears(dtx *apicontext.DataContext) error {
 	brandStmt = strings.TrimRight(brandStmt, ",") + ")"
 	wholeStmt := stmtBeginning + brandStmt + stmtEnd
 
-	err = database.Init()
+	err := database.Init()
 	if err != nil {
 		return err
 	}
@@ -72,8 +65,6 @@ func (l *Lookup) GetYears(dtx *apicontext.DataContext) error {
 		PerPage:       len(l.Years),
 		TotalPages:    1,
 	}
-	if dtx.BrandString != "" {
-		go redis.Setex(redis_key, l.Years, 604800)
-	}
+	l.cache("years", l.Years, len(l.Years))
 	return nil
 }
@@ -0,0 +1,487 @@
+package quality
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/models/products"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Rule is a completeness check. Applies decides whether the rule is
+// relevant to a part at all, Check whether the part passes it.
+type Rule struct {
+	Name        string                   `json:"name" xml:"name,attr"`
+	Description string                   `json:"description" xml:"description,attr"`
+	Weight      float64                  `json:"weight" xml:"weight,attr"`
+	Applies     func(products.Part) bool `json:"-" xml:"-"`
+	Check       func(products.Part) bool `json:"-" xml:"-"`
+}
+
+// RuleConfig overrides a rule's weight or turns it off.
+type RuleConfig struct {
+	Weight   float64 `json:"weight"`
+	Disabled bool    `json:"disabled"`
+}
+
+var (
+	rules = []Rule{
+		{
+			Name:        "images",
+			Description: "Part has at least one image",
+			Weight:      2,
+			Check: func(p products.Part) bool {
+				for _, img := range p.Images {
+					if img.Path != nil && img.Path.String() != "" {
+						return true
+					}
+				}
+				return false
+			},
+		},
+		{
+			Name:        "install_sheet",
+			Description: "Part has an install sheet",
+			Weight:      1,
+			Check: func(p products.Part) bool {
+				return p.InstallSheet != nil && p.InstallSheet.String() != ""
+			},
+		},
+		{
+			Name:        "upc",
+			Description: "Part has a UPC with a valid check digit",
+			Weight:      1,
+			Check: func(p products.Part) bool {
+				return ValidUPC(p.UPC)
+			},
+		},
+		{
+			Name:        "packages",
+			Description: "Part has packaging information",
+			Weight:      2,
+			Check: func(p products.Part) bool {
+				return len(p.Packages) > 0
+			},
+		},
+		{
+			Name:        "dimensions",
+			Description: "Every package has a length, width and height",
+			Weight:      1,
+			Applies: func(p products.Part) bool {
+				return len(p.Packages) > 0
+			},
+			Check: func(p products.Part) bool {
+				for _, pkg := range p.Packages {
+					if pkg.Length <= 0 || pkg.Width <= 0 || pkg.Height <= 0 {
+						return false
+					}
+				}
+				return true
+			},
+		},
+		{
+			Name:        "weight",
+			Description: "Parcel allowed packages have a weight",
+			Weight:      2,
+			Applies: func(p products.Part) bool {
+				for _, pkg := range p.Packages {
+					if pkg.ParcelAllowed {
+						return true
+					}
+				}
+				return false
+			},
+			Check: func(p products.Part) bool {
+				for _, pkg := range p.Packages {
+					if pkg.ParcelAllowed && pkg.Weight <= 0 {
+						return false
+					}
+				}
+				return true
+			},
+		},
+		{
+			Name:        "vehicles",
+			Description: "Application specific parts have vehicle applications",
+			Weight:      3,
+			Applies: func(p products.Part) bool {
+				return p.AppSpecific
+			},
+			Check: func(p products.Part) bool {
+				return len(p.Vehicles) > 0 || len(p.AcesVehicles) > 0 || len(p.LuverneVehicles) > 0
+			},
+		},
+		{
+			Name:        "categories",
+			Description: "Part is in at least one category",
+			Weight:      1,
+			Check: func(p products.Part) bool {
+				return len(p.Categories) > 0
+			},
+		},
+		{
+			Name:        "list_price",
+			Description: "Part has a list price",
+			Weight:      1,
+			Check: func(p products.Part) bool {
+				for _, pr := range p.Pricing {
+					if pr.Type == "List" && pr.Price > 0 {
+						return true
+					}
+				}
+				return false
+			},
+		},
+		{
+			Name:        "description",
+			Description: "Part has a short description",
+			Weight:      1,
+			Check: func(p products.Part) bool {
+				return strings.TrimSpace(p.ShortDesc) != ""
+			},
+		},
+	}
+	config   = make(map[string]RuleConfig)
+	configMu sync.RWMutex
+)
+
+// Rules returns the enabled rules with any configured weights applied.
+func Rules() []Rule {
+	configMu.RLock()
+	defer configMu.RUnlock()
+
+	var enabled []Rule
+	for _, r := range rules {
+		if c, ok := config[r.Name]; ok {
+			if c.Disabled {
+				continue
+			}
+			if c.Weight > 0 {
+				r.Weight = c.Weight
+			}
+		}
+		enabled = append(enabled, r)
+	}
+	return enabled
+}
+
+// Select narrows the enabled rules to the named ones.
+func Select(names []string) ([]Rule, error) {
+	enabled := Rules()
+	if len(names) == 0 {
+		return enabled, nil
+	}
+
+	var selected []Rule
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		found := false
+		for _, r := range enabled {
+			if r.Name == name {
+				selected = append(selected, r)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown data quality rule %s", name)
+		}
+	}
+	return selected, nil
+}
+
+// Configure replaces the rule configuration, keyed by rule name.
+func Configure(c map[string]RuleConfig) {
+	configMu.Lock()
+	config = c
+	configMu.Unlock()
+}
+
+// LoadConfigFile configures the rules from the JSON file at path.
+func LoadConfigFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var c map[string]RuleConfig
+	if err = json.NewDecoder(f).Decode(&c); err != nil {
+		return err
+	}
+	Configure(c)
+	return nil
+}
+
+// ValidUPC checks the length and check digit of a UPC-A or EAN-13 code.
+func ValidUPC(upc string) bool {
+	upc = strings.TrimSpace(upc)
+	if len(upc) != 12 && len(upc) != 13 {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < len(upc)-1; i++ {
+		d := int(upc[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		// weights alternate 3 and 1 counting back from the check digit
+		if (len(upc)-1-i)%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	check := int(upc[len(upc)-1] - '0')
+	return check >= 0 && check <= 9 && (10-sum%10)%10 == check
+}
+
+// PartResult is how a single part scored.
+type PartResult struct {
+	ID         int      `json:"id" xml:"id,attr"`
+	PartNumber string   `json:"part_number" xml:"part_number,attr"`
+	Brand      string   `json:"brand" xml:"brand,attr"`
+	Categories []string `json:"categories" xml:"categories"`
+	Score      float64  `json:"score" xml:"score,attr"`
+	Failed     []string `json:"failed" xml:"failed"`
+}
+
+// Summary rolls up the scores of a group of parts.
+type Summary struct {
+	ID        int            `json:"id" xml:"id,attr"`
+	Name      string         `json:"name" xml:"name,attr"`
+	Parts     int            `json:"parts" xml:"parts,attr"`
+	Offending int            `json:"offending" xml:"offending,attr"`
+	Score     float64        `json:"score" xml:"score,attr"`
+	Failures  map[string]int `json:"failures" xml:"-"`
+	total     float64
+}
+
+// Report is the data quality of a set of parts. Offenders are the parts
+// that failed at least one rule, worst first.
+type Report struct {
+	Generated  time.Time    `json:"generated" xml:"generated,attr"`
+	Rules      []Rule       `json:"rules" xml:"rules"`
+	Overall    Summary      `json:"overall" xml:"overall"`
+	Brands     []Summary    `json:"brands" xml:"brands"`
+	Categories []Summary    `json:"categories" xml:"categories"`
+	Offenders  []PartResult `json:"-" xml:"-"`
+}
+
+// Generate scans the active parts for the brands in the data context.
+func Generate(rules []Rule, dtx *apicontext.DataContext) (Report, error) {
+	if err := database.Init(); err != nil {
+		return Report{}, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	brands := dtx.BrandArray
+	if dtx.BrandID != 0 {
+		brands = []int{dtx.BrandID}
+	}
+	query := bson.M{
+		"status": bson.M{
+			"$in": products.ActiveStatuses(),
+		},
+		"brand.id": bson.M{
+			"$in": brands,
+		},
+	}
+	fields := bson.M{
+		"id": 1, "part_number": 1, "brand": 1, "short_description": 1, "install_sheet": 1,
+		"application_specific": 1, "images": 1, "upc": 1, "packages": 1, "pricing": 1,
+		"categories.id": 1, "categories.title": 1,
+		"vehicle_applications.year": 1, "aces_vehicles.base": 1, "luverne_applications.year": 1,
+	}
+
+	s := NewScanner(rules)
+	var p products.Part
+	iter := session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Select(fields).Iter()
+	for iter.Next(&p) {
+		s.Add(p)
+		p = products.Part{}
+	}
+	if err := iter.Close(); err != nil {
+		return Report{}, err
+	}
+
+	return s.Report(), nil
+}
+
+// Scanner scores parts one at a time so the whole catalog never has to be
+// in memory.
+type Scanner struct {
+	rules      []Rule
+	overall    Summary
+	brands     map[int]*Summary
+	categories map[int]*Summary
+	offenders  []PartResult
+}
+
+// NewScanner returns a scanner for the rules.
+func NewScanner(rules []Rule) *Scanner {
+	return &Scanner{
+		rules:      rules,
+		overall:    Summary{Name: "All Parts", Failures: make(map[string]int)},
+		brands:     make(map[int]*Summary),
+		categories: make(map[int]*Summary),
+	}
+}
+
+// Score checks the part against the rules. The score is the percent of the
+// applicable rule weight the part passed.
+func Score(p products.Part, rules []Rule) PartResult {
+	res := PartResult{
+		ID:         p.ID,
+		PartNumber: p.PartNumber,
+		Brand:      p.Brand.Name,
+		Score:      100,
+	}
+	for _, c := range p.Categories {
+		res.Categories = append(res.Categories, c.Title)
+	}
+
+	var possible, passed float64
+	for _, r := range rules {
+		if r.Applies != nil && !r.Applies(p) {
+			continue
+		}
+		possible += r.Weight
+		if r.Check(p) {
+			passed += r.Weight
+			continue
+		}
+		res.Failed = append(res.Failed, r.Name)
+	}
+	if possible > 0 {
+		res.Score = round(passed / possible * 100)
+	}
+	return res
+}
+
+// Add scores the part and adds it to the summaries.
+func (s *Scanner) Add(p products.Part) {
+	res := Score(p, s.rules)
+
+	s.overall.add(res)
+	b, ok := s.brands[p.Brand.ID]
+	if !ok {
+		b = &Summary{ID: p.Brand.ID, Name: p.Brand.Name, Failures: make(map[string]int)}
+		s.brands[p.Brand.ID] = b
+	}
+	b.add(res)
+
+	for _, cat := range p.Categories {
+		c, ok := s.categories[cat.CategoryID]
+		if !ok {
+			c = &Summary{ID: cat.CategoryID, Name: cat.Title, Failures: make(map[string]int)}
+			s.categories[cat.CategoryID] = c
+		}
+		c.add(res)
+	}
+
+	if len(res.Failed) > 0 {
+		s.offenders = append(s.offenders, res)
+	}
+}
+
+// Report finishes the scan.
+func (s *Scanner) Report() Report {
+	rep := Report{
+		Generated:  time.Now(),
+		Rules:      s.rules,
+		Overall:    s.overall.finish(),
+		Brands:     make([]Summary, 0, len(s.brands)),
+		Categories: make([]Summary, 0, len(s.categories)),
+		Offenders:  s.offenders,
+	}
+	for _, b := range s.brands {
+		rep.Brands = append(rep.Brands, b.finish())
+	}
+	for _, c := range s.categories {
+		rep.Categories = append(rep.Categories, c.finish())
+	}
+
+	// worst first so the summaries read as a to do list
+	sortSummaries(rep.Brands)
+	sortSummaries(rep.Categories)
+	sort.SliceStable(rep.Offenders, func(i, j int) bool {
+		if rep.Offenders[i].Score != rep.Offenders[j].Score {
+			return rep.Offenders[i].Score < rep.Offenders[j].Score
+		}
+		return rep.Offenders[i].ID < rep.Offenders[j].ID
+	})
+
+	return rep
+}
+
+// WriteCSV writes the offending parts as CSV.
+func (rep Report) WriteCSV(w io.Writer) error {
+	wr := csv.NewWriter(w)
+	wr.Write([]string{
+		"Part ID",
+		"Part Number",
+		"Brand",
+		"Categories",
+		"Score",
+		"Failed Rules",
+	})
+	for _, o := range rep.Offenders {
+		wr.Write([]string{
+			strconv.Itoa(o.ID),
+			o.PartNumber,
+			o.Brand,
+			strings.Join(o.Categories, "; "),
+			strconv.FormatFloat(o.Score, 'f', 2, 64),
+			strings.Join(o.Failed, " "),
+		})
+	}
+	wr.Flush()
+	return wr.Error()
+}
+
+func (s *Summary) add(res PartResult) {
+	s.Parts++
+	s.total += res.Score
+	if len(res.Failed) > 0 {
+		s.Offending++
+	}
+	for _, f := range res.Failed {
+		s.Failures[f]++
+	}
+}
+
+func (s *Summary) finish() Summary {
+	if s.Parts > 0 {
+		s.Score = round(s.total / float64(s.Parts))
+	}
+	return *s
+}
+
+func sortSummaries(s []Summary) {
+	sort.Slice(s, func(i, j int) bool {
+		if s[i].Score != s[j].Score {
+			return s[i].Score < s[j].Score
+		}
+		return s[i].ID < s[j].ID
+	})
+}
+
+func round(f float64) float64 {
+	return math.Floor(f*100+0.5) / 100
+}
@@ -0,0 +1,135 @@
+package quality
+
+import (
+	"bytes"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func testParts() []products.Part {
+	sheet, _ := url.Parse("https://www.curtmfg.com/masterlibrary/13000/installsheet/13000.pdf")
+	img, _ := url.Parse("https://www.curtmfg.com/masterlibrary/13000/images/13000.jpg")
+	return []products.Part{
+		{
+			ID:           13000,
+			PartNumber:   "13000",
+			Brand:        brand.Brand{ID: 1, Name: "CURT"},
+			ShortDesc:    "Class 3 Trailer Hitch",
+			InstallSheet: sheet,
+			AppSpecific:  true,
+			UPC:          "036293130001",
+			Images:       []products.Image{{Path: img}},
+			Packages:     []products.Package{{Length: 30, Width: 20, Height: 10, Weight: 50, ParcelAllowed: true}},
+			Pricing:      []products.Price{{Type: "List", Price: 250}},
+			Categories:   []products.Category{{CategoryID: 11, Title: "Trailer Hitches"}},
+			Vehicles:     []products.VehicleApplication{{Year: "2016", Make: "Chevrolet", Model: "Silverado 1500"}},
+		},
+		{
+			ID:          11000,
+			PartNumber:  "11000",
+			Brand:       brand.Brand{ID: 1, Name: "CURT"},
+			ShortDesc:   "Class 1 Trailer Hitch",
+			AppSpecific: true,
+			UPC:         "036293110003",
+			Packages:    []products.Package{{Length: 30, Width: 20, Height: 10, ParcelAllowed: true}},
+			Pricing:     []products.Price{{Type: "List", Price: 150}},
+			Categories:  []products.Category{{CategoryID: 11, Title: "Trailer Hitches"}},
+		},
+		{
+			ID:         56000,
+			PartNumber: "56000",
+			Brand:      brand.Brand{ID: 3, Name: "ARIES"},
+			Categories: []products.Category{{CategoryID: 20, Title: "Wiring"}},
+		},
+	}
+}
+
+func TestValidUPC(t *testing.T) {
+	Convey("Testing ValidUPC()", t, func() {
+		So(ValidUPC("036293130001"), ShouldBeTrue)
+		So(ValidUPC("036293130002"), ShouldBeFalse)
+		So(ValidUPC("4006381333931"), ShouldBeTrue)
+		So(ValidUPC("03629313000A"), ShouldBeFalse)
+		So(ValidUPC(""), ShouldBeFalse)
+	})
+}
+
+func TestScore(t *testing.T) {
+	parts := testParts()
+
+	Convey("Testing Score()", t, func() {
+		res := Score(parts[0], Rules())
+		So(res.Score, ShouldEqual, 100)
+		So(res.Failed, ShouldBeEmpty)
+
+		res = Score(parts[1], Rules())
+		So(res.Failed, ShouldResemble, []string{"images", "install_sheet", "weight", "vehicles"})
+		So(res.Score, ShouldBeBetween, 0, 100)
+
+		// no packages, so the dimension and weight rules don't apply
+		res = Score(parts[2], Rules())
+		So(res.Failed, ShouldNotContain, "dimensions")
+		So(res.Failed, ShouldNotContain, "weight")
+		So(res.Failed, ShouldNotContain, "vehicles")
+		So(res.Failed, ShouldContain, "packages")
+	})
+
+	Convey("Testing Configure()", t, func() {
+		Configure(map[string]RuleConfig{"install_sheet": {Disabled: true}, "images": {Weight: 10}})
+		defer Configure(map[string]RuleConfig{})
+
+		rules := Rules()
+		for _, r := range rules {
+			So(r.Name, ShouldNotEqual, "install_sheet")
+			if r.Name == "images" {
+				So(r.Weight, ShouldEqual, 10)
+			}
+		}
+		res := Score(parts[1], rules)
+		So(res.Failed, ShouldNotContain, "install_sheet")
+	})
+
+	Convey("Testing Select()", t, func() {
+		rules, err := Select([]string{"UPC", "images"})
+		So(err, ShouldBeNil)
+		So(len(rules), ShouldEqual, 2)
+		_, err = Select([]string{"color"})
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestScanner(t *testing.T) {
+	Convey("Testing Scanner", t, func() {
+		s := NewScanner(Rules())
+		for _, p := range testParts() {
+			s.Add(p)
+		}
+		rep := s.Report()
+
+		So(rep.Overall.Parts, ShouldEqual, 3)
+		So(rep.Overall.Offending, ShouldEqual, 2)
+		So(rep.Overall.Failures["vehicles"], ShouldEqual, 1)
+
+		So(len(rep.Brands), ShouldEqual, 2)
+		So(rep.Brands[0].Name, ShouldEqual, "ARIES")
+		So(rep.Brands[1].Parts, ShouldEqual, 2)
+		So(rep.Brands[1].Offending, ShouldEqual, 1)
+
+		So(len(rep.Categories), ShouldEqual, 2)
+		So(rep.Categories[0].Name, ShouldEqual, "Wiring")
+
+		So(len(rep.Offenders), ShouldEqual, 2)
+		So(rep.Offenders[0].ID, ShouldEqual, 56000)
+
+		var buf bytes.Buffer
+		So(rep.WriteCSV(&buf), ShouldBeNil)
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		So(len(lines), ShouldEqual, 3)
+		So(lines[0], ShouldStartWith, "Part ID,Part Number")
+		So(lines[2], ShouldContainSubstring, "images install_sheet weight vehicles")
+	})
+}
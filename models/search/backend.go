@@ -0,0 +1,210 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"unicode"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/apifilter"
+	"github.com/curt-labs/API/models/brand"
+)
+
+const (
+	// AllIndex is the index that spans the index of every brand.
+	AllIndex = "all"
+
+	// DocumentType is the type parts are indexed as.
+	DocumentType = "part"
+
+	// SuggestField is the field of a document that suggestions complete.
+	SuggestField = "suggest"
+)
+
+// SearchBackend runs searches against an index of parts. Elasticsearch and
+// the in-memory index used for tests and local development both satisfy it.
+type SearchBackend interface {
+	// Search runs a free text query.
+	Search(index string, q Query) (*SearchResult, error)
+
+	// ExactAndClose runs a free text query that ranks an exact part number
+	// match above everything else.
+	ExactAndClose(index string, q Query) (*SearchResult, error)
+
+	// Suggest completes the prefix from the suggest field of the documents.
+	Suggest(index, prefix string, size int) ([]Suggestion, error)
+
+	// Index adds the documents to the index, replacing any with the same
+	// ID.
+	Index(index string, docs ...Document) error
+
+	// Delete removes the documents with the IDs from the index.
+	Delete(index string, ids ...string) error
+
+	// CreateIndex creates an index with the settings and mappings of body.
+	CreateIndex(index string, body interface{}) error
+
+	// DeleteIndex removes the indexes and their documents.
+	DeleteIndex(indexes ...string) error
+
+	// IndexExists reports whether there's an index or alias with the name.
+	IndexExists(name string) (bool, error)
+
+	// Aliases returns the indexes the alias points to.
+	Aliases(alias string) ([]string, error)
+
+	// UpdateAliases applies the alias actions in one step. When any of them
+	// fails, none of them are applied.
+	UpdateAliases(actions ...AliasAction) error
+}
+
+// AliasAction adds an index to an alias, or removes it.
+type AliasAction struct {
+	Alias  string
+	Index  string
+	Remove bool
+}
+
+// Query is a page of a free text search. Hits are limited to the filters
+// and, when there are any, to the web visibilities and the parts indexed
+// under the vehicle key. An empty text matches every part. The hits across
+// every page are counted by the values of the facets, each under every
+// filter but its own.
+type Query struct {
+	Text         string
+	From         int
+	Size         int
+	Filters      Filters
+	Visibilities []string
+	Vehicle      string
+	Facets       []Facet
+}
+
+// Document is a document to index. Its source is encoded as JSON.
+type Document struct {
+	ID     string
+	Type   string
+	Source interface{}
+}
+
+// SearchResult is the page of hits of a search, shaped like an
+// Elasticsearch response.
+type SearchResult struct {
+	TookInMillis int64       `json:"took"`
+	TimedOut     bool        `json:"timed_out"`
+	Hits         *SearchHits `json:"hits"`
+
+	Facets  []apifilter.Options `json:"facets,omitempty"`
+	Vehicle *DetectedVehicle    `json:"vehicle,omitempty"`
+}
+
+// SearchHits are the hits of a search.
+type SearchHits struct {
+	TotalHits int64        `json:"total"`
+	MaxScore  *float64     `json:"max_score"`
+	Hits      []*SearchHit `json:"hits"`
+}
+
+// SearchHit is a document that matched a search.
+type SearchHit struct {
+	Score  *float64         `json:"_score"`
+	Index  string           `json:"_index"`
+	Type   string           `json:"_type"`
+	Id     string           `json:"_id"`
+	Source *json.RawMessage `json:"_source"`
+}
+
+// Suggestion is a completion of a prefix. Payload is whatever was indexed
+// with the completion.
+type Suggestion struct {
+	Text    string      `json:"text"`
+	Score   float64     `json:"score"`
+	Payload interface{} `json:"payload,omitempty"`
+}
+
+var (
+	// DefaultBackend is the Elasticsearch cluster of the ELASTICSEARCH_IP,
+	// ELASTIC_USER and ELASTIC_PASS environment variables.
+	DefaultBackend = ElasticBackend{}
+
+	// Backend runs every search. Swap it out for a MemoryBackend to search
+	// without a cluster.
+	Backend SearchBackend = &DefaultBackend
+
+	// BrandIndexes maps brand IDs to the index of their parts, as loaded by
+	// LoadBrandIndexes. Brands without an index are searched through
+	// AllIndex.
+	BrandIndexes = map[int]string{}
+
+	// allBrands lists the brands LoadBrandIndexes names indexes after.
+	allBrands = brand.GetAllBrands
+)
+
+// RegisterBrandIndex sets the index the parts of a brand are searched in.
+// Register brands before serving searches.
+func RegisterBrandIndex(brand int, index string) {
+	BrandIndexes[brand] = index
+}
+
+// BrandIndex is the name of the index of the brand's parts: its code in
+// lower case without anything but letters and digits, like "curt".
+func BrandIndex(b brand.Brand) string {
+	return strings.Map(func(r rune) rune {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, b.Code)
+}
+
+// LoadBrandIndexes replaces BrandIndexes with the BrandIndex of every brand
+// with a code. Load them before serving or indexing searches.
+func LoadBrandIndexes() error {
+	brands, err := allBrands()
+	if err != nil {
+		return err
+	}
+
+	indexes := make(map[int]string, len(brands))
+	for _, b := range brands {
+		if index := BrandIndex(b); index != "" {
+			indexes[b.ID] = index
+		}
+	}
+	if len(indexes) == 0 {
+		return errors.New("no brands to name search indexes after")
+	}
+	BrandIndexes = indexes
+	return nil
+}
+
+// findIndex returns the index of the brand, or of the brands of the data
+// context when brand is zero. Brands spread across more than one index, or
+// without an index, are searched through AllIndex.
+func findIndex(brand int, dtx *apicontext.DataContext) string {
+	brands := []int{brand}
+	if brand == 0 {
+		brands = nil
+		if dtx != nil {
+			brands = dtx.BrandArray
+		}
+	}
+
+	index := ""
+	for _, b := range brands {
+		idx, ok := BrandIndexes[b]
+		if !ok {
+			continue
+		}
+		if index != "" && index != idx {
+			return AllIndex
+		}
+		index = idx
+	}
+
+	if index == "" {
+		return AllIndex
+	}
+	return index
+}
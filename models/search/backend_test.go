@@ -0,0 +1,180 @@
+package search
+
+import (
+	"encoding/json"
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/models/brand"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestFindIndex(t *testing.T) {
+	oldBrands, oldIndexes := allBrands, BrandIndexes
+	defer func() { allBrands, BrandIndexes = oldBrands, oldIndexes }()
+
+	Convey("Testing LoadBrandIndexes()", t, func() {
+		allBrands = func() (brand.Brands, error) {
+			return brand.Brands{
+				{ID: 1, Code: "CURT"},
+				{ID: 3, Code: "ARIES"},
+				{ID: 4, Code: "Luverne"},
+				{ID: 6, Code: "U.W.S."},
+				{ID: 7},
+			}, nil
+		}
+		So(LoadBrandIndexes(), ShouldBeNil)
+		So(BrandIndexes, ShouldResemble, map[int]string{1: "curt", 3: "aries", 4: "luverne", 6: "uws"})
+
+		allBrands = func() (brand.Brands, error) { return nil, nil }
+		So(LoadBrandIndexes(), ShouldNotBeNil)
+		So(BrandIndexes[1], ShouldEqual, "curt")
+	})
+
+	Convey("Testing findIndex()", t, func() {
+		So(findIndex(1, nil), ShouldEqual, "curt")
+		So(findIndex(3, &apicontext.DataContext{BrandArray: []int{1}}), ShouldEqual, "aries")
+		So(findIndex(0, &apicontext.DataContext{BrandArray: []int{4}}), ShouldEqual, "luverne")
+		So(findIndex(0, &apicontext.DataContext{BrandArray: []int{1, 3}}), ShouldEqual, AllIndex)
+		So(findIndex(0, &apicontext.DataContext{BrandArray: []int{1, 2}}), ShouldEqual, "curt")
+		So(findIndex(0, &apicontext.DataContext{}), ShouldEqual, AllIndex)
+		So(findIndex(42, nil), ShouldEqual, AllIndex)
+
+		Convey("with a registered brand", func() {
+			RegisterBrandIndex(42, "towing")
+			defer delete(BrandIndexes, 42)
+			So(findIndex(42, nil), ShouldEqual, "towing")
+		})
+	})
+}
+
+func TestMemoryBackend(t *testing.T) {
+	m := NewMemoryBackend()
+	m.Index("curt",
+		Document{ID: "13000", Source: map[string]interface{}{
+			"raw_part":  "13000",
+			"title":     "Class 3 Trailer Hitch",
+			"suggest":   map[string]interface{}{"input": []string{"13000", "Class 3 Trailer Hitch"}, "output": "13000", "weight": 5},
+			"attribute": []string{"Black", "Receiver Hitch"},
+		}},
+		Document{ID: "55000", Source: map[string]interface{}{
+			"raw_part": "55000",
+			"title":    "Wiring Harness 13000 Hitch",
+			"suggest":  "55000",
+		}},
+	)
+	m.Index("aries", Document{ID: "2047", Source: map[string]interface{}{
+		"raw_part": "2047",
+		"title":    "Running Board",
+		"suggest":  []interface{}{map[string]interface{}{"input": "2047", "weight": 1}},
+	}})
+
+	ids := func(res *SearchResult) []string {
+		var found []string
+		for _, hit := range res.Hits.Hits {
+			found = append(found, hit.Id)
+		}
+		return found
+	}
+
+	Convey("Testing MemoryBackend", t, func() {
+		Convey("Search() ranks by matched words", func() {
+			res, err := m.Search("curt", Query{Text: "trailer hitch", Size: 10})
+			So(err, ShouldBeNil)
+			So(ids(res), ShouldResemble, []string{"13000", "55000"})
+			So(res.Hits.TotalHits, ShouldEqual, 2)
+			So(*res.Hits.MaxScore, ShouldEqual, 3)
+		})
+
+		Convey("Search() pages", func() {
+			res, err := m.Search("curt", Query{Text: "hitch", From: 1, Size: 1})
+			So(err, ShouldBeNil)
+			So(res.Hits.TotalHits, ShouldEqual, 2)
+			So(len(res.Hits.Hits), ShouldEqual, 1)
+		})
+
+		Convey("Search() of the all index spans every index", func() {
+			res, err := m.Search(AllIndex, Query{Text: "board OR hitch"})
+			So(err, ShouldBeNil)
+			So(res.Hits.TotalHits, ShouldEqual, 3)
+
+			res, err = m.Search("aries", Query{Text: "hitch"})
+			So(err, ShouldBeNil)
+			So(res.Hits.TotalHits, ShouldEqual, 0)
+			So(res.Hits.Hits, ShouldNotBeNil)
+		})
+
+		Convey("ExactAndClose() ranks the part number first", func() {
+			res, err := m.Search("curt", Query{Text: "13000"})
+			So(err, ShouldBeNil)
+			So(len(res.Hits.Hits), ShouldEqual, 2)
+
+			res, err = m.ExactAndClose("curt", Query{Text: "13000"})
+			So(err, ShouldBeNil)
+			So(ids(res), ShouldResemble, []string{"13000", "55000"})
+			So(*res.Hits.Hits[0].Score, ShouldEqual, 11)
+
+			var source map[string]interface{}
+			So(json.Unmarshal(*res.Hits.Hits[0].Source, &source), ShouldBeNil)
+			So(source["title"], ShouldEqual, "Class 3 Trailer Hitch")
+		})
+
+		Convey("Suggest() completes prefixes", func() {
+			s, err := m.Suggest(AllIndex, "class", 5)
+			So(err, ShouldBeNil)
+			So(len(s), ShouldEqual, 1)
+			So(s[0].Text, ShouldEqual, "13000")
+
+			s, err = m.Suggest(AllIndex, "", 5)
+			So(err, ShouldBeNil)
+			So(s, ShouldBeEmpty)
+		})
+
+		Convey("Index() replaces and Delete() removes", func() {
+			m := NewMemoryBackend()
+			So(m.Index("curt", Document{ID: "1", Source: map[string]string{"title": "hitch"}}), ShouldBeNil)
+			So(m.Index("curt", Document{ID: "1", Source: map[string]string{"title": "ball mount"}}), ShouldBeNil)
+
+			res, _ := m.Search("curt", Query{Text: "hitch"})
+			So(res.Hits.TotalHits, ShouldEqual, 0)
+			res, _ = m.Search("curt", Query{Text: "mount"})
+			So(res.Hits.TotalHits, ShouldEqual, 1)
+
+			So(m.Delete("curt", "1", "2"), ShouldBeNil)
+			res, _ = m.Search("curt", Query{Text: "mount"})
+			So(res.Hits.TotalHits, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestDslBackend(t *testing.T) {
+	m := NewMemoryBackend()
+	m.Index("curt",
+		Document{ID: "1", Source: map[string]interface{}{"raw_part": "1", "title": "hitch"}},
+		Document{ID: "2", Source: map[string]interface{}{"raw_part": "2", "title": "hitch", "web_visibility": "Disabled"}},
+	)
+
+	old := Backend
+	Backend = m
+	defer func() { Backend = old }()
+
+	public := &apicontext.DataContext{KeyType: "Public", BrandArray: []int{1}}
+
+	Convey("Testing Dsl() against a MemoryBackend", t, func() {
+		res, err := Dsl("", 0, 0, 0, public, "", nil, false)
+		So(err, ShouldNotBeNil)
+		So(res, ShouldBeNil)
+
+		res, err = Dsl("hitch", 0, 0, 0, public, "", nil, false)
+		So(err, ShouldBeNil)
+		So(res.Hits.TotalHits, ShouldEqual, 1)
+		So(res.Hits.Hits[0].Id, ShouldEqual, "1")
+
+		res, err = ExactAndCloseDsl("hitch", 0, 0, 0, public, nil, false)
+		So(err, ShouldBeNil)
+		So(res.Hits.TotalHits, ShouldEqual, 1)
+
+		res, err = Dsl("hitch", 0, 0, 0, &apicontext.DataContext{KeyType: "Internal", BrandArray: []int{1}}, "", nil, false)
+		So(err, ShouldBeNil)
+		So(res.Hits.TotalHits, ShouldEqual, 2)
+	})
+}
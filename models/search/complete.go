@@ -0,0 +1,439 @@
+package search
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/models/products"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// The types of completions.
+const (
+	PartCompletion     = "part"
+	CategoryCompletion = "category"
+	MakeCompletion     = "make"
+	ModelCompletion    = "model"
+)
+
+const (
+	// DefaultCompletions is how many completions are returned when the
+	// caller doesn't ask for a count.
+	DefaultCompletions = 10
+
+	// MaxCompletions is the most completions returned.
+	MaxCompletions = 50
+)
+
+var (
+	// CompletionTTL is how long the completions loaded from the catalog are
+	// served before they're reloaded in the background.
+	CompletionTTL = time.Hour
+
+	// CompletionRetry is how long after a reload of the completions starts
+	// that another can start, so a failing catalog isn't scanned on every
+	// request.
+	CompletionRetry = 5 * time.Minute
+)
+
+// Completion is a part number, category or vehicle that completes what's
+// been typed into a search box.
+type Completion struct {
+	Type  string `json:"type" xml:"type,attr"`
+	Text  string `json:"text" xml:"text"`
+	ID    int    `json:"id,omitempty" xml:"id,attr,omitempty"`
+	Make  string `json:"make,omitempty" xml:"make,omitempty"`
+	Model string `json:"model,omitempty" xml:"model,omitempty"`
+}
+
+// CompletionIndex completes prefixes from the part numbers, category titles
+// and vehicles of the catalog. Prefixes are matched by their letters and
+// digits only, so "13-0" completes part 13000, and against the start of any
+// word of a title, so "hitch" completes "Trailer Hitch Accessories".
+type CompletionIndex struct {
+	Built    time.Time
+	entries  []completionEntry
+	vehicles *VehicleNames
+}
+
+type completionEntry struct {
+	key   string
+	group *completionGroup
+}
+
+// completionGroup is a completion of a brand and visibility, weighed by the
+// number of parts it's from.
+type completionGroup struct {
+	Completion
+	brand      int
+	visibility string
+	weight     int
+}
+
+// CompletionBuilder builds a CompletionIndex one part at a time.
+type CompletionBuilder struct {
+	groups   map[string]*completionGroup
+	keys     map[*completionGroup][]string
+	vehicles *VehicleNames
+}
+
+var (
+	completions      *CompletionIndex
+	completionsMu    sync.Mutex
+	completionReload bool
+	completionTried  time.Time
+)
+
+// NewCompletionBuilder returns an empty CompletionBuilder.
+func NewCompletionBuilder() *CompletionBuilder {
+	return &CompletionBuilder{
+		groups:   make(map[string]*completionGroup),
+		keys:     make(map[*completionGroup][]string),
+		vehicles: NewVehicleNames(products.Aliases),
+	}
+}
+
+// BuildCompletions builds a CompletionIndex from the parts.
+func BuildCompletions(parts []products.Part) *CompletionIndex {
+	b := NewCompletionBuilder()
+	for _, p := range parts {
+		b.Add(p)
+	}
+	return b.Index()
+}
+
+// Add adds the part number, categories and vehicles of the part.
+func (b *CompletionBuilder) Add(p products.Part) {
+	brand, visibility := p.Brand.ID, p.WebVisibility
+	if visibility == "" {
+		visibility = products.PUBLIC
+	}
+
+	if p.PartNumber != "" {
+		b.add(Completion{Type: PartCompletion, Text: p.PartNumber, ID: p.ID}, brand, visibility, products.AliasKey(p.PartNumber))
+	}
+
+	for _, c := range p.Categories {
+		if c.Title == "" {
+			continue
+		}
+		b.add(Completion{Type: CategoryCompletion, Text: c.Title, ID: c.CategoryID}, brand, visibility, wordKeys(c.Title)...)
+	}
+
+	seen := make(map[string]bool)
+	makes := make(map[string]bool)
+	addVehicle := func(vehicleMake, model string) {
+		vehicleMake = products.NormalizeMake(vehicleMake)
+		model = products.NormalizeModel(vehicleMake, model)
+		if vehicleMake == "" {
+			return
+		}
+		k := strings.ToLower(vehicleMake + "|" + model)
+		if seen[k] {
+			return
+		}
+		seen[k] = true
+		b.vehicles.Add(vehicleMake, model)
+
+		makeKeys := append([]string{products.AliasKey(vehicleMake)}, aliasKeys(products.Aliases.Makes, vehicleMake)...)
+		if !makes[strings.ToLower(vehicleMake)] {
+			makes[strings.ToLower(vehicleMake)] = true
+			b.add(Completion{Type: MakeCompletion, Text: vehicleMake, Make: vehicleMake}, brand, visibility, makeKeys...)
+		}
+		if model == "" {
+			return
+		}
+
+		modelKeys := wordKeys(model)
+		modelKeys = append(modelKeys, aliasKeys(products.Aliases.Models[products.AliasKey(vehicleMake)], model)...)
+		keys := append([]string(nil), modelKeys...)
+		for _, mk := range makeKeys {
+			for _, k := range modelKeys {
+				keys = append(keys, mk+k)
+			}
+		}
+		b.add(Completion{Type: ModelCompletion, Text: vehicleMake + " " + model, Make: vehicleMake, Model: model}, brand, visibility, keys...)
+	}
+	for _, app := range p.Vehicles {
+		addVehicle(app.Make, app.Model)
+	}
+	for _, av := range p.AcesVehicles {
+		addVehicle(av.Base.Make, av.Base.Model)
+	}
+	for _, app := range p.LuverneVehicles {
+		addVehicle(app.Make, app.Model)
+	}
+}
+
+func (b *CompletionBuilder) add(c Completion, brand int, visibility string, keys ...string) {
+	id := strings.Join([]string{c.Type, strings.ToLower(c.Text), strconv.Itoa(brand), visibility}, "|")
+	g, ok := b.groups[id]
+	if !ok {
+		g = &completionGroup{Completion: c, brand: brand, visibility: visibility}
+		b.groups[id] = g
+	}
+	g.weight++
+
+	for _, k := range keys {
+		if k != "" {
+			b.keys[g] = append(b.keys[g], k)
+		}
+	}
+}
+
+// Index returns the CompletionIndex of the parts added so far.
+func (b *CompletionBuilder) Index() *CompletionIndex {
+	idx := &CompletionIndex{Built: time.Now(), vehicles: b.vehicles}
+	for g, keys := range b.keys {
+		seen := make(map[string]bool, len(keys))
+		for _, k := range keys {
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			idx.entries = append(idx.entries, completionEntry{key: k, group: g})
+		}
+	}
+	sort.Slice(idx.entries, func(i, j int) bool {
+		return idx.entries[i].key < idx.entries[j].key
+	})
+	return idx
+}
+
+// Complete returns the completions of the prefix for the brands that the
+// data context can see, best first. Exact matches come first, then the
+// completions with the most parts.
+func (idx *CompletionIndex) Complete(prefix string, brands []int, dtx *apicontext.DataContext, size int) []Completion {
+	found := make([]Completion, 0)
+	key := products.AliasKey(prefix)
+	if idx == nil || key == "" {
+		return found
+	}
+	if size <= 0 {
+		size = DefaultCompletions
+	}
+
+	inBrands := make(map[int]bool, len(brands))
+	for _, b := range brands {
+		inBrands[b] = true
+	}
+	visibilities := make(map[string]bool)
+	for _, v := range products.Visibilities(dtx) {
+		visibilities[v] = true
+	}
+
+	type match struct {
+		Completion
+		exact  bool
+		weight int
+	}
+	var matches []*match
+	byText := make(map[string]*match)
+	counted := make(map[*completionGroup]bool)
+
+	i := sort.Search(len(idx.entries), func(i int) bool {
+		return idx.entries[i].key >= key
+	})
+	for ; i < len(idx.entries) && strings.HasPrefix(idx.entries[i].key, key); i++ {
+		e := idx.entries[i]
+		g := e.group
+		if !inBrands[g.brand] || !visibilities[g.visibility] {
+			continue
+		}
+
+		id := g.Type + "|" + strings.ToLower(g.Text)
+		m, ok := byText[id]
+		if !ok {
+			m = &match{Completion: g.Completion}
+			byText[id] = m
+			matches = append(matches, m)
+		}
+		if e.key == key {
+			m.exact = true
+		}
+		if !counted[g] {
+			counted[g] = true
+			m.weight += g.weight
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.exact != b.exact {
+			return a.exact
+		}
+		if a.weight != b.weight {
+			return a.weight > b.weight
+		}
+		if len(a.Text) != len(b.Text) {
+			return len(a.Text) < len(b.Text)
+		}
+		return a.Text < b.Text
+	})
+
+	for _, m := range matches {
+		if len(found) == size {
+			break
+		}
+		found = append(found, m.Completion)
+	}
+	return found
+}
+
+// Complete completes the prefix from the catalog. The completions are
+// loaded in the background, and reloaded once they're older than
+// CompletionTTL. Until they're loaded, part numbers are completed from the
+// search index.
+func Complete(prefix string, brands []int, dtx *apicontext.DataContext, size int) ([]Completion, error) {
+	completionsMu.Lock()
+	idx := completions
+	reload := !completionReload && (idx == nil || time.Since(idx.Built) > CompletionTTL) && time.Since(completionTried) > CompletionRetry
+	completionsMu.Unlock()
+
+	if reload {
+		go func() {
+			if err := RefreshCompletions(); err != nil {
+				log.Printf("failed to reload search completions: %v", err)
+			}
+		}()
+	}
+
+	if idx == nil {
+		return suggestParts(prefix, brands, dtx, size)
+	}
+	return idx.Complete(prefix, brands, dtx, size), nil
+}
+
+// RefreshCompletions reloads the completions from the catalog, unless a
+// reload is already running.
+func RefreshCompletions() error {
+	completionsMu.Lock()
+	if completionReload {
+		completionsMu.Unlock()
+		return nil
+	}
+	completionReload, completionTried = true, time.Now()
+	completionsMu.Unlock()
+
+	idx, err := LoadCompletions()
+
+	completionsMu.Lock()
+	defer completionsMu.Unlock()
+	completionReload = false
+	if err != nil {
+		return err
+	}
+	completions = idx
+	return nil
+}
+
+// suggestParts completes part numbers of the brands from the suggest field
+// of the search index.
+func suggestParts(prefix string, brands []int, dtx *apicontext.DataContext, size int) ([]Completion, error) {
+	found := make([]Completion, 0)
+	key := products.AliasKey(prefix)
+	if key == "" || len(brands) == 0 {
+		return found, nil
+	}
+	if size <= 0 {
+		size = DefaultCompletions
+	}
+
+	brand := 0
+	if len(brands) == 1 {
+		brand = brands[0]
+	}
+	suggestions, err := Backend.Suggest(findIndex(brand, dtx), key, MaxCompletions)
+	if err != nil {
+		return nil, err
+	}
+
+	inBrands := make(map[int]bool, len(brands))
+	for _, b := range brands {
+		inBrands[b] = true
+	}
+	visibilities := make(map[string]bool)
+	for _, v := range products.Visibilities(dtx) {
+		visibilities[v] = true
+	}
+
+	for _, s := range suggestions {
+		if len(found) == size {
+			break
+		}
+		var payload SuggestPayload
+		if data, err := json.Marshal(s.Payload); err != nil || json.Unmarshal(data, &payload) != nil {
+			continue
+		}
+		if payload.Visibility == "" {
+			payload.Visibility = products.PUBLIC
+		}
+		if !inBrands[payload.Brand] || !visibilities[payload.Visibility] {
+			continue
+		}
+		found = append(found, Completion{Type: PartCompletion, Text: s.Text, ID: payload.ID})
+	}
+	return found, nil
+}
+
+// LoadCompletions builds a CompletionIndex from the active parts of every
+// brand.
+func LoadCompletions() (*CompletionIndex, error) {
+	if err := database.Init(); err != nil {
+		return nil, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	query := bson.M{
+		"status": bson.M{
+			"$in": products.ActiveStatuses(),
+		},
+	}
+	fields := bson.M{
+		"id": 1, "part_number": 1, "brand.id": 1, "web_visibility": 1,
+		"categories.id": 1, "categories.title": 1,
+		"vehicle_applications.make": 1, "vehicle_applications.model": 1, "aces_vehicles.base": 1,
+		"luverne_applications.make": 1, "luverne_applications.model": 1,
+	}
+
+	b := NewCompletionBuilder()
+	var p products.Part
+	iter := session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Select(fields).Iter()
+	for iter.Next(&p) {
+		b.Add(p)
+		p = products.Part{}
+	}
+	if err := iter.Close(); err != nil {
+		return nil, err
+	}
+	return b.Index(), nil
+}
+
+// wordKeys are the keys of a name starting at each of its words.
+func wordKeys(name string) []string {
+	ws := words(name)
+	keys := make([]string, 0, len(ws))
+	for i := range ws {
+		keys = append(keys, strings.Join(ws[i:], ""))
+	}
+	return keys
+}
+
+// aliasKeys are the keys of the aliases of a name.
+func aliasKeys(aliases map[string]string, name string) []string {
+	var keys []string
+	for alias, n := range aliases {
+		if strings.EqualFold(n, name) {
+			keys = append(keys, products.AliasKey(alias))
+		}
+	}
+	return keys
+}
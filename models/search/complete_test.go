@@ -0,0 +1,126 @@
+package search
+
+import (
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestCompletionIndex(t *testing.T) {
+	hitches := products.Category{CategoryID: 1, Title: "Trailer Hitches"}
+	wiring := products.Category{CategoryID: 2, Title: "Wiring"}
+	f150 := products.VehicleApplication{Year: "2015", Make: "Ford", Model: "F150"}
+
+	parts := []products.Part{
+		{ID: 1, PartNumber: "13000", Brand: brand.Brand{ID: 1}, Categories: []products.Category{hitches}, Vehicles: []products.VehicleApplication{f150}},
+		{ID: 2, PartNumber: "13001", Brand: brand.Brand{ID: 1}, Categories: []products.Category{hitches}},
+		{ID: 3, PartNumber: "130-02", Brand: brand.Brand{ID: 1}, WebVisibility: products.LOGGEDIN},
+		{ID: 4, PartNumber: "56000", Brand: brand.Brand{ID: 1}, Categories: []products.Category{wiring}, Vehicles: []products.VehicleApplication{
+			{Year: "2016", Make: "FORD", Model: "f-150"},
+		}},
+		{ID: 5, PartNumber: "2047", Brand: brand.Brand{ID: 3}, Categories: []products.Category{hitches}, AcesVehicles: []products.AcesVehicle{
+			{Base: products.BaseVehicle{Year: 2012, Make: "Chevrolet", Model: "Silverado 1500"}},
+		}},
+	}
+	idx := BuildCompletions(parts)
+
+	public := &apicontext.DataContext{KeyType: "Public"}
+	texts := func(cs []Completion) []string {
+		var found []string
+		for _, c := range cs {
+			found = append(found, c.Type+":"+c.Text)
+		}
+		return found
+	}
+
+	Convey("Testing CompletionIndex.Complete()", t, func() {
+		Convey("part numbers are matched without dashes", func() {
+			cs := idx.Complete("13-00", []int{1}, public, 10)
+			So(texts(cs), ShouldResemble, []string{"part:13000", "part:13001"})
+			So(cs[0].ID, ShouldEqual, 1)
+
+			cs = idx.Complete("13000", []int{1}, public, 10)
+			So(texts(cs), ShouldResemble, []string{"part:13000"})
+		})
+
+		Convey("hidden parts are only completed for the keys that see them", func() {
+			cs := idx.Complete("1300", []int{1}, &apicontext.DataContext{KeyType: "Internal"}, 10)
+			So(texts(cs), ShouldResemble, []string{"part:13000", "part:13001", "part:130-02"})
+		})
+
+		Convey("categories are matched at any word and ranked by parts", func() {
+			cs := idx.Complete("hitch", []int{1, 3}, public, 10)
+			So(texts(cs), ShouldResemble, []string{"category:Trailer Hitches"})
+
+			cs = idx.Complete("tr", []int{1}, public, 10)
+			So(texts(cs), ShouldResemble, []string{"category:Trailer Hitches"})
+		})
+
+		Convey("makes and models are normalized and matched through aliases", func() {
+			cs := idx.Complete("f", []int{1}, public, 10)
+			So(texts(cs), ShouldResemble, []string{"make:Ford", "model:Ford F-150"})
+			So(cs[1].Make, ShouldEqual, "Ford")
+			So(cs[1].Model, ShouldEqual, "F-150")
+
+			cs = idx.Complete("ford f1", []int{1}, public, 10)
+			So(texts(cs), ShouldResemble, []string{"model:Ford F-150"})
+
+			cs = idx.Complete("chevy silverado", []int{3}, public, 10)
+			So(texts(cs), ShouldResemble, []string{"model:Chevrolet Silverado 1500"})
+		})
+
+		Convey("completions are limited to the brands", func() {
+			So(idx.Complete("2047", []int{1}, public, 10), ShouldBeEmpty)
+			So(idx.Complete("2047", nil, public, 10), ShouldBeEmpty)
+			So(texts(idx.Complete("2047", []int{3}, public, 10)), ShouldResemble, []string{"part:2047"})
+		})
+
+		Convey("the count limits the completions", func() {
+			So(len(idx.Complete("1", []int{1}, public, 1)), ShouldEqual, 1)
+			So(idx.Complete("--", []int{1}, public, 1), ShouldBeEmpty)
+		})
+	})
+}
+
+func TestComplete(t *testing.T) {
+	parts := []products.Part{
+		{ID: 1, PartNumber: "13000", Brand: brand.Brand{ID: 1}},
+		{ID: 2, PartNumber: "130-01", Brand: brand.Brand{ID: 1}, WebVisibility: products.LOGGEDIN},
+		{ID: 3, PartNumber: "13002", Brand: brand.Brand{ID: 3}},
+	}
+	oldIndexes := BrandIndexes
+	BrandIndexes = map[int]string{1: "curt", 3: "aries"}
+	defer func() { BrandIndexes = oldIndexes }()
+
+	m := NewMemoryBackend()
+	for _, p := range parts {
+		m.Index(BrandIndexes[p.Brand.ID], Document{ID: p.PartNumber, Source: NewPartDocument(p)})
+	}
+
+	oldBackend, oldCompletions, oldTried := Backend, completions, completionTried
+	Backend, completions, completionTried = m, nil, time.Now()
+	defer func() { Backend, completions, completionTried = oldBackend, oldCompletions, oldTried }()
+
+	public := &apicontext.DataContext{KeyType: "Public", BrandArray: []int{1, 3}}
+
+	Convey("Testing Complete() before the completions are loaded", t, func() {
+		cs, err := Complete("130", []int{1}, public, 10)
+		So(err, ShouldBeNil)
+		So(cs, ShouldResemble, []Completion{{Type: PartCompletion, Text: "13000", ID: 1}})
+
+		cs, err = Complete("130", []int{1, 3}, public, 1)
+		So(err, ShouldBeNil)
+		So(len(cs), ShouldEqual, 1)
+
+		cs, err = Complete("130", []int{1}, &apicontext.DataContext{KeyType: "Internal", BrandArray: []int{1}}, 10)
+		So(err, ShouldBeNil)
+		So(len(cs), ShouldEqual, 2)
+
+		cs, err = Complete("130", nil, public, 10)
+		So(err, ShouldBeNil)
+		So(cs, ShouldBeEmpty)
+	})
+}
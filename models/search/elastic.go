@@ -0,0 +1,404 @@
+package search
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/curt-labs/API/helpers/apifilter"
+	"github.com/curt-labs/API/models/products"
+	elastic "gopkg.in/olivere/elastic.v2"
+)
+
+// ElasticBackend searches an Elasticsearch cluster. The hosts and
+// credentials are read from the environment the first time it's used when
+// they aren't set.
+type ElasticBackend struct {
+	Hosts    []string
+	User     string
+	Password string
+
+	mu     sync.Mutex
+	client *elastic.Client
+}
+
+// Client returns the client of the cluster, connecting on first use.
+func (b *ElasticBackend) Client() (*elastic.Client, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.client != nil {
+		return b.client, nil
+	}
+
+	if len(b.Hosts) == 0 {
+		b.Hosts = []string{"http://127.0.0.1:9200"}
+		if d := os.Getenv("ELASTICSEARCH_IP"); d != "" {
+			b.Hosts = []string{}
+			for _, u := range strings.Split(d, ",") {
+				b.Hosts = append(b.Hosts, fmt.Sprintf("http://%s:9200", u))
+			}
+		}
+		b.User = os.Getenv("ELASTIC_USER")
+		b.Password = os.Getenv("ELASTIC_PASS")
+	}
+
+	funcs := []elastic.ClientOptionFunc{
+		elastic.SetURL(b.Hosts...),
+		elastic.SetMaxRetries(10),
+	}
+	if b.User != "" && b.Password != "" {
+		funcs = append(funcs, elastic.SetBasicAuth(b.User, b.Password))
+	}
+
+	c, err := elastic.NewSimpleClient(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	b.client = c
+	return c, nil
+}
+
+func (b *ElasticBackend) Search(index string, q Query) (*SearchResult, error) {
+	if q.Text == "" {
+		return b.search(index, q, elastic.NewMatchAllQuery())
+	}
+	return b.search(index, q, elastic.NewQueryStringQuery(q.Text))
+}
+
+func (b *ElasticBackend) ExactAndClose(index string, q Query) (*SearchResult, error) {
+	if q.Text == "" {
+		return b.search(index, q, elastic.NewMatchAllQuery())
+	}
+	query := elastic.NewBoolQuery().
+		Must(elastic.NewMatchQuery("_all", q.Text)).
+		Should(elastic.NewMatchQuery("raw_part", q.Text).Boost(10))
+	return b.search(index, q, query)
+}
+
+func (b *ElasticBackend) search(index string, q Query, query elastic.Query) (*SearchResult, error) {
+	c, err := b.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	if filter, ok := scopeFilter(q); ok {
+		query = elastic.NewFilteredQuery(query).Filter(filter)
+	}
+	svc := c.Search(index).From(q.From).Size(q.Size).Query(query)
+	if filter, ok := facetFilter(q.Filters, ""); ok {
+		svc.PostFilter(filter)
+	}
+	for _, f := range q.Facets {
+		var agg elastic.Aggregation = elastic.NewTermsAggregation().Field(f.Field).Size(FacetSize)
+		if f.Key == PriceFacet {
+			agg = elastic.NewHistogramAggregation().Field(f.Field).Interval(PriceBand).MinDocCount(1)
+		}
+		var filter elastic.Filter = elastic.NewMatchAllFilter()
+		if others, ok := facetFilter(q.Filters, f.Key); ok {
+			filter = others
+		}
+		svc.Aggregation(f.Key, elastic.NewFilterAggregation().Filter(filter).SubAggregation(f.Key, agg))
+	}
+
+	res, err := svc.Do()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &SearchResult{
+		TookInMillis: res.TookInMillis,
+		TimedOut:     res.TimedOut,
+		Hits:         &SearchHits{Hits: []*SearchHit{}},
+	}
+	result.Facets = elasticFacets(res.Aggregations, q.Facets)
+	if res.Hits == nil {
+		return result, nil
+	}
+
+	result.Hits.TotalHits = res.Hits.TotalHits
+	result.Hits.MaxScore = res.Hits.MaxScore
+	for _, hit := range res.Hits.Hits {
+		result.Hits.Hits = append(result.Hits.Hits, &SearchHit{
+			Score:  hit.Score,
+			Index:  hit.Index,
+			Type:   hit.Type,
+			Id:     hit.Id,
+			Source: hit.Source,
+		})
+	}
+	return result, nil
+}
+
+// scopeFilter limits a search to the visibilities and vehicle of the
+// query. Facets are counted within it.
+func scopeFilter(q Query) (elastic.Filter, bool) {
+	var filters []elastic.Filter
+	if len(q.Visibilities) > 0 {
+		filters = append(filters, visibilityFilter(q.Visibilities))
+	}
+
+	if q.Vehicle != "" {
+		filters = append(filters, elastic.NewTermFilter("vehicles", q.Vehicle))
+	}
+
+	if len(filters) == 0 {
+		return nil, false
+	}
+	return elastic.NewBoolFilter().Must(filters...), true
+}
+
+// facetFilter limits the hits of a search to the facet filters but the
+// filter of the except facet. It's applied after the aggregations, so a
+// facet is counted under every filter but its own. Values of a facet are
+// alternatives; every facet must match.
+func facetFilter(values Filters, except string) (elastic.Filter, bool) {
+	var filters []elastic.Filter
+	for _, f := range Facets() {
+		vals := values[f.Key]
+		if len(vals) == 0 || f.Key == except {
+			continue
+		}
+		if f.Key == PriceFacet {
+			var bands []elastic.Filter
+			for _, v := range vals {
+				if low, high, ok := ParsePriceBand(v); ok {
+					bands = append(bands, elastic.NewRangeFilter(f.Field).Gte(low).Lt(high))
+				}
+			}
+			filters = append(filters, elastic.NewOrFilter(bands...))
+			continue
+		}
+		terms := make([]interface{}, 0, len(vals))
+		for _, v := range vals {
+			terms = append(terms, v)
+		}
+		filters = append(filters, elastic.NewTermsFilter(f.Field, terms...))
+	}
+
+	if len(filters) == 0 {
+		return nil, false
+	}
+	return elastic.NewBoolFilter().Must(filters...), true
+}
+
+// visibilityFilter matches the documents of the visibilities. Documents
+// without a web visibility are matched by their web flags, as
+// indexedVisibility reads them.
+func visibilityFilter(visibilities []string) elastic.Filter {
+	terms := make([]interface{}, 0, len(visibilities))
+	for _, v := range visibilities {
+		terms = append(terms, v)
+	}
+	visible := []elastic.Filter{elastic.NewTermsFilter("web_visibility", terms...)}
+
+	legacy := elastic.NewMissingFilter("web_visibility")
+	loggedIn := elastic.NewTermFilter("showForLoggedIn", true)
+	disabled := elastic.NewTermFilter("showOnWebsite", false)
+	for _, v := range visibilities {
+		switch v {
+		case products.PUBLIC:
+			visible = append(visible, elastic.NewBoolFilter().Must(legacy).MustNot(loggedIn, disabled))
+		case products.LOGGEDIN:
+			visible = append(visible, elastic.NewBoolFilter().Must(legacy, loggedIn))
+		case products.DISABLED:
+			visible = append(visible, elastic.NewBoolFilter().Must(legacy, disabled).MustNot(loggedIn))
+		}
+	}
+	return elastic.NewOrFilter(visible...)
+}
+
+// elasticFacets reads the facet counts out of the aggregations of a search,
+// each under the filter aggregation of its facet.
+func elasticFacets(res elastic.Aggregations, facets []Facet) []apifilter.Options {
+	var options []apifilter.Options
+	for _, f := range facets {
+		opts := apifilter.Options{Key: f.Key, Options: []apifilter.Option{}}
+		filtered, ok := res.Filter(f.Key)
+		if !ok {
+			options = append(options, opts)
+			continue
+		}
+		aggs := filtered.Aggregations
+		if f.Key == PriceFacet {
+			if h, ok := aggs.Histogram(f.Key); ok {
+				for _, b := range h.Buckets {
+					opts.Options = append(opts.Options, apifilter.Option{
+						Value: PriceBandOf(float64(b.Key)),
+						Count: int(b.DocCount),
+					})
+				}
+			}
+		} else if t, ok := aggs.Terms(f.Key); ok {
+			for _, b := range t.Buckets {
+				opts.Options = append(opts.Options, apifilter.Option{
+					Value: fmt.Sprint(b.Key),
+					Count: int(b.DocCount),
+				})
+			}
+		}
+		sortOptions(f.Key, opts.Options)
+		options = append(options, opts)
+	}
+	return options
+}
+
+// Suggest completes the prefix with the completion suggester of the
+// suggest field.
+func (b *ElasticBackend) Suggest(index, prefix string, size int) ([]Suggestion, error) {
+	c, err := b.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	suggester := elastic.NewCompletionSuggester(SuggestField).Field(SuggestField).Text(prefix).Size(size)
+	res, err := c.Suggest(index).Suggester(suggester).Do()
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]Suggestion, 0, size)
+	for _, s := range res[SuggestField] {
+		for _, opt := range s.Options {
+			suggestions = append(suggestions, Suggestion{
+				Text:    opt.Text,
+				Score:   float64(opt.Score),
+				Payload: opt.Payload,
+			})
+		}
+	}
+	return suggestions, nil
+}
+
+func (b *ElasticBackend) Index(index string, docs ...Document) error {
+	if len(docs) == 0 {
+		return nil
+	}
+	c, err := b.Client()
+	if err != nil {
+		return err
+	}
+
+	bulk := c.Bulk()
+	for _, doc := range docs {
+		typ := doc.Type
+		if typ == "" {
+			typ = DocumentType
+		}
+		bulk.Add(elastic.NewBulkIndexRequest().Index(index).Type(typ).Id(doc.ID).Doc(doc.Source))
+	}
+	return bulkError(bulk.Do())
+}
+
+func (b *ElasticBackend) Delete(index string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	c, err := b.Client()
+	if err != nil {
+		return err
+	}
+
+	bulk := c.Bulk()
+	for _, id := range ids {
+		bulk.Add(elastic.NewBulkDeleteRequest().Index(index).Type(DocumentType).Id(id))
+	}
+	return bulkError(bulk.Do())
+}
+
+func (b *ElasticBackend) CreateIndex(index string, body interface{}) error {
+	c, err := b.Client()
+	if err != nil {
+		return err
+	}
+
+	res, err := c.CreateIndex(index).BodyJson(body).Do()
+	if err != nil {
+		return err
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("creating index %s was not acknowledged", index)
+	}
+	return nil
+}
+
+func (b *ElasticBackend) DeleteIndex(indexes ...string) error {
+	c, err := b.Client()
+	if err != nil {
+		return err
+	}
+
+	for _, index := range indexes {
+		if _, err = c.DeleteIndex(index).Do(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *ElasticBackend) IndexExists(name string) (bool, error) {
+	c, err := b.Client()
+	if err != nil {
+		return false, err
+	}
+	return c.IndexExists(name).Do()
+}
+
+func (b *ElasticBackend) Aliases(alias string) ([]string, error) {
+	c, err := b.Client()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := c.Aliases().Do()
+	if err != nil {
+		return nil, err
+	}
+	indexes := res.IndicesByAlias(alias)
+	sort.Strings(indexes)
+	return indexes, nil
+}
+
+func (b *ElasticBackend) UpdateAliases(actions ...AliasAction) error {
+	if len(actions) == 0 {
+		return nil
+	}
+	c, err := b.Client()
+	if err != nil {
+		return err
+	}
+
+	svc := c.Alias()
+	for _, a := range actions {
+		if a.Remove {
+			svc.Remove(a.Index, a.Alias)
+		} else {
+			svc.Add(a.Index, a.Alias)
+		}
+	}
+	res, err := svc.Do()
+	if err != nil {
+		return err
+	}
+	if !res.Acknowledged {
+		return fmt.Errorf("updating aliases was not acknowledged")
+	}
+	return nil
+}
+
+// bulkError returns the first failure of a bulk request. Deleting a
+// document that isn't there isn't a failure.
+func bulkError(res *elastic.BulkResponse, err error) error {
+	if err != nil || res == nil {
+		return err
+	}
+	for _, item := range res.Failed() {
+		if item.Status == 404 && item.Error == "" {
+			continue
+		}
+		return fmt.Errorf("failed to index %s: %s", item.Id, item.Error)
+	}
+	return nil
+}
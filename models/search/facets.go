@@ -0,0 +1,143 @@
+package search
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/curt-labs/API/helpers/apifilter"
+)
+
+const (
+	// PriceFacet is the key of the list price facet. Its values are bands
+	// of PriceBand dollars, like the price filters of categories.
+	PriceFacet = "Price"
+
+	// PriceBand is the width of the price bands.
+	PriceBand = 50
+
+	// FacetSize is the most values returned for a facet.
+	FacetSize = 25
+)
+
+// Facet is a field of the search documents that results are counted and
+// filtered by. Key is the key of the facet's apifilter.Options.
+type Facet struct {
+	Key   string
+	Field string
+}
+
+var (
+	// DefaultFacets are the facets of every search, ahead of the attribute
+	// facets.
+	DefaultFacets = []Facet{
+		{Key: "Brand", Field: "brand.name.raw"},
+		{Key: "Category", Field: "categories.title.raw"},
+		{Key: "Class", Field: "class.name.raw"},
+		{Key: PriceFacet, Field: "price"},
+	}
+
+	// FacetAttributes are the part attributes searches are faceted by.
+	FacetAttributes = []string{"Finish", "Color", "Location"}
+)
+
+// Facets returns DefaultFacets followed by a facet per FacetAttributes.
+func Facets() []Facet {
+	facets := append([]Facet(nil), DefaultFacets...)
+	for _, attr := range FacetAttributes {
+		facets = append(facets, Facet{Key: attr, Field: "facets." + FacetName(attr)})
+	}
+	return facets
+}
+
+// Filters are the values of facets a search is limited to, by facet key.
+// A result must have one of the values of every facet.
+type Filters map[string][]string
+
+// ParseFilters reads facet filters like "Finish:Black" or "Price:$50 - $100".
+// Keys are matched to Facets without regard to case.
+func ParseFilters(values []string) (Filters, error) {
+	filters := make(Filters)
+	for _, v := range values {
+		parts := strings.SplitN(v, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid facet filter %q, expected key:value", v)
+		}
+
+		facet, ok := findFacet(strings.TrimSpace(parts[0]))
+		if !ok {
+			return nil, fmt.Errorf("unknown facet %q", parts[0])
+		}
+		value := strings.TrimSpace(parts[1])
+		if facet.Key == PriceFacet {
+			if _, _, ok := ParsePriceBand(value); !ok {
+				return nil, fmt.Errorf("invalid price band %q", value)
+			}
+		}
+		filters[facet.Key] = append(filters[facet.Key], value)
+	}
+	return filters, nil
+}
+
+func findFacet(key string) (Facet, bool) {
+	for _, f := range Facets() {
+		if strings.EqualFold(f.Key, key) {
+			return f, true
+		}
+	}
+	return Facet{}, false
+}
+
+// PriceBandOf returns the band of the price, like "$50 - $100".
+func PriceBandOf(price float64) string {
+	low := (int(price) / PriceBand) * PriceBand
+	return fmt.Sprintf("$%d - $%d", low, low+PriceBand)
+}
+
+// ParsePriceBand returns the bounds of a band like "$50 - $100". Prices
+// from low up to, but not including, high are in the band.
+func ParsePriceBand(band string) (low, high float64, ok bool) {
+	segs := strings.Split(strings.Replace(band, "$", "", -1), "-")
+	if len(segs) != 2 {
+		return 0, 0, false
+	}
+	low, err := strconv.ParseFloat(strings.TrimSpace(segs[0]), 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	high, err = strconv.ParseFloat(strings.TrimSpace(segs[1]), 64)
+	if err != nil || high <= low {
+		return 0, 0, false
+	}
+	return low, high, true
+}
+
+// markSelected selects the options of the facets that are filtered on.
+func markSelected(facets []apifilter.Options, filters Filters) {
+	for i := range facets {
+		for j := range facets[i].Options {
+			for _, v := range filters[facets[i].Key] {
+				if v == facets[i].Options[j].Value {
+					facets[i].Options[j].Selected = true
+				}
+			}
+		}
+	}
+}
+
+// sortOptions orders price bands from cheapest and other values by count,
+// most first.
+func sortOptions(key string, opts []apifilter.Option) {
+	sort.SliceStable(opts, func(i, j int) bool {
+		if key == PriceFacet {
+			a, _, _ := ParsePriceBand(opts[i].Value)
+			b, _, _ := ParsePriceBand(opts[j].Value)
+			return a < b
+		}
+		if opts[i].Count != opts[j].Count {
+			return opts[i].Count > opts[j].Count
+		}
+		return opts[i].Value < opts[j].Value
+	})
+}
@@ -0,0 +1,123 @@
+package search
+
+import (
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/apifilter"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"strconv"
+	"testing"
+)
+
+func TestFacets(t *testing.T) {
+	part := func(id int, class, finish string, price float64, visibility string) Document {
+		p := products.Part{
+			ID:            id,
+			PartNumber:    strconv.Itoa(id),
+			Status:        800,
+			ShortDesc:     "Trailer Hitch",
+			WebVisibility: visibility,
+			Brand:         brand.Brand{ID: 1, Name: "CURT"},
+			Class:         products.Class{Name: class},
+			Categories:    []products.Category{{CategoryID: 1, Title: "Trailer Hitches"}},
+			Attributes:    []products.Attribute{{Key: "Finish", Value: finish}},
+			Pricing:       []products.Price{{Type: "List", Price: price}},
+		}
+		return Document{ID: p.PartNumber, Source: NewPartDocument(p)}
+	}
+
+	m := NewMemoryBackend()
+	m.Index("curt",
+		part(1, "Class 3", "Black", 199.99, ""),
+		part(2, "Class 3", "Black", 210, ""),
+		part(3, "Class 4", "Chrome", 120, ""),
+		part(4, "Class 4", "Chrome", 130, products.LOGGEDIN),
+	)
+
+	old := Backend
+	Backend = m
+	defer func() { Backend = old }()
+
+	public := &apicontext.DataContext{KeyType: "Public", BrandArray: []int{1}}
+	facet := func(res *SearchResult, key string) apifilter.Options {
+		for _, f := range res.Facets {
+			if f.Key == key {
+				return f
+			}
+		}
+		return apifilter.Options{}
+	}
+
+	Convey("Testing ParseFilters()", t, func() {
+		filters, err := ParseFilters([]string{"finish:Black", "Finish: Chrome", "price:$100 - $150"})
+		So(err, ShouldBeNil)
+		So(filters, ShouldResemble, Filters{
+			"Finish": {"Black", "Chrome"},
+			"Price":  {"$100 - $150"},
+		})
+
+		_, err = ParseFilters([]string{"Finish"})
+		So(err, ShouldNotBeNil)
+		_, err = ParseFilters([]string{"Weight:10"})
+		So(err, ShouldNotBeNil)
+		_, err = ParseFilters([]string{"Price:cheap"})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Testing price bands", t, func() {
+		So(PriceBandOf(199.99), ShouldEqual, "$150 - $200")
+		So(PriceBandOf(200), ShouldEqual, "$200 - $250")
+		low, high, ok := ParsePriceBand("$150 - $200")
+		So(ok, ShouldBeTrue)
+		So(low, ShouldEqual, 150)
+		So(high, ShouldEqual, 200)
+	})
+
+	Convey("Testing Dsl() facets", t, func() {
+		res, err := Dsl("hitch", 0, 0, 0, public, "", nil, false)
+		So(err, ShouldBeNil)
+		So(res.Hits.TotalHits, ShouldEqual, 3)
+		So(len(res.Facets), ShouldEqual, len(Facets()))
+		So(facet(res, "Brand").Options, ShouldResemble, []apifilter.Option{{Value: "CURT", Count: 3}})
+		So(facet(res, "Category").Options, ShouldResemble, []apifilter.Option{{Value: "Trailer Hitches", Count: 3}})
+		So(facet(res, "Class").Options, ShouldResemble, []apifilter.Option{
+			{Value: "Class 3", Count: 2},
+			{Value: "Class 4", Count: 1},
+		})
+		So(facet(res, "Finish").Options, ShouldResemble, []apifilter.Option{
+			{Value: "Black", Count: 2},
+			{Value: "Chrome", Count: 1},
+		})
+		So(facet(res, "Price").Options, ShouldResemble, []apifilter.Option{
+			{Value: "$100 - $150", Count: 1},
+			{Value: "$150 - $200", Count: 1},
+			{Value: "$200 - $250", Count: 1},
+		})
+		So(facet(res, "Color").Options, ShouldBeEmpty)
+
+		res, err = Dsl("hitch", 0, 0, 0, public, "", Filters{"Finish": {"Black"}, "Price": {"$200 - $250", "$100 - $150"}}, false)
+		So(err, ShouldBeNil)
+		So(res.Hits.TotalHits, ShouldEqual, 1)
+		So(res.Hits.Hits[0].Id, ShouldEqual, "2")
+		So(facet(res, "Finish").Options, ShouldResemble, []apifilter.Option{
+			{Value: "Black", Selected: true, Count: 1},
+			{Value: "Chrome", Count: 1},
+		})
+		So(facet(res, "Price").Options, ShouldResemble, []apifilter.Option{
+			{Value: "$150 - $200", Count: 1},
+			{Value: "$200 - $250", Selected: true, Count: 1},
+		})
+		So(facet(res, "Class").Options, ShouldResemble, []apifilter.Option{{Value: "Class 3", Count: 1}})
+
+		internal := &apicontext.DataContext{KeyType: "Internal", BrandArray: []int{1}}
+		res, err = ExactAndCloseDsl("hitch", 0, 0, 0, internal, Filters{"Class": {"Class 4"}}, false)
+		So(err, ShouldBeNil)
+		So(res.Hits.TotalHits, ShouldEqual, 2)
+		So(facet(res, "Class").Options, ShouldResemble, []apifilter.Option{
+			{Value: "Class 3", Count: 2},
+			{Value: "Class 4", Selected: true, Count: 2},
+		})
+		So(facet(res, "Finish").Options, ShouldResemble, []apifilter.Option{{Value: "Chrome", Count: 2}})
+	})
+}
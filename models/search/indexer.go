@@ -0,0 +1,644 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/helpers/redis"
+	"github.com/curt-labs/API/models/products"
+	"github.com/curt-labs/API/models/vehicle"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	// IndexedKey prefixes the redis key of the last time the parts of an
+	// index were synced.
+	IndexedKey = "search:indexed"
+
+	// DefaultBatchSize is how many parts are sent to the backend at once.
+	DefaultBatchSize = 500
+)
+
+// Mapping is the settings and mappings of the part indexes. Part numbers are
+// analyzed down to their lowercase letters and digits, so a search for
+// "13-000" matches part 13000 exactly. Attributes are also indexed
+// untouched under facets, by attribute name.
+var Mapping = map[string]interface{}{
+	"settings": map[string]interface{}{
+		"analysis": map[string]interface{}{
+			"char_filter": map[string]interface{}{
+				"alphanumeric": map[string]interface{}{
+					"type":        "pattern_replace",
+					"pattern":     "[^A-Za-z0-9]",
+					"replacement": "",
+				},
+			},
+			"analyzer": map[string]interface{}{
+				"part_number": map[string]interface{}{
+					"type":        "custom",
+					"tokenizer":   "keyword",
+					"char_filter": []string{"alphanumeric"},
+					"filter":      []string{"lowercase"},
+				},
+			},
+		},
+	},
+	"mappings": map[string]interface{}{
+		DocumentType: map[string]interface{}{
+			"dynamic_templates": []interface{}{
+				map[string]interface{}{
+					"facets": map[string]interface{}{
+						"path_match": "facets.*",
+						"mapping":    notAnalyzed,
+					},
+				},
+			},
+			"properties": map[string]interface{}{
+				"id":          map[string]interface{}{"type": "integer"},
+				"part_number": map[string]interface{}{"type": "string"},
+				"raw_part":    map[string]interface{}{"type": "string", "analyzer": "part_number"},
+				"brand": map[string]interface{}{
+					"properties": map[string]interface{}{
+						"id":   map[string]interface{}{"type": "integer"},
+						"name": withRaw,
+					},
+				},
+				"status":            map[string]interface{}{"type": "integer"},
+				"web_visibility":    notAnalyzed,
+				"short_description": map[string]interface{}{"type": "string"},
+				"attributes": map[string]interface{}{
+					"properties": map[string]interface{}{
+						"name":  map[string]interface{}{"type": "string"},
+						"value": map[string]interface{}{"type": "string"},
+					},
+				},
+				"categories": map[string]interface{}{
+					"properties": map[string]interface{}{
+						"id":    map[string]interface{}{"type": "integer"},
+						"title": withRaw,
+					},
+				},
+				"class": map[string]interface{}{
+					"properties": map[string]interface{}{
+						"id":   map[string]interface{}{"type": "integer"},
+						"name": withRaw,
+					},
+				},
+				"price":         map[string]interface{}{"type": "double"},
+				"fitment":       map[string]interface{}{"type": "string"},
+				"vehicles":      notAnalyzed,
+				"date_modified": map[string]interface{}{"type": "date"},
+				SuggestField: map[string]interface{}{
+					"type":     "completion",
+					"analyzer": "part_number",
+					"payloads": true,
+				},
+			},
+		},
+	},
+}
+
+var (
+	notAnalyzed = map[string]interface{}{"type": "string", "index": "not_analyzed"}
+
+	// withRaw is a string that is also indexed untouched as raw, for
+	// filters and facets.
+	withRaw = map[string]interface{}{
+		"type": "string",
+		"fields": map[string]interface{}{
+			"raw": notAnalyzed,
+		},
+	}
+)
+
+// PartDocument is the search document of a part: the part's JSON, without
+// its vehicle applications, plus the fields the indexes search and filter
+// on.
+type PartDocument struct {
+	products.Part
+	WebVisibility string              `json:"web_visibility"`
+	RawPart       string              `json:"raw_part"`
+	Price         float64             `json:"price"`
+	Facets        map[string][]string `json:"facets"`
+	Fitment       []string            `json:"fitment"`
+	VehicleKeys   []string            `json:"vehicles"`
+	Suggest       PartSuggest         `json:"suggest"`
+}
+
+// PartSuggest completes the part number of a document.
+type PartSuggest struct {
+	Input   []string       `json:"input"`
+	Output  string         `json:"output"`
+	Payload SuggestPayload `json:"payload"`
+}
+
+// SuggestPayload is what a part number completion needs to be checked
+// against the brands and visibilities of a caller.
+type SuggestPayload struct {
+	ID         int    `json:"id"`
+	Brand      int    `json:"brand"`
+	Visibility string `json:"visibility"`
+}
+
+// NewPartDocument builds the search document of the part.
+func NewPartDocument(p products.Part) PartDocument {
+	p.SetWebFlags()
+	doc := PartDocument{
+		Part:          p,
+		WebVisibility: p.WebVisibility,
+		RawPart:       p.PartNumber,
+		Facets:        make(map[string][]string),
+		Fitment:       make([]string, 0),
+		VehicleKeys:   make([]string, 0),
+		Suggest: PartSuggest{
+			Input:   []string{p.PartNumber},
+			Output:  p.PartNumber,
+			Payload: SuggestPayload{ID: p.ID, Brand: p.Brand.ID},
+		},
+	}
+	if doc.WebVisibility == "" {
+		doc.WebVisibility = products.PUBLIC
+	}
+	doc.Suggest.Payload.Visibility = doc.WebVisibility
+	if key := products.AliasKey(p.PartNumber); key != "" && key != p.PartNumber {
+		doc.Suggest.Input = append(doc.Suggest.Input, key)
+	}
+
+	for _, pr := range p.Pricing {
+		if strings.EqualFold(pr.Type, "List") {
+			doc.Price = pr.Price
+			break
+		}
+	}
+
+	for _, a := range p.Attributes {
+		name, value := FacetName(a.Key), strings.TrimSpace(a.Value)
+		if name == "" || value == "" {
+			continue
+		}
+		doc.Facets[name] = appendUnique(doc.Facets[name], value)
+	}
+
+	doc.Fitment, doc.VehicleKeys = partFitment(p)
+	doc.Part.Vehicles = nil
+	doc.Part.AcesVehicles = nil
+	doc.Part.LuverneVehicles = nil
+
+	return doc
+}
+
+// FacetName is the name of an attribute under the facets of a document: its
+// lowercase letters and digits.
+func FacetName(attribute string) string {
+	return products.AliasKey(attribute)
+}
+
+// VehicleKey is how a vehicle is indexed under the vehicles of a document.
+// The make and model are resolved through the vehicle aliases and reduced
+// to their lowercase letters and digits. Parts are indexed under the make,
+// the make and model, the year and make and the year, make and model of
+// each vehicle; a year of zero leaves it out.
+func VehicleKey(year int, vehicleMake, model string) string {
+	model = products.NormalizeModel(vehicleMake, model)
+	key := products.AliasKey(products.NormalizeMake(vehicleMake))
+	if model != "" {
+		key += "|" + products.AliasKey(model)
+	}
+	if year != 0 {
+		key = strconv.Itoa(year) + "|" + key
+	}
+	return key
+}
+
+// partFitment returns the fitment text of a part, a line like
+// "2015-2017 Ford F-150 SuperCrew" per vehicle, and the keys of its
+// vehicles.
+func partFitment(p products.Part) ([]string, []string) {
+	type fit struct {
+		make, model, style string
+		years              []int
+	}
+	vehicles := make(map[string]*fit)
+	var order []string
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+
+	add := func(year int, vehicleMake, model, style string) {
+		vehicleMake = strings.TrimSpace(vehicleMake)
+		if vehicleMake == "" {
+			return
+		}
+		model = products.NormalizeModel(vehicleMake, model)
+		vehicleMake = products.NormalizeMake(vehicleMake)
+		style = strings.Join(strings.Fields(style), " ")
+
+		for _, k := range []string{VehicleKey(0, vehicleMake, ""), VehicleKey(0, vehicleMake, model), VehicleKey(year, vehicleMake, ""), VehicleKey(year, vehicleMake, model)} {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+
+		id := strings.ToLower(vehicleMake + "|" + model + "|" + style)
+		v, ok := vehicles[id]
+		if !ok {
+			v = &fit{make: vehicleMake, model: model, style: style}
+			vehicles[id] = v
+			order = append(order, id)
+		}
+		if year != 0 {
+			v.years = append(v.years, year)
+		}
+	}
+
+	for _, app := range p.Vehicles {
+		year, _ := strconv.Atoi(strings.TrimSpace(app.Year))
+		add(year, app.Make, app.Model, app.Style)
+	}
+	for _, av := range p.AcesVehicles {
+		add(av.Base.Year, av.Base.Make, av.Base.Model, av.Submodel)
+	}
+	for _, app := range p.LuverneVehicles {
+		year, _ := strconv.Atoi(strings.TrimSpace(app.Year))
+		add(year, app.Make, app.Model, app.Body)
+	}
+
+	fitment := make([]string, 0, len(order))
+	for _, id := range order {
+		v := vehicles[id]
+		line := strings.Join(strings.Fields(strings.Join([]string{vehicle.JoinYearRanges(vehicle.YearRanges(v.years)), v.make, v.model, v.style}, " ")), " ")
+		fitment = append(fitment, line)
+	}
+	return fitment, keys
+}
+
+func appendUnique(list []string, val string) []string {
+	for _, v := range list {
+		if strings.EqualFold(v, val) {
+			return list
+		}
+	}
+	return append(list, val)
+}
+
+// PartSource calls each with the parts of the brand modified after since,
+// whatever their status, or with every active part of the brand when since
+// is zero.
+type PartSource func(brand int, since time.Time, each func(products.Part) error) error
+
+// IndexReport is the outcome of indexing the parts of a brand.
+type IndexReport struct {
+	Brand   int    `json:"brand"`
+	Alias   string `json:"alias"`
+	Index   string `json:"index"`
+	Full    bool   `json:"full"`
+	Indexed int    `json:"indexed"`
+	Deleted int    `json:"deleted"`
+}
+
+// Indexer keeps the search indexes of the brands in BrandIndexes in sync
+// with the catalog. A full reindex builds a new index per brand and swaps
+// it in behind the brand's alias and AllIndex in one step; an update
+// indexes the parts modified since the last sync through the aliases.
+type Indexer struct {
+	// Backend defaults to Backend.
+	Backend SearchBackend
+
+	// Parts defaults to the parts of the product database.
+	Parts PartSource
+
+	// BatchSize defaults to DefaultBatchSize.
+	BatchSize int
+
+	// Now defaults to time.Now.
+	Now func() time.Time
+
+	synced map[string]time.Time
+}
+
+// Reindex builds a new index of the parts of each brand, every brand in
+// BrandIndexes when none are given, and swaps the indexes in. The old
+// indexes are removed.
+//
+// Indexes with the name of an alias, left from before the indexes were
+// aliased, are deleted just before the swap, since an alias can't take the
+// name of an index. A legacy AllIndex is only replaced when every brand is
+// reindexed; until then it keeps serving searches across brands and the
+// new indexes are only aliased to their brands.
+func (i *Indexer) Reindex(brands ...int) ([]IndexReport, error) {
+	i.defaults()
+	if len(brands) == 0 {
+		brands = registeredBrands()
+	}
+
+	started := i.Now()
+	stamp := started.UTC().Format("20060102150405")
+
+	var reports []IndexReport
+	var created, old []string
+	cleanup := func() {
+		if len(created) > 0 {
+			i.Backend.DeleteIndex(created...)
+		}
+	}
+
+	for _, brand := range brands {
+		alias, ok := BrandIndexes[brand]
+		if !ok {
+			cleanup()
+			return reports, fmt.Errorf("brand %d doesn't have a search index", brand)
+		}
+
+		index := alias + "_" + stamp
+		if err := i.Backend.CreateIndex(index, Mapping); err != nil {
+			cleanup()
+			return reports, err
+		}
+		created = append(created, index)
+
+		report := IndexReport{Brand: brand, Alias: alias, Index: index, Full: true}
+		if err := i.index(index, brand, time.Time{}, &report); err != nil {
+			cleanup()
+			return reports, err
+		}
+		reports = append(reports, report)
+	}
+
+	var legacy []string
+	for _, name := range aliasesOf(reports) {
+		unaliased, err := i.unaliasedIndex(name)
+		if err != nil {
+			cleanup()
+			return reports, err
+		}
+		if unaliased {
+			legacy = append(legacy, name)
+		}
+	}
+	legacyAll, err := i.unaliasedIndex(AllIndex)
+	if err != nil {
+		cleanup()
+		return reports, err
+	}
+	withAll := !legacyAll || everyBrand(brands)
+	if legacyAll && withAll {
+		legacy = append(legacy, AllIndex)
+	}
+
+	var actions []AliasAction
+	for _, r := range reports {
+		previous, err := i.Backend.Aliases(r.Alias)
+		if err != nil {
+			cleanup()
+			return reports, err
+		}
+		for _, p := range previous {
+			actions = append(actions, AliasAction{Alias: r.Alias, Index: p, Remove: true})
+			if !legacyAll {
+				actions = append(actions, AliasAction{Alias: AllIndex, Index: p, Remove: true})
+			}
+		}
+		actions = append(actions, AliasAction{Alias: r.Alias, Index: r.Index})
+		if withAll {
+			actions = append(actions, AliasAction{Alias: AllIndex, Index: r.Index})
+		}
+		old = append(old, previous...)
+	}
+
+	if len(legacy) > 0 {
+		if err := i.Backend.DeleteIndex(legacy...); err != nil {
+			cleanup()
+			return reports, err
+		}
+	}
+	if err := i.Backend.UpdateAliases(actions...); err != nil {
+		if len(legacy) > 0 {
+			// the legacy indexes are gone, so keep the new ones around to
+			// be aliased by hand rather than leave nothing to search
+			return reports, fmt.Errorf("removed legacy indexes %s but failed to alias %s: %v", strings.Join(legacy, ", "), strings.Join(created, ", "), err)
+		}
+		cleanup()
+		return reports, err
+	}
+	for _, r := range reports {
+		i.synced[r.Alias] = started
+		redis.Set(IndexedKey+":"+r.Alias, started)
+	}
+
+	if len(old) > 0 {
+		return reports, i.Backend.DeleteIndex(old...)
+	}
+	return reports, nil
+}
+
+// Update indexes the parts of the brand modified after since, and removes
+// the ones that are no longer active.
+func (i *Indexer) Update(brand int, since time.Time) (IndexReport, error) {
+	i.defaults()
+	alias, ok := BrandIndexes[brand]
+	if !ok {
+		return IndexReport{Brand: brand}, fmt.Errorf("brand %d doesn't have a search index", brand)
+	}
+	if since.IsZero() {
+		return IndexReport{Brand: brand, Alias: alias}, fmt.Errorf("an update needs the time of the last sync")
+	}
+
+	report := IndexReport{Brand: brand, Alias: alias, Index: alias}
+	err := i.index(alias, brand, since, &report)
+	return report, err
+}
+
+// Sync updates the index of each brand in BrandIndexes with the parts
+// modified since its last sync. Brands that have never been synced, or
+// whose alias is missing, are reindexed.
+func (i *Indexer) Sync() ([]IndexReport, error) {
+	i.defaults()
+
+	var full []int
+	var result []IndexReport
+	for _, brand := range registeredBrands() {
+		alias := BrandIndexes[brand]
+		since := i.lastSynced(alias)
+		exists, err := i.Backend.IndexExists(alias)
+		if err != nil {
+			return result, err
+		}
+		if since.IsZero() || !exists {
+			full = append(full, brand)
+			continue
+		}
+
+		started := i.Now()
+		report, err := i.Update(brand, since)
+		result = append(result, report)
+		if err != nil {
+			return result, err
+		}
+		i.synced[alias] = started
+		redis.Set(IndexedKey+":"+alias, started)
+	}
+
+	if len(full) > 0 {
+		r, err := i.Reindex(full...)
+		result = append(result, r...)
+		if err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// index sends the parts of the brand modified after since to the index in
+// batches, deleting the ones that aren't active.
+func (i *Indexer) index(index string, brand int, since time.Time, report *IndexReport) error {
+	active := make(map[int]bool)
+	for _, s := range products.ActiveStatuses() {
+		active[s] = true
+	}
+
+	var docs []Document
+	var deletes []string
+	flush := func() error {
+		if err := i.Backend.Index(index, docs...); err != nil {
+			return err
+		}
+		if err := i.Backend.Delete(index, deletes...); err != nil {
+			return err
+		}
+		report.Indexed += len(docs)
+		report.Deleted += len(deletes)
+		docs, deletes = docs[:0], deletes[:0]
+		return nil
+	}
+
+	err := i.Parts(brand, since, func(p products.Part) error {
+		id := strconv.Itoa(p.ID)
+		if !active[p.Status] {
+			if !since.IsZero() {
+				deletes = append(deletes, id)
+			}
+		} else {
+			docs = append(docs, Document{ID: id, Type: DocumentType, Source: NewPartDocument(p)})
+		}
+		if len(docs)+len(deletes) >= i.BatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return flush()
+}
+
+// unaliasedIndex reports whether there's an index, rather than an alias,
+// with the name of an alias.
+func (i *Indexer) unaliasedIndex(name string) (bool, error) {
+	exists, err := i.Backend.IndexExists(name)
+	if err != nil || !exists {
+		return false, err
+	}
+	aliased, err := i.Backend.Aliases(name)
+	if err != nil {
+		return false, err
+	}
+	return len(aliased) == 0, nil
+}
+
+// lastSynced returns when the parts of the alias were last synced, from
+// this indexer or from redis.
+func (i *Indexer) lastSynced(alias string) time.Time {
+	if t, ok := i.synced[alias]; ok {
+		return t
+	}
+
+	var t time.Time
+	data, err := redis.Get(IndexedKey + ":" + alias)
+	if err == nil && len(data) > 0 {
+		json.Unmarshal(data, &t)
+	}
+	return t
+}
+
+func (i *Indexer) defaults() {
+	if i.Backend == nil {
+		i.Backend = Backend
+	}
+	if i.Parts == nil {
+		i.Parts = catalogParts
+	}
+	if i.BatchSize <= 0 {
+		i.BatchSize = DefaultBatchSize
+	}
+	if i.Now == nil {
+		i.Now = time.Now
+	}
+	if i.synced == nil {
+		i.synced = make(map[string]time.Time)
+	}
+}
+
+// registeredBrands returns the brands of BrandIndexes in order.
+func registeredBrands() []int {
+	brands := make([]int, 0, len(BrandIndexes))
+	for b := range BrandIndexes {
+		brands = append(brands, b)
+	}
+	sort.Ints(brands)
+	return brands
+}
+
+// everyBrand reports whether the brands include every brand in
+// BrandIndexes.
+func everyBrand(brands []int) bool {
+	given := make(map[int]bool, len(brands))
+	for _, b := range brands {
+		given[b] = true
+	}
+	for b := range BrandIndexes {
+		if !given[b] {
+			return false
+		}
+	}
+	return true
+}
+
+func aliasesOf(reports []IndexReport) []string {
+	aliases := make([]string, 0, len(reports))
+	for _, r := range reports {
+		aliases = append(aliases, r.Alias)
+	}
+	return aliases
+}
+
+// catalogParts streams the parts of the brand from the product database.
+func catalogParts(brand int, since time.Time, each func(products.Part) error) error {
+	if err := database.Init(); err != nil {
+		return err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	query := bson.M{"brand.id": brand}
+	if since.IsZero() {
+		query["status"] = bson.M{"$in": products.ActiveStatuses()}
+	} else {
+		query["date_modified"] = bson.M{"$gt": since}
+	}
+
+	var p products.Part
+	iter := session.DB(database.ProductDatabase).C(database.ProductCollectionName).Find(query).Iter()
+	for iter.Next(&p) {
+		if err := each(p); err != nil {
+			iter.Close()
+			return err
+		}
+		p = products.Part{}
+	}
+	return iter.Close()
+}
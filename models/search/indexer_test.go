@@ -0,0 +1,243 @@
+package search
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+	"time"
+)
+
+func TestNewPartDocument(t *testing.T) {
+	p := products.Part{
+		ID:         13000,
+		PartNumber: "13-000",
+		Status:     800,
+		Brand:      brand.Brand{ID: 1},
+		Attributes: []products.Attribute{
+			{Key: "Finish", Value: "Black"},
+			{Key: "Finish", Value: "black"},
+			{Key: "Tow Rating", Value: "8,000 lbs"},
+			{Key: "Color", Value: " "},
+		},
+		Pricing: []products.Price{
+			{Type: "Map", Price: 150},
+			{Type: "List", Price: 199.99},
+		},
+		Categories: []products.Category{{CategoryID: 1, Title: "Trailer Hitches"}},
+		Vehicles: []products.VehicleApplication{
+			{Year: "2015", Make: "Ford", Model: "F150", Style: "SuperCrew"},
+			{Year: "2016", Make: "ford", Model: "F-150", Style: "SuperCrew"},
+			{Year: "2018", Make: "Ford", Model: "F-150", Style: "SuperCrew"},
+		},
+		AcesVehicles: []products.AcesVehicle{
+			{Base: products.BaseVehicle{Year: 2014, Make: "Chevy", Model: "Silverado 1500"}, Submodel: "LT"},
+		},
+	}
+
+	Convey("Testing NewPartDocument()", t, func() {
+		doc := NewPartDocument(p)
+		So(doc.RawPart, ShouldEqual, "13-000")
+		So(doc.WebVisibility, ShouldEqual, products.PUBLIC)
+		So(doc.ShowOnWebsite, ShouldBeTrue)
+		So(doc.Price, ShouldEqual, 199.99)
+		So(doc.Facets, ShouldResemble, map[string][]string{
+			"finish":    {"Black"},
+			"towrating": {"8,000 lbs"},
+		})
+		So(doc.Fitment, ShouldResemble, []string{
+			"2015-2016, 2018 Ford F-150 SuperCrew",
+			"2014 Chevrolet Silverado 1500 LT",
+		})
+		So(doc.VehicleKeys, ShouldResemble, []string{
+			"ford", "ford|f150", "2015|ford", "2015|ford|f150", "2016|ford", "2016|ford|f150", "2018|ford", "2018|ford|f150",
+			"chevrolet", "chevrolet|silverado1500", "2014|chevrolet", "2014|chevrolet|silverado1500",
+		})
+		So(doc.Suggest.Input, ShouldResemble, []string{"13-000", "13000"})
+		So(doc.Suggest.Payload, ShouldResemble, SuggestPayload{ID: 13000, Brand: 1, Visibility: products.PUBLIC})
+
+		data, err := json.Marshal(doc)
+		So(err, ShouldBeNil)
+		var source map[string]interface{}
+		So(json.Unmarshal(data, &source), ShouldBeNil)
+		So(source["part_number"], ShouldEqual, "13-000")
+		So(source["web_visibility"], ShouldEqual, products.PUBLIC)
+		So(source["vehicle_applications"], ShouldBeNil)
+		So(source["aces_vehicles"], ShouldBeNil)
+		So(source["showOnWebsite"], ShouldEqual, true)
+	})
+
+	Convey("Testing VehicleKey()", t, func() {
+		So(VehicleKey(2015, "Ford", "F150"), ShouldEqual, "2015|ford|f150")
+		So(VehicleKey(0, "chevy", "silverado-1500"), ShouldEqual, "chevrolet|silverado1500")
+		So(VehicleKey(0, "Ford", ""), ShouldEqual, "ford")
+	})
+}
+
+func TestIndexer(t *testing.T) {
+	catalog := map[int][]products.Part{
+		1: {
+			{ID: 1, PartNumber: "13000", Status: 800, Brand: brand.Brand{ID: 1}, ShortDesc: "Class 3 Trailer Hitch", DateModified: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+			{ID: 2, PartNumber: "13001", Status: 800, Brand: brand.Brand{ID: 1}, ShortDesc: "Class 3 Trailer Hitch", DateModified: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+		3: {
+			{ID: 3, PartNumber: "2047", Status: 800, Brand: brand.Brand{ID: 3}, ShortDesc: "Running Board", DateModified: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		},
+	}
+	source := func(brand int, since time.Time, each func(products.Part) error) error {
+		for _, p := range catalog[brand] {
+			if since.IsZero() && p.Status < 700 || !p.DateModified.After(since) {
+				continue
+			}
+			if err := each(p); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	old := BrandIndexes
+	BrandIndexes = map[int]string{1: "curt", 3: "aries"}
+	defer func() { BrandIndexes = old }()
+
+	now := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemoryBackend()
+	idx := Indexer{
+		Backend:   m,
+		Parts:     source,
+		BatchSize: 1,
+		Now:       func() time.Time { return now },
+	}
+	total := func(index, query string) int64 {
+		res, err := m.Search(index, Query{Text: query})
+		So(err, ShouldBeNil)
+		return res.Hits.TotalHits
+	}
+
+	Convey("Testing Indexer", t, func() {
+		Convey("Reindex() leaves old indexes serving when the swap fails", func() {
+			broken := NewMemoryBackend()
+			So(broken.CreateIndex("curt_20260101000000", nil), ShouldBeNil)
+			So(broken.Index("curt_20260101000000", Document{ID: "99", Source: map[string]string{"title": "old hitch"}}), ShouldBeNil)
+			So(broken.UpdateAliases(AliasAction{Alias: "curt", Index: "curt_20260101000000"}), ShouldBeNil)
+
+			failing := Indexer{Backend: failingAliases{broken}, Parts: source, Now: idx.Now}
+			_, err := failing.Reindex(1)
+			So(err, ShouldNotBeNil)
+
+			exists, _ := broken.IndexExists("curt_20260201000000")
+			So(exists, ShouldBeFalse)
+			res, err := broken.Search("curt", Query{Text: "hitch"})
+			So(err, ShouldBeNil)
+			So(res.Hits.TotalHits, ShouldEqual, 1)
+		})
+
+		Convey("Reindex() keeps the new indexes when the swap fails after removing legacy ones", func() {
+			broken := NewMemoryBackend()
+			So(broken.CreateIndex("curt", nil), ShouldBeNil)
+
+			failing := Indexer{Backend: failingAliases{broken}, Parts: source, Now: idx.Now}
+			_, err := failing.Reindex(1)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "curt_20260201000000")
+
+			exists, _ := broken.IndexExists("curt_20260201000000")
+			So(exists, ShouldBeTrue)
+		})
+
+		Convey("Reindex() only replaces a legacy all index with every brand", func() {
+			legacy := NewMemoryBackend()
+			So(legacy.CreateIndex(AllIndex, nil), ShouldBeNil)
+			So(legacy.Index(AllIndex, Document{ID: "3", Source: map[string]string{"title": "old running board"}}), ShouldBeNil)
+			So(legacy.CreateIndex("curt", nil), ShouldBeNil)
+
+			partial := Indexer{Backend: legacy, Parts: source, Now: idx.Now}
+			_, err := partial.Reindex(1)
+			So(err, ShouldBeNil)
+			aliased, _ := legacy.Aliases("curt")
+			So(aliased, ShouldResemble, []string{"curt_20260201000000"})
+			exists, _ := legacy.IndexExists(AllIndex)
+			So(exists, ShouldBeTrue)
+			res, err := legacy.Search(AllIndex, Query{Text: "board"})
+			So(err, ShouldBeNil)
+			So(res.Hits.TotalHits, ShouldEqual, 1)
+
+			partial.Now = func() time.Time { return now.Add(time.Hour) }
+			_, err = partial.Reindex()
+			So(err, ShouldBeNil)
+			aliased, _ = legacy.Aliases(AllIndex)
+			So(aliased, ShouldResemble, []string{"aries_20260201010000", "curt_20260201010000"})
+			exists, _ = legacy.IndexExists("curt_20260201000000")
+			So(exists, ShouldBeFalse)
+		})
+
+		Convey("Reindex() swaps new indexes in behind the aliases", func() {
+			So(m.CreateIndex("curt", nil), ShouldBeNil)
+			So(m.Index("curt", Document{ID: "99", Source: map[string]string{"title": "stale hitch"}}), ShouldBeNil)
+
+			reports, err := idx.Reindex()
+			So(err, ShouldBeNil)
+			So(len(reports), ShouldEqual, 2)
+			So(reports[0], ShouldResemble, IndexReport{Brand: 1, Alias: "curt", Index: "curt_20260201000000", Full: true, Indexed: 2})
+			So(reports[1].Index, ShouldEqual, "aries_20260201000000")
+
+			aliased, _ := m.Aliases("curt")
+			So(aliased, ShouldResemble, []string{"curt_20260201000000"})
+			aliased, _ = m.Aliases(AllIndex)
+			So(aliased, ShouldResemble, []string{"aries_20260201000000", "curt_20260201000000"})
+			So(total("curt", "hitch"), ShouldEqual, 2)
+			So(total(AllIndex, "hitch board"), ShouldEqual, 3)
+
+			now = now.Add(24 * time.Hour)
+			_, err = idx.Reindex(1)
+			So(err, ShouldBeNil)
+			aliased, _ = m.Aliases(AllIndex)
+			So(aliased, ShouldResemble, []string{"aries_20260201000000", "curt_20260202000000"})
+			exists, _ := m.IndexExists("curt_20260201000000")
+			So(exists, ShouldBeFalse)
+
+			_, err = idx.Reindex(42)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Update() indexes modified parts and removes inactive ones", func() {
+			catalog[1][0].ShortDesc = "Class 3 Receiver"
+			catalog[1][0].DateModified = now.Add(time.Hour)
+			catalog[1][1].Status = 0
+			catalog[1][1].DateModified = now.Add(time.Hour)
+
+			report, err := idx.Update(1, now)
+			So(err, ShouldBeNil)
+			So(report.Indexed, ShouldEqual, 1)
+			So(report.Deleted, ShouldEqual, 1)
+			So(total("curt", "hitch"), ShouldEqual, 0)
+			So(total("curt", "receiver"), ShouldEqual, 1)
+
+			_, err = idx.Update(1, time.Time{})
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("Sync() updates synced brands and reindexes the rest", func() {
+			catalog[3] = append(catalog[3], products.Part{ID: 4, PartNumber: "2048", Status: 800, Brand: brand.Brand{ID: 3}, ShortDesc: "Running Board", DateModified: now.Add(2 * time.Hour)})
+			So(total("aries", "board"), ShouldEqual, 1)
+
+			reports, err := idx.Sync()
+			So(err, ShouldBeNil)
+			So(len(reports), ShouldEqual, 2)
+			So(reports[1].Full, ShouldBeFalse)
+			So(reports[1].Indexed, ShouldEqual, 1)
+			So(total("aries", "board"), ShouldEqual, 2)
+		})
+	})
+}
+
+// failingAliases is a backend whose alias updates always fail.
+type failingAliases struct {
+	*MemoryBackend
+}
+
+func (f failingAliases) UpdateAliases(actions ...AliasAction) error {
+	return errors.New("alias update failed")
+}
@@ -0,0 +1,624 @@
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/curt-labs/API/helpers/apifilter"
+)
+
+// defaultSize is the page size of a query without one, as in Elasticsearch.
+const defaultSize = 10
+
+// MemoryBackend is an in-memory index for tests and local development.
+// Documents match a query when they contain any of its words, or it has
+// none, and the values of its filters, and score higher the more often they
+// contain the words. An exact match of the raw_part field wins an ExactAndClose search.
+// AllIndex searches every index unless documents were indexed into it
+// directly, or it's an alias.
+type MemoryBackend struct {
+	mu      sync.RWMutex
+	indexes map[string]map[string]*memoryDocument
+	aliases map[string][]string
+}
+
+type memoryDocument struct {
+	index    string
+	id       string
+	typ      string
+	source   json.RawMessage
+	fields   map[string]interface{}
+	terms    map[string]int
+	rawPart  string
+	suggests []memorySuggest
+}
+
+// memorySuggest is a completion of the suggest field. The field takes the
+// forms of an Elasticsearch completion field: a string, a list of strings,
+// an object with input, output, weight and payload, or a list of those.
+type memorySuggest struct {
+	Input   []string    `json:"input"`
+	Output  string      `json:"output"`
+	Weight  int         `json:"weight"`
+	Payload interface{} `json:"payload"`
+}
+
+// NewMemoryBackend returns an empty MemoryBackend.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		indexes: make(map[string]map[string]*memoryDocument),
+		aliases: make(map[string][]string),
+	}
+}
+
+func (m *MemoryBackend) Search(index string, q Query) (*SearchResult, error) {
+	return m.search(index, q, false), nil
+}
+
+func (m *MemoryBackend) ExactAndClose(index string, q Query) (*SearchResult, error) {
+	return m.search(index, q, true), nil
+}
+
+func (m *MemoryBackend) search(index string, q Query, exact bool) *SearchResult {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	terms := queryTerms(q.Text)
+	raw := strings.ToLower(strings.TrimSpace(q.Text))
+
+	type scored struct {
+		doc   *memoryDocument
+		score float64
+	}
+	var matches []scored
+	var scoped []*memoryDocument
+	for _, docs := range m.resolve(index) {
+		for _, doc := range docs {
+			score := 0.0
+			for _, t := range terms {
+				score += float64(doc.terms[t])
+			}
+			if q.Text == "" {
+				score = 1
+			}
+			if score == 0 || !doc.scoped(q) {
+				continue
+			}
+			scoped = append(scoped, doc)
+			if !doc.filtered(q.Filters, "") {
+				continue
+			}
+			if exact && doc.rawPart != "" && doc.rawPart == raw {
+				score += 10
+			}
+			matches = append(matches, scored{doc: doc, score: score})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+		if a.doc.index != b.doc.index {
+			return a.doc.index < b.doc.index
+		}
+		return a.doc.id < b.doc.id
+	})
+
+	res := &SearchResult{
+		Hits: &SearchHits{
+			TotalHits: int64(len(matches)),
+			Hits:      []*SearchHit{},
+		},
+	}
+	if len(matches) > 0 {
+		max := matches[0].score
+		res.Hits.MaxScore = &max
+	}
+	if len(q.Facets) > 0 {
+		res.Facets = memoryFacets(scoped, q)
+	}
+
+	size := q.Size
+	if size <= 0 {
+		size = defaultSize
+	}
+	for i := q.From; i >= 0 && i < len(matches) && i < q.From+size; i++ {
+		score := matches[i].score
+		source := append(json.RawMessage(nil), matches[i].doc.source...)
+		res.Hits.Hits = append(res.Hits.Hits, &SearchHit{
+			Score:  &score,
+			Index:  matches[i].doc.index,
+			Type:   matches[i].doc.typ,
+			Id:     matches[i].doc.id,
+			Source: &source,
+		})
+	}
+	return res
+}
+
+// Suggest returns the outputs of the completions with an input starting
+// with the prefix, heaviest first.
+func (m *MemoryBackend) Suggest(index, prefix string, size int) ([]Suggestion, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	prefix = strings.ToLower(strings.TrimSpace(prefix))
+	suggestions := make([]Suggestion, 0)
+	if prefix == "" {
+		return suggestions, nil
+	}
+
+	seen := make(map[string]int)
+	for _, docs := range m.resolve(index) {
+		for _, doc := range docs {
+			for _, s := range doc.suggests {
+				if !s.matches(prefix) {
+					continue
+				}
+				if i, ok := seen[s.Output]; ok {
+					if float64(s.Weight) > suggestions[i].Score {
+						suggestions[i].Score = float64(s.Weight)
+						suggestions[i].Payload = s.Payload
+					}
+					continue
+				}
+				seen[s.Output] = len(suggestions)
+				suggestions = append(suggestions, Suggestion{
+					Text:    s.Output,
+					Score:   float64(s.Weight),
+					Payload: s.Payload,
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Text < suggestions[j].Text
+	})
+	if size > 0 && len(suggestions) > size {
+		suggestions = suggestions[:size]
+	}
+	return suggestions, nil
+}
+
+func (m *MemoryBackend) Index(index string, docs ...Document) error {
+	indexed := make([]*memoryDocument, 0, len(docs))
+	for _, doc := range docs {
+		d, err := newMemoryDocument(index, doc)
+		if err != nil {
+			return err
+		}
+		indexed = append(indexed, d)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index, err := m.writeIndex(index)
+	if err != nil {
+		return err
+	}
+	if m.indexes == nil {
+		m.indexes = make(map[string]map[string]*memoryDocument)
+	}
+	if m.indexes[index] == nil {
+		m.indexes[index] = make(map[string]*memoryDocument)
+	}
+	for _, d := range indexed {
+		d.index = index
+		m.indexes[index][d.id] = d
+	}
+	return nil
+}
+
+func (m *MemoryBackend) Delete(index string, ids ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	index, err := m.writeIndex(index)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		delete(m.indexes[index], id)
+	}
+	return nil
+}
+
+func (m *MemoryBackend) CreateIndex(index string, body interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.indexes[index]; ok {
+		return fmt.Errorf("index %s already exists", index)
+	}
+	if _, ok := m.aliases[index]; ok {
+		return fmt.Errorf("an alias named %s already exists", index)
+	}
+	if m.indexes == nil {
+		m.indexes = make(map[string]map[string]*memoryDocument)
+	}
+	m.indexes[index] = make(map[string]*memoryDocument)
+	return nil
+}
+
+func (m *MemoryBackend) DeleteIndex(indexes ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, index := range indexes {
+		if _, ok := m.indexes[index]; !ok {
+			return fmt.Errorf("index %s does not exist", index)
+		}
+		delete(m.indexes, index)
+		for alias := range m.aliases {
+			m.removeAlias(alias, index)
+		}
+	}
+	return nil
+}
+
+func (m *MemoryBackend) IndexExists(name string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	_, index := m.indexes[name]
+	_, alias := m.aliases[name]
+	return index || alias, nil
+}
+
+func (m *MemoryBackend) Aliases(alias string) ([]string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return append([]string{}, m.aliases[alias]...), nil
+}
+
+// UpdateAliases applies every action or, when one of them adds an index
+// that doesn't exist or adds an alias named like an index, none of them.
+func (m *MemoryBackend) UpdateAliases(actions ...AliasAction) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, a := range actions {
+		if a.Remove {
+			continue
+		}
+		if _, ok := m.indexes[a.Index]; !ok {
+			return fmt.Errorf("index %s does not exist", a.Index)
+		}
+		if _, ok := m.indexes[a.Alias]; ok {
+			return fmt.Errorf("an index named %s already exists", a.Alias)
+		}
+	}
+
+	if m.aliases == nil {
+		m.aliases = make(map[string][]string)
+	}
+	for _, a := range actions {
+		m.removeAlias(a.Alias, a.Index)
+		if !a.Remove {
+			m.aliases[a.Alias] = append(m.aliases[a.Alias], a.Index)
+			sort.Strings(m.aliases[a.Alias])
+		}
+	}
+	return nil
+}
+
+func (m *MemoryBackend) removeAlias(alias, index string) {
+	var kept []string
+	for _, i := range m.aliases[alias] {
+		if i != index {
+			kept = append(kept, i)
+		}
+	}
+	if len(kept) == 0 {
+		delete(m.aliases, alias)
+		return
+	}
+	m.aliases[alias] = kept
+}
+
+// writeIndex returns the index that writes to the name go to: the index
+// of an alias of one index, or the name itself.
+func (m *MemoryBackend) writeIndex(name string) (string, error) {
+	indexes, ok := m.aliases[name]
+	if !ok {
+		return name, nil
+	}
+	if len(indexes) != 1 {
+		return "", fmt.Errorf("alias %s points to %d indexes", name, len(indexes))
+	}
+	return indexes[0], nil
+}
+
+// resolve returns the documents of the comma separated indexes and
+// aliases.
+func (m *MemoryBackend) resolve(index string) []map[string]*memoryDocument {
+	var docs []map[string]*memoryDocument
+	for _, name := range strings.Split(index, ",") {
+		if indexes, ok := m.aliases[name]; ok {
+			for _, i := range indexes {
+				docs = append(docs, m.indexes[i])
+			}
+		} else if d, ok := m.indexes[name]; ok {
+			docs = append(docs, d)
+		} else if name == AllIndex {
+			for _, d := range m.indexes {
+				docs = append(docs, d)
+			}
+		}
+	}
+	return docs
+}
+
+func newMemoryDocument(index string, doc Document) (*memoryDocument, error) {
+	source, err := json.Marshal(doc.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err = json.Unmarshal(source, &fields); err != nil {
+		return nil, err
+	}
+
+	d := &memoryDocument{
+		index:  index,
+		id:     doc.ID,
+		typ:    doc.Type,
+		source: source,
+		fields: fields,
+		terms:  make(map[string]int),
+	}
+	if d.typ == "" {
+		d.typ = DocumentType
+	}
+	if raw, ok := fields["raw_part"].(string); ok {
+		d.rawPart = strings.ToLower(strings.TrimSpace(raw))
+	}
+	d.suggests = parseSuggests(fields[SuggestField])
+	delete(fields, SuggestField)
+
+	for _, t := range flatten(fields, nil) {
+		d.terms[t]++
+	}
+	return d, nil
+}
+
+// filtered reports whether the document has a value of every filter but
+// the filter of the except facet.
+func (d *memoryDocument) filtered(filters Filters, except string) bool {
+	for _, f := range Facets() {
+		values := filters[f.Key]
+		if len(values) == 0 || f.Key == except {
+			continue
+		}
+		if !d.hasAny(f, values) {
+			return false
+		}
+	}
+	return true
+}
+
+// scoped reports whether the document has one of the visibilities of the
+// query and its vehicle.
+func (d *memoryDocument) scoped(q Query) bool {
+	if q.Vehicle != "" && !d.hasAny(Facet{Field: "vehicles"}, []string{q.Vehicle}) {
+		return false
+	}
+
+	if len(q.Visibilities) == 0 {
+		return true
+	}
+	visibility := d.visibility()
+	for _, v := range q.Visibilities {
+		if v == visibility {
+			return true
+		}
+	}
+	return false
+}
+
+// visibility is the web visibility of the document, read like
+// indexedVisibility.
+func (d *memoryDocument) visibility() string {
+	webVisibility, _ := d.fields["web_visibility"].(string)
+	var showOnWebsite, showForLoggedIn *bool
+	if b, ok := d.fields["showOnWebsite"].(bool); ok {
+		showOnWebsite = &b
+	}
+	if b, ok := d.fields["showForLoggedIn"].(bool); ok {
+		showForLoggedIn = &b
+	}
+	return indexedVisibility(webVisibility, showOnWebsite, showForLoggedIn)
+}
+
+func (d *memoryDocument) hasAny(f Facet, values []string) bool {
+	for _, dv := range d.facetValues(f) {
+		for _, v := range values {
+			if dv == v {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// facetValues returns the distinct values of the field of the facet. The
+// price is in bands.
+func (d *memoryDocument) facetValues(f Facet) []string {
+	path := strings.Split(strings.TrimSuffix(f.Field, ".raw"), ".")
+	var values []string
+	for _, v := range fieldValues(d.fields, path) {
+		if f.Key == PriceFacet {
+			price, ok := v.(float64)
+			if !ok {
+				continue
+			}
+			v = PriceBandOf(price)
+		}
+		var str string
+		switch t := v.(type) {
+		case string:
+			str = t
+		case float64:
+			str = strconv.FormatFloat(t, 'f', -1, 64)
+		default:
+			continue
+		}
+		values = appendUnique(values, str)
+	}
+	return values
+}
+
+// fieldValues returns the values at the path of a decoded JSON value,
+// through any lists along the way.
+func fieldValues(val interface{}, path []string) []interface{} {
+	if list, ok := val.([]interface{}); ok {
+		var values []interface{}
+		for _, item := range list {
+			values = append(values, fieldValues(item, path)...)
+		}
+		return values
+	}
+	if len(path) == 0 {
+		return []interface{}{val}
+	}
+	obj, ok := val.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return fieldValues(obj[path[0]], path[1:])
+}
+
+// memoryFacets counts the documents by the values of the facets of the
+// query. Each facet is counted under every filter but its own, so the other
+// values of a selected facet are still offered.
+func memoryFacets(docs []*memoryDocument, q Query) []apifilter.Options {
+	options := make([]apifilter.Options, 0, len(q.Facets))
+	for _, f := range q.Facets {
+		counts := make(map[string]int)
+		var values []string
+		for _, doc := range docs {
+			if !doc.filtered(q.Filters, f.Key) {
+				continue
+			}
+			for _, v := range doc.facetValues(f) {
+				if counts[v] == 0 {
+					values = append(values, v)
+				}
+				counts[v]++
+			}
+		}
+
+		opts := apifilter.Options{Key: f.Key, Options: []apifilter.Option{}}
+		for _, v := range values {
+			opts.Options = append(opts.Options, apifilter.Option{Value: v, Count: counts[v]})
+		}
+		sortOptions(f.Key, opts.Options)
+		if f.Key != PriceFacet && len(opts.Options) > FacetSize {
+			opts.Options = opts.Options[:FacetSize]
+		}
+		options = append(options, opts)
+	}
+	return options
+}
+
+// parseSuggests reads the completions of a suggest field.
+func parseSuggests(field interface{}) []memorySuggest {
+	var suggests []memorySuggest
+	switch v := field.(type) {
+	case string:
+		suggests = append(suggests, memorySuggest{Input: []string{v}})
+	case []interface{}:
+		for _, item := range v {
+			suggests = append(suggests, parseSuggests(item)...)
+		}
+	case map[string]interface{}:
+		s := memorySuggest{Payload: v["payload"]}
+		switch in := v["input"].(type) {
+		case string:
+			s.Input = []string{in}
+		case []interface{}:
+			for _, i := range in {
+				if str, ok := i.(string); ok {
+					s.Input = append(s.Input, str)
+				}
+			}
+		}
+		s.Output, _ = v["output"].(string)
+		if w, ok := v["weight"].(float64); ok {
+			s.Weight = int(w)
+		}
+		if len(s.Input) > 0 {
+			suggests = append(suggests, s)
+		}
+	}
+
+	for i := range suggests {
+		if suggests[i].Output == "" {
+			suggests[i].Output = suggests[i].Input[0]
+		}
+	}
+	return suggests
+}
+
+func (s memorySuggest) matches(prefix string) bool {
+	for _, in := range s.Input {
+		if strings.HasPrefix(strings.ToLower(in), prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// flatten appends the words of every string and number in a decoded JSON
+// value to terms.
+func flatten(val interface{}, terms []string) []string {
+	switch v := val.(type) {
+	case string:
+		terms = append(terms, words(v)...)
+	case float64:
+		terms = append(terms, strconv.FormatFloat(v, 'f', -1, 64))
+	case []interface{}:
+		for _, item := range v {
+			terms = flatten(item, terms)
+		}
+	case map[string]interface{}:
+		for _, item := range v {
+			terms = flatten(item, terms)
+		}
+	}
+	return terms
+}
+
+// queryTerms are the words of a query, without the operators of the query
+// string syntax.
+func queryTerms(query string) []string {
+	var terms []string
+	for _, f := range strings.Fields(query) {
+		if f == "AND" || f == "OR" || f == "NOT" {
+			continue
+		}
+		terms = append(terms, words(f)...)
+	}
+	return terms
+}
+
+// words splits a string into lowercase runs of letters and digits.
+func words(s string) []string {
+	return strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -2,47 +2,18 @@ package search
 
 import (
 	"errors"
-	"fmt"
-	"os"
-	"strconv"
-	"strings"
-
-	elastic "gopkg.in/olivere/elastic.v2"
 
 	"github.com/curt-labs/API/helpers/apicontext"
-	"github.com/mattbaird/elastigo/lib"
+	"github.com/curt-labs/API/models/products"
 )
 
-func newConn() (*elastic.Client, error) {
-	hosts := []string{"http://127.0.0.1:9200"}
-
-	if d := os.Getenv("ELASTICSEARCH_IP"); d != "" {
-		hosts = []string{}
-		urls := strings.Split(d, ",")
-		for _, u := range urls {
-			hosts = append(
-				hosts,
-				fmt.Sprintf("http://%s:9200", u),
-			)
-		}
-	}
-
-	user := os.Getenv("ELASTIC_USER")
-	pass := os.Getenv("ELASTIC_PASS")
-
-	funcs := []elastic.ClientOptionFunc{
-		elastic.SetURL(hosts...),
-		elastic.SetMaxRetries(10),
-	}
-
-	if user != "" && pass != "" {
-		funcs = append(funcs, elastic.SetBasicAuth(user, pass))
-	}
-
-	return elastic.NewSimpleClient(funcs...)
-}
-
-func Dsl(query string, page int, count int, brand int, dtx *apicontext.DataContext, rawPartNumber string) (*elastic.SearchResult, error) {
+// Dsl searches the index of the brand, or of the brands of the data context,
+// for the query string, limited to the facet filters and to the parts the
+// data context can see. The result counts every facet of Facets. With
+// detectVehicle, a vehicle named in the query limits the search to the
+// parts that fit it and is returned with the result. When none of the
+// vehicle's parts match, the whole query is searched instead.
+func Dsl(query string, page int, count int, brand int, dtx *apicontext.DataContext, rawPartNumber string, filters Filters, detectVehicle bool) (*SearchResult, error) {
 
 	if page == 1 {
 		page = 0
@@ -54,15 +25,12 @@ func Dsl(query string, page int, count int, brand int, dtx *apicontext.DataConte
 		return nil, errors.New("cannot execute a search on an empty query")
 	}
 
-	c, err := newConn()
-	if err != nil {
-		return nil, err
-	}
-
-	return c.Search(findIndex(brand, dtx)).From(page * count).Size(count).Query(elastic.NewQueryStringQuery(query)).Do()
+	return facetedSearch(Backend.Search, findIndex(brand, dtx), query, page*count, count, dtx, filters, detectVehicle)
 }
 
-func ExactAndCloseDsl(query string, page int, count int, brand int, dtx *apicontext.DataContext) (*elastigo.SearchResult, error) {
+// ExactAndCloseDsl searches like Dsl, ranking parts whose number is the
+// query first.
+func ExactAndCloseDsl(query string, page int, count int, brand int, dtx *apicontext.DataContext, filters Filters, detectVehicle bool) (*SearchResult, error) {
 
 	if page == 1 {
 		page = 0
@@ -74,102 +42,63 @@ func ExactAndCloseDsl(query string, page int, count int, brand int, dtx *apicont
 		return nil, errors.New("cannot execute a search on an empty query")
 	}
 
-	var con *elastigo.Conn
-	if host := os.Getenv("ELASTICSEARCH_IP"); host != "" {
-		con = &elastigo.Conn{
-			Protocol: elastigo.DefaultProtocol,
-			Domain:   host,
-			Port:     os.Getenv("ELASTIC_PORT"),
-			Username: os.Getenv("ELASTIC_USER"),
-			Password: os.Getenv("ELASTIC_PASS"),
-		}
-	}
-	if con == nil {
-		return nil, errors.New("failed to connect to elasticsearch")
-	}
-
-	from := strconv.Itoa(page * count)
-	size := strconv.Itoa(count)
-
-	index := findIndex(brand, dtx)
-
-	args := map[string]interface{}{
-		"from": from,
-		"size": size,
-		"query": map[string]interface{}{
-			"bool": map[string]interface{}{
-				"must": map[string]interface{}{
-					"match": map[string]interface{}{
-						"_all": query,
-					},
-				},
-				"should": map[string]interface{}{
-					"match": map[string]interface{}{
-						"raw_part": map[string]interface{}{
-							"query": query,
-							"boost": 10,
-						},
-					},
-				},
-			},
-		},
-	}
-	res, err := con.Search(index, "", nil, args)
-	return &res, err
+	return facetedSearch(Backend.ExactAndClose, findIndex(brand, dtx), query, page*count, count, dtx, filters, detectVehicle)
 }
 
-func findIndex(brand int, dtx *apicontext.DataContext) string {
-	searchCurt := false
-	searchAries := false
-	searchLuverne := false
-	searchRetrac := false
-	searchUWS := false
-
-	if brand == 0 {
-		for _, br := range dtx.BrandArray {
-			if br == 1 { // search curt
-				searchCurt = true
-			} else if br == 3 { // search aries
-				searchAries = true
-			} else if br == 4 { // search luverne
-				searchLuverne = true
-			} else if br == 5 { // search retrac
-				searchRetrac = true
-			} else if br == 6 { // search UWS
-				searchUWS = true
-			}
-		}
-	} else {
-		if brand == 1 {
-			searchCurt = true
-		} else if brand == 3 {
-			searchAries = true
-		} else if brand == 4 {
-			searchLuverne = true
-		} else if brand == 5 {
-			searchRetrac = true
-		} else if brand == 6 {
-			searchUWS = true
-		}
-	}
-
-	index := "all"
-
-	if searchCurt && !searchAries && !searchLuverne && !searchRetrac && !searchUWS {
-		index = "curt"
+// facetedSearch runs a faceted query with the search of a backend. A search
+// limited to a detected vehicle that finds nothing, like on an index built
+// before parts carried their vehicles, is run again without the vehicle.
+func facetedSearch(run func(string, Query) (*SearchResult, error), index string, text string, from, size int, dtx *apicontext.DataContext, filters Filters, detectVehicle bool) (*SearchResult, error) {
+	q, vehicle := facetedQuery(text, from, size, dtx, filters, detectVehicle)
+	res, err := run(index, q)
+	if err == nil && vehicle != nil && (res.Hits == nil || res.Hits.TotalHits == 0) {
+		q, vehicle = facetedQuery(text, from, size, dtx, filters, false)
+		res, err = run(index, q)
 	}
-	if searchAries && !searchCurt && !searchLuverne && !searchRetrac && !searchUWS {
-		index = "aries"
-	}
-	if searchLuverne && !searchAries && !searchCurt && !searchRetrac && !searchUWS {
-		index = "luverne"
-	}
-	if searchRetrac && !searchAries && !searchCurt && !searchLuverne && !searchUWS {
-		index = "retrac"
-	}
-	if searchUWS && !searchAries && !searchCurt && !searchLuverne && !searchRetrac {
-		index = "uws"
+	if err != nil {
+		return nil, err
 	}
+	markSelected(res.Facets, filters)
+	res.Vehicle = vehicle
+	return res, nil
+}
+
+// facetedQuery is a page of a search for the text, limited to the filters
+// and the visibilities of the data context, that counts every facet. With
+// detectVehicle, the vehicle named in the text is split out of it and the
+// search is limited to the vehicle's parts.
+func facetedQuery(text string, from, size int, dtx *apicontext.DataContext, filters Filters, detectVehicle bool) (Query, *DetectedVehicle) {
+	q := Query{
+		Text:         text,
+		From:         from,
+		Size:         size,
+		Filters:      filters,
+		Visibilities: products.Visibilities(dtx),
+		Facets:       Facets(),
+	}
+	if !detectVehicle {
+		return q, nil
+	}
+
+	vehicle := DetectVehicle(text)
+	if vehicle != nil {
+		q.Text = vehicle.Terms
+		q.Vehicle = vehicle.Key()
+	}
+	return q, vehicle
+}
 
-	return index
+// indexedVisibility is the web visibility of a search document. Documents
+// indexed before the web visibility was carry the web flags of the part's
+// JSON instead, and documents without either are public.
+func indexedVisibility(webVisibility string, showOnWebsite, showForLoggedIn *bool) string {
+	switch {
+	case webVisibility != "":
+		return webVisibility
+	case showForLoggedIn != nil && *showForLoggedIn:
+		return products.LOGGEDIN
+	case showOnWebsite != nil && !*showOnWebsite:
+		return products.DISABLED
+	}
+	return products.PUBLIC
 }
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/curt-labs/API/models/products"
+)
+
+const (
+	// MinVehicleYear is the oldest year detected in a query.
+	MinVehicleYear = 1900
+
+	// maxNameWords is the most words of a make or model name.
+	maxNameWords = 3
+)
+
+// DetectedVehicle is the vehicle named in a search query. Terms are the
+// rest of the query, searched within the parts that fit the vehicle.
+type DetectedVehicle struct {
+	Year  int    `json:"year,omitempty" xml:"year,attr,omitempty"`
+	Make  string `json:"make" xml:"make,attr"`
+	Model string `json:"model,omitempty" xml:"model,attr,omitempty"`
+	Terms string `json:"terms" xml:"terms,attr"`
+}
+
+// Key returns the key the vehicle's parts are indexed under.
+func (v *DetectedVehicle) Key() string {
+	return VehicleKey(v.Year, v.Make, v.Model)
+}
+
+// VehicleNames are the makes and models detected in queries, by their
+// lowercase letters and digits.
+type VehicleNames struct {
+	makes      map[string]string
+	models     map[string]map[string]string
+	modelMakes map[string][]string
+}
+
+// NewVehicleNames returns the makes and models of the alias table, under
+// their aliases as well as their names.
+func NewVehicleNames(aliases *products.AliasTable) *VehicleNames {
+	n := &VehicleNames{
+		makes:      make(map[string]string),
+		models:     make(map[string]map[string]string),
+		modelMakes: make(map[string][]string),
+	}
+	if aliases == nil {
+		return n
+	}
+
+	for alias, name := range aliases.Makes {
+		n.addMake(products.AliasKey(alias), name)
+		n.addMake(products.AliasKey(name), name)
+	}
+	for mk, models := range aliases.Models {
+		mk = products.AliasKey(mk)
+		n.addMake(mk, mk)
+		for alias, name := range models {
+			n.addModel(mk, products.AliasKey(alias), name)
+			n.addModel(mk, products.AliasKey(name), name)
+		}
+	}
+	return n
+}
+
+// Add adds a make and model of the catalog. Catalog names replace the
+// lowercase make keys of the alias table.
+func (n *VehicleNames) Add(vehicleMake, model string) {
+	vehicleMake = products.NormalizeMake(vehicleMake)
+	model = products.NormalizeModel(vehicleMake, model)
+	mk := products.AliasKey(vehicleMake)
+	if mk == "" {
+		return
+	}
+	n.addMake(mk, vehicleMake)
+	if model != "" {
+		n.addModel(mk, products.AliasKey(model), model)
+	}
+}
+
+func (n *VehicleNames) addMake(key, name string) {
+	if key == "" {
+		return
+	}
+	if cur, ok := n.makes[key]; !ok || cur == key {
+		n.makes[key] = name
+	}
+}
+
+func (n *VehicleNames) addModel(mk, key, name string) {
+	if key == "" {
+		return
+	}
+	if n.models[mk] == nil {
+		n.models[mk] = make(map[string]string)
+	}
+	if _, ok := n.models[mk][key]; ok {
+		return
+	}
+	n.models[mk][key] = name
+	n.modelMakes[key] = append(n.modelMakes[key], mk)
+}
+
+// Detect finds the year, make and model in a query like "2015 f150 hitch".
+// Makes and models may span a few words and are matched through their
+// aliases. A model without a make is detected when it belongs to only one
+// make and the query has a year or the model has a digit, so "f150 hitch"
+// is a Ford but "sport rack" isn't anything. A year is only detected with a
+// make. It returns nil when the query doesn't name a vehicle.
+func (n *VehicleNames) Detect(query string) *DetectedVehicle {
+	if n == nil {
+		return nil
+	}
+
+	tokens := strings.Fields(query)
+	used := make([]bool, len(tokens))
+	v := &DetectedVehicle{}
+
+	yearAt := -1
+	for i, t := range tokens {
+		if year, ok := vehicleYear(t); ok {
+			v.Year, yearAt = year, i
+			break
+		}
+	}
+	if yearAt >= 0 {
+		used[yearAt] = true
+	}
+
+	mk := ""
+	if i, l, key, ok := match(tokens, used, func(k string) bool { _, ok := n.makes[k]; return ok }); ok {
+		v.Make = n.makes[key]
+		mk = products.AliasKey(v.Make)
+		markUsed(used, i, l)
+	}
+
+	if mk != "" {
+		if i, l, key, ok := match(tokens, used, func(k string) bool { _, ok := n.models[mk][k]; return ok }); ok {
+			v.Model = n.models[mk][key]
+			markUsed(used, i, l)
+		}
+	} else {
+		i, l, key, ok := match(tokens, used, func(k string) bool { return len(n.modelMakes[k]) > 0 })
+		if ok && len(n.modelMakes[key]) == 1 && (yearAt >= 0 || strings.IndexAny(key, "0123456789") >= 0) {
+			mk = n.modelMakes[key][0]
+			v.Make = n.makes[mk]
+			v.Model = n.models[mk][key]
+			markUsed(used, i, l)
+		}
+	}
+
+	if mk == "" {
+		return nil
+	}
+
+	var terms []string
+	for i, t := range tokens {
+		if !used[i] {
+			terms = append(terms, t)
+		}
+	}
+	v.Terms = strings.Join(terms, " ")
+	return v
+}
+
+// match finds the leftmost run of unused tokens, longest first, whose key
+// is a name.
+func match(tokens []string, used []bool, isName func(key string) bool) (int, int, string, bool) {
+	for i := range tokens {
+		for l := maxNameWords; l > 0; l-- {
+			if i+l > len(tokens) || anyUsed(used[i:i+l]) {
+				continue
+			}
+			key := ""
+			for _, t := range tokens[i : i+l] {
+				key += products.AliasKey(t)
+			}
+			if key != "" && isName(key) {
+				return i, l, key, true
+			}
+		}
+	}
+	return 0, 0, "", false
+}
+
+func anyUsed(used []bool) bool {
+	for _, u := range used {
+		if u {
+			return true
+		}
+	}
+	return false
+}
+
+func markUsed(used []bool, i, l int) {
+	for j := i; j < i+l; j++ {
+		used[j] = true
+	}
+}
+
+// vehicleYear reads a four digit model year, no later than next year.
+func vehicleYear(token string) (int, bool) {
+	if len(token) != 4 {
+		return 0, false
+	}
+	year, err := strconv.Atoi(token)
+	if err != nil || year < MinVehicleYear || year > time.Now().Year()+1 {
+		return 0, false
+	}
+	return year, true
+}
+
+// DetectVehicle finds the vehicle in a query with the makes and models of
+// the catalog once the completions are loaded, or of the vehicle aliases
+// until then.
+func DetectVehicle(query string) *DetectedVehicle {
+	completionsMu.Lock()
+	idx := completions
+	completionsMu.Unlock()
+
+	if idx != nil && idx.vehicles != nil {
+		return idx.vehicles.Detect(query)
+	}
+	return NewVehicleNames(products.Aliases).Detect(query)
+}
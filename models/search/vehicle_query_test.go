@@ -0,0 +1,101 @@
+package search
+
+import (
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/models/brand"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"strconv"
+	"testing"
+)
+
+func TestDetectVehicle(t *testing.T) {
+	Convey("Testing VehicleNames.Detect() with the aliases", t, func() {
+		names := NewVehicleNames(&products.DefaultAliases)
+
+		So(names.Detect("2015 f150 hitch"), ShouldResemble, &DetectedVehicle{Year: 2015, Make: "ford", Model: "F-150", Terms: "hitch"})
+		So(names.Detect("chevy s10 5th wheel"), ShouldResemble, &DetectedVehicle{Make: "Chevrolet", Model: "S10", Terms: "5th wheel"})
+		So(names.Detect("hitch for 2016 F250 SD"), ShouldResemble, &DetectedVehicle{Year: 2016, Make: "ford", Model: "F-250 Super Duty", Terms: "hitch for"})
+		So(names.Detect("range rover 2012 cargo carrier"), ShouldResemble, &DetectedVehicle{Year: 2012, Make: "Land Rover", Terms: "cargo carrier"})
+		So(names.Detect("2015 ram 1500"), ShouldResemble, &DetectedVehicle{Year: 2015, Make: "ram", Model: "1500", Terms: ""})
+
+		So(names.Detect("silverado hitch"), ShouldBeNil)
+		So(names.Detect("2047 running board"), ShouldBeNil)
+		So(names.Detect("13000"), ShouldBeNil)
+		So(names.Detect(""), ShouldBeNil)
+	})
+
+	Convey("Testing VehicleNames.Detect() with the catalog", t, func() {
+		names := NewVehicleNames(&products.DefaultAliases)
+		names.Add("Ford", "F-150")
+		names.Add("Toyota", "Tacoma")
+
+		v := names.Detect("2015 f150 hitch")
+		So(v, ShouldResemble, &DetectedVehicle{Year: 2015, Make: "Ford", Model: "F-150", Terms: "hitch"})
+		So(v.Key(), ShouldEqual, "2015|ford|f150")
+		So(names.Detect("Toyota Tacoma bike rack"), ShouldResemble, &DetectedVehicle{Make: "Toyota", Model: "Tacoma", Terms: "bike rack"})
+		So(names.Detect("2010 toyota hitch").Key(), ShouldEqual, "2010|toyota")
+	})
+}
+
+func TestDslVehicle(t *testing.T) {
+	part := func(id int, desc string, vehicles ...products.VehicleApplication) products.Part {
+		return products.Part{
+			ID:         id,
+			PartNumber: strconv.Itoa(id),
+			Status:     800,
+			ShortDesc:  desc,
+			Brand:      brand.Brand{ID: 1},
+			Vehicles:   vehicles,
+		}
+	}
+	parts := []products.Part{
+		part(1, "Class 3 Trailer Hitch", products.VehicleApplication{Year: "2015", Make: "Ford", Model: "F-150"}),
+		part(2, "Class 3 Trailer Hitch", products.VehicleApplication{Year: "2015", Make: "Chevrolet", Model: "Silverado 1500"}),
+		part(3, "Wiring Harness", products.VehicleApplication{Year: "2015", Make: "Ford", Model: "F-150"}),
+		part(4, "Class 3 Trailer Hitch", products.VehicleApplication{Year: "2010", Make: "Ford", Model: "F-150"}),
+	}
+
+	m := NewMemoryBackend()
+	for _, p := range parts {
+		m.Index("curt", Document{ID: p.PartNumber, Source: NewPartDocument(p)})
+	}
+
+	oldBackend, oldCompletions := Backend, completions
+	Backend, completions = m, BuildCompletions(parts)
+	defer func() { Backend, completions = oldBackend, oldCompletions }()
+
+	public := &apicontext.DataContext{KeyType: "Public", BrandArray: []int{1}}
+
+	Convey("Testing Dsl() with a vehicle in the query", t, func() {
+		res, err := Dsl("2015 f150 hitch", 0, 0, 0, public, "", nil, true)
+		So(err, ShouldBeNil)
+		So(res.Vehicle, ShouldResemble, &DetectedVehicle{Year: 2015, Make: "Ford", Model: "F-150", Terms: "hitch"})
+		So(res.Hits.TotalHits, ShouldEqual, 1)
+		So(res.Hits.Hits[0].Id, ShouldEqual, "1")
+
+		res, err = Dsl("2015 f150", 0, 0, 0, public, "", nil, true)
+		So(err, ShouldBeNil)
+		So(res.Vehicle.Terms, ShouldEqual, "")
+		So(res.Hits.TotalHits, ShouldEqual, 2)
+
+		res, err = ExactAndCloseDsl("ford hitch", 0, 0, 0, public, nil, true)
+		So(err, ShouldBeNil)
+		So(res.Vehicle, ShouldResemble, &DetectedVehicle{Make: "Ford", Terms: "hitch"})
+		So(res.Hits.TotalHits, ShouldEqual, 2)
+
+		res, err = Dsl("2015 silverado wiring", 0, 0, 0, public, "", nil, true)
+		So(err, ShouldBeNil)
+		So(res.Vehicle, ShouldBeNil)
+		ids := []string{}
+		for _, hit := range res.Hits.Hits {
+			ids = append(ids, hit.Id)
+		}
+		So(ids, ShouldContain, "3")
+
+		res, err = Dsl("2015 f150 hitch", 0, 0, 0, public, "", nil, false)
+		So(err, ShouldBeNil)
+		So(res.Vehicle, ShouldBeNil)
+		So(res.Hits.TotalHits, ShouldEqual, 4)
+	})
+}
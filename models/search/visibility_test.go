@@ -0,0 +1,53 @@
+package search
+
+import (
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func TestIndexedVisibility(t *testing.T) {
+	yes, no := true, false
+
+	Convey("Testing indexedVisibility()", t, func() {
+		So(indexedVisibility("", nil, nil), ShouldEqual, products.PUBLIC)
+		So(indexedVisibility("", &yes, &no), ShouldEqual, products.PUBLIC)
+		So(indexedVisibility("", &yes, &yes), ShouldEqual, products.LOGGEDIN)
+		So(indexedVisibility("", &no, &no), ShouldEqual, products.DISABLED)
+		So(indexedVisibility(products.DISABLED, &yes, &no), ShouldEqual, products.DISABLED)
+	})
+}
+
+func TestDslVisibility(t *testing.T) {
+	m := NewMemoryBackend()
+	m.Index("curt",
+		Document{ID: "1", Source: map[string]interface{}{"title": "hitch"}},
+		Document{ID: "2", Source: map[string]interface{}{"title": "hitch", "showOnWebsite": true, "showForLoggedIn": false}},
+		Document{ID: "3", Source: map[string]interface{}{"title": "hitch", "showOnWebsite": true, "showForLoggedIn": true}},
+		Document{ID: "4", Source: map[string]interface{}{"title": "hitch", "showOnWebsite": false, "showForLoggedIn": false}},
+		Document{ID: "5", Source: map[string]interface{}{"title": "hitch", "web_visibility": products.DISABLED, "showOnWebsite": true}},
+		Document{ID: "6", Source: map[string]interface{}{"title": "hitch", "web_visibility": products.LOGGEDIN}},
+	)
+
+	old := Backend
+	Backend = m
+	defer func() { Backend = old }()
+
+	ids := func(dtx *apicontext.DataContext) []string {
+		res, err := Dsl("hitch", 0, 0, 0, dtx, "", nil, false)
+		So(err, ShouldBeNil)
+		found := []string{}
+		for _, hit := range res.Hits.Hits {
+			found = append(found, hit.Id)
+		}
+		So(res.Hits.TotalHits, ShouldEqual, len(found))
+		return found
+	}
+
+	Convey("Testing Dsl() with the web flags of older documents", t, func() {
+		So(ids(&apicontext.DataContext{KeyType: "Public", BrandArray: []int{1}}), ShouldResemble, []string{"1", "2"})
+		So(ids(&apicontext.DataContext{KeyType: "Authentication", UserID: "user", BrandArray: []int{1}}), ShouldResemble, []string{"1", "2", "3", "6"})
+		So(ids(&apicontext.DataContext{KeyType: "Internal", BrandArray: []int{1}}), ShouldResemble, []string{"1", "2", "3", "4", "5", "6"})
+	})
+}
@@ -0,0 +1,184 @@
+package shipping
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RateProvider prices a shipment. Carrier integrations and the local
+// RateTable both satisfy it, so the estimator doesn't care where a rate
+// comes from.
+type RateProvider interface {
+	Rate(s Shipment) (Rate, error)
+}
+
+// Rate is a priced shipment.
+type Rate struct {
+	Carrier     string  `json:"carrier" xml:"carrier,attr"`
+	Service     string  `json:"service" xml:"service,attr"`
+	Amount      float64 `json:"amount" xml:"amount,attr"`
+	TransitDays int     `json:"transit_days" xml:"transit_days,attr"`
+}
+
+// ZoneRate is the price of shipping to a single zone.
+type ZoneRate struct {
+	Base        float64 `json:"base"`
+	PerPound    float64 `json:"per_pound"`
+	Minimum     float64 `json:"minimum"`
+	TransitDays int     `json:"transit_days"`
+}
+
+// RateTable is a local, zone based rate provider. Zones are derived from
+// the distance between the origin and destination three digit postal
+// prefixes, starting at zone 2 like the parcel carriers do.
+type RateTable struct {
+	Carrier        string     `json:"carrier"`
+	ParcelService  string     `json:"parcel_service"`
+	FreightService string     `json:"freight_service"`
+	Parcel         []ZoneRate `json:"parcel"`
+	Freight        []ZoneRate `json:"freight"`
+}
+
+var (
+	// DefaultRateTable is used when no rate table has been loaded. The
+	// numbers are ballpark figures for estimates and tests, not a quote.
+	DefaultRateTable = RateTable{
+		Carrier:        "CURT",
+		ParcelService:  "Ground",
+		FreightService: "LTL",
+		Parcel: []ZoneRate{
+			{},
+			{},
+			{Base: 8.50, PerPound: 0.45, Minimum: 9.50, TransitDays: 1},
+			{Base: 9.25, PerPound: 0.55, Minimum: 10.25, TransitDays: 2},
+			{Base: 10.00, PerPound: 0.65, Minimum: 11.00, TransitDays: 3},
+			{Base: 10.75, PerPound: 0.75, Minimum: 11.75, TransitDays: 3},
+			{Base: 11.50, PerPound: 0.90, Minimum: 12.50, TransitDays: 4},
+			{Base: 12.25, PerPound: 1.05, Minimum: 13.25, TransitDays: 5},
+			{Base: 13.00, PerPound: 1.20, Minimum: 14.00, TransitDays: 5},
+		},
+		Freight: []ZoneRate{
+			{},
+			{},
+			{Base: 95, PerPound: 0.18, Minimum: 125, TransitDays: 2},
+			{Base: 105, PerPound: 0.21, Minimum: 140, TransitDays: 3},
+			{Base: 115, PerPound: 0.24, Minimum: 155, TransitDays: 3},
+			{Base: 125, PerPound: 0.27, Minimum: 170, TransitDays: 4},
+			{Base: 140, PerPound: 0.31, Minimum: 190, TransitDays: 5},
+			{Base: 155, PerPound: 0.35, Minimum: 210, TransitDays: 6},
+			{Base: 170, PerPound: 0.40, Minimum: 230, TransitDays: 7},
+		},
+	}
+
+	// Provider prices every estimate. Swap it out for a carrier
+	// integration or a loaded rate table.
+	Provider RateProvider = &DefaultRateTable
+)
+
+// LoadRateTable decodes a JSON rate table.
+func LoadRateTable(r io.Reader) (*RateTable, error) {
+	var t RateTable
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, err
+	}
+	if len(t.Parcel) < 3 || len(t.Freight) < 3 {
+		return nil, fmt.Errorf("rate table must define zones 2 and up for parcel and freight")
+	}
+	return &t, nil
+}
+
+// LoadRateTableFile replaces Provider with the rate table stored at path.
+func LoadRateTableFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	t, err := LoadRateTable(f)
+	if err != nil {
+		return err
+	}
+	Provider = t
+	return nil
+}
+
+// Rate implements RateProvider.
+func (t *RateTable) Rate(s Shipment) (Rate, error) {
+	rate := Rate{
+		Carrier: t.Carrier,
+	}
+
+	table := t.Parcel
+	rate.Service = t.ParcelService
+	if s.Mode == Freight {
+		table = t.Freight
+		rate.Service = t.FreightService
+	}
+
+	zone, err := Zone(s.OriginPostalCode, s.DestinationPostalCode)
+	if err != nil {
+		return rate, err
+	}
+	if zone >= len(table) {
+		zone = len(table) - 1
+	}
+	zr := table[zone]
+	rate.TransitDays = zr.TransitDays
+
+	if s.Mode == Freight {
+		// LTL is priced on the actual weight of the whole shipment
+		rate.Amount = zr.Base + zr.PerPound*math.Ceil(s.ActualWeight)
+	} else {
+		for _, pkg := range s.Packages {
+			rate.Amount += float64(pkg.Boxes) * (zr.Base + zr.PerPound*math.Ceil(pkg.BillableWeight))
+		}
+	}
+
+	if rate.Amount < zr.Minimum {
+		rate.Amount = zr.Minimum
+	}
+	rate.Amount = round(rate.Amount)
+
+	return rate, nil
+}
+
+// Zone returns the shipping zone between two postal codes. Only the
+// three digit prefix of US ZIP codes is considered.
+func Zone(origin, destination string) (int, error) {
+	o, err := postalPrefix(origin)
+	if err != nil {
+		return 0, err
+	}
+	d, err := postalPrefix(destination)
+	if err != nil {
+		return 0, err
+	}
+
+	diff := o - d
+	if diff < 0 {
+		diff = -diff
+	}
+	return 2 + diff/100, nil
+}
+
+func postalPrefix(postal string) (int, error) {
+	postal = strings.TrimSpace(postal)
+	if len(postal) < 3 {
+		return 0, fmt.Errorf("invalid postal code: %s", postal)
+	}
+	prefix, err := strconv.Atoi(postal[:3])
+	if err != nil {
+		return 0, fmt.Errorf("invalid postal code: %s", postal)
+	}
+	return prefix, nil
+}
+
+func round(f float64) float64 {
+	return math.Floor(f*100+0.5) / 100
+}
@@ -0,0 +1,355 @@
+package shipping
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/curt-labs/API/helpers/apicontext"
+	"github.com/curt-labs/API/helpers/conversions"
+	"github.com/curt-labs/API/helpers/database"
+	"github.com/curt-labs/API/models/customer"
+	"github.com/curt-labs/API/models/products"
+)
+
+const (
+	Parcel  = "parcel"
+	Freight = "ltl"
+
+	// DimDivisor is the cubic inches per pound used by the parcel
+	// carriers to compute dimensional weight.
+	DimDivisor = 139.0
+
+	// MaxParcelWeight and MaxParcelSize are the parcel carrier limits for
+	// a single box, in pounds and inches of length plus girth.
+	MaxParcelWeight = 150.0
+	MaxParcelSize   = 165.0
+)
+
+var (
+	// BoxFee is charged for each box of a package flagged with BoxFee.
+	BoxFee = 5.00
+
+	// DefaultOriginPostalCode is used when the customer doesn't have a
+	// default warehouse. (Eau Claire, WI)
+	DefaultOriginPostalCode = "54701"
+)
+
+// Item is a part and quantity being shipped.
+type Item struct {
+	PartID   int `json:"part" xml:"part,attr"`
+	Quantity int `json:"quantity" xml:"quantity,attr"`
+}
+
+// EstimateRequest is the payload for a shipping estimate.
+type EstimateRequest struct {
+	PostalCode string `json:"postal_code" xml:"postal_code"`
+	Items      []Item `json:"items" xml:"items"`
+}
+
+// PackageLine is a single package definition of a part, normalized to
+// inches and pounds.
+type PackageLine struct {
+	PartID            int     `json:"part" xml:"part,attr"`
+	Boxes             int     `json:"boxes" xml:"boxes,attr"`
+	Length            float64 `json:"length" xml:"length,attr"`
+	Width             float64 `json:"width" xml:"width,attr"`
+	Height            float64 `json:"height" xml:"height,attr"`
+	Weight            float64 `json:"weight" xml:"weight,attr"`
+	DimensionalWeight float64 `json:"dimensional_weight" xml:"dimensional_weight,attr"`
+	BillableWeight    float64 `json:"billable_weight" xml:"billable_weight,attr"`
+	ParcelAllowed     bool    `json:"parcel_allowed" xml:"parcel_allowed,attr"`
+	BoxFee            bool    `json:"box_fee" xml:"box_fee,attr"`
+}
+
+// Shipment is the packed form of an estimate request, ready to be rated.
+type Shipment struct {
+	Mode                  string        `json:"mode" xml:"mode,attr"`
+	OriginWarehouse       string        `json:"origin_warehouse,omitempty" xml:"origin_warehouse,attr,omitempty"`
+	OriginPostalCode      string        `json:"origin_postal_code" xml:"origin_postal_code,attr"`
+	DestinationPostalCode string        `json:"destination_postal_code" xml:"destination_postal_code,attr"`
+	Packages              []PackageLine `json:"packages" xml:"packages"`
+	ActualWeight          float64       `json:"actual_weight" xml:"actual_weight,attr"`
+	DimensionalWeight     float64       `json:"dimensional_weight" xml:"dimensional_weight,attr"`
+	BillableWeight        float64       `json:"billable_weight" xml:"billable_weight,attr"`
+	Boxes                 int           `json:"boxes" xml:"boxes,attr"`
+	Reasons               []string      `json:"freight_reasons,omitempty" xml:"freight_reasons,omitempty"`
+}
+
+// Estimate is the priced shipment returned to the caller.
+type Estimate struct {
+	Shipment             Shipment `json:"shipment" xml:"shipment"`
+	Rate                 Rate     `json:"rate" xml:"rate"`
+	BoxFees              float64  `json:"box_fees" xml:"box_fees,attr"`
+	Subtotal             float64  `json:"subtotal" xml:"subtotal,attr"`
+	FreeFreightThreshold float64  `json:"free_freight_threshold" xml:"free_freight_threshold,attr"`
+	FreeFreight          bool     `json:"free_freight" xml:"free_freight,attr"`
+	Total                float64  `json:"total" xml:"total,attr"`
+}
+
+// GetEstimate prices the request for the customer behind the data
+// context, applying the account's free freight threshold.
+func GetEstimate(req EstimateRequest, dtx *apicontext.DataContext) (Estimate, error) {
+	var est Estimate
+	if req.PostalCode == "" {
+		return est, errors.New("a destination postal code is required")
+	}
+	if len(req.Items) == 0 {
+		return est, errors.New("at least one item is required")
+	}
+
+	parts, err := getParts(req.Items, dtx)
+	if err != nil {
+		return est, err
+	}
+
+	threshold, origin, err := customerShipping(dtx)
+	if err != nil {
+		return est, err
+	}
+
+	shipment, err := Pack(req.Items, parts)
+	if err != nil {
+		return est, err
+	}
+	shipment.DestinationPostalCode = req.PostalCode
+	shipment.OriginWarehouse = origin.Code
+	shipment.OriginPostalCode = origin.PostalCode
+	if shipment.OriginPostalCode == "" {
+		shipment.OriginPostalCode = DefaultOriginPostalCode
+	}
+
+	return Price(shipment, Subtotal(req.Items, parts), threshold, Provider)
+}
+
+// Pack converts the requested items into box counts and weights and
+// decides whether the shipment can go parcel or has to go LTL freight.
+func Pack(items []Item, parts map[int]products.Part) (Shipment, error) {
+	s := Shipment{
+		Mode: Parcel,
+	}
+
+	for _, item := range items {
+		if item.Quantity < 1 {
+			return s, fmt.Errorf("invalid quantity for part %d", item.PartID)
+		}
+		part, ok := parts[item.PartID]
+		if !ok {
+			return s, fmt.Errorf("part %d not found", item.PartID)
+		}
+		if len(part.Packages) == 0 {
+			return s, fmt.Errorf("part %d has no package information", item.PartID)
+		}
+
+		for _, pkg := range part.Packages {
+			line, err := packageLine(part.ID, pkg, item.Quantity)
+			if err != nil {
+				return s, err
+			}
+			s.Packages = append(s.Packages, line)
+
+			boxes := float64(line.Boxes)
+			s.Boxes += line.Boxes
+			s.ActualWeight += line.Weight * boxes
+			s.DimensionalWeight += line.DimensionalWeight * boxes
+			s.BillableWeight += line.BillableWeight * boxes
+
+			switch {
+			case !line.ParcelAllowed:
+				s.Reasons = append(s.Reasons, fmt.Sprintf("part %d is not allowed to ship parcel", part.ID))
+			case line.Weight > MaxParcelWeight:
+				s.Reasons = append(s.Reasons, fmt.Sprintf("part %d exceeds the parcel weight limit", part.ID))
+			case lengthPlusGirth(line) > MaxParcelSize:
+				s.Reasons = append(s.Reasons, fmt.Sprintf("part %d exceeds the parcel size limit", part.ID))
+			}
+		}
+	}
+
+	if len(s.Reasons) > 0 {
+		s.Mode = Freight
+	}
+
+	s.ActualWeight = round(s.ActualWeight)
+	s.DimensionalWeight = round(s.DimensionalWeight)
+	s.BillableWeight = round(s.BillableWeight)
+
+	return s, nil
+}
+
+// Price rates the shipment and applies box fees and the free freight
+// threshold. A threshold of zero means the account doesn't get free
+// freight.
+func Price(s Shipment, subtotal, threshold float64, provider RateProvider) (Estimate, error) {
+	est := Estimate{
+		Shipment:             s,
+		Subtotal:             round(subtotal),
+		FreeFreightThreshold: threshold,
+	}
+
+	rate, err := provider.Rate(s)
+	if err != nil {
+		return est, err
+	}
+	est.Rate = rate
+
+	for _, line := range s.Packages {
+		if line.BoxFee {
+			est.BoxFees += BoxFee * float64(line.Boxes)
+		}
+	}
+	est.BoxFees = round(est.BoxFees)
+
+	if threshold > 0 && subtotal >= threshold {
+		est.FreeFreight = true
+		return est, nil
+	}
+
+	est.Total = round(est.Rate.Amount + est.BoxFees)
+	return est, nil
+}
+
+// Subtotal is the merchandise total used against the free freight
+// threshold. Customer pricing wins over list price when we have it.
+func Subtotal(items []Item, parts map[int]products.Part) float64 {
+	var total float64
+	for _, item := range items {
+		part := parts[item.PartID]
+		price := part.Customer.Price
+		if price == 0 {
+			for _, pr := range part.Pricing {
+				if pr.Type == "List" {
+					price = pr.Price
+					break
+				}
+			}
+		}
+		total += price * float64(item.Quantity)
+	}
+	return total
+}
+
+func packageLine(partID int, pkg products.Package, quantity int) (PackageLine, error) {
+	line := PackageLine{
+		PartID:        partID,
+		ParcelAllowed: pkg.ParcelAllowed,
+		BoxFee:        pkg.BoxFee,
+	}
+
+	var ok bool
+	dims := []*float64{&line.Length, &line.Width, &line.Height}
+	for i, v := range []float64{pkg.Length, pkg.Width, pkg.Height} {
+		if *dims[i], ok = conversions.ToInches(v, pkg.DimensionUnit); !ok {
+			return line, fmt.Errorf("part %d has an unknown dimension unit: %s", partID, pkg.DimensionUnit)
+		}
+	}
+	if line.Weight, ok = conversions.ToPounds(pkg.Weight, pkg.WeightUnit); !ok {
+		return line, fmt.Errorf("part %d has an unknown weight unit: %s", partID, pkg.WeightUnit)
+	}
+	if line.Weight <= 0 {
+		return line, fmt.Errorf("part %d has no package weight", partID)
+	}
+
+	perBox := pkg.Quantity
+	if perBox < 1 {
+		perBox = 1
+	}
+	line.Boxes = int(math.Ceil(float64(quantity) / float64(perBox)))
+
+	line.DimensionalWeight = round(math.Ceil(line.Length*line.Width*line.Height) / DimDivisor)
+	line.BillableWeight = math.Max(line.Weight, line.DimensionalWeight)
+
+	return line, nil
+}
+
+func lengthPlusGirth(line PackageLine) float64 {
+	sides := []float64{line.Length, line.Width, line.Height}
+	longest := 0
+	for i, side := range sides {
+		if side > sides[longest] {
+			longest = i
+		}
+	}
+	girth := 0.0
+	for i, side := range sides {
+		if i != longest {
+			girth += 2 * side
+		}
+	}
+	return sides[longest] + girth
+}
+
+func getParts(items []Item, dtx *apicontext.DataContext) (map[int]products.Part, error) {
+	mapped := make(map[int]products.Part, 0)
+
+	err := database.Init()
+	if err != nil {
+		return mapped, err
+	}
+	session := database.ProductMongoSession.Copy()
+	defer session.Close()
+
+	var ids []int
+	for _, item := range items {
+		ids = append(ids, item.PartID)
+	}
+
+	parts, err := products.GetVisible(ids, dtx, session)
+	if err != nil {
+		return mapped, err
+	}
+
+	parts, err = products.BindCustomerToSeveralParts(parts, dtx)
+	if err != nil {
+		return mapped, err
+	}
+
+	for _, p := range parts {
+		mapped[p.ID] = p
+	}
+	return mapped, nil
+}
+
+// customerShipping returns the free freight threshold and default
+// warehouse of the customer that owns the API key.
+func customerShipping(dtx *apicontext.DataContext) (float64, products.Warehouse, error) {
+	var threshold float64
+	var origin products.Warehouse
+
+	var c customer.Customer
+	if err := c.GetCustomerIdFromKey(dtx.APIKey); err == sql.ErrNoRows {
+		// not every key belongs to an account, those just don't
+		// get free freight.
+		return threshold, origin, nil
+	} else if err != nil {
+		return threshold, origin, err
+	}
+	if err := c.GetAccounts(); err != nil {
+		return threshold, origin, err
+	}
+
+	var warehouseID int
+	for _, acct := range c.Accounts {
+		if acct.FreightLimit > 0 && (threshold == 0 || acct.FreightLimit < threshold) {
+			threshold = acct.FreightLimit
+		}
+		if warehouseID == 0 {
+			warehouseID = acct.DefaultWarehouseID
+		}
+	}
+
+	if warehouseID > 0 {
+		warehouses, err := products.GetWarehouses()
+		if err != nil {
+			return threshold, origin, err
+		}
+		for _, w := range warehouses {
+			if w.ID == warehouseID {
+				origin = w
+				break
+			}
+		}
+	}
+
+	return threshold, origin, nil
+}
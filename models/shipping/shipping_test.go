@@ -0,0 +1,140 @@
+package shipping
+
+import (
+	"github.com/curt-labs/API/models/products"
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+func testParts() map[int]products.Part {
+	return map[int]products.Part{
+		11000: {
+			ID: 11000,
+			Packages: []products.Package{
+				{Length: 24, Width: 12, Height: 6, Weight: 20, DimensionUnit: "in", WeightUnit: "lb", Quantity: 1, ParcelAllowed: true},
+			},
+			Pricing: []products.Price{{Type: "List", Price: 150}},
+		},
+		13000: {
+			ID: 13000,
+			Packages: []products.Package{
+				{Length: 150, Width: 40, Height: 30, Weight: 90, DimensionUnit: "cm", WeightUnit: "kg", Quantity: 1, ParcelAllowed: true, BoxFee: true},
+			},
+			Pricing:  []products.Price{{Type: "List", Price: 400}},
+			Customer: products.CustomerPart{Price: 300},
+		},
+		40000: {
+			ID: 40000,
+			Packages: []products.Package{
+				{Length: 6, Width: 4, Height: 2, Weight: 8, DimensionUnit: "in", WeightUnit: "oz", Quantity: 10, ParcelAllowed: true},
+			},
+		},
+	}
+}
+
+func TestPack(t *testing.T) {
+	Convey("Testing Pack() with a parcel shipment", t, func() {
+		s, err := Pack([]Item{{PartID: 11000, Quantity: 2}, {PartID: 40000, Quantity: 15}}, testParts())
+		So(err, ShouldBeNil)
+		So(s.Mode, ShouldEqual, Parcel)
+		So(len(s.Packages), ShouldEqual, 2)
+		So(s.Packages[0].Boxes, ShouldEqual, 2)
+		So(s.Packages[0].DimensionalWeight, ShouldEqual, 12.43)
+		So(s.Packages[0].BillableWeight, ShouldEqual, 20)
+		So(s.Packages[1].Boxes, ShouldEqual, 2)
+		So(s.Packages[1].Weight, ShouldEqual, 0.5)
+		So(s.ActualWeight, ShouldEqual, 41)
+		So(s.Boxes, ShouldEqual, 4)
+	})
+
+	Convey("Testing Pack() with an oversized package", t, func() {
+		s, err := Pack([]Item{{PartID: 11000, Quantity: 1}, {PartID: 13000, Quantity: 1}}, testParts())
+		So(err, ShouldBeNil)
+		So(s.Mode, ShouldEqual, Freight)
+		So(len(s.Reasons), ShouldEqual, 1)
+		So(s.Packages[1].Length, ShouldAlmostEqual, 59.06, 0.01)
+	})
+
+	Convey("Testing Pack() with bad input", t, func() {
+		_, err := Pack([]Item{{PartID: 11000, Quantity: 0}}, testParts())
+		So(err, ShouldNotBeNil)
+		_, err = Pack([]Item{{PartID: 1, Quantity: 1}}, testParts())
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestZone(t *testing.T) {
+	Convey("Testing Zone()", t, func() {
+		z, err := Zone("54701", "54703")
+		So(err, ShouldBeNil)
+		So(z, ShouldEqual, 2)
+
+		z, err = Zone("54701", "90210")
+		So(err, ShouldBeNil)
+		So(z, ShouldEqual, 5)
+
+		_, err = Zone("54701", "T2P")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestPrice(t *testing.T) {
+	parts := testParts()
+
+	Convey("Testing Price() for parcel", t, func() {
+		items := []Item{{PartID: 11000, Quantity: 2}}
+		s, err := Pack(items, parts)
+		So(err, ShouldBeNil)
+		s.OriginPostalCode = "54701"
+		s.DestinationPostalCode = "54703"
+
+		est, err := Price(s, Subtotal(items, parts), 0, &DefaultRateTable)
+		So(err, ShouldBeNil)
+		So(est.Subtotal, ShouldEqual, 300)
+		So(est.Rate.Service, ShouldEqual, "Ground")
+		So(est.Rate.Amount, ShouldEqual, 35)
+		So(est.Total, ShouldEqual, 35)
+		So(est.FreeFreight, ShouldBeFalse)
+	})
+
+	Convey("Testing Price() for freight with box fees", t, func() {
+		items := []Item{{PartID: 13000, Quantity: 2}}
+		s, err := Pack(items, parts)
+		So(err, ShouldBeNil)
+		s.OriginPostalCode = "54701"
+		s.DestinationPostalCode = "54703"
+
+		est, err := Price(s, Subtotal(items, parts), 1000, &DefaultRateTable)
+		So(err, ShouldBeNil)
+		So(est.Subtotal, ShouldEqual, 600)
+		So(est.Rate.Service, ShouldEqual, "LTL")
+		So(est.Rate.Amount, ShouldEqual, 166.46)
+		So(est.BoxFees, ShouldEqual, 10)
+		So(est.Total, ShouldEqual, 176.46)
+	})
+
+	Convey("Testing Price() over the free freight threshold", t, func() {
+		items := []Item{{PartID: 13000, Quantity: 2}}
+		s, _ := Pack(items, parts)
+		s.OriginPostalCode = "54701"
+		s.DestinationPostalCode = "54703"
+
+		est, err := Price(s, Subtotal(items, parts), 500, &DefaultRateTable)
+		So(err, ShouldBeNil)
+		So(est.FreeFreight, ShouldBeTrue)
+		So(est.Total, ShouldEqual, 0)
+	})
+}
+
+func TestLoadRateTable(t *testing.T) {
+	Convey("Testing LoadRateTable()", t, func() {
+		_, err := LoadRateTable(strings.NewReader(`{"parcel":[{}],"freight":[]}`))
+		So(err, ShouldNotBeNil)
+
+		tbl, err := LoadRateTable(strings.NewReader(`{"carrier":"UPS","parcel":[{},{},{"base":10}],"freight":[{},{},{"base":100}]}`))
+		So(err, ShouldBeNil)
+		So(tbl.Carrier, ShouldEqual, "UPS")
+		So(tbl.Parcel[2].Base, ShouldEqual, 10)
+	})
+}
@@ -0,0 +1,231 @@
+package vehicle
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// YearRange is a run of consecutive model years.
+type YearRange struct {
+	From int `json:"from" xml:"from,attr"`
+	To   int `json:"to" xml:"to,attr"`
+}
+
+// Summary is the fitment of a part for one make and model, with the years
+// collapsed into ranges and the styles and notes of every year combined.
+type Summary struct {
+	Make   string      `json:"make" xml:"make"`
+	Model  string      `json:"model" xml:"model"`
+	Years  []YearRange `json:"years" xml:"years"`
+	Styles []string    `json:"styles,omitempty" xml:"styles,omitempty"`
+	Notes  []string    `json:"notes,omitempty" xml:"notes,omitempty"`
+}
+
+type summaryGroup struct {
+	Summary
+	years  map[int]bool
+	styles map[string]bool
+	notes  map[string]bool
+}
+
+func (yr YearRange) String() string {
+	if yr.From == yr.To {
+		return strconv.Itoa(yr.From)
+	}
+	return strconv.Itoa(yr.From) + "-" + strconv.Itoa(yr.To)
+}
+
+// YearRanges collapses the years into runs of consecutive years.
+func YearRanges(years []int) []YearRange {
+	sorted := make([]int, len(years))
+	copy(sorted, years)
+	sort.Ints(sorted)
+
+	ranges := make([]YearRange, 0)
+	for _, y := range sorted {
+		if n := len(ranges); n > 0 && y <= ranges[n-1].To+1 {
+			ranges[n-1].To = y
+			continue
+		}
+		ranges = append(ranges, YearRange{From: y, To: y})
+	}
+	return ranges
+}
+
+// JoinYearRanges lists the ranges, like "2010-2012, 2015".
+func JoinYearRanges(ranges []YearRange) string {
+	years := make([]string, 0, len(ranges))
+	for _, yr := range ranges {
+		years = append(years, yr.String())
+	}
+	return strings.Join(years, ", ")
+}
+
+// Style is the submodel of the vehicle followed by its configuration values.
+func (v Vehicle) Style() string {
+	parts := make([]string, 0)
+	if v.Submodel != "" {
+		parts = append(parts, v.Submodel)
+	}
+	for _, c := range v.Configuration {
+		parts = append(parts, c.Value)
+	}
+	return strings.Join(parts, " ")
+}
+
+// Summarize groups the vehicles by make and model. When notes isn't nil it's
+// called for every vehicle and the distinct notes are kept.
+func Summarize(vehicles []Vehicle, notes func(Vehicle) ([]string, error)) ([]Summary, error) {
+	sorted := make([]Vehicle, len(vehicles))
+	copy(sorted, vehicles)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Year != sorted[j].Year {
+			return sorted[i].Year < sorted[j].Year
+		}
+		return sorted[i].Style() < sorted[j].Style()
+	})
+
+	groups := make(map[string]*summaryGroup)
+	for _, v := range sorted {
+		g := group(groups, v.Make, v.Model)
+		g.addYear(v.Year)
+		g.addStyle(v.Style())
+
+		if notes == nil {
+			continue
+		}
+		ns, err := notes(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range ns {
+			g.addNote(n)
+		}
+	}
+	return summaries(groups), nil
+}
+
+// SummarizeMongo groups the ARIES vehicles by make and model.
+func SummarizeMongo(vehicles []MgoVehicle) []Summary {
+	groups := make(map[string]*summaryGroup)
+	for _, v := range vehicles {
+		year, err := strconv.Atoi(strings.TrimSpace(v.Year))
+		if err != nil {
+			continue
+		}
+		g := group(groups, v.Make, v.Model)
+		g.addYear(year)
+		g.addStyle(v.Style)
+	}
+	return summaries(groups)
+}
+
+// ReverseLookupSummary returns the fitment summaries of the part, with the
+// notes of each vehicle.
+func ReverseLookupSummary(partId int) ([]Summary, error) {
+	vehicles, err := ReverseLookup(partId)
+	if err != nil {
+		return nil, err
+	}
+
+	return Summarize(vehicles, func(v Vehicle) ([]string, error) {
+		return v.GetNotes(partId)
+	})
+}
+
+// ReverseMongoLookupSummary returns the fitment summaries of the ARIES part.
+func ReverseMongoLookupSummary(partId int) ([]Summary, error) {
+	vehicles, err := ReverseMongoLookup(partId)
+	if err != nil {
+		return nil, err
+	}
+	return SummarizeMongo(vehicles), nil
+}
+
+// WriteSummaryCSV writes one row per make and model.
+func WriteSummaryCSV(w io.Writer, summaries []Summary) error {
+	wr := csv.NewWriter(w)
+	wr.Write([]string{
+		"Make",
+		"Model",
+		"Years",
+		"Styles",
+		"Notes",
+	})
+	for _, s := range summaries {
+		wr.Write([]string{
+			s.Make,
+			s.Model,
+			JoinYearRanges(s.Years),
+			strings.Join(s.Styles, "; "),
+			strings.Join(s.Notes, "; "),
+		})
+	}
+	wr.Flush()
+	return wr.Error()
+}
+
+func group(groups map[string]*summaryGroup, mk, model string) *summaryGroup {
+	key := strings.ToLower(strings.TrimSpace(mk)) + "|" + strings.ToLower(strings.TrimSpace(model))
+	g, ok := groups[key]
+	if !ok {
+		g = &summaryGroup{
+			Summary: Summary{
+				Make:  strings.TrimSpace(mk),
+				Model: strings.TrimSpace(model),
+			},
+			years:  make(map[int]bool),
+			styles: make(map[string]bool),
+			notes:  make(map[string]bool),
+		}
+		groups[key] = g
+	}
+	return g
+}
+
+func (g *summaryGroup) addYear(year int) {
+	if year > 0 {
+		g.years[year] = true
+	}
+}
+
+func (g *summaryGroup) addStyle(style string) {
+	style = strings.TrimSpace(style)
+	if style != "" && !g.styles[strings.ToLower(style)] {
+		g.styles[strings.ToLower(style)] = true
+		g.Styles = append(g.Styles, style)
+	}
+}
+
+func (g *summaryGroup) addNote(note string) {
+	note = strings.TrimSpace(note)
+	if note != "" && !g.notes[note] {
+		g.notes[note] = true
+		g.Notes = append(g.Notes, note)
+	}
+}
+
+func summaries(groups map[string]*summaryGroup) []Summary {
+	sums := make([]Summary, 0, len(groups))
+	for _, g := range groups {
+		years := make([]int, 0, len(g.years))
+		for y := range g.years {
+			years = append(years, y)
+		}
+		g.Years = YearRanges(years)
+		sort.Strings(g.Styles)
+		sums = append(sums, g.Summary)
+	}
+
+	sort.Slice(sums, func(i, j int) bool {
+		mi, mj := strings.ToLower(sums[i].Make), strings.ToLower(sums[j].Make)
+		if mi != mj {
+			return mi < mj
+		}
+		return strings.ToLower(sums[i].Model) < strings.ToLower(sums[j].Model)
+	})
+	return sums
+}
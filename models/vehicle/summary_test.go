@@ -0,0 +1,88 @@
+package vehicle
+
+import (
+	"bytes"
+	"errors"
+	. "github.com/smartystreets/goconvey/convey"
+	"testing"
+)
+
+func testVehicles() []Vehicle {
+	vehicles := []Vehicle{
+		{Year: 2010, Make: "Chevrolet", Model: "Silverado 1500", Submodel: "LT"},
+		{Year: 2002, Make: "Ford", Model: "F-150", Submodel: "XL"},
+	}
+	for year := 2004; year <= 2008; year++ {
+		vehicles = append(vehicles, Vehicle{Year: year, Make: "Ford", Model: "F-150", Submodel: "XLT"})
+	}
+	for year := 2010; year <= 2014; year++ {
+		vehicles = append(vehicles, Vehicle{
+			Year:          year,
+			Make:          "ford",
+			Model:         "F-150",
+			Submodel:      "XL",
+			Configuration: []Config{{Type: "Bed Length", Value: "78.0"}},
+		})
+	}
+	return vehicles
+}
+
+func TestSummary(t *testing.T) {
+	Convey("Testing YearRanges()", t, func() {
+		So(YearRanges([]int{2008, 2004, 2005, 2006, 2006, 2010}), ShouldResemble, []YearRange{
+			{From: 2004, To: 2006},
+			{From: 2008, To: 2008},
+			{From: 2010, To: 2010},
+		})
+		So(YearRanges(nil), ShouldBeEmpty)
+		So(YearRange{From: 2004, To: 2018}.String(), ShouldEqual, "2004-2018")
+		So(YearRange{From: 2004, To: 2004}.String(), ShouldEqual, "2004")
+	})
+
+	Convey("Testing Summarize()", t, func() {
+		sums, err := Summarize(testVehicles(), func(v Vehicle) ([]string, error) {
+			if v.Year >= 2010 {
+				return []string{"Requires drilling", ""}, nil
+			}
+			return nil, nil
+		})
+		So(err, ShouldBeNil)
+		So(len(sums), ShouldEqual, 2)
+
+		So(sums[0].Make, ShouldEqual, "Chevrolet")
+		So(sums[0].Years, ShouldResemble, []YearRange{{From: 2010, To: 2010}})
+
+		ford := sums[1]
+		So(ford.Model, ShouldEqual, "F-150")
+		So(ford.Years, ShouldResemble, []YearRange{{From: 2002, To: 2002}, {From: 2004, To: 2008}, {From: 2010, To: 2014}})
+		So(ford.Styles, ShouldResemble, []string{"XL", "XL 78.0", "XLT"})
+		So(ford.Notes, ShouldResemble, []string{"Requires drilling"})
+
+		_, err = Summarize(testVehicles(), func(v Vehicle) ([]string, error) {
+			return nil, errors.New("no notes")
+		})
+		So(err, ShouldNotBeNil)
+	})
+
+	Convey("Testing SummarizeMongo()", t, func() {
+		sums := SummarizeMongo([]MgoVehicle{
+			{Year: "2015", Make: "Jeep", Model: "Wrangler", Style: "Sport"},
+			{Year: "2016", Make: "Jeep", Model: "Wrangler", Style: "Rubicon"},
+			{Year: "2016", Make: "Jeep", Model: "Wrangler", Style: "sport"},
+			{Year: "", Make: "Jeep", Model: "Wrangler", Style: "Sahara"},
+		})
+		So(len(sums), ShouldEqual, 1)
+		So(sums[0].Years, ShouldResemble, []YearRange{{From: 2015, To: 2016}})
+		So(sums[0].Styles, ShouldResemble, []string{"Rubicon", "Sport"})
+	})
+
+	Convey("Testing WriteSummaryCSV()", t, func() {
+		sums, err := Summarize(testVehicles(), nil)
+		So(err, ShouldBeNil)
+
+		var buf bytes.Buffer
+		So(WriteSummaryCSV(&buf, sums), ShouldBeNil)
+		So(buf.String(), ShouldContainSubstring, "Make,Model,Years,Styles,Notes\n")
+		So(buf.String(), ShouldContainSubstring, `Ford,F-150,"2002, 2004-2008, 2010-2014",XL; XL 78.0; XLT,`)
+	})
+}
@@ -242,7 +242,7 @@ func (vehicle *Vehicle) GetGroupsByConfig() (groups []int) {
 func (v *Vehicle) GetNotes(partId int) (notes []string, err error) {
 	qrystmt := vehicleNotesStmt
 	if len(v.Configuration) > 0 {
-		qrystmt += "  ca.value in (`"
+		qrystmt += "  ca.value in ("
 		for i, c := range v.Configuration {
 			qrystmt = qrystmt + "'" + api_helpers.Escape(c.Value) + "'"
 			if i < len(v.Configuration)-1 {
@@ -0,0 +1,294 @@
+package vinLookup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/curt-labs/API/models/products"
+)
+
+var (
+	// ErrInvalidVIN is returned for VINs that aren't 17 valid characters.
+	ErrInvalidVIN = errors.New("VIN must be 17 characters and can't contain I, O or Q")
+
+	// ErrCheckDigit is returned when the ninth character of a VIN doesn't
+	// match the check digit calculated from the rest of it.
+	ErrCheckDigit = errors.New("VIN check digit does not calculate properly")
+
+	// ErrUndecodable is returned when the manufacturer of a VIN isn't in
+	// the pattern table.
+	ErrUndecodable = errors.New("failed to decode VIN")
+)
+
+// Decoder decodes a VIN into a vehicle. The offline pattern table and the
+// Polk web service both satisfy it.
+type Decoder interface {
+	Decode(vin string) (DecodedVehicle, error)
+}
+
+// DecodedVehicle is the vehicle a VIN was decoded to. Decoders fill in as
+// much as they know; an offline decode often stops at the make.
+type DecodedVehicle struct {
+	VIN            string                   `json:"vin" xml:"vin,attr"`
+	Year           int                      `json:"year" xml:"year"`
+	Make           string                   `json:"make,omitempty" xml:"make,omitempty"`
+	Model          string                   `json:"model,omitempty" xml:"model,omitempty"`
+	Submodel       string                   `json:"submodel,omitempty" xml:"submodel,omitempty"`
+	Configurations []products.Configuration `json:"configurations,omitempty" xml:"configurations,omitempty"`
+}
+
+// Pattern maps a manufacturer (WMI) and the vehicle descriptor section (VDS,
+// the 4th through 8th characters) of VINs to a vehicle. A `*` in VDS matches
+// any character and an empty VDS matches every vehicle of the manufacturer.
+// FromYear and ToYear limit the model years the pattern applies to.
+type Pattern struct {
+	WMI      string `json:"wmi"`
+	VDS      string `json:"vds,omitempty"`
+	FromYear int    `json:"from_year,omitempty"`
+	ToYear   int    `json:"to_year,omitempty"`
+	Make     string `json:"make"`
+	Model    string `json:"model,omitempty"`
+	Submodel string `json:"submodel,omitempty"`
+}
+
+// OfflineDecoder decodes VINs without calling out to a service. It checks
+// the check digit, decodes the model year and looks the vehicle up in its
+// pattern table.
+type OfflineDecoder struct {
+	Patterns []Pattern
+}
+
+var (
+	// WMIPatterns map the manufacturers we sell the most for to their
+	// makes, so an offline decode gets at least the year and make.
+	WMIPatterns = []Pattern{
+		{WMI: "1G1", Make: "Chevrolet"},
+		{WMI: "1GC", Make: "Chevrolet"},
+		{WMI: "1GN", Make: "Chevrolet"},
+		{WMI: "2G1", Make: "Chevrolet"},
+		{WMI: "3GC", Make: "Chevrolet"},
+		{WMI: "3GN", Make: "Chevrolet"},
+		{WMI: "1GT", Make: "GMC"},
+		{WMI: "1GK", Make: "GMC"},
+		{WMI: "3GT", Make: "GMC"},
+		{WMI: "1GJ", Make: "GMC"},
+		{WMI: "1G4", Make: "Buick"},
+		{WMI: "5GA", Make: "Buick"},
+		{WMI: "1G6", Make: "Cadillac"},
+		{WMI: "1GY", Make: "Cadillac"},
+		{WMI: "1FA", Make: "Ford"},
+		{WMI: "1FD", Make: "Ford"},
+		{WMI: "1FM", Make: "Ford"},
+		{WMI: "1FT", Make: "Ford"},
+		{WMI: "2FM", Make: "Ford"},
+		{WMI: "3FA", Make: "Ford"},
+		{WMI: "1LN", Make: "Lincoln"},
+		{WMI: "5LM", Make: "Lincoln"},
+		{WMI: "1C4", Make: "Jeep"},
+		{WMI: "1J4", Make: "Jeep"},
+		{WMI: "1J8", Make: "Jeep"},
+		{WMI: "1D7", Make: "Dodge"},
+		{WMI: "1B7", Make: "Dodge"},
+		{WMI: "2D4", Make: "Dodge"},
+		{WMI: "1C6", Make: "Ram"},
+		{WMI: "3C6", Make: "Ram"},
+		{WMI: "2C4", Make: "Chrysler"},
+		{WMI: "5TD", Make: "Toyota"},
+		{WMI: "5TF", Make: "Toyota"},
+		{WMI: "4T1", Make: "Toyota"},
+		{WMI: "JTE", Make: "Toyota"},
+		{WMI: "1N6", Make: "Nissan"},
+		{WMI: "5N1", Make: "Nissan"},
+		{WMI: "5FN", Make: "Honda"},
+		{WMI: "2HK", Make: "Honda"},
+		{WMI: "5J6", Make: "Honda"},
+		{WMI: "4S4", Make: "Subaru"},
+		{WMI: "5XY", Make: "Kia"},
+		{WMI: "5NM", Make: "Hyundai"},
+	}
+
+	// DefaultDecoder decodes with WMIPatterns only.
+	DefaultDecoder = OfflineDecoder{Patterns: WMIPatterns}
+
+	// Provider decodes every VIN. Swap it out for the Polk web service or
+	// a decoder with a loaded pattern table.
+	Provider Decoder = &DefaultDecoder
+
+	transliteration = map[rune]int{
+		'A': 1, 'B': 2, 'C': 3, 'D': 4, 'E': 5, 'F': 6, 'G': 7, 'H': 8,
+		'J': 1, 'K': 2, 'L': 3, 'M': 4, 'N': 5, 'P': 7, 'R': 9,
+		'S': 2, 'T': 3, 'U': 4, 'V': 5, 'W': 6, 'X': 7, 'Y': 8, 'Z': 9,
+	}
+	weights = []int{8, 7, 6, 5, 4, 3, 2, 10, 0, 9, 8, 7, 6, 5, 4, 3, 2}
+
+	// yearCodes are the model year codes of the 10th character, starting
+	// with 1980. They repeat every 30 years.
+	yearCodes = "ABCDEFGHJKLMNPRSTVWXY123456789"
+)
+
+// LoadPatternTable decodes a JSON pattern table. The WMI patterns are kept
+// so unknown models still decode to a make.
+func LoadPatternTable(r io.Reader) (*OfflineDecoder, error) {
+	var patterns []Pattern
+	if err := json.NewDecoder(r).Decode(&patterns); err != nil {
+		return nil, err
+	}
+	for _, p := range patterns {
+		if len(p.WMI) != 3 || len(p.VDS) > 5 || p.Make == "" {
+			return nil, fmt.Errorf("pattern %s%s needs a 3 character WMI, at most 5 VDS characters and a make", p.WMI, p.VDS)
+		}
+	}
+	return &OfflineDecoder{Patterns: append(patterns, WMIPatterns...)}, nil
+}
+
+// LoadPatternFile replaces Provider with an offline decoder using the
+// pattern table stored at path.
+func LoadPatternFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d, err := LoadPatternTable(f)
+	if err != nil {
+		return err
+	}
+	Provider = d
+	return nil
+}
+
+// Decode implements Decoder.
+func (d *OfflineDecoder) Decode(vin string) (DecodedVehicle, error) {
+	vin, err := Normalize(vin)
+	if err != nil {
+		return DecodedVehicle{VIN: vin}, err
+	}
+	if !ValidCheckDigit(vin) {
+		return DecodedVehicle{VIN: vin}, ErrCheckDigit
+	}
+
+	v := DecodedVehicle{
+		VIN:  vin,
+		Year: ModelYear(vin),
+	}
+
+	p, ok := d.match(vin, v.Year)
+	if !ok {
+		return v, ErrUndecodable
+	}
+	v.Make = p.Make
+	v.Model = p.Model
+	v.Submodel = p.Submodel
+	return v, nil
+}
+
+// match returns the most specific pattern for the VIN, the one with the
+// fewest wildcards.
+func (d *OfflineDecoder) match(vin string, year int) (Pattern, bool) {
+	wmi, vds := vin[:3], vin[3:8]
+
+	var best Pattern
+	bestScore := -1
+	for _, p := range d.Patterns {
+		if !strings.EqualFold(p.WMI, wmi) {
+			continue
+		}
+		if (p.FromYear > 0 && year < p.FromYear) || (p.ToYear > 0 && year > p.ToYear) {
+			continue
+		}
+		score, ok := vdsScore(strings.ToUpper(p.VDS), vds)
+		if !ok {
+			continue
+		}
+		if score > bestScore {
+			best = p
+			bestScore = score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// vdsScore counts the characters of the pattern that match exactly.
+func vdsScore(pattern, vds string) (int, bool) {
+	score := 0
+	for i := 0; i < len(pattern); i++ {
+		if pattern[i] == '*' {
+			continue
+		}
+		if pattern[i] != vds[i] {
+			return 0, false
+		}
+		score++
+	}
+	return score, true
+}
+
+// Normalize upper cases the VIN and checks its length and characters.
+func Normalize(vin string) (string, error) {
+	vin = strings.ToUpper(strings.TrimSpace(vin))
+	if len(vin) != 17 {
+		return vin, ErrInvalidVIN
+	}
+	for _, c := range vin {
+		if c >= '0' && c <= '9' {
+			continue
+		}
+		if _, ok := transliteration[c]; !ok {
+			return vin, ErrInvalidVIN
+		}
+	}
+	return vin, nil
+}
+
+// ValidCheckDigit reports whether the 9th character of the normalized VIN
+// is its check digit.
+func ValidCheckDigit(vin string) bool {
+	if len(vin) != 17 {
+		return false
+	}
+	sum := 0
+	for i, c := range vin {
+		val, ok := transliteration[c]
+		if c >= '0' && c <= '9' {
+			val, ok = int(c-'0'), true
+		}
+		if !ok {
+			return false
+		}
+		sum += val * weights[i]
+	}
+
+	check := byte('0' + sum%11)
+	if sum%11 == 10 {
+		check = 'X'
+	}
+	return vin[8] == check
+}
+
+// ModelYear decodes the model year of the normalized VIN. Letters in the 7th
+// character mean the second 30 year cycle, so a 10th character of A is 2010
+// rather than 1980. Years past next year fall back a cycle.
+func ModelYear(vin string) int {
+	if len(vin) != 17 {
+		return 0
+	}
+	i := strings.IndexByte(yearCodes, vin[9])
+	if i < 0 {
+		return 0
+	}
+
+	year := 1980 + i
+	if vin[6] < '0' || vin[6] > '9' {
+		year += 30
+	}
+	if year > time.Now().Year()+1 {
+		year -= 30
+	}
+	return year
+}
@@ -0,0 +1,88 @@
+package vinLookup
+
+import (
+	. "github.com/smartystreets/goconvey/convey"
+	"strings"
+	"testing"
+)
+
+const testPatterns = `[
+	{"wmi": "1GC", "vds": "VK***", "from_year": 2014, "to_year": 2018, "make": "Chevrolet", "model": "Silverado 1500"},
+	{"wmi": "1GC", "vds": "VKRE*", "from_year": 2014, "to_year": 2018, "make": "Chevrolet", "model": "Silverado 1500", "submodel": "LT"},
+	{"wmi": "1GT", "vds": "JK3", "to_year": 2006, "make": "GMC", "model": "Sierra 3500"}
+]`
+
+func TestOfflineDecoder(t *testing.T) {
+	Convey("Testing ValidCheckDigit()", t, func() {
+		So(ValidCheckDigit("1G4HA5EM2AU000001"), ShouldBeTrue)
+		So(ValidCheckDigit("1FAHP2FW5AG100583"), ShouldBeTrue)
+		So(ValidCheckDigit("1G6DA5EGXA0100211"), ShouldBeTrue)
+		So(ValidCheckDigit("1G6DA5EG1A0100211"), ShouldBeFalse)
+		So(ValidCheckDigit("1G6DA5EG"), ShouldBeFalse)
+	})
+
+	Convey("Testing ModelYear()", t, func() {
+		So(ModelYear("1G4HA5EM2AU000001"), ShouldEqual, 2010)
+		So(ModelYear("1GTJK34131E957990"), ShouldEqual, 2001)
+		So(ModelYear("1GCVKREC6EZ123456"), ShouldEqual, 2014)
+	})
+
+	Convey("Testing Normalize()", t, func() {
+		vin, err := Normalize(" 1g4ha5em2au000001 ")
+		So(err, ShouldBeNil)
+		So(vin, ShouldEqual, "1G4HA5EM2AU000001")
+
+		_, err = Normalize("1G4HA5EM2AU00000")
+		So(err, ShouldEqual, ErrInvalidVIN)
+		_, err = Normalize("1G4HA5EM2AU00000O")
+		So(err, ShouldEqual, ErrInvalidVIN)
+	})
+
+	Convey("Testing Decode() with the default patterns", t, func() {
+		v, err := DefaultDecoder.Decode("1g4ha5em2au000001")
+		So(err, ShouldBeNil)
+		So(v.VIN, ShouldEqual, "1G4HA5EM2AU000001")
+		So(v.Year, ShouldEqual, 2010)
+		So(v.Make, ShouldEqual, "Buick")
+		So(v.Model, ShouldEqual, "")
+
+		_, err = DefaultDecoder.Decode("1G6DA5EG1A0100211")
+		So(err, ShouldEqual, ErrCheckDigit)
+
+		v, err = DefaultDecoder.Decode("WBA3A5C53CF256551")
+		So(err, ShouldEqual, ErrUndecodable)
+		So(v.Year, ShouldEqual, 2012)
+	})
+
+	Convey("Testing Decode() with a pattern table", t, func() {
+		d, err := LoadPatternTable(strings.NewReader(testPatterns))
+		So(err, ShouldBeNil)
+
+		v, err := d.Decode("1GCVKREC6EZ123456")
+		So(err, ShouldBeNil)
+		So(v.Model, ShouldEqual, "Silverado 1500")
+		So(v.Submodel, ShouldEqual, "LT")
+
+		v, err = d.Decode("1GTJK34131E957990")
+		So(err, ShouldBeNil)
+		So(v.Make, ShouldEqual, "GMC")
+		So(v.Model, ShouldEqual, "Sierra 3500")
+
+		v, err = d.Decode("1FAHP2FW5AG100583")
+		So(err, ShouldBeNil)
+		So(v.Make, ShouldEqual, "Ford")
+		So(v.Model, ShouldEqual, "")
+
+		l := v.Lookup()
+		So(l.Vehicle.Base.Year, ShouldEqual, 2010)
+		So(l.Makes, ShouldResemble, []string{"Ford"})
+		So(l.Models, ShouldBeEmpty)
+	})
+
+	Convey("Testing LoadPatternTable() with a bad pattern", t, func() {
+		_, err := LoadPatternTable(strings.NewReader(`[{"wmi": "1G", "make": "Chevrolet"}]`))
+		So(err, ShouldNotBeNil)
+		_, err = LoadPatternTable(strings.NewReader(`{`))
+		So(err, ShouldNotBeNil)
+	})
+}
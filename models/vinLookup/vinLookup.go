@@ -168,17 +168,11 @@ const (
 		ACES_CYLINDERS,ACES_RESERVED,DOOR_CNT,BODY_STYLE_DESC,WHL_BAS_SHRST_INCHS,TRK_BED_LEN_DESC,TRANS_CD,TRK_BED_LEN_CD,ENG_FUEL_DESC`
 )
 
+// VinPartLookup decodes the VIN with Provider and loads the parts that fit
+// the vehicle. When the decoder doesn't know the model, the models of the
+// make are returned to pick from instead.
 func VinPartLookup(vin string, dtx *apicontext.DataContext) (l products.Lookup, err error) {
-	//get ACES vehicles
-	av, configMap, err := getAcesVehicle(vin)
-	if err != nil {
-		return l, err
-	} else if av.AAIABaseVehicleID == 0 {
-		return l, errors.New("failed to decode VIN")
-	}
-
-	//get CURT vehicle
-	l, err = av.getCurtVehicles(configMap)
+	l, err = GetVehicleConfigs(vin)
 	if err != nil {
 		return l, err
 	}
@@ -188,31 +182,84 @@ func VinPartLookup(vin string, dtx *apicontext.DataContext) (l products.Lookup,
 		l.Brands = append(l.Brands, brand)
 	}
 
-	//get parts
-	var ps []products.Part
-	ch := make(chan []products.Part)
-	go l.LoadParts(ch, 1, 1000, dtx)
-	ps = <-ch
+	if l.Vehicle.Base.Model == "" {
+		err = l.GetModels()
+		if err == nil && len(l.Models) == 0 {
+			err = sql.ErrNoRows
+		}
+		return l, err
+	}
+	if l.Vehicle.Submodel == "" {
+		if err = l.GetSubmodels(); err != nil {
+			return l, err
+		}
+	}
 
-	l.Parts = ps
+	//get parts
+	if err = l.GetParts(1, 1000, nil, dtx); err != nil {
+		return l, err
+	}
 	if len(l.Parts) == 0 {
 		err = sql.ErrNoRows
 	}
 	return l, err
 }
 
+// GetVehicleConfigs decodes the VIN with Provider.
 func GetVehicleConfigs(vin string) (l products.Lookup, err error) {
+	v, err := Provider.Decode(vin)
+	if err != nil {
+		return l, err
+	}
+	return v.Lookup(), nil
+}
+
+// Lookup returns a vehicle lookup for the decoded vehicle.
+func (v DecodedVehicle) Lookup() (l products.Lookup) {
+	l.Vehicle.Base.Year = v.Year
+	l.Vehicle.Base.Make = v.Make
+	l.Vehicle.Base.Model = v.Model
+	l.Vehicle.Submodel = v.Submodel
+	l.Vehicle.Configurations = v.Configurations
+
+	l.Years = append(l.Years, v.Year)
+	l.Makes = append(l.Makes, v.Make)
+	if v.Model != "" {
+		l.Models = append(l.Models, v.Model)
+	}
+	if v.Submodel != "" {
+		l.Submodels = append(l.Submodels, v.Submodel)
+	}
+	return l
+}
+
+// PolkDecoder decodes VINs with the Polk VINtelligence web service, which
+// also returns the configuration of the vehicle.
+type PolkDecoder struct{}
+
+// Decode implements Decoder.
+func (PolkDecoder) Decode(vin string) (DecodedVehicle, error) {
+	v := DecodedVehicle{VIN: vin}
+
 	//get ACES vehicles
 	av, configMap, err := getAcesVehicle(vin)
 	if err != nil {
-		return l, err
+		return v, err
 	} else if av.AAIABaseVehicleID == 0 {
-		return l, errors.New("failed to decode VIN")
+		return v, ErrUndecodable
 	}
 
 	//get CURT vehicle
-	l, err = av.getCurtVehicles(configMap)
-	return l, err
+	l, err := av.getCurtVehicles(configMap)
+	if err != nil {
+		return v, err
+	}
+	v.Year = l.Vehicle.Base.Year
+	v.Make = l.Vehicle.Base.Make
+	v.Model = l.Vehicle.Base.Model
+	v.Submodel = l.Vehicle.Submodel
+	v.Configurations = l.Vehicle.Configurations
+	return v, nil
 }
 
 //already have vehicleID (vcdb_vehicle.ID)? get parts
@@ -241,6 +288,10 @@ func (v *CurtVehicle) GetPartsFromVehicleConfig(dtx *apicontext.DataContext) (ps
 		if err != nil {
 			return ps, err
 		}
+		if !p.VisibleTo(dtx) {
+			continue
+		}
+		p.SetWebFlags()
 
 		ps = append(ps, p)
 	}
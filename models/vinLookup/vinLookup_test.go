@@ -1,7 +1,9 @@
 package vinLookup
 
 import (
+	"github.com/curt-labs/API/helpers/apicontext"
 	"github.com/curt-labs/API/helpers/apicontextmock"
+	"github.com/curt-labs/API/models/products"
 	. "github.com/smartystreets/goconvey/convey"
 
 	"database/sql"
@@ -43,3 +45,84 @@ func TestVinLookup(t *testing.T) {
 	})
 	_ = apicontextmock.DeMock(dtx)
 }
+
+// fakeDecoder decodes every VIN to the same vehicle.
+type fakeDecoder DecodedVehicle
+
+func (d fakeDecoder) Decode(vin string) (DecodedVehicle, error) {
+	v := DecodedVehicle(d)
+	v.VIN = vin
+	return v, nil
+}
+
+func TestVinPartLookupDecoded(t *testing.T) {
+	dtx, err := apicontextmock.Mock()
+	if err != nil {
+		t.Skip("no database: ", err)
+	}
+	defer apicontextmock.DeMock(dtx)
+
+	v, want := vehicleWithParts(dtx)
+	if len(want) == 0 {
+		t.Skip("no vehicle with parts")
+	}
+
+	defer func(p Decoder) { Provider = p }(Provider)
+	Provider = fakeDecoder(v)
+
+	Convey("Testing VinPartLookup() with a fully decoded vehicle", t, func() {
+		l, err := VinPartLookup(taurusVin, dtx)
+		So(err, ShouldBeNil)
+		So(l.Vehicle.Base.Model, ShouldEqual, v.Model)
+		So(l.Vehicle.Submodel, ShouldEqual, v.Submodel)
+		So(partIDs(l.Parts), ShouldResemble, want)
+	})
+}
+
+// vehicleWithParts finds a vehicle down to the submodel, when it has any,
+// that has parts, and returns it with the IDs of its parts.
+func vehicleWithParts(dtx *apicontext.DataContext) (DecodedVehicle, []int) {
+	var years products.Lookup
+	if err := years.GetYears(dtx); err != nil {
+		return DecodedVehicle{}, nil
+	}
+
+	tries := 0
+	for _, year := range years.Years {
+		makes := products.Lookup{Vehicle: products.Vehicle{Base: products.BaseVehicle{Year: year}}, Brands: dtx.BrandArray}
+		if err := makes.GetMakes(dtx); err != nil {
+			continue
+		}
+		for _, mk := range makes.Makes {
+			models := makes
+			models.Vehicle.Base.Make = mk
+			if err := models.GetModels(); err != nil {
+				continue
+			}
+			for _, model := range models.Models {
+				if tries++; tries > 50 {
+					return DecodedVehicle{}, nil
+				}
+				l := models
+				l.Vehicle.Base.Model = model
+				if err := l.GetSubmodels(); err == nil && len(l.Submodels) > 0 {
+					l.Vehicle.Submodel = l.Submodels[0]
+				}
+				if err := l.GetParts(1, 1000, nil, dtx); err != nil || len(l.Parts) == 0 {
+					continue
+				}
+				v := DecodedVehicle{Year: year, Make: mk, Model: model, Submodel: l.Vehicle.Submodel}
+				return v, partIDs(l.Parts)
+			}
+		}
+	}
+	return DecodedVehicle{}, nil
+}
+
+func partIDs(parts []products.Part) []int {
+	ids := make([]int, 0, len(parts))
+	for _, p := range parts {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}